@@ -5,11 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"github.com/gorilla/websocket"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sarabi/internal/tunnel"
 	"strings"
+	"time"
 )
 
 type (
@@ -32,6 +35,7 @@ type (
 		DoMultipart(ctx context.Context, files []MultipartFile, params Params) (io.ReadCloser, error)
 		Download(ctx context.Context, param Params) (io.ReadCloser, error)
 		SSE(ctx context.Context, param Params) (io.ReadCloser, error)
+		Tunnel(ctx context.Context, param Params) (*websocket.Conn, error)
 	}
 
 	client struct {
@@ -76,7 +80,12 @@ func (c client) Do(ctx context.Context, param Params) error {
 	if err != nil {
 		return err
 	}
-	if param.Body != nil {
+	req.Header.Set("Content-Type", "application/json")
+	if reader, ok := param.Body.(io.Reader); ok {
+		// raw bodies are sent as they are, e.g the chunks of an upload
+		req.Body = io.NopCloser(reader)
+		req.Header.Set("Content-Type", "application/octet-stream")
+	} else if param.Body != nil {
 		bodyBin, err := json.Marshal(param.Body)
 		if err != nil {
 			return err
@@ -84,7 +93,6 @@ func (c client) Do(ctx context.Context, param Params) error {
 		req.Body = io.NopCloser(bytes.NewBuffer(bodyBin))
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	if len(param.Headers) > 0 {
 		for k, v := range param.Headers {
 			req.Header.Set(k, v)
@@ -241,6 +249,14 @@ func (c client) SSE(ctx context.Context, param Params) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
+	if param.Body != nil {
+		bodyBin, err := json.Marshal(param.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewBuffer(bodyBin))
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	if len(param.Headers) > 0 {
 		for k, v := range param.Headers {
@@ -260,6 +276,58 @@ func (c client) SSE(ctx context.Context, param Params) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
+// Tunnel opens a websocket to the server, the http scheme of the server is switched to its websocket counterpart
+func (c client) Tunnel(ctx context.Context, param Params) (*websocket.Conn, error) {
+	tunnelUrl, err := url.Parse(c.baseUrl + param.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tunnelUrl.Scheme {
+	case "https":
+		tunnelUrl.Scheme = "wss"
+	case "http":
+		tunnelUrl.Scheme = "ws"
+	}
+
+	if len(param.QueryParams) > 0 {
+		values := url.Values{}
+		for k, v := range param.QueryParams {
+			values.Add(k, v)
+		}
+		tunnelUrl.RawQuery = values.Encode()
+	}
+
+	header := http.Header{}
+	for k, v := range param.Headers {
+		header.Set(k, v)
+	}
+	if c.accessKey != "" {
+		header.Set(accessKeyHeader, c.accessKey)
+	}
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 30 * time.Second,
+		ReadBufferSize:   tunnel.BufferSize,
+		WriteBufferSize:  tunnel.BufferSize,
+	}
+	conn, resp, err := dialer.DialContext(ctx, tunnelUrl.String(), header)
+	if err != nil {
+		// the server answers with an error response when it refuses to open the tunnel
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			defer resp.Body.Close()
+			responseBody, readErr := io.ReadAll(resp.Body)
+			if readErr != nil {
+				return nil, err
+			}
+			return nil, c.parseError(responseBody)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
 func (c client) parseError(b []byte) error {
 	var errorResponse struct {
 		Message string
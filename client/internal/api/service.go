@@ -2,17 +2,27 @@ package api
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"io"
+	"strconv"
+	"time"
 )
 
 type (
 	Service interface {
 		ApplicationService
 		BackupService
+		DatabaseService
+		AddonService
+		VolumeService
+		JobService
+		InternalAccessService
 		Pinger
 	}
 
@@ -23,10 +33,10 @@ type (
 	ApplicationService interface {
 		CreateApplication(ctx context.Context, params CreateApplicationParams) (Application, error)
 		GetApplication(ctx context.Context, id uuid.UUID) (Application, error)
-		Deploy(ctx context.Context, frontend, backend io.Reader, params DeployParams) (<-chan Event, error)
+		Deploy(ctx context.Context, frontend, backend io.Reader, services map[string]io.Reader, params DeployParams) (<-chan Event, error)
 		UpdateVariables(ctx context.Context, applicationID uuid.UUID, params UpdateVariablesParams) error
 		ListApplications(ctx context.Context) ([]Application, error)
-		Destroy(ctx context.Context, applicationID uuid.UUID, environment string) error
+		Destroy(ctx context.Context, applicationID uuid.UUID, params DestroyParams) error
 		ListVariables(ctx context.Context, applicationID uuid.UUID, environment string) ([]Var, error)
 		AddDomain(ctx context.Context, applicationID uuid.UUID, param AddDomainParam) error
 		RemoveDomain(ctx context.Context, applicationID uuid.UUID, name string) error
@@ -41,6 +51,53 @@ type (
 		CreateBackupSchedule(ctx context.Context, applicationID uuid.UUID, params CreateBackupParams) error
 		ListBackups(ctx context.Context, applicationID uuid.UUID, environment string) ([]Backup, error)
 		DownloadBackup(ctx context.Context, backupID uuid.UUID) (io.ReadCloser, error)
+		BackupNow(ctx context.Context, applicationID uuid.UUID, environment string) (<-chan Event, error)
+		UpdatePITR(ctx context.Context, applicationID uuid.UUID, params UpdatePITRParams) error
+		Restore(ctx context.Context, applicationID uuid.UUID, environment string, target time.Time) (<-chan Event, error)
+	}
+
+	DatabaseService interface {
+		UpgradeDatabase(ctx context.Context, applicationID uuid.UUID, params UpgradeDatabaseParams) (<-chan Event, error)
+		UpdateDatabaseConfig(ctx context.Context, applicationID uuid.UUID, params UpdateDatabaseConfigParams) error
+		AddStorageEngine(ctx context.Context, applicationID uuid.UUID, engine string) (<-chan Event, error)
+		RemoveStorageEngine(ctx context.Context, applicationID uuid.UUID, params RemoveStorageEngineParams) (<-chan Event, error)
+		RotateCredentials(ctx context.Context, applicationID uuid.UUID, params RotateCredentialsParams) (<-chan Event, error)
+		DatabaseTunnel(ctx context.Context, applicationID uuid.UUID, params DatabaseTunnelParams) (*websocket.Conn, error)
+		DatabaseShell(ctx context.Context, applicationID uuid.UUID, params DatabaseShellParams) (*websocket.Conn, error)
+		CreateImport(ctx context.Context, applicationID uuid.UUID) (DatabaseImport, error)
+		UploadImportChunk(ctx context.Context, applicationID, importID uuid.UUID, offset int64, chunk io.Reader) (DatabaseImport, error)
+		RemoveImport(ctx context.Context, applicationID, importID uuid.UUID) error
+		ImportDatabase(ctx context.Context, applicationID, importID uuid.UUID, params ImportDatabaseParams) (<-chan Event, error)
+		CloneDatabase(ctx context.Context, applicationID uuid.UUID, params CloneDatabaseParams) (<-chan Event, error)
+		LinkDatabase(ctx context.Context, applicationID uuid.UUID, params LinkDatabaseParams) (<-chan Event, error)
+		UnlinkDatabase(ctx context.Context, applicationID uuid.UUID, params UnlinkDatabaseParams) (<-chan Event, error)
+		ScaleReplicas(ctx context.Context, applicationID uuid.UUID, params ScaleReplicasParams) (<-chan Event, error)
+		ListReplicas(ctx context.Context, applicationID uuid.UUID, environment string) ([]ReplicaStatus, error)
+	}
+
+	AddonService interface {
+		AddAddon(ctx context.Context, applicationID uuid.UUID, params AddAddonParams) (<-chan Event, error)
+		RemoveAddon(ctx context.Context, applicationID uuid.UUID, params RemoveAddonParams) (<-chan Event, error)
+		ListAddons(ctx context.Context, applicationID uuid.UUID, environment string) ([]Addon, error)
+	}
+
+	VolumeService interface {
+		AddVolume(ctx context.Context, applicationID uuid.UUID, params AddVolumeParams) (<-chan Event, error)
+		RemoveVolume(ctx context.Context, applicationID uuid.UUID, params RemoveVolumeParams) (<-chan Event, error)
+		ListVolumes(ctx context.Context, applicationID uuid.UUID, environment string) ([]Volume, error)
+	}
+
+	JobService interface {
+		AddJob(ctx context.Context, applicationID uuid.UUID, params AddJobParams) (*Job, error)
+		RemoveJob(ctx context.Context, applicationID uuid.UUID, environment, name string) error
+		ListJobs(ctx context.Context, applicationID uuid.UUID, environment string) ([]Job, error)
+		JobHistory(ctx context.Context, applicationID uuid.UUID, environment, name string, limit int) ([]JobRun, error)
+	}
+
+	InternalAccessService interface {
+		AllowInternalAccess(ctx context.Context, applicationID uuid.UUID, application string) error
+		DenyInternalAccess(ctx context.Context, applicationID uuid.UUID, application string) error
+		InternalAccess(ctx context.Context, applicationID uuid.UUID) (*InternalAccess, error)
 	}
 )
 
@@ -83,7 +140,7 @@ func (s service) CreateApplication(ctx context.Context, params CreateApplication
 	return response.Application, err
 }
 
-func (s service) Deploy(ctx context.Context, frontend, backend io.Reader, params DeployParams) (<-chan Event, error) {
+func (s service) Deploy(ctx context.Context, frontend, backend io.Reader, services map[string]io.Reader, params DeployParams) (<-chan Event, error) {
 	files := make([]MultipartFile, 0)
 	if frontend != nil {
 		files = append(files, MultipartFile{
@@ -97,6 +154,12 @@ func (s service) Deploy(ctx context.Context, frontend, backend io.Reader, params
 			Name:    "backend.tar.gz",
 		})
 	}
+	for name, content := range services {
+		files = append(files, MultipartFile{
+			Content: content,
+			Name:    "service-" + name + ".tar.gz",
+		})
+	}
 
 	var response struct {
 		Data DeployResponse `json:"data"`
@@ -108,31 +171,9 @@ func (s service) Deploy(ctx context.Context, frontend, backend io.Reader, params
 		Response: &response,
 	}
 
-	ch := make(chan Event, 100)
-	go func() {
-		resp, err := s.apiClient.DoMultipart(ctx, files, httpParams)
-		if err != nil {
-			ch <- Event{
-				Type:    Error,
-				Message: err.Error(),
-			}
-			return
-		}
-
-		sc := bufio.NewScanner(resp)
-		for sc.Scan() {
-			ev := &Event{}
-			if err := json.Unmarshal(sc.Bytes(), ev); err != nil {
-				continue
-			}
-
-			ch <- *ev
-		}
-
-		if err := sc.Err(); err != nil {
-		}
-	}()
-	return ch, nil
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.DoMultipart(ctx, files, httpParams)
+	}), nil
 }
 
 func (s service) UpdateVariables(ctx context.Context, applicationID uuid.UUID, params UpdateVariablesParams) error {
@@ -166,20 +207,15 @@ func (s service) ListApplications(ctx context.Context) ([]Application, error) {
 	return response.Data, nil
 }
 
-func (s service) Destroy(ctx context.Context, applicationID uuid.UUID, environment string) error {
-	type body struct {
-		Environment string `json:"environment"`
-	}
-
+func (s service) Destroy(ctx context.Context, applicationID uuid.UUID, params DestroyParams) error {
 	var response struct {
 		Message string `json:"message"`
 	}
 
-	b := body{Environment: environment}
 	param := Params{
 		Method:   "POST",
 		Path:     fmt.Sprintf("applications/%s/destroy", applicationID),
-		Body:     b,
+		Body:     params,
 		Response: &response,
 	}
 	return s.apiClient.Do(ctx, param)
@@ -328,6 +364,203 @@ func (s service) DownloadBackup(ctx context.Context, backupID uuid.UUID) (io.Rea
 	return s.apiClient.Download(ctx, param)
 }
 
+func (s service) BackupNow(ctx context.Context, applicationID uuid.UUID, environment string) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/backups", applicationID),
+		QueryParams: map[string]string{
+			"environment": environment,
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) UpdatePITR(ctx context.Context, applicationID uuid.UUID, params UpdatePITRParams) error {
+	param := Params{
+		Method: "PUT",
+		Path:   fmt.Sprintf("applications/%s/pitr", applicationID),
+		Body:   params,
+	}
+	return s.apiClient.Do(ctx, param)
+}
+
+func (s service) Restore(ctx context.Context, applicationID uuid.UUID, environment string, target time.Time) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/restore", applicationID),
+		QueryParams: map[string]string{
+			"environment": environment,
+			"to":          target.Format(time.RFC3339),
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) UpgradeDatabase(ctx context.Context, applicationID uuid.UUID, params UpgradeDatabaseParams) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/databases/upgrade", applicationID),
+		QueryParams: map[string]string{
+			"environment": params.Environment,
+			"engine":      params.StorageEngine,
+			"version":     params.Version,
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) UpdateDatabaseConfig(ctx context.Context, applicationID uuid.UUID, params UpdateDatabaseConfigParams) error {
+	param := Params{
+		Method: "PUT",
+		Path:   fmt.Sprintf("applications/%s/databases/config", applicationID),
+		Body:   params,
+	}
+	return s.apiClient.Do(ctx, param)
+}
+
+func (s service) AddStorageEngine(ctx context.Context, applicationID uuid.UUID, engine string) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/databases", applicationID),
+		QueryParams: map[string]string{
+			"engine": engine,
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) RemoveStorageEngine(ctx context.Context, applicationID uuid.UUID, params RemoveStorageEngineParams) (<-chan Event, error) {
+	param := Params{
+		Method: "DELETE",
+		Path:   fmt.Sprintf("applications/%s/databases", applicationID),
+		QueryParams: map[string]string{
+			"engine":    params.StorageEngine,
+			"keep_data": strconv.FormatBool(params.KeepData),
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) RotateCredentials(ctx context.Context, applicationID uuid.UUID, params RotateCredentialsParams) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/databases/rotate-credentials", applicationID),
+		QueryParams: map[string]string{
+			"environment": params.Environment,
+			"engine":      params.StorageEngine,
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) DatabaseTunnel(ctx context.Context, applicationID uuid.UUID, params DatabaseTunnelParams) (*websocket.Conn, error) {
+	return s.apiClient.Tunnel(ctx, Params{
+		Path: fmt.Sprintf("applications/%s/databases/tunnel", applicationID),
+		QueryParams: map[string]string{
+			"environment": params.Environment,
+			"engine":      params.StorageEngine,
+		},
+	})
+}
+
+func (s service) DatabaseShell(ctx context.Context, applicationID uuid.UUID, params DatabaseShellParams) (*websocket.Conn, error) {
+	return s.apiClient.Tunnel(ctx, Params{
+		Path: fmt.Sprintf("applications/%s/databases/shell", applicationID),
+		QueryParams: map[string]string{
+			"environment": params.Environment,
+			"engine":      params.StorageEngine,
+			"command":     params.Command,
+			"rows":        strconv.Itoa(params.Rows),
+			"cols":        strconv.Itoa(params.Cols),
+		},
+	})
+}
+
+func (s service) CreateImport(ctx context.Context, applicationID uuid.UUID) (DatabaseImport, error) {
+	var response struct {
+		Import DatabaseImport `json:"data"`
+	}
+
+	err := s.apiClient.Do(ctx, Params{
+		Method:   "POST",
+		Path:     fmt.Sprintf("applications/%s/databases/imports", applicationID),
+		Response: &response,
+	})
+	return response.Import, err
+}
+
+func (s service) UploadImportChunk(ctx context.Context, applicationID, importID uuid.UUID, offset int64, chunk io.Reader) (DatabaseImport, error) {
+	var response struct {
+		Import DatabaseImport `json:"data"`
+	}
+
+	err := s.apiClient.Do(ctx, Params{
+		Method:      "PUT",
+		Path:        fmt.Sprintf("applications/%s/databases/imports/%s", applicationID, importID),
+		QueryParams: map[string]string{"offset": strconv.FormatInt(offset, 10)},
+		Body:        chunk,
+		Response:    &response,
+	})
+	return response.Import, err
+}
+
+func (s service) RemoveImport(ctx context.Context, applicationID, importID uuid.UUID) error {
+	var response struct {
+		Message string `json:"message"`
+	}
+	return s.apiClient.Do(ctx, Params{
+		Method:   "DELETE",
+		Path:     fmt.Sprintf("applications/%s/databases/imports/%s", applicationID, importID),
+		Response: &response,
+	})
+}
+
+func (s service) ImportDatabase(ctx context.Context, applicationID, importID uuid.UUID, params ImportDatabaseParams) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/databases/imports/%s/load", applicationID, importID),
+		QueryParams: map[string]string{
+			"environment": params.Environment,
+			"engine":      params.StorageEngine,
+			"wipe":        strconv.FormatBool(params.Wipe),
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) CloneDatabase(ctx context.Context, applicationID uuid.UUID, params CloneDatabaseParams) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/databases/clone", applicationID),
+		Body:   params,
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
 func (s service) TailLogs(ctx context.Context, f LogFilterParams) (<-chan Event, error) {
 	param := Params{
 		Method: "GET",
@@ -338,7 +571,44 @@ func (s service) TailLogs(ctx context.Context, f LogFilterParams) (<-chan Event,
 		},
 	}
 
-	ch := make(chan Event, 100)
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) GetApplication(ctx context.Context, id uuid.UUID) (Application, error) {
+	var response struct {
+		Data Application `json:"data"`
+	}
+
+	param := Params{
+		Method:   "GET",
+		Path:     "application",
+		Response: &response,
+		QueryParams: map[string]string{
+			"id": id.String(),
+		},
+	}
+
+	if err := s.apiClient.Do(ctx, param); err != nil {
+		return Application{}, err
+	}
+	return response.Data, nil
+}
+
+func (s service) StreamLogs(ctx context.Context, applicationID uuid.UUID, filter LogFilterParams) (<-chan Event, error) {
+	param := Params{
+		Method: "GET",
+		Path:   fmt.Sprintf("applications/%s/stream-logs", applicationID),
+		QueryParams: map[string]string{
+			"environment": filter.Environment,
+			"since":       filter.Since,
+			"start":       filter.Start,
+			"end":         filter.End,
+		},
+	}
+
+	ch := make(chan Event, 1000)
 	go func() {
 		resp, err := s.apiClient.SSE(ctx, param)
 		if err != nil {
@@ -360,70 +630,309 @@ func (s service) TailLogs(ctx context.Context, f LogFilterParams) (<-chan Event,
 		}
 
 		if err := sc.Err(); err != nil {
-			ch <- Event{
-				Type:    Error,
-				Message: err.Error(),
-			}
 		}
 	}()
 	return ch, nil
 }
 
-func (s service) GetApplication(ctx context.Context, id uuid.UUID) (Application, error) {
+func (s service) LinkDatabase(ctx context.Context, applicationID uuid.UUID, params LinkDatabaseParams) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/databases/link", applicationID),
+		Body:   params,
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) UnlinkDatabase(ctx context.Context, applicationID uuid.UUID, params UnlinkDatabaseParams) (<-chan Event, error) {
+	param := Params{
+		Method: "DELETE",
+		Path:   fmt.Sprintf("applications/%s/databases/link", applicationID),
+		QueryParams: map[string]string{
+			"environment": params.Environment,
+			"engine":      params.StorageEngine,
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) ScaleReplicas(ctx context.Context, applicationID uuid.UUID, params ScaleReplicasParams) (<-chan Event, error) {
+	param := Params{
+		Method: "PUT",
+		Path:   fmt.Sprintf("applications/%s/databases/replicas", applicationID),
+		Body:   params,
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) ListReplicas(ctx context.Context, applicationID uuid.UUID, environment string) ([]ReplicaStatus, error) {
 	var response struct {
-		Data Application `json:"data"`
+		Data []ReplicaStatus `json:"data"`
+	}
+	param := Params{
+		Method:   "GET",
+		Path:     fmt.Sprintf("applications/%s/databases/replicas", applicationID),
+		Response: &response,
+		QueryParams: map[string]string{
+			"environment": environment,
+		},
+	}
+	if err := s.apiClient.Do(ctx, param); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func (s service) AddAddon(ctx context.Context, applicationID uuid.UUID, params AddAddonParams) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/addons", applicationID),
+		Body:   params,
 	}
 
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) RemoveAddon(ctx context.Context, applicationID uuid.UUID, params RemoveAddonParams) (<-chan Event, error) {
+	param := Params{
+		Method: "DELETE",
+		Path:   fmt.Sprintf("applications/%s/addons/%s", applicationID, params.Name),
+		QueryParams: map[string]string{
+			"environment": params.Environment,
+			"keep_data":   strconv.FormatBool(params.KeepData),
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) ListAddons(ctx context.Context, applicationID uuid.UUID, environment string) ([]Addon, error) {
+	var response struct {
+		Data []Addon `json:"data"`
+	}
 	param := Params{
 		Method:   "GET",
-		Path:     "application",
+		Path:     fmt.Sprintf("applications/%s/addons", applicationID),
 		Response: &response,
 		QueryParams: map[string]string{
-			"id": id.String(),
+			"environment": environment,
 		},
 	}
+	if err := s.apiClient.Do(ctx, param); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func (s service) AddVolume(ctx context.Context, applicationID uuid.UUID, params AddVolumeParams) (<-chan Event, error) {
+	param := Params{
+		Method: "POST",
+		Path:   fmt.Sprintf("applications/%s/volumes", applicationID),
+		Body:   params,
+	}
 
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) RemoveVolume(ctx context.Context, applicationID uuid.UUID, params RemoveVolumeParams) (<-chan Event, error) {
+	param := Params{
+		Method: "DELETE",
+		Path:   fmt.Sprintf("applications/%s/volumes/%s", applicationID, params.Name),
+		QueryParams: map[string]string{
+			"environment": params.Environment,
+			"keep_data":   strconv.FormatBool(params.KeepData),
+		},
+	}
+
+	return streamEvents(func() (io.ReadCloser, error) {
+		return s.apiClient.SSE(ctx, param)
+	}), nil
+}
+
+func (s service) ListVolumes(ctx context.Context, applicationID uuid.UUID, environment string) ([]Volume, error) {
+	var response struct {
+		Data []Volume `json:"data"`
+	}
+	param := Params{
+		Method:   "GET",
+		Path:     fmt.Sprintf("applications/%s/volumes", applicationID),
+		Response: &response,
+		QueryParams: map[string]string{
+			"environment": environment,
+		},
+	}
 	if err := s.apiClient.Do(ctx, param); err != nil {
-		return Application{}, err
+		return nil, err
 	}
 	return response.Data, nil
 }
 
-func (s service) StreamLogs(ctx context.Context, applicationID uuid.UUID, filter LogFilterParams) (<-chan Event, error) {
+func (s service) AddJob(ctx context.Context, applicationID uuid.UUID, params AddJobParams) (*Job, error) {
+	var response struct {
+		Data *Job `json:"data"`
+	}
 	param := Params{
-		Method: "GET",
-		Path:   fmt.Sprintf("applications/%s/stream-logs", applicationID),
+		Method:   "POST",
+		Path:     fmt.Sprintf("applications/%s/jobs", applicationID),
+		Body:     params,
+		Response: &response,
+	}
+	if err := s.apiClient.Do(ctx, param); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func (s service) RemoveJob(ctx context.Context, applicationID uuid.UUID, environment, name string) error {
+	var response struct {
+		Message string `json:"message"`
+	}
+	param := Params{
+		Method:   "DELETE",
+		Path:     fmt.Sprintf("applications/%s/jobs/%s", applicationID, name),
+		Response: &response,
 		QueryParams: map[string]string{
-			"environment": filter.Environment,
-			"since":       filter.Since,
-			"start":       filter.Start,
-			"end":         filter.End,
+			"environment": environment,
 		},
 	}
+	return s.apiClient.Do(ctx, param)
+}
+
+func (s service) ListJobs(ctx context.Context, applicationID uuid.UUID, environment string) ([]Job, error) {
+	var response struct {
+		Data []Job `json:"data"`
+	}
+	param := Params{
+		Method:   "GET",
+		Path:     fmt.Sprintf("applications/%s/jobs", applicationID),
+		Response: &response,
+		QueryParams: map[string]string{
+			"environment": environment,
+		},
+	}
+	if err := s.apiClient.Do(ctx, param); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func (s service) JobHistory(ctx context.Context, applicationID uuid.UUID, environment, name string, limit int) ([]JobRun, error) {
+	var response struct {
+		Data []JobRun `json:"data"`
+	}
+	param := Params{
+		Method:   "GET",
+		Path:     fmt.Sprintf("applications/%s/jobs/%s/runs", applicationID, name),
+		Response: &response,
+		QueryParams: map[string]string{
+			"environment": environment,
+			"limit":       strconv.Itoa(limit),
+		},
+	}
+	if err := s.apiClient.Do(ctx, param); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+func (s service) AllowInternalAccess(ctx context.Context, applicationID uuid.UUID, application string) error {
+	var response struct {
+		Message string `json:"message"`
+	}
+	param := Params{
+		Method:   "POST",
+		Path:     fmt.Sprintf("applications/%s/internal-access", applicationID),
+		Body:     map[string]string{"application": application},
+		Response: &response,
+	}
+	return s.apiClient.Do(ctx, param)
+}
+
+func (s service) DenyInternalAccess(ctx context.Context, applicationID uuid.UUID, application string) error {
+	var response struct {
+		Message string `json:"message"`
+	}
+	param := Params{
+		Method:   "DELETE",
+		Path:     fmt.Sprintf("applications/%s/internal-access/%s", applicationID, application),
+		Response: &response,
+	}
+	return s.apiClient.Do(ctx, param)
+}
+
+func (s service) InternalAccess(ctx context.Context, applicationID uuid.UUID) (*InternalAccess, error) {
+	var response struct {
+		Data *InternalAccess `json:"data"`
+	}
+	param := Params{
+		Method:   "GET",
+		Path:     fmt.Sprintf("applications/%s/internal-access", applicationID),
+		Response: &response,
+	}
+	if err := s.apiClient.Do(ctx, param); err != nil {
+		return nil, err
+	}
+	return response.Data, nil
+}
+
+// streamEvents reads the events of a stream into the returned channel. when the stream can't be opened, read or
+// decoded, or ends before its Complete event, an Error followed by a Complete event is sent so that the reader stops
+func streamEvents(open func() (io.ReadCloser, error)) <-chan Event {
+	ch := make(chan Event, 100)
+	fail := func(err error) {
+		ch <- Event{
+			Type:    Error,
+			Message: err.Error(),
+		}
+		ch <- Event{Type: Complete}
+	}
 
-	ch := make(chan Event, 1000)
 	go func() {
-		resp, err := s.apiClient.SSE(ctx, param)
+		resp, err := open()
 		if err != nil {
-			ch <- Event{
-				Type:    Error,
-				Message: err.Error(),
-			}
+			fail(err)
 			return
 		}
+		defer resp.Close()
 
 		sc := bufio.NewScanner(resp)
 		for sc.Scan() {
+			if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+				continue
+			}
+
 			ev := &Event{}
 			if err := json.Unmarshal(sc.Bytes(), ev); err != nil {
-				continue
+				fail(fmt.Errorf("invalid event from the server: %w", err))
+				return
 			}
 
 			ch <- *ev
+			if ev.Type == Complete {
+				return
+			}
 		}
 
 		if err := sc.Err(); err != nil {
+			fail(err)
+			return
 		}
+		fail(errors.New("the server closed the stream before the operation completed"))
 	}()
-	return ch, nil
+	return ch
 }
@@ -2,15 +2,38 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/google/uuid"
+	"sort"
+	"strings"
 	"time"
 )
 
 type (
 	DeployParams struct {
-		Instances     int       `json:"instances"`
-		ApplicationID uuid.UUID `json:"application_id"`
-		Environment   string    `json:"environment" validate:"required"`
+		Instances     int                `json:"instances"`
+		ApplicationID uuid.UUID          `json:"application_id"`
+		Environment   string             `json:"environment" validate:"required"`
+		Processes     map[string]Process `json:"processes,omitempty"`
+		Services      []ServiceParams    `json:"services,omitempty"`
+		Visibility    string             `json:"visibility,omitempty"`
+	}
+
+	// ServiceParams is a named service deployed with its own upload
+	ServiceParams struct {
+		Name       string            `json:"name"`
+		Port       string            `json:"port"`
+		Replicas   int               `json:"replicas"`
+		Dockerfile string            `json:"dockerfile,omitempty"`
+		Domains    []string          `json:"domains,omitempty"`
+		Variables  map[string]string `json:"variables,omitempty"`
+		Visibility string            `json:"visibility,omitempty"`
+	}
+
+	Process struct {
+		Command  string `json:"command"`
+		Replicas int    `json:"replicas"`
+		Schedule string `json:"schedule,omitempty"`
 	}
 
 	CreateApplicationParams struct {
@@ -44,12 +67,26 @@ type (
 	ScaleAppParams struct {
 		Count       int    `json:"count"`
 		Environment string `json:"environment"`
+		Process     string `json:"process"`
 	}
 
 	RollbackParams struct {
 		Identifier string `json:"identifier"`
 	}
 
+	DestroyParams struct {
+		Environment   string `json:"environment"`
+		FinalBackup   bool   `json:"final_backup"`
+		DeleteBackups bool   `json:"delete_backups"`
+		KeepVolumes   bool   `json:"keep_volumes"`
+	}
+
+	UpdatePITRParams struct {
+		Environment              string `json:"environment"`
+		Enabled                  bool   `json:"enabled"`
+		BaseBackupCronExpression string `json:"base_backup_cron_expression"`
+	}
+
 	CreateBackupParams struct {
 		Environment    string `json:"environment"`
 		CronExpression string `json:"cron_expression"`
@@ -67,13 +104,197 @@ type (
 
 type (
 	Application struct {
-		ID             uuid.UUID `json:"id"`
-		Name           string    `json:"name"`
-		Domain         string    `json:"domain"`
-		StorageEngines []string  `json:"storage_engines"`
-		Backend        string    `json:"backend"`
-		Frontend       string    `json:"frontend"`
-		CreatedAt      time.Time `json:"created_at"`
+		ID             uuid.UUID      `json:"id"`
+		Name           string         `json:"name"`
+		Domain         string         `json:"domain"`
+		StorageEngines []string       `json:"storage_engines"`
+		EngineVersions EngineVersions `json:"engine_versions"`
+		Backend        string         `json:"backend"`
+		Frontend       string         `json:"frontend"`
+		CreatedAt      time.Time      `json:"created_at"`
+	}
+
+	EngineVersions struct {
+		Default      map[string]string            `json:"default"`
+		Environments map[string]map[string]string `json:"environments"`
+	}
+
+	UpdateDatabaseConfigParams struct {
+		Environment   string            `json:"environment"`
+		StorageEngine string            `json:"storage_engine"`
+		Settings      map[string]string `json:"settings"`
+	}
+
+	UpgradeDatabaseParams struct {
+		Environment   string
+		StorageEngine string
+		Version       string
+	}
+
+	DatabaseTunnelParams struct {
+		Environment   string
+		StorageEngine string
+	}
+
+	DatabaseImport struct {
+		ID   uuid.UUID `json:"id"`
+		Size int64     `json:"size"`
+	}
+
+	ImportDatabaseParams struct {
+		Environment   string
+		StorageEngine string
+		Wipe          bool
+	}
+
+	CloneDatabaseParams struct {
+		From          string              `json:"from"`
+		To            string              `json:"to"`
+		StorageEngine string              `json:"storage_engine"`
+		Rules         []AnonymisationRule `json:"rules"`
+	}
+
+	AnonymisationRule struct {
+		StorageEngine string `json:"storage_engine"`
+		Table         string `json:"table"`
+		Column        string `json:"column"`
+		Strategy      string `json:"strategy"`
+		Fake          string `json:"fake,omitempty"`
+	}
+
+	LinkDatabaseParams struct {
+		Environment   string `json:"environment"`
+		StorageEngine string `json:"storage_engine"`
+		URL           string `json:"url"`
+	}
+
+	UnlinkDatabaseParams struct {
+		Environment   string
+		StorageEngine string
+	}
+
+	ScaleReplicasParams struct {
+		Environment   string `json:"environment"`
+		StorageEngine string `json:"storage_engine"`
+		Replicas      int    `json:"replicas"`
+	}
+
+	ReplicaStatus struct {
+		Name       string  `json:"name"`
+		Container  string  `json:"container"`
+		Status     string  `json:"status"`
+		State      string  `json:"state"`
+		LagSeconds float64 `json:"lag_seconds"`
+		LagBytes   int64   `json:"lag_bytes"`
+	}
+
+	DatabaseShellParams struct {
+		Environment   string
+		StorageEngine string
+		Command       string
+		Rows          int
+		Cols          int
+	}
+
+	RotateCredentialsParams struct {
+		Environment   string
+		StorageEngine string
+	}
+
+	RemoveStorageEngineParams struct {
+		StorageEngine string
+		KeepData      bool
+	}
+
+	AddAddonParams struct {
+		Environment string            `json:"environment"`
+		Name        string            `json:"name"`
+		Image       string            `json:"image"`
+		Port        string            `json:"port"`
+		Volume      string            `json:"volume"`
+		Vars        map[string]string `json:"vars"`
+		Secrets     []string          `json:"secrets"`
+		Public      bool              `json:"public"`
+	}
+
+	RemoveAddonParams struct {
+		Environment string
+		Name        string
+		KeepData    bool
+	}
+
+	AddVolumeParams struct {
+		Environment string `json:"environment"`
+		Name        string `json:"name"`
+		Path        string `json:"path"`
+	}
+
+	RemoveVolumeParams struct {
+		Environment string
+		Name        string
+		KeepData    bool
+	}
+
+	Volume struct {
+		ID          string    `json:"id"`
+		Environment string    `json:"environment"`
+		Name        string    `json:"name"`
+		Path        string    `json:"path"`
+		Size        int64     `json:"size"`
+		CreatedAt   time.Time `json:"created_at"`
+	}
+
+	Addon struct {
+		ID          string            `json:"id"`
+		Environment string            `json:"environment"`
+		Name        string            `json:"name"`
+		Image       string            `json:"image"`
+		Port        string            `json:"port"`
+		Volume      string            `json:"volume"`
+		Vars        map[string]string `json:"vars"`
+		Secrets     []string          `json:"secrets"`
+		Public      bool              `json:"public"`
+		Status      string            `json:"status"`
+		URL         string            `json:"url"`
+		CreatedAt   time.Time         `json:"created_at"`
+	}
+
+	AddJobParams struct {
+		Environment       string   `json:"environment"`
+		Name              string   `json:"name"`
+		Schedule          string   `json:"schedule"`
+		Command           []string `json:"command"`
+		Timeout           int      `json:"timeout"`
+		ConcurrencyPolicy string   `json:"concurrency_policy"`
+	}
+
+	// InternalAccess has the internal addresses of the backends of an application and the applications
+	// allowed to reach them
+	InternalAccess struct {
+		Hosts       []string `json:"hosts"`
+		AllowedFrom []string `json:"allowed_from"`
+		Reaches     []string `json:"reaches"`
+	}
+
+	Job struct {
+		ID                string     `json:"id"`
+		Environment       string     `json:"environment"`
+		Name              string     `json:"name"`
+		Schedule          string     `json:"schedule"`
+		Command           []string   `json:"command"`
+		Timeout           int        `json:"timeout"`
+		ConcurrencyPolicy string     `json:"concurrency_policy"`
+		NextRun           *time.Time `json:"next_run"`
+		CreatedAt         time.Time  `json:"created_at"`
+	}
+
+	JobRun struct {
+		ID         string    `json:"id"`
+		Status     string    `json:"status"`
+		ExitCode   int64     `json:"exit_code"`
+		Output     string    `json:"output"`
+		StartedAt  time.Time `json:"started_at"`
+		FinishedAt time.Time `json:"finished_at"`
 	}
 
 	DeployResponse struct {
@@ -82,8 +303,9 @@ type (
 	}
 
 	AccessURL struct {
-		Frontend []string `json:"frontend"`
-		Backend  []string `json:"backend"`
+		Frontend []string            `json:"frontend"`
+		Backend  []string            `json:"backend"`
+		Services map[string][]string `json:"services"`
 	}
 
 	Var struct {
@@ -100,6 +322,7 @@ type (
 		Status        string    `json:"status"`
 		Instances     int       `json:"instances"`
 		Name          string    `json:"name"`
+		Health        string    `json:"health"`
 		Port          string    `json:"port"`
 		InstanceType  string    `json:"instance_type"`
 		Identifier    string    `json:"identifier"`
@@ -107,13 +330,28 @@ type (
 	}
 
 	Backup struct {
-		ID            uuid.UUID `json:"id" gorm:"primaryKey"`
-		ApplicationID uuid.UUID `json:"application_id"`
-		Environment   string    `json:"environment"`
-		CreatedAt     time.Time `json:"created_at"`
-		StorageEngine string    `json:"storage_engine"`
-		Location      string    `json:"location"`
-		StorageType   string    `json:"storage_type"`
+		ID            uuid.UUID     `json:"id" gorm:"primaryKey"`
+		ApplicationID uuid.UUID     `json:"application_id"`
+		Environment   string        `json:"environment"`
+		CreatedAt     time.Time     `json:"created_at"`
+		StorageEngine string        `json:"storage_engine"`
+		Location      string        `json:"location"`
+		StorageType   string        `json:"storage_type"`
+		Size          int64         `json:"size"`
+		Status        string        `json:"status"`
+		Trigger       string        `json:"trigger"`
+		Error         string        `json:"error"`
+		Duration      time.Duration `json:"duration"`
+		Kind          string        `json:"kind"`
+		Volume        string        `json:"volume"`
+		Copies        []BackupCopy  `json:"copies"`
+	}
+
+	BackupCopy struct {
+		Destination string `json:"destination"`
+		StorageType string `json:"storage_type"`
+		Status      string `json:"status"`
+		Error       string `json:"error"`
 	}
 
 	LogEntry struct {
@@ -137,6 +375,89 @@ const (
 	Complete Type = "complete"
 )
 
+// StorageEnginesString lists the storage engines with the version new environments run,
+// followed by the environments that were upgraded to another one
+func (a Application) StorageEnginesString() string {
+	result := make([]string, 0, len(a.StorageEngines))
+	for _, se := range a.StorageEngines {
+		value := se
+		if version := a.EngineVersions.Default[se]; version != "" {
+			value += " " + version
+		}
+
+		upgrades := make([]string, 0)
+		for env, versions := range a.EngineVersions.Environments {
+			if version, ok := versions[se]; ok {
+				upgrades = append(upgrades, fmt.Sprintf("%s: %s", env, version))
+			}
+		}
+		if len(upgrades) > 0 {
+			sort.Strings(upgrades)
+			value += " (" + strings.Join(upgrades, ", ") + ")"
+		}
+		result = append(result, value)
+	}
+	return strings.Join(result, ", ")
+}
+
+func (b Backup) Failed() bool {
+	return b.Status == "FAILED"
+}
+
+func (b Backup) StatusString() string {
+	if b.Status == "" {
+		return "SUCCEEDED"
+	}
+	return b.Status
+}
+
+// Source is the storage engine of a dump or the volume of a volume snapshot
+func (b Backup) Source() string {
+	if b.Kind == "volume" {
+		return "volume " + b.Volume
+	}
+	return b.StorageEngine
+}
+
+// KindString tells logical dumps apart from the base backups used by point-in-time recovery
+func (b Backup) KindString() string {
+	if b.Kind == "" {
+		return "dump"
+	}
+	return b.Kind
+}
+
+// CopiesString lists the destinations a backup was replicated to and whether each copy succeeded
+func (b Backup) CopiesString() string {
+	if len(b.Copies) == 0 {
+		return b.StorageTypeString()
+	}
+
+	result := make([]string, 0, len(b.Copies))
+	for _, next := range b.Copies {
+		result = append(result, fmt.Sprintf("%s: %s", next.Destination, next.Status))
+	}
+	return strings.Join(result, "\n")
+}
+
+func (b Backup) SizeString() string {
+	return FormatSize(b.Size)
+}
+
+// FormatSize formats a number of bytes with the largest unit it holds, e.g 1.5 MB
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
 func (b Backup) StorageTypeString() string {
 	switch b.StorageType {
 	case "S3":
@@ -146,3 +467,11 @@ func (b Backup) StorageTypeString() string {
 	}
 	return "Unknown"
 }
+
+// Duration is how long the run took, "-" while it is running
+func (r JobRun) Duration() string {
+	if r.FinishedAt.IsZero() {
+		return "-"
+	}
+	return r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond).String()
+}
@@ -0,0 +1,23 @@
+package cmdutil
+
+import (
+	"github.com/manifoldco/promptui"
+	"sarabi/internal/misc"
+)
+
+// Confirm asks a yes/no question. promptui returns ErrAbort when the answer is no
+func Confirm(label string) (bool, error) {
+	p := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	result, err := p.Run()
+	if err == promptui.ErrAbort {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return misc.StrContains(result, []string{"Yes", "yes", "y"}), nil
+}
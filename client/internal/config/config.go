@@ -23,6 +23,73 @@ type (
 		Backend        string    `yaml:"backend"`
 		Domain         string    `yaml:"domain"`
 		StorageEngines []string  `yaml:"storageEngines"`
+		// Anonymise are applied to the data copied by sarabi env clone-db
+		Anonymise []AnonymisationRule `yaml:"anonymise"`
+		// Processes are the process types of the backend: web, worker and cron
+		Processes map[string]ProcessConfig `yaml:"processes"`
+		// Services are named backends deployed next to the backend, by name
+		Services map[string]ServiceConfig `yaml:"services"`
+		// Visibility of the backend is public or internal, an internal backend has no public route and is
+		// only reached by the applications allowed with sarabi access allow
+		Visibility string `yaml:"visibility"`
+	}
+
+	// ServiceConfig is a named service built from its own directory, e.g
+	//
+	//	services:
+	//	  api:
+	//	    path: ./services/api
+	//	    port: 8080
+	//	    replicas: 2
+	//	    domains:
+	//	      - api.example.com
+	//	  gateway:
+	//	    path: ./services
+	//	    dockerfile: gateway/Dockerfile
+	//	    variables:
+	//	      LOG_LEVEL: debug
+	//	  ledger:
+	//	    path: ./services/ledger
+	//	    visibility: internal
+	ServiceConfig struct {
+		Path string `yaml:"path"`
+		// Dockerfile is relative to Path, Dockerfile when it is not set
+		Dockerfile string `yaml:"dockerfile"`
+		// Port is the port the service listens on, one is allocated and set in PORT when it is not set
+		Port int `yaml:"port"`
+		// Replicas is 1 when it is not set
+		Replicas  *int              `yaml:"replicas"`
+		Domains   []string          `yaml:"domains"`
+		Variables map[string]string `yaml:"variables"`
+		// Visibility is public when it is not set, see ApplicationConfig.Visibility
+		Visibility string `yaml:"visibility"`
+	}
+
+	// ProcessConfig is a process type run from the image of the backend, e.g
+	//
+	//	processes:
+	//	  web:
+	//	    command: ./bin/server
+	//	  worker:
+	//	    command: ./bin/worker
+	//	    replicas: 2
+	//	  cron:
+	//	    command: ./bin/cleanup
+	//	    schedule: "0 * * * *"
+	ProcessConfig struct {
+		Command string `yaml:"command"`
+		// Replicas is 1 when it is not set, it is not set for cron
+		Replicas *int   `yaml:"replicas"`
+		Schedule string `yaml:"schedule"`
+	}
+
+	// AnonymisationRule replaces the values of a column, or of a dotted field of a mongo collection
+	AnonymisationRule struct {
+		Engine   string `yaml:"engine"`
+		Table    string `yaml:"table"`
+		Column   string `yaml:"column"`
+		Strategy string `yaml:"strategy"`
+		Fake     string `yaml:"fake"`
 	}
 
 	Config struct {
@@ -0,0 +1,25 @@
+package access
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/access/allow"
+	"sarabi/client/pkg/cmd/access/deny"
+	"sarabi/client/pkg/cmd/access/list"
+)
+
+func NewAccessCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "access <command>",
+		Short: "Manage which applications reach the backends of an application",
+		Long:  "Every backend is reached by the other backends of its application at <service>.<env>.<app>.internal, the backend is named backend. Other applications on the server reach them only once they are allowed. An allowed application shares a network with the application, the two can open connections to each other's containers on it but only the application allowed to is given the internal hosts",
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	cmd.AddCommand(allow.NewAllowCmd(svc, cfg))
+	cmd.AddCommand(deny.NewDenyCmd(svc, cfg))
+	cmd.AddCommand(list.NewListCmd(svc, cfg))
+	return cmd
+}
@@ -0,0 +1,29 @@
+package allow
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+)
+
+func NewAllowCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "allow <application>",
+		Short:   "Allow an application to reach the backends of this application",
+		Long:    "Allow the backends of another application on the server to reach the backends of this application at their internal hosts, including the internal ones. The running backends are connected straight away. The two applications share a network, so the backends of this application can also reach the containers of the allowed one by their container names; only allow applications you trust both ways.",
+		Example: "sarabi access allow checkout",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			if err := svc.AllowInternalAccess(cmd.Context(), cfg.ApplicationID, args[0]); err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			cmdutil.PrintS(args[0] + " is allowed to reach the backends of the application")
+		},
+	}
+	return cmd
+}
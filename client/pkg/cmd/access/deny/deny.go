@@ -0,0 +1,38 @@
+package deny
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+)
+
+func NewDenyCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "deny <application>",
+		Short:   "Stop an application from reaching the backends of this application",
+		Long:    "Stop the backends of another application from reaching the backends of this application, the connections between them are closed straight away.",
+		Example: "sarabi access deny checkout",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ok, err := cmdutil.Confirm(args[0] + " will no longer reach the backends of the application, continue?")
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			if !ok {
+				return
+			}
+
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			if err := svc.DenyInternalAccess(cmd.Context(), cfg.ApplicationID, args[0]); err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			cmdutil.PrintS(args[0] + " is no longer allowed to reach the backends of the application")
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,50 @@
+package list
+
+import (
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewListCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the internal hosts of an application and the applications allowed to reach them",
+		Long:    "List the internal hosts of the active backends of an application, the applications allowed to reach them and the applications it is allowed to reach.",
+		Example: "sarabi access list",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			access, err := svc.InternalAccess(cmd.Context(), cfg.ApplicationID)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			tw := table.NewWriter()
+			tw.AppendHeader(table.Row{"Internal Hosts", "Allowed From", "Reaches"})
+			tw.SetStyle(table.StyleLight)
+			tw.AppendSeparator()
+			tw.AppendRow(table.Row{
+				orNone(access.Hosts),
+				orNone(access.AllowedFrom),
+				orNone(access.Reaches),
+			})
+
+			cmdutil.Print("")
+			cmdutil.Print(tw.Render())
+		},
+	}
+	return cmd
+}
+
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, "\n")
+}
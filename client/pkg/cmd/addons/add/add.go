@@ -0,0 +1,88 @@
+package add
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewAddCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.AddAddonParams{}
+	var vars []string
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Run an add-on service in an environment",
+		Long: "Run a docker image on the network of an environment, the backends reach it at <name>:<port>. " +
+			"The backends are deployed again with <NAME>_HOST, <NAME>_PORT and <NAME>_URL, and with the variables generated by --secret which the add-on gets too. " +
+			"The path given by --volume is kept across restarts. With --public, the add-on is reachable at https://<name>-<env>.<domain>. " +
+			"Adding an add-on again starts it over with the new settings, its data and generated secrets are kept.",
+		Example: `sarabi addons add meilisearch --image getmeili/meilisearch:v1.8 --port 7700 --volume /meili_data --secret MEILI_MASTER_KEY --env prod
+sarabi addons add mailpit --image axllent/mailpit --port 8025 --var MP_MAX_MESSAGES=500 --public --env staging`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			params.Name = args[0]
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+			if params.Image == "" || params.Port == "" {
+				cmdutil.PrintE("Please specify the image and the port of the add-on")
+				return
+			}
+
+			params.Vars = make(map[string]string)
+			for _, next := range vars {
+				key, value, ok := strings.Cut(next, "=")
+				if !ok {
+					cmdutil.PrintE("Invalid variable: " + next + ", expected KEY=VALUE")
+					return
+				}
+				params.Vars[key] = value
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.AddAddon(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment to run the add-on in")
+	cmd.Flags().StringVar(&params.Image, "image", "", "Docker image of the add-on")
+	cmd.Flags().StringVar(&params.Port, "port", "", "Port the add-on listens on")
+	cmd.Flags().StringVar(&params.Volume, "volume", "", "Path in the container to keep in a volume")
+	cmd.Flags().StringArrayVar(&params.Secrets, "secret", nil, "Name of a variable to generate a random value for, can be repeated")
+	cmd.Flags().StringArrayVar(&vars, "var", nil, "Variable of the add-on as KEY=VALUE, can be repeated")
+	cmd.Flags().BoolVar(&params.Public, "public", false, "Route https://<name>-<env>.<domain> to the add-on")
+	return cmd
+}
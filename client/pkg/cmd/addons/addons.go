@@ -0,0 +1,25 @@
+package addons
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/addons/add"
+	"sarabi/client/pkg/cmd/addons/list"
+	"sarabi/client/pkg/cmd/addons/remove"
+)
+
+func NewAddonsCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "addons <command>",
+		Short: "Manage the add-on services of an application",
+		Long:  "Add-ons run any docker image next to the application, e.g a search engine or a mail catcher. The backends reach them by name on the network of the environment",
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	cmd.AddCommand(add.NewAddCmd(svc, cfg))
+	cmd.AddCommand(list.NewListCmd(svc, cfg))
+	cmd.AddCommand(remove.NewRemoveCmd(svc, cfg))
+	return cmd
+}
@@ -0,0 +1,62 @@
+package list
+
+import (
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewListCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the add-ons of the application",
+		Long:    "List the add-ons of an environment with the status of their containers, the ones of every environment when --env is left out.",
+		Example: "sarabi addons list --env prod",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			addons, err := svc.ListAddons(cmd.Context(), cfg.ApplicationID, environment)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			tw := table.NewWriter()
+			tw.AppendHeader(table.Row{"Name", "Environment", "Image", "Port", "Volume", "Secrets", "Status", "URL"})
+			tw.SetStyle(table.StyleLight)
+			tw.AppendSeparator()
+
+			for _, addon := range addons {
+				tw.AppendRow(table.Row{
+					addon.Name,
+					addon.Environment,
+					addon.Image,
+					addon.Port,
+					orDash(addon.Volume),
+					orDash(strings.Join(addon.Secrets, ", ")),
+					addon.Status,
+					orDash(addon.URL),
+				})
+				tw.AppendSeparator()
+			}
+
+			cmdutil.Print("")
+			cmdutil.Print(tw.Render())
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment of the add-ons")
+	return cmd
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
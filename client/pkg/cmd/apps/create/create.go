@@ -15,12 +15,13 @@ import (
 	"sarabi/client/internal/cmdutil"
 	"sarabi/client/internal/config"
 	"sarabi/internal/misc"
+	"strings"
 	"time"
 )
 
 var (
 	storageEngines = []string{
-		"None", "Postgresql", "Mysql", "MongoDB", "Redis", "Done",
+		"None", "Postgresql", "Mysql", "MongoDB", "Redis", "RabbitMQ", "MinIO", "Done",
 	}
 	cfgFileName = ".sarabi.yml"
 	engineAlias = map[string]string{
@@ -28,6 +29,8 @@ var (
 		"Mysql":      "mysql",
 		"MongoDB":    "mongo",
 		"Redis":      "redis",
+		"RabbitMQ":   "rabbitmq",
+		"MinIO":      "minio",
 	}
 )
 
@@ -82,7 +85,18 @@ func NewCreateAppCmd(svc api.Service) *cobra.Command {
 					continue
 				}
 
-				result = append(result, engineAlias[storageEngines[i]])
+				versionPrompt := createEngineVersionPrompt(value)
+				version, err := versionPrompt.Run()
+				if err != nil {
+					cmdutil.PrintE(err.Error())
+					return
+				}
+
+				engine := engineAlias[value]
+				if version != "" {
+					engine += ":" + version
+				}
+				result = append(result, engine)
 			}
 
 			bePathPrompt := createBackendFilesPathPrompt()
@@ -185,6 +199,18 @@ func createStorageEnginePrompt(selectedEngines map[int]bool) promptui.Select {
 	}
 }
 
+func createEngineVersionPrompt(engine string) promptui.Prompt {
+	return promptui.Prompt{
+		Label: fmt.Sprintf("%s version (leave empty for the default)", engine),
+		Validate: func(s string) error {
+			if strings.ContainsAny(s, " :/") {
+				return fmt.Errorf("invalid version: %s", s)
+			}
+			return nil
+		},
+	}
+}
+
 func createFrontendFilesPathPrompt() promptui.Prompt {
 	return promptui.Prompt{
 		Label: "Enter your frontend file location",
@@ -6,7 +6,6 @@ import (
 	"github.com/spf13/cobra"
 	"sarabi/client/internal/api"
 	"sarabi/client/internal/cmdutil"
-	"strings"
 	"time"
 )
 
@@ -36,7 +35,7 @@ func NewListAppsCmd(svc api.Service) *cobra.Command {
 					next.ID.String(),
 					next.Name,
 					next.Domain,
-					strings.Join(next.StorageEngines, ", "),
+					next.StorageEnginesString(),
 					next.CreatedAt.Format("02-01-2006"),
 				}
 				tw.AppendRow(row)
@@ -6,6 +6,9 @@ import (
 	"sarabi/client/internal/config"
 	"sarabi/client/pkg/cmd/backup/download"
 	"sarabi/client/pkg/cmd/backup/list"
+	"sarabi/client/pkg/cmd/backup/now"
+	"sarabi/client/pkg/cmd/backup/pitr"
+	"sarabi/client/pkg/cmd/backup/restore"
 	"sarabi/client/pkg/cmd/backup/schedule"
 )
 
@@ -22,5 +25,8 @@ func NewBackupCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command
 	cmd.AddCommand(schedule.NewCreateBackupScheduleCmd(svc, cfg))
 	cmd.AddCommand(list.NewListBackupsCmd(svc, cfg))
 	cmd.AddCommand(download.NewDownloadBackupCmd(svc))
+	cmd.AddCommand(now.NewBackupNowCmd(svc, cfg))
+	cmd.AddCommand(pitr.NewPITRCmd(svc, cfg))
+	cmd.AddCommand(restore.NewRestoreCmd(svc, cfg))
 	return cmd
 }
@@ -1,11 +1,13 @@
 package list
 
 import (
+	"fmt"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
 	"sarabi/client/internal/api"
 	"sarabi/client/internal/cmdutil"
 	"sarabi/client/internal/config"
+	"time"
 )
 
 func NewListBackupsCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
@@ -25,8 +27,21 @@ func NewListBackupsCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Com
 				return
 			}
 
+			for _, backup := range backups {
+				// backups are returned newest first
+				if backup.Failed() {
+					cmdutil.PrintE(fmt.Sprintf("Last failed backup: %s %s/%s (%s): %s",
+						backup.CreatedAt.Format("2006-01-02 15:04:05"),
+						backup.Environment,
+						backup.Source(),
+						backup.Trigger,
+						backup.Error))
+					break
+				}
+			}
+
 			tw := table.NewWriter()
-			header := table.Row{"ID", "Environment", "Location", "Database Engine", "Created At", "Size"}
+			header := table.Row{"ID", "Environment", "Copies", "Source", "Kind", "Created At", "Size", "Status", "Trigger", "Duration"}
 			tw.AppendHeader(header)
 			tw.SetStyle(table.StyleLight)
 			tw.AppendSeparator()
@@ -35,10 +50,14 @@ func NewListBackupsCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Com
 				row := table.Row{
 					backup.ID,
 					backup.Environment,
-					backup.StorageTypeString(),
-					backup.StorageEngine,
+					backup.CopiesString(),
+					backup.Source(),
+					backup.KindString(),
 					backup.CreatedAt.Format("2006-01-02 15:04:05"),
-					"100 MB",
+					backup.SizeString(),
+					backup.StatusString(),
+					backup.Trigger,
+					backup.Duration.Round(time.Millisecond),
 				}
 				tw.AppendRow(row)
 				tw.AppendSeparator()
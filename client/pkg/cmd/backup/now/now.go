@@ -0,0 +1,81 @@
+package now
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewBackupNowCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	cmd := &cobra.Command{
+		Use:     "now",
+		Short:   "Run a backup immediately",
+		Long:    "Backup all the databases of an environment right away, without waiting for the next scheduled run. Progress is streamed until the backup completes.",
+		Example: "sarabi backup now --env <environment>",
+		Run: func(cmd *cobra.Command, args []string) {
+			if environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.BackupNow(ctx, cfg.ApplicationID, environment)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					handleBackupEvent(ev, cancel)
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment you want to backup")
+	return cmd
+}
+
+func handleBackupEvent(ev api.Event, cancel context.CancelFunc) {
+	switch ev.Type {
+	case api.Info:
+		cmdutil.Print(strings.Trim(ev.Message, "\n"))
+	case api.Error:
+		cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+	case api.Success:
+		cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+	case api.Complete:
+		cancel()
+		var backups []api.Backup
+		if err := json.Unmarshal(ev.Data, &backups); err != nil {
+			return
+		}
+
+		failed := 0
+		for _, b := range backups {
+			if b.Failed() {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			cmdutil.PrintE(fmt.Sprintf("Backup finished with %d failure(s) out of %d", failed, len(backups)))
+			return
+		}
+		cmdutil.PrintS(fmt.Sprintf("Backup succeeded! (%d)", len(backups)))
+	}
+}
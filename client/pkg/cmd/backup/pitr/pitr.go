@@ -0,0 +1,66 @@
+package pitr
+
+import (
+	"context"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+)
+
+func NewPITRCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	var cronExpression string
+	var disable bool
+	cmd := &cobra.Command{
+		Use:     "pitr",
+		Short:   "Enable or disable point-in-time recovery",
+		Long:    "Enable point-in-time recovery for the postgres database of an environment. WAL is shipped to the backup storage every minute and base backups are taken on the given schedule(daily by default). Enabling or disabling it restarts the database.",
+		Example: "sarabi backup pitr --env <environment> [--base-backup-expression <cron_expression>] [--disable]",
+		Run: func(cmd *cobra.Command, args []string) {
+			if environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			if cronExpression != "" {
+				parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+				if _, err := parser.Parse(cronExpression); err != nil {
+					cmdutil.PrintE(fmt.Sprintf("invalid cron expression: %s", err.Error()))
+					return
+				}
+			}
+
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			params := api.UpdatePITRParams{
+				Environment:              environment,
+				Enabled:                  !disable,
+				BaseBackupCronExpression: cronExpression,
+			}
+			if err := svc.UpdatePITR(ctx, cfg.ApplicationID, params); err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			if disable {
+				cmdutil.PrintS("Point-in-time recovery disabled!")
+				return
+			}
+			cmdutil.PrintS("Point-in-time recovery enabled!")
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment you want to configure point-in-time recovery for")
+	cmd.Flags().StringVarP(&cronExpression, "base-backup-expression", "x", "", "The cron expression that defines how often base backups are taken")
+	cmd.Flags().BoolVar(&disable, "disable", false, "Turn point-in-time recovery off")
+	return cmd
+}
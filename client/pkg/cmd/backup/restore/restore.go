@@ -0,0 +1,79 @@
+package restore
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+	"time"
+)
+
+func NewRestoreCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	var to string
+	cmd := &cobra.Command{
+		Use:     "restore",
+		Short:   "Restore a database to a point in time",
+		Long:    "Restore the postgres database of an environment to the state it was in at the given time(local time). Point-in-time recovery must have been enabled before that time. The database is unavailable while the restore runs.",
+		Example: `sarabi backup restore --env <environment> --to "2026-10-01 14:32:00"`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			target, err := time.ParseInLocation(time.DateTime, to, time.Local)
+			if err != nil {
+				cmdutil.PrintE("invalid time, expected format: YYYY-MM-DD HH:MM:SS")
+				return
+			}
+
+			ok, err := cmdutil.Confirm("The current data of " + environment + " will be replaced, continue?")
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			if !ok {
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.Restore(ctx, cfg.ApplicationID, environment, target)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment you want to restore")
+	cmd.Flags().StringVar(&to, "to", "", "The point in time to restore to, in the format YYYY-MM-DD HH:MM:SS")
+	return cmd
+}
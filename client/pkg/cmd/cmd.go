@@ -6,17 +6,23 @@ import (
 	"sarabi/client/internal/api"
 	"sarabi/client/internal/auth"
 	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/access"
+	"sarabi/client/pkg/cmd/addons"
 	"sarabi/client/pkg/cmd/apps"
 	"sarabi/client/pkg/cmd/backup"
 	configcmd "sarabi/client/pkg/cmd/config"
+	"sarabi/client/pkg/cmd/db"
 	"sarabi/client/pkg/cmd/deploy"
 	"sarabi/client/pkg/cmd/deployments"
 	"sarabi/client/pkg/cmd/destroy"
 	"sarabi/client/pkg/cmd/domains"
+	"sarabi/client/pkg/cmd/env"
+	"sarabi/client/pkg/cmd/jobs"
 	"sarabi/client/pkg/cmd/logs"
 	"sarabi/client/pkg/cmd/rollback"
 	"sarabi/client/pkg/cmd/scale"
 	"sarabi/client/pkg/cmd/vars"
+	"sarabi/client/pkg/cmd/volumes"
 )
 
 func New() (*cobra.Command, error) {
@@ -56,6 +62,12 @@ func New() (*cobra.Command, error) {
 	cmd.AddCommand(scale.NewScaleAppCmd(svc, appConfig))
 	cmd.AddCommand(rollback.NewRollbackCmd(svc))
 	cmd.AddCommand(backup.NewBackupCmd(svc, appConfig))
+	cmd.AddCommand(db.NewDatabaseCmd(svc, appConfig))
+	cmd.AddCommand(addons.NewAddonsCmd(svc, appConfig))
+	cmd.AddCommand(volumes.NewVolumesCmd(svc, appConfig))
+	cmd.AddCommand(jobs.NewJobsCmd(svc, appConfig))
+	cmd.AddCommand(access.NewAccessCmd(svc, appConfig))
+	cmd.AddCommand(env.NewEnvCmd(svc, appConfig))
 	cmd.AddCommand(logs.NewLogsCmd(svc, appConfig))
 	cmd.AddCommand(configcmd.NewConfigCmd())
 	return cmd, nil
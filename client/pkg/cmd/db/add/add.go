@@ -0,0 +1,56 @@
+package add
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewAddCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add <engine[:version]>",
+		Short: "Add a storage engine to the application",
+		Long: "Add a storage engine to the application. It is provisioned in every environment the application is deployed to, " +
+			"and the backends are deployed again so they get its variables. The default version of the engine is used unless one is given.",
+		Example: `sarabi db add redis
+sarabi db add postgres:16`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.AddStorageEngine(ctx, cfg.ApplicationID, args[0])
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+	return cmd
+}
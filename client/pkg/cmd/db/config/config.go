@@ -0,0 +1,21 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/db/config/set"
+)
+
+func NewDatabaseConfigCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config <command>",
+		Short: "Manage databases configuration",
+		Long:  "Databases are tuned for the CPU and memory allocated to them, change their settings when the tuned ones don't fit",
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	cmd.AddCommand(set.NewSetCmd(svc, cfg))
+	return cmd
+}
@@ -0,0 +1,69 @@
+package set
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+	"time"
+)
+
+func NewSetCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.UpdateDatabaseConfigParams{}
+	cmd := &cobra.Command{
+		Use:   "set <name=value>...",
+		Short: "Change database settings",
+		Long: "Change settings of a database, they are written to its configuration file (postgresql.conf, my.cnf, mongod.conf, redis.conf, rabbitmq.conf or the MINIO_ variables of minio) and the database is restarted. " +
+			"The previous configuration is put back if the database doesn't start with the new one. An empty value goes back to the tuned setting. " +
+			"Setting replication.replSetName runs mongo as a single node replica set, e.g for transactions, and names it in MONGO_DATABASE_URL.",
+		Example: `sarabi db config set --env staging max_connections=200 work_mem=16MB
+sarabi db config set --env staging --engine redis maxmemory-policy=allkeys-lru
+sarabi db config set --env staging max_connections=
+sarabi db config set --env staging --engine mongo replication.replSetName=rs0`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment --env")
+				return
+			}
+
+			settings, err := parseSettings(args)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			params.Settings = settings
+
+			cmdutil.StartLoading(fmt.Sprintf("applying settings and restarting database...(%d)", len(settings)))
+			defer cmdutil.StopLoading()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			if err := svc.UpdateDatabaseConfig(ctx, cfg.ApplicationID, params); err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			cmdutil.PrintS("database configuration updated!")
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the database")
+	cmd.Flags().StringVar(&params.StorageEngine, "engine", "", "Storage engine to configure, required when the application has more than one")
+	return cmd
+}
+
+func parseSettings(args []string) (map[string]string, error) {
+	result := make(map[string]string, len(args))
+	for _, next := range args {
+		name, value, found := strings.Cut(next, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid setting: %s, expected name=value", next)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
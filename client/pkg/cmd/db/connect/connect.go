@@ -0,0 +1,81 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"net"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"sarabi/internal/tunnel"
+)
+
+func NewConnectCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.DatabaseTunnelParams{}
+	var localPort int
+	cmd := &cobra.Command{
+		Use:   "connect",
+		Short: "Open a tunnel to a database",
+		Long: "Open a tunnel from a local port to a storage engine of an environment, through the sarabi API. " +
+			"Every connection to the local port is carried over an authenticated websocket to the database, " +
+			"no port is opened on the server's firewall. The tunnel stays open until interrupted.",
+		Example: `sarabi db connect --env prod --local-port 5433
+sarabi db connect --env prod --engine redis --local-port 6380`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			// the first connection checks the tunnel can be opened before anything listens locally
+			ws, err := svc.DatabaseTunnel(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			_ = ws.Close()
+
+			listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			go func() {
+				<-ctx.Done()
+				_ = listener.Close()
+			}()
+
+			cmdutil.PrintS(fmt.Sprintf("Tunnel to %s open on %s, press Ctrl+C to close it", params.Environment, listener.Addr()))
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+
+				go func(conn net.Conn) {
+					ws, err := svc.DatabaseTunnel(ctx, cfg.ApplicationID, params)
+					if err != nil {
+						cmdutil.PrintE(err.Error())
+						_ = conn.Close()
+						return
+					}
+
+					if err := tunnel.Pipe(ws, conn); err != nil {
+						cmdutil.PrintE(err.Error())
+					}
+				}(conn)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the database")
+	cmd.Flags().StringVar(&params.StorageEngine, "engine", "", "Storage engine to connect to, required when the application has more than one")
+	cmd.Flags().IntVar(&localPort, "local-port", 0, "Local port the tunnel listens on, a free port is picked when left out")
+	return cmd
+}
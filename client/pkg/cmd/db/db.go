@@ -0,0 +1,41 @@
+package db
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/db/add"
+	dbconfig "sarabi/client/pkg/cmd/db/config"
+	"sarabi/client/pkg/cmd/db/connect"
+	"sarabi/client/pkg/cmd/db/dbimport"
+	"sarabi/client/pkg/cmd/db/link"
+	"sarabi/client/pkg/cmd/db/remove"
+	"sarabi/client/pkg/cmd/db/replicas"
+	"sarabi/client/pkg/cmd/db/rotate"
+	"sarabi/client/pkg/cmd/db/shell"
+	"sarabi/client/pkg/cmd/db/unlink"
+	"sarabi/client/pkg/cmd/db/upgrade"
+)
+
+func NewDatabaseCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db <command>",
+		Short: "Manage sarabi applications databases",
+		Long:  "Manage the storage engines of an application, e.g add or remove them, upgrade them to a new version, change their settings, rotate their credentials, import dumps into them, link them to external databases, run read replicas of them, connect to them or open a shell on them",
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	cmd.AddCommand(add.NewAddCmd(svc, cfg))
+	cmd.AddCommand(remove.NewRemoveCmd(svc, cfg))
+	cmd.AddCommand(upgrade.NewUpgradeCmd(svc, cfg))
+	cmd.AddCommand(dbconfig.NewDatabaseConfigCmd(svc, cfg))
+	cmd.AddCommand(rotate.NewRotateCredentialsCmd(svc, cfg))
+	cmd.AddCommand(connect.NewConnectCmd(svc, cfg))
+	cmd.AddCommand(shell.NewShellCmd(svc, cfg))
+	cmd.AddCommand(dbimport.NewImportCmd(svc, cfg))
+	cmd.AddCommand(link.NewLinkCmd(svc, cfg))
+	cmd.AddCommand(unlink.NewUnlinkCmd(svc, cfg))
+	cmd.AddCommand(replicas.NewReplicasCmd(svc, cfg))
+	return cmd
+}
@@ -0,0 +1,153 @@
+package dbimport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+	"time"
+)
+
+const (
+	chunkSize = 8 << 20 // 8MB
+	// chunkAttempts is how many times a chunk is sent before the upload is given up
+	chunkAttempts = 3
+)
+
+func NewImportCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.ImportDatabaseParams{}
+	cmd := &cobra.Command{
+		Use:   "import <dump>",
+		Short: "Import a dump into a database",
+		Long: "Upload a dump and load it into a storage engine of an environment with the engine's native tool. " +
+			"The format is told from the content of the dump: plain SQL, a pg_dump custom archive, a mysqldump, " +
+			"a mongodump archive, a tar of a mongodump directory or a Redis RDB file, optionally gzip compressed. " +
+			"With --wipe, a backup is taken and the data of the engine deleted before the dump is loaded. " +
+			"A Redis RDB file replaces all the data of redis, it can only be imported with --wipe.",
+		Example: `sarabi db import --env prod ./dump.sql.gz
+sarabi db import --env prod --engine mongo --wipe ./dump.archive`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			fi, err := os.Open(args[0])
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			defer fi.Close()
+
+			stat, err := fi.Stat()
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			if params.Wipe {
+				ok, err := cmdutil.Confirm("The data of the database in " + params.Environment + " will be deleted before the import, continue?")
+				if err != nil {
+					cmdutil.PrintE(err.Error())
+					return
+				}
+				if !ok {
+					return
+				}
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			dump, err := svc.CreateImport(ctx, cfg.ApplicationID)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			if err := upload(ctx, svc, cfg.ApplicationID, dump.ID, fi, stat.Size()); err != nil {
+				cmdutil.PrintE(err.Error())
+				// ctx is done when the upload was interrupted
+				if err := svc.RemoveImport(context.Background(), cfg.ApplicationID, dump.ID); err != nil {
+					cmdutil.PrintE("failed to remove the upload: " + err.Error())
+				}
+				return
+			}
+
+			resp, err := svc.ImportDatabase(ctx, cfg.ApplicationID, dump.ID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the database")
+	cmd.Flags().StringVar(&params.StorageEngine, "engine", "", "Storage engine to import into, required when the application has more than one")
+	cmd.Flags().BoolVar(&params.Wipe, "wipe", false, "Take a backup then delete the data of the database before the import")
+	return cmd
+}
+
+// upload sends a dump in chunks, a chunk that fails is sent again from the same offset
+func upload(ctx context.Context, svc api.Service, applicationID, importID uuid.UUID, r io.Reader, size int64) error {
+	buf := make([]byte, chunkSize)
+	var offset, reported int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n == 0 {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		var uploadErr error
+		for attempt := 1; attempt <= chunkAttempts; attempt++ {
+			_, uploadErr = svc.UploadImportChunk(ctx, applicationID, importID, offset, bytes.NewReader(buf[:n]))
+			if uploadErr == nil || ctx.Err() != nil {
+				break
+			}
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if uploadErr != nil {
+			return fmt.Errorf("failed to upload dump: %w", uploadErr)
+		}
+
+		offset += int64(n)
+		if percent := offset * 100 / max(size, 1); percent >= reported+10 || offset == size {
+			reported = percent - percent%10
+			cmdutil.Print(fmt.Sprintf("Uploaded %d%% (%s of %s)", percent, api.FormatSize(offset), api.FormatSize(size)))
+		}
+	}
+	return nil
+}
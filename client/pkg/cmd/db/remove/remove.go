@@ -0,0 +1,74 @@
+package remove
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewRemoveCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.RemoveStorageEngineParams{}
+	cmd := &cobra.Command{
+		Use:   "remove <engine>",
+		Short: "Remove a storage engine from the application",
+		Long: "Remove a storage engine from every environment of the application. Its variables are removed and the backends deployed again without them. " +
+			"The data of the engine is deleted, unless --keep-data is set, in which case adding the engine again brings it back.",
+		Example: `sarabi db remove mongo
+sarabi db remove mongo --keep-data`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			params.StorageEngine = args[0]
+
+			label := "The data of " + params.StorageEngine + " will be deleted in every environment, continue?"
+			if params.KeepData {
+				label = params.StorageEngine + " will be removed from every environment, continue?"
+			}
+			ok, err := cmdutil.Confirm(label)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			if !ok {
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.RemoveStorageEngine(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&params.KeepData, "keep-data", false, "Keep the data of the engine so that adding it again brings it back")
+	return cmd
+}
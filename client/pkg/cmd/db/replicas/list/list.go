@@ -0,0 +1,62 @@
+package list
+
+import (
+	"fmt"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+)
+
+func NewListCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the read replicas of an environment",
+		Long:    "List the read replicas of the databases of an environment with their replication state and how far behind they are.",
+		Example: "sarabi db replicas list --env prod",
+		Run: func(cmd *cobra.Command, args []string) {
+			if environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			replicas, err := svc.ListReplicas(cmd.Context(), cfg.ApplicationID, environment)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			tw := table.NewWriter()
+			tw.AppendHeader(table.Row{"Name", "Container", "Status", "State", "Lag", "Lag Size"})
+			tw.SetStyle(table.StyleLight)
+			tw.AppendSeparator()
+
+			for _, replica := range replicas {
+				state := replica.State
+				if state == "" {
+					state = "-"
+				}
+				tw.AppendRow(table.Row{
+					replica.Name,
+					replica.Container,
+					replica.Status,
+					state,
+					fmt.Sprintf("%.1fs", replica.LagSeconds),
+					api.FormatSize(replica.LagBytes),
+				})
+				tw.AppendSeparator()
+			}
+
+			cmdutil.Print("")
+			cmdutil.Print(tw.Render())
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment of the database")
+	return cmd
+}
@@ -0,0 +1,23 @@
+package replicas
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/db/replicas/list"
+	"sarabi/client/pkg/cmd/db/replicas/set"
+)
+
+func NewReplicasCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replicas <command>",
+		Short: "Manage databases read replicas",
+		Long:  "Read replicas stream the data of a database and serve reads, applications reach them with POSTGRES_READ_URL",
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	cmd.AddCommand(set.NewSetCmd(svc, cfg))
+	cmd.AddCommand(list.NewListCmd(svc, cfg))
+	return cmd
+}
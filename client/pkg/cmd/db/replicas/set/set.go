@@ -0,0 +1,74 @@
+package set
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strconv"
+	"strings"
+)
+
+func NewSetCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.ScaleReplicasParams{}
+	cmd := &cobra.Command{
+		Use:   "set <count>",
+		Short: "Set how many read replicas a database has",
+		Long: "Run hot standby replicas of a database streaming from it on the network of the environment. " +
+			"New replicas copy the data of the database, replicas above count are removed with their data. " +
+			"The backends are deployed again with POSTGRES_READ_URL pointing at the replicas, or at the database when there are none.",
+		Example: `sarabi db replicas set 1 --env prod
+sarabi db replicas set 0 --env prod`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			count, err := strconv.Atoi(args[0])
+			if err != nil || count < 0 {
+				cmdutil.PrintE("Please specify a valid number of replicas")
+				return
+			}
+			params.Replicas = count
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.ScaleReplicas(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the database")
+	cmd.Flags().StringVar(&params.StorageEngine, "engine", "", "Storage engine to replicate, postgres when left out")
+	return cmd
+}
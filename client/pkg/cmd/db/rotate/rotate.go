@@ -0,0 +1,64 @@
+package rotate
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewRotateCredentialsCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.RotateCredentialsParams{}
+	cmd := &cobra.Command{
+		Use:   "rotate-credentials",
+		Short: "Rotate the credentials of a database",
+		Long: "Create new credentials for a storage engine of an environment, deploy the backend again with them and revoke the previous ones. " +
+			"The previous credentials keep working until the backend runs with the new ones.",
+		Example: `sarabi db rotate-credentials --env prod
+sarabi db rotate-credentials --env prod --engine redis`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.RotateCredentials(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment whose credentials are rotated")
+	cmd.Flags().StringVar(&params.StorageEngine, "engine", "", "Storage engine whose credentials are rotated, required when the application has more than one")
+	return cmd
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package shell
+
+import (
+	"context"
+	"sarabi/internal/tunnel"
+)
+
+// watchResize does nothing on windows, it has no resize signal. the shell keeps the size it started with
+func watchResize(ctx context.Context, shell *tunnel.Shell, fd int) {}
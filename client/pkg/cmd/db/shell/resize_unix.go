@@ -0,0 +1,32 @@
+//go:build !windows
+
+package shell
+
+import (
+	"context"
+	"golang.org/x/term"
+	"os"
+	"os/signal"
+	"sarabi/internal/tunnel"
+	"syscall"
+)
+
+// watchResize sends the new size of the terminal to the shell whenever it is resized
+func watchResize(ctx context.Context, shell *tunnel.Shell, fd int) {
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	defer signal.Stop(resized)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-resized:
+			cols, rows, err := term.GetSize(fd)
+			if err != nil {
+				continue
+			}
+			_ = shell.Resize(tunnel.Size{Rows: uint(rows), Cols: uint(cols)})
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"sarabi/internal/tunnel"
+)
+
+func NewShellCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.DatabaseShellParams{}
+	cmd := &cobra.Command{
+		Use:   "shell",
+		Short: "Open the native client of a database",
+		Long: "Start the native client of a storage engine of an environment inside its container, logged in with the stored credentials: " +
+			"psql for postgres, mysql for mysql, mongosh for mongo and redis-cli for redis. RabbitMQ and MinIO have no interactive client, " +
+			"a shell is opened with rabbitmqctl or mc, the latter set up with the alias local, and --command runs one of them. " +
+			"The client is attached to this terminal, with --command a single command is run and its output printed instead.",
+		Example: `sarabi db shell --env prod
+sarabi db shell --env prod --engine redis
+sarabi db shell --env prod --command "select count(*) from users"`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if params.Command != "" {
+				ws, err := svc.DatabaseShell(ctx, cfg.ApplicationID, params)
+				if err != nil {
+					cmdutil.PrintE(err.Error())
+					return
+				}
+
+				_, err = io.Copy(os.Stdout, tunnel.NewShell(ws))
+				exit(err)
+				return
+			}
+
+			stdin := int(os.Stdin.Fd())
+			if !term.IsTerminal(stdin) {
+				cmdutil.PrintE("An interactive shell needs a terminal, use --command to run a single command")
+				return
+			}
+
+			params.Cols, params.Rows, _ = term.GetSize(int(os.Stdout.Fd()))
+			ws, err := svc.DatabaseShell(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			// keys like Ctrl+C go to the database client instead of stopping the cli
+			state, err := term.MakeRaw(stdin)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			shell := tunnel.NewShell(ws)
+			go watchResize(ctx, shell, int(os.Stdout.Fd()))
+			go func() {
+				_, _ = io.Copy(shell, os.Stdin)
+			}()
+
+			_, err = io.Copy(os.Stdout, shell)
+			_ = term.Restore(stdin, state)
+			exit(err)
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the database")
+	cmd.Flags().StringVar(&params.StorageEngine, "engine", "", "Storage engine to open, required when the application has more than one")
+	cmd.Flags().StringVarP(&params.Command, "command", "c", "", "Run a single command and print its output")
+	return cmd
+}
+
+// exit ends the cli with the exit code of the database client, the session ends with it
+func exit(err error) {
+	code, ok := tunnel.ExitCode(err)
+	if !ok {
+		if err != nil && !errors.Is(err, io.EOF) {
+			cmdutil.PrintE(err.Error())
+		}
+		os.Exit(1)
+	}
+
+	if code != 0 {
+		os.Exit(code)
+	}
+}
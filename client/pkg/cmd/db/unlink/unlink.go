@@ -0,0 +1,65 @@
+package unlink
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewUnlinkCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.UnlinkDatabaseParams{}
+	cmd := &cobra.Command{
+		Use:   "unlink <engine>",
+		Short: "Unlink a storage engine of an environment from its external database",
+		Long: "Forget the external database a storage engine of an environment is linked to, nothing is deleted from it. " +
+			"The engine runs in a container again with the data and credentials it had before it was linked, " +
+			"and the backends are deployed again with its variables.",
+		Example: `sarabi db unlink postgres --env prod`,
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+			params.StorageEngine = args[0]
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.UnlinkDatabase(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the database")
+	return cmd
+}
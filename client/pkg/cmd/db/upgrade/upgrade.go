@@ -0,0 +1,79 @@
+package upgrade
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewUpgradeCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.UpgradeDatabaseParams{}
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade a database to another version",
+		Long: "Upgrade a storage engine of an environment to another version. A backup is taken first, the data is then copied into a new container running the target version and checked before it takes over. " +
+			"The backend is stopped while the data is copied. The previous version is put back if anything goes wrong.",
+		Example: `sarabi db upgrade --env staging --to 17
+sarabi db upgrade --env staging --engine mysql --to 9.2.0`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			if params.Version == "" {
+				cmdutil.PrintE("Please specify the version to upgrade to")
+				return
+			}
+
+			ok, err := cmdutil.Confirm("The backend of " + params.Environment + " will be stopped during the upgrade, continue?")
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			if !ok {
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.UpgradeDatabase(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment you want to upgrade")
+	cmd.Flags().StringVar(&params.StorageEngine, "engine", "", "Storage engine to upgrade, required when the application has more than one")
+	cmd.Flags().StringVar(&params.Version, "to", "", "The version to upgrade to")
+	return cmd
+}
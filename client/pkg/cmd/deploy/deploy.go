@@ -3,6 +3,7 @@ package deploy
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/cobra"
 	"io"
@@ -13,6 +14,8 @@ import (
 	"sarabi/client/internal/cmdutil"
 	"sarabi/client/internal/config"
 	"sarabi/internal/bundler"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -20,24 +23,34 @@ func NewDeployCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command
 	deployParams := &api.DeployParams{
 		Instances:     1,
 		ApplicationID: cfg.ApplicationID,
+		Visibility:    cfg.Visibility,
 	}
 	mValidator := validator.New(validator.WithRequiredStructEnabled())
+	var services []string
 
 	cmd := &cobra.Command{
 		Use:     "deploy",
 		Short:   "Deploy an application",
 		Long:    "Deploy an application on your server via sarabi.",
-		Example: "sarabi deploy --env <environment> --replicas <number_of_replicas>",
+		Example: "sarabi deploy --env <environment> --replicas <number_of_replicas>\nsarabi deploy --env <environment> --service api --service gateway",
 		Run: func(cmd *cobra.Command, args []string) {
 			if err := mValidator.Struct(deployParams); err != nil {
 				cmdutil.PrintE(err.Error())
 				return
 			}
+			setProcesses(deployParams, cfg, cmd.Flags().Changed("replicas"))
+			if err := setServices(deployParams, cfg, services); err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			// --service deploys the services given and nothing else
+			deployAll := len(services) == 0
 
 			cmdutil.StartLoading("Bundling...")
 			tmpFePath := ""
 			tmpBePath := ""
-			if cfg.Frontend != "" {
+			if cfg.Frontend != "" && deployAll {
 				tmpFePath = filepath.Join(os.Getenv("HOME"), "tmp", "frontend.tar.gz")
 				if err := bundler.Gzip(cfg.Frontend, tmpFePath); err != nil {
 					cmdutil.PrintE("failed to bundle frontend: " + err.Error())
@@ -45,7 +58,7 @@ func NewDeployCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command
 				}
 			}
 
-			if cfg.Backend != "" {
+			if cfg.Backend != "" && deployAll {
 				tmpBePath = filepath.Join(os.Getenv("HOME"), "tmp", "backend.tar.gz")
 				if err := bundler.Gzip(cfg.Backend, tmpBePath); err != nil {
 					cmdutil.PrintE("failed to bundle backend: " + err.Error())
@@ -72,6 +85,22 @@ func NewDeployCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command
 				}
 			}
 
+			serviceArtifacts := make(map[string]io.Reader, len(deployParams.Services))
+			for _, next := range deployParams.Services {
+				tmpPath := filepath.Join(os.Getenv("HOME"), "tmp", "service-"+next.Name+".tar.gz")
+				if err := bundler.Gzip(cfg.Services[next.Name].Path, tmpPath); err != nil {
+					cmdutil.PrintE("failed to bundle service " + next.Name + ": " + err.Error())
+					return
+				}
+				defer os.Remove(tmpPath)
+
+				serviceArtifacts[next.Name], err = os.Open(tmpPath)
+				if err != nil {
+					cmdutil.PrintE("failed to bundle service " + next.Name + ": " + err.Error())
+					return
+				}
+			}
+
 			defer func() {
 				if tmpBePath != "" {
 					_ = os.Remove(tmpBePath)
@@ -86,7 +115,7 @@ func NewDeployCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command
 			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 			defer cancel()
 
-			resp, err := svc.Deploy(ctx, frontend, backend, *deployParams)
+			resp, err := svc.Deploy(ctx, frontend, backend, serviceArtifacts, *deployParams)
 			if err != nil {
 				cmdutil.PrintE(err.Error())
 				return
@@ -105,9 +134,74 @@ func NewDeployCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command
 
 	cmd.Flags().StringVarP(&deployParams.Environment, "env", "e", "", "Environment you're targeting for deployment")
 	cmd.Flags().IntVarP(&deployParams.Instances, "replicas", "i", 1, "Total number of replicas to run")
+	cmd.Flags().StringSliceVarP(&services, "service", "s", nil, "Named service of .sarabi.yml to deploy, every service, the frontend and the backend are deployed when it is not set")
 	return cmd
 }
 
+// setServices sends the named services of .sarabi.yml, only the ones in names when it is not empty
+func setServices(params *api.DeployParams, cfg config.ApplicationConfig, names []string) error {
+	if len(names) == 0 {
+		for name := range cfg.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	for _, name := range names {
+		next, ok := cfg.Services[name]
+		if !ok {
+			return fmt.Errorf("unknown service: %s, it is not in %s", name, config.Path)
+		}
+		if next.Path == "" {
+			return fmt.Errorf("service %s has no path", name)
+		}
+
+		service := api.ServiceParams{
+			Name:       name,
+			Replicas:   1,
+			Dockerfile: next.Dockerfile,
+			Domains:    next.Domains,
+			Variables:  next.Variables,
+			Visibility: next.Visibility,
+		}
+		if next.Port != 0 {
+			service.Port = strconv.Itoa(next.Port)
+		}
+		if next.Replicas != nil {
+			service.Replicas = *next.Replicas
+		}
+		params.Services = append(params.Services, service)
+	}
+	return nil
+}
+
+// setProcesses sends the process types of .sarabi.yml, the replicas of web are the replicas of the deployment
+// unless --replicas is given
+func setProcesses(params *api.DeployParams, cfg config.ApplicationConfig, replicasSet bool) {
+	if len(cfg.Processes) == 0 {
+		return
+	}
+
+	params.Processes = make(map[string]api.Process, len(cfg.Processes))
+	for name, next := range cfg.Processes {
+		process := api.Process{Command: next.Command, Schedule: next.Schedule, Replicas: 1}
+		if next.Replicas != nil {
+			process.Replicas = *next.Replicas
+		}
+
+		switch name {
+		case "web":
+			if !replicasSet && next.Replicas != nil {
+				params.Instances = *next.Replicas
+			}
+			process.Replicas = 0
+		case "cron":
+			process.Replicas = 0
+		}
+		params.Processes[name] = process
+	}
+}
+
 func handleDeployEvent(ev api.Event, cancel context.CancelFunc) {
 	switch ev.Type {
 	case api.Info:
@@ -131,5 +225,13 @@ func handleDeployEvent(ev api.Event, cancel context.CancelFunc) {
 		if len(resp.AccessURL.Frontend) > 0 {
 			cmdutil.Print("Frontend: " + strings.Join(resp.AccessURL.Frontend, " | "))
 		}
+		names := make([]string, 0, len(resp.AccessURL.Services))
+		for name := range resp.AccessURL.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cmdutil.Print(name + ": " + strings.Join(resp.AccessURL.Services[name], " | "))
+		}
 	}
 }
@@ -32,7 +32,7 @@ func NewListDeploymentsCmd(svc api.Service, cfg config.ApplicationConfig) *cobra
 			}
 
 			writer := table.NewWriter()
-			writer.AppendHeader(table.Row{"Identifier", "Name", "Environment", "Instance Type", "Instances", "Status", "Created Time"})
+			writer.AppendHeader(table.Row{"Identifier", "Name", "Environment", "Instance Type", "Instances", "Status", "Health", "Created Time"})
 			for _, dep := range deps {
 				row := table.Row{
 					dep.Identifier,
@@ -41,6 +41,7 @@ func NewListDeploymentsCmd(svc api.Service, cfg config.ApplicationConfig) *cobra
 					dep.InstanceType,
 					dep.Instances,
 					dep.Status,
+					health(dep),
 					dep.CreatedAt.Format("2006-02-01"),
 				}
 				writer.AppendRow(row)
@@ -88,3 +89,11 @@ func listDeployments(svc api.Service, applicationID uuid.UUID, instance, environ
 
 	return deps, nil
 }
+
+// health is only reported for databases, the other instance types show a placeholder
+func health(dep api.Deployment) string {
+	if dep.Health == "" {
+		return "-"
+	}
+	return dep.Health
+}
@@ -3,58 +3,72 @@ package destroy
 import (
 	"context"
 	"github.com/google/uuid"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"sarabi/client/internal/api"
 	"sarabi/client/internal/cmdutil"
 	"sarabi/client/internal/config"
-	"sarabi/internal/misc"
 	"time"
 )
 
 func NewDestroyDeploymentCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
 	var environment string
+	params := &api.DestroyParams{}
 	cmd := &cobra.Command{
 		Use:     "destroy",
 		Short:   "Destroy/Take down a deployment",
-		Long:    "Destroy/Take down a deployment on your sarabi server. If you specify the 'environment' flag, the deploy for this specific environment will be taken down or else all the deployment associated with this app will be destroyed/taken down",
-		Example: "sarabi deploy destroy --env <environment>",
+		Long:    "Destroy/Take down a deployment on your sarabi server. If you specify the 'environment' flag, the deploy for this specific environment will be taken down or else all the deployment associated with this app will be destroyed/taken down. Scheduled backups of the destroyed environment(s) are removed, you will be asked whether to take a final backup and, without one, whether to keep the existing ones. The volumes of the backends are deleted with their data unless they are kept",
+		Example: "sarabi deploy destroy --env <environment> [--final-backup] [--delete-backups] [--keep-volumes]",
 		Run: func(cmd *cobra.Command, args []string) {
 			if environment == "" {
-				p := promptui.Prompt{
-					Label:     "Are you sure you want to take down all the deployment for this application?",
-					IsConfirm: true,
-				}
-				result, err := p.Run()
+				ok, err := cmdutil.Confirm("Are you sure you want to take down all the deployment for this application?")
 				if err != nil {
 					cmdutil.PrintE(err.Error())
 					return
 				}
+				if !ok {
+					return
+				}
+			}
+			params.Environment = environment
 
-				if misc.StrContains(result, []string{"Yes", "yes", "y"}) {
-					if err := runDestroy(svc, cfg.ApplicationID, ""); err != nil {
-						cmdutil.PrintE(err.Error())
-						return
-					} else {
-						cmdutil.PrintS("Operation succeeded!")
-					}
+			if !cmd.Flags().Changed("final-backup") {
+				ok, err := cmdutil.Confirm("Take a final backup of the databases before destroying?")
+				if err != nil {
+					cmdutil.PrintE(err.Error())
+					return
 				}
-			} else {
-				err := runDestroy(svc, cfg.ApplicationID, environment)
+				params.FinalBackup = ok
+			}
+
+			// the final backup would be deleted with them
+			if !params.FinalBackup && !cmd.Flags().Changed("delete-backups") {
+				ok, err := cmdutil.Confirm("Delete the existing backups too?")
 				if err != nil {
 					cmdutil.PrintE(err.Error())
-				} else {
-					cmdutil.PrintS("Operation succeeded!")
+					return
 				}
+				params.DeleteBackups = ok
+			}
+
+			cmdutil.StartLoading("Destroying...")
+			defer cmdutil.StopLoading()
+
+			if err := runDestroy(svc, cfg.ApplicationID, *params); err != nil {
+				cmdutil.PrintE(err.Error())
+				return
 			}
+			cmdutil.PrintS("Operation succeeded!")
 		},
 	}
 	cmd.Flags().StringVarP(&environment, "env", "e", "", "The environment you want to destroy its resources. WARNING: empty value means all deployment in all environments will be destroyed")
+	cmd.Flags().BoolVar(&params.FinalBackup, "final-backup", false, "Take a final backup of the databases before they are destroyed")
+	cmd.Flags().BoolVar(&params.DeleteBackups, "delete-backups", false, "Delete the existing backups of the destroyed environment(s)")
+	cmd.Flags().BoolVar(&params.KeepVolumes, "keep-volumes", false, "Keep the volumes of the backends, deploying the environment again mounts them with their data")
 	return cmd
 }
 
-func runDestroy(svc api.Service, applicationID uuid.UUID, environment string) error {
+func runDestroy(svc api.Service, applicationID uuid.UUID, params api.DestroyParams) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
-	return svc.Destroy(ctx, applicationID, environment)
+	return svc.Destroy(ctx, applicationID, params)
 }
@@ -0,0 +1,102 @@
+package clonedb
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewCloneDbCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.CloneDatabaseParams{}
+	cmd := &cobra.Command{
+		Use:   "clone-db",
+		Short: "Copy the database of an environment into another",
+		Long: "Take a fresh dump of the storage engines of an environment and restore it into another environment, " +
+			"replacing its data. The anonymise rules of " + config.Path + " are applied before the data reaches the target, " +
+			"each rule names a table and a column, a collection and a dotted field for mongo, and a strategy: " +
+			"hash, null or fake (email, name, phone or text). A backup of the target is taken first.",
+		Example: `sarabi env clone-db --from prod --to staging
+sarabi env clone-db --from prod --to staging --engine postgres
+
+# .sarabi.yml
+anonymise:
+  - table: users
+    column: email
+    strategy: fake
+    fake: email
+  - table: users
+    column: password_hash
+    strategy: hash`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if params.From == "" || params.To == "" {
+				cmdutil.PrintE("Please specify the --from and --to environments")
+				return
+			}
+
+			params.Rules = make([]api.AnonymisationRule, 0, len(cfg.Anonymise))
+			for _, rule := range cfg.Anonymise {
+				params.Rules = append(params.Rules, api.AnonymisationRule{
+					StorageEngine: rule.Engine,
+					Table:         rule.Table,
+					Column:        rule.Column,
+					Strategy:      rule.Strategy,
+					Fake:          rule.Fake,
+				})
+			}
+
+			label := fmt.Sprintf("The data of the database in %s will be replaced by the data of %s, continue?", params.To, params.From)
+			if len(params.Rules) == 0 {
+				label = fmt.Sprintf("No anonymise rules in %s, the data of %s will be copied as it is. %s", config.Path, params.From, label)
+			}
+			ok, err := cmdutil.Confirm(label)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			if !ok {
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.CloneDatabase(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&params.From, "from", "", "Environment to copy the database from")
+	cmd.Flags().StringVar(&params.To, "to", "", "Environment whose database is replaced")
+	cmd.Flags().StringVar(&params.StorageEngine, "engine", "", "Storage engine to clone, all of them when left out")
+	return cmd
+}
@@ -0,0 +1,21 @@
+package env
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/env/clonedb"
+)
+
+func NewEnvCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env <command>",
+		Short: "Manage sarabi applications environments",
+		Long:  "Work across the environments of an application, e.g copy the database of one environment into another",
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	cmd.AddCommand(clonedb.NewCloneDbCmd(svc, cfg))
+	return cmd
+}
@@ -0,0 +1,64 @@
+package add
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+	"time"
+)
+
+func NewAddCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.AddJobParams{}
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "add <name> --cron <expression> -- <command>",
+		Short: "Schedule a job",
+		Long: "Schedule a command to run in a container of the image of the active backend of an environment. " +
+			"A job of the same name is replaced. The command is run as it is, without a shell. " +
+			"--concurrency decides what happens when the job is due while its previous run has not exited: " +
+			"forbid skips the new run, replace stops the previous run and allow runs both.",
+		Example: `sarabi jobs add nightly-report --cron "0 2 * * *" --env prod -- ./bin/report
+sarabi jobs add sync --cron "*/5 * * * *" --env prod --timeout 4m --concurrency replace -- ./bin/sync --full`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			dash := cmd.ArgsLenAtDash()
+			if dash != 1 {
+				cmdutil.PrintE("Please specify the name of the job followed by -- and its command")
+				return
+			}
+			params.Name = args[0]
+			params.Command = args[dash:]
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+			if params.Schedule == "" {
+				cmdutil.PrintE("Please specify a cron expression with --cron")
+				return
+			}
+			params.Timeout = int(timeout.Seconds())
+
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			job, err := svc.AddJob(cmd.Context(), cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			cmdutil.PrintS(job.Name + " scheduled in " + job.Environment + ": " + job.Schedule + " " + strings.Join(job.Command, " "))
+			if job.NextRun != nil {
+				cmdutil.Print("Next run: " + job.NextRun.Local().Format("2006-01-02 15:04:05"))
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the backend")
+	cmd.Flags().StringVar(&params.Schedule, "cron", "", "Cron expression the job runs on e.g \"0 2 * * *\"")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "How long a run may take before it is stopped, 1h when it is not set")
+	cmd.Flags().StringVar(&params.ConcurrencyPolicy, "concurrency", "forbid", "What happens when the previous run has not exited: forbid, replace or allow")
+	return cmd
+}
@@ -0,0 +1,89 @@
+package history
+
+import (
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strconv"
+	"strings"
+)
+
+// outputPreview is how much of the last line of the output of a run is shown in the table
+const outputPreview = 60
+
+func NewHistoryCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	var limit int
+	var logs bool
+	cmd := &cobra.Command{
+		Use:   "history <name>",
+		Short: "List the last runs of a job",
+		Long: "List the last runs of a job with their exit code and how long they took, the latest first. " +
+			"The table shows the last line of the output of every run, --logs prints all of it.",
+		Example: `sarabi jobs history nightly-report --env prod
+sarabi jobs history nightly-report --env prod --limit 1 --logs`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			cmdutil.StartLoading("Working...")
+			runs, err := svc.JobHistory(cmd.Context(), cfg.ApplicationID, environment, args[0], limit)
+			cmdutil.StopLoading()
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			if logs {
+				for _, run := range runs {
+					cmdutil.Print("")
+					cmdutil.Print("=== " + run.StartedAt.Local().Format("2006-01-02 15:04:05") + " " + run.Status + " (exit code " + strconv.FormatInt(run.ExitCode, 10) + ", " + run.Duration() + ")")
+					cmdutil.Print(strings.TrimRight(run.Output, "\n"))
+				}
+				return
+			}
+
+			tw := table.NewWriter()
+			tw.AppendHeader(table.Row{"Started At", "Status", "Exit Code", "Duration", "Output"})
+			tw.SetStyle(table.StyleLight)
+			tw.AppendSeparator()
+
+			for _, run := range runs {
+				exitCode := "-"
+				if run.ExitCode >= 0 && run.Status != "running" {
+					exitCode = strconv.FormatInt(run.ExitCode, 10)
+				}
+				tw.AppendRow(table.Row{
+					run.StartedAt.Local().Format("2006-01-02 15:04:05"),
+					run.Status,
+					exitCode,
+					run.Duration(),
+					lastLine(run.Output),
+				})
+				tw.AppendSeparator()
+			}
+
+			cmdutil.Print("")
+			cmdutil.Print(tw.Render())
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment of the job")
+	cmd.Flags().IntVar(&limit, "limit", 20, "How many runs are listed")
+	cmd.Flags().BoolVar(&logs, "logs", false, "Print the output of every run")
+	return cmd
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	line := lines[len(lines)-1]
+	if len(line) > outputPreview {
+		return line[:outputPreview] + "..."
+	}
+	return line
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/jobs/add"
+	"sarabi/client/pkg/cmd/jobs/history"
+	"sarabi/client/pkg/cmd/jobs/list"
+	"sarabi/client/pkg/cmd/jobs/remove"
+)
+
+func NewJobsCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "jobs <command>",
+		Short: "Manage the scheduled jobs of an application",
+		Long:  "Jobs run a command on a cron schedule in a container of the image of the active backend, with the variables of the backend. The container is removed once the command exited",
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	cmd.AddCommand(add.NewAddCmd(svc, cfg))
+	cmd.AddCommand(list.NewListCmd(svc, cfg))
+	cmd.AddCommand(remove.NewRemoveCmd(svc, cfg))
+	cmd.AddCommand(history.NewHistoryCmd(svc, cfg))
+	return cmd
+}
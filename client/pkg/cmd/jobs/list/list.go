@@ -0,0 +1,59 @@
+package list
+
+import (
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+	"time"
+)
+
+func NewListCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the jobs of an application",
+		Long:    "List the jobs of an environment with the time they run next, the ones of every environment when --env is left out.",
+		Example: "sarabi jobs list --env prod",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			jobs, err := svc.ListJobs(cmd.Context(), cfg.ApplicationID, environment)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			tw := table.NewWriter()
+			tw.AppendHeader(table.Row{"Name", "Environment", "Schedule", "Command", "Timeout", "Concurrency", "Next Run"})
+			tw.SetStyle(table.StyleLight)
+			tw.AppendSeparator()
+
+			for _, job := range jobs {
+				nextRun := "-"
+				if job.NextRun != nil {
+					nextRun = job.NextRun.Local().Format("2006-01-02 15:04:05")
+				}
+				tw.AppendRow(table.Row{
+					job.Name,
+					job.Environment,
+					job.Schedule,
+					strings.Join(job.Command, " "),
+					(time.Duration(job.Timeout) * time.Second).String(),
+					job.ConcurrencyPolicy,
+					nextRun,
+				})
+				tw.AppendSeparator()
+			}
+
+			cmdutil.Print("")
+			cmdutil.Print(tw.Render())
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment of the jobs")
+	return cmd
+}
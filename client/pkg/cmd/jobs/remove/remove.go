@@ -0,0 +1,46 @@
+package remove
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+)
+
+func NewRemoveCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	cmd := &cobra.Command{
+		Use:     "remove <name>",
+		Short:   "Remove a job",
+		Long:    "Stop scheduling a job and delete the history of its runs. A run in progress is left to complete.",
+		Example: "sarabi jobs remove nightly-report --env prod",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			ok, err := cmdutil.Confirm(args[0] + " and its history will be removed from " + environment + ", continue?")
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			if !ok {
+				return
+			}
+
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			if err := svc.RemoveJob(cmd.Context(), cfg.ApplicationID, environment, args[0]); err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			cmdutil.PrintS(args[0] + " removed from " + environment)
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment of the job")
+	return cmd
+}
@@ -13,18 +13,19 @@ import (
 func NewScaleAppCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
 	var environment string
 	var replicas int
+	var process string
 	cmd := &cobra.Command{
 		Use:     "scale",
 		Short:   "Scale backend instances",
-		Long:    "Use this command to increase/decrease the number of running backend instances for a specific app in your sarabi server",
-		Example: "sarabi scale --env <environment> --replicas <number_of_replicas>",
+		Long:    "Use this command to increase/decrease the number of running backend instances for a specific app in your sarabi server. The web replicas are scaled unless --process worker is given, workers can be scaled down to 0",
+		Example: "sarabi scale --env <environment> --replicas <number_of_replicas> --process worker",
 		Run: func(cmd *cobra.Command, args []string) {
 			if environment == "" {
 				cmdutil.PrintE("Please specify environment")
 				return
 			}
 
-			if replicas <= 0 {
+			if replicas < 0 || (replicas == 0 && process == "web") {
 				cmdutil.PrintE("Replicas must be > 0")
 				return
 			}
@@ -38,6 +39,7 @@ func NewScaleAppCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Comman
 			params := api.ScaleAppParams{
 				Count:       replicas,
 				Environment: environment,
+				Process:     process,
 			}
 			err := svc.Scale(ctx, cfg.ApplicationID, params)
 			if err != nil {
@@ -51,5 +53,6 @@ func NewScaleAppCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Comman
 
 	cmd.Flags().StringVarP(&environment, "env", "e", "", "The name of the environment you want to scale")
 	cmd.Flags().IntVarP(&replicas, "replicas", "i", 0, "The number of replicas")
+	cmd.Flags().StringVarP(&process, "process", "p", "web", "The process type you want to scale: web or worker")
 	return cmd
 }
@@ -0,0 +1,71 @@
+package add
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewAddCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.AddVolumeParams{}
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Mount a persistent volume into the backend",
+		Long: "Mount a named volume at --path in every container of the backend of an environment. " +
+			"What the application writes there is kept across deployments, scaling and rollbacks, and is backed up with the databases as a tar snapshot. " +
+			"The backend is deployed again with the volume when it runs.",
+		Example: `sarabi volumes add uploads --path /app/uploads --env prod
+sarabi volumes add data --path /var/lib/app --env staging`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			params.Name = args[0]
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+			if params.Path == "" {
+				cmdutil.PrintE("Please specify the path to mount the volume at")
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.AddVolume(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the backend")
+	cmd.Flags().StringVar(&params.Path, "path", "", "Path in the backend containers to mount the volume at")
+	return cmd
+}
@@ -0,0 +1,55 @@
+package list
+
+import (
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+)
+
+func NewListCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	var environment string
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the volumes of the backend",
+		Long:    "List the volumes of an environment with how much they hold, the ones of every environment when --env is left out.",
+		Example: "sarabi volumes list --env prod",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.StartLoading("Working...")
+			defer cmdutil.StopLoading()
+
+			volumes, err := svc.ListVolumes(cmd.Context(), cfg.ApplicationID, environment)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			tw := table.NewWriter()
+			tw.AppendHeader(table.Row{"Name", "Environment", "Path", "Size", "Created At"})
+			tw.SetStyle(table.StyleLight)
+			tw.AppendSeparator()
+
+			for _, volume := range volumes {
+				size := "-"
+				if volume.Size >= 0 {
+					size = api.FormatSize(volume.Size)
+				}
+				tw.AppendRow(table.Row{
+					volume.Name,
+					volume.Environment,
+					volume.Path,
+					size,
+					volume.CreatedAt.Format("2006-01-02 15:04:05"),
+				})
+				tw.AppendSeparator()
+			}
+
+			cmdutil.Print("")
+			cmdutil.Print(tw.Render())
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "env", "e", "", "Environment of the volumes")
+	return cmd
+}
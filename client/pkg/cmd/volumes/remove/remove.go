@@ -0,0 +1,79 @@
+package remove
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/cmdutil"
+	"sarabi/client/internal/config"
+	"strings"
+)
+
+func NewRemoveCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	params := api.RemoveVolumeParams{}
+	cmd := &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a volume from the backend of an environment",
+		Long: "Remove a volume from the backend of an environment. The backend is deployed again without it. " +
+			"The data of the volume is deleted, unless --keep-data is set, in which case adding it again brings it back. Its backups are kept.",
+		Example: `sarabi volumes remove uploads --env prod
+sarabi volumes remove uploads --env prod --keep-data`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			params.Name = args[0]
+			if params.Environment == "" {
+				cmdutil.PrintE("Please specify environment")
+				return
+			}
+
+			label := "The data of " + params.Name + " in " + params.Environment + " will be deleted, continue?"
+			if params.KeepData {
+				label = params.Name + " will be removed from " + params.Environment + ", continue?"
+			}
+			ok, err := cmdutil.Confirm(label)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+			if !ok {
+				return
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+
+			resp, err := svc.RemoveVolume(ctx, cfg.ApplicationID, params)
+			if err != nil {
+				cmdutil.PrintE(err.Error())
+				return
+			}
+
+			for {
+				select {
+				case ev := <-resp:
+					switch ev.Type {
+					case api.Info:
+						cmdutil.Print(strings.Trim(ev.Message, "\n"))
+					case api.Error:
+						cmdutil.PrintE(strings.Trim(ev.Message, "\n"))
+					case api.Success, api.Complete:
+						if ev.Message != "" {
+							cmdutil.PrintS(strings.Trim(ev.Message, "\n"))
+						}
+						if ev.Type == api.Complete {
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&params.Environment, "env", "e", "", "Environment of the backend")
+	cmd.Flags().BoolVar(&params.KeepData, "keep-data", false, "Keep the data of the volume")
+	return cmd
+}
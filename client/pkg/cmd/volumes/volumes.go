@@ -0,0 +1,25 @@
+package volumes
+
+import (
+	"github.com/spf13/cobra"
+	"sarabi/client/internal/api"
+	"sarabi/client/internal/config"
+	"sarabi/client/pkg/cmd/volumes/add"
+	"sarabi/client/pkg/cmd/volumes/list"
+	"sarabi/client/pkg/cmd/volumes/remove"
+)
+
+func NewVolumesCmd(svc api.Service, cfg config.ApplicationConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "volumes <command>",
+		Short: "Manage the persistent volumes of the backend",
+		Long:  "Volumes are mounted into every container of the backend of an environment, their data is kept across deployments and backed up with the databases",
+		Run: func(cmd *cobra.Command, args []string) {
+		},
+	}
+
+	cmd.AddCommand(add.NewAddCmd(svc, cfg))
+	cmd.AddCommand(list.NewListCmd(svc, cfg))
+	cmd.AddCommand(remove.NewRemoveCmd(svc, cfg))
+	return cmd
+}
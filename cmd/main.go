@@ -96,11 +96,21 @@ func setup(cfg config.Config) (*http.Server, error, func() error) {
 	backupSettingsRepo := database.NewBackupSettingsRepository(db)
 	credentialRepo := database.NewServerConfigRepository(db)
 	backupRepository := database.NewBackupRepository(db)
-	naRepository := database.NewNetworkAccessRepository(db)
 	logsRepository := database.NewLogsRepository(db)
+	pitrSettingsRepository := database.NewPITRSettingsRepository(db)
+	walSegmentRepository := database.NewWALSegmentRepository(db)
+	backupCopyRepository := database.NewBackupCopyRepository(db)
+	databaseConfigRepository := database.NewDatabaseConfigRepository(db)
+	credentialRotationRepository := database.NewCredentialRotationRepository(db)
+	externalDatabaseRepository := database.NewExternalDatabaseRepository(db)
+	addonRepository := database.NewAddonRepository(db)
+	volumeRepository := database.NewVolumeRepository(db)
+	internalAccessRepository := database.NewInternalAccessRepository(db)
+	jobRepository := database.NewJobRepository(db)
+	jobRunRepository := database.NewJobRunRepository(db)
 
 	encryptor := misc.NewEncryptor(cfg.EncryptionKey)
-	appService := service.NewApplicationService(appRepo, deploymentRepo)
+	appService := service.NewApplicationService(appRepo, deploymentRepo, databaseConfigRepository, externalDatabaseRepository, addonRepository, volumeRepository, internalAccessRepository)
 	secretService := service.NewSecretService(encryptor, secretRepo, deploymentSecretRepo, credentialRepo)
 	domainService := service.NewDomainService(domainRepo)
 	caddyClient := caddy.NewClient(eventBus, domainService)
@@ -113,11 +123,18 @@ func setup(cfg config.Config) (*http.Server, error, func() error) {
 	fm := firewall.NewManager()
 	logsManager := logs.NewManager(docker, appService, logsRepository, secretService, lokiClient, eventBus)
 
-	backupSvc, err := service.NewBackupService(docker, appService, secretService, backupSettingsRepo, backupRepository)
+	scheduler, err := service.NewScheduler()
 	if err != nil {
 		return nil, err, nil
 	}
 
+	backupSvc, err := service.NewBackupService(ctx, docker, appService, secretService, backupSettingsRepo, backupRepository,
+		pitrSettingsRepository, walSegmentRepository, backupCopyRepository, eventBus, scheduler)
+	if err != nil {
+		return nil, err, nil
+	}
+	jobService := service.NewJobService(jobRepository, jobRunRepository, scheduler)
+
 	if err := backupSvc.Run(ctx); err != nil {
 		return nil, err, nil
 	}
@@ -133,7 +150,12 @@ func setup(cfg config.Config) (*http.Server, error, func() error) {
 	}()
 
 	mn := manager.New(appService, secretService, docker, caddyClient,
-		bundler.NewArtifactStore(), domainService, backupSvc, fm, naRepository, eventBus, cfg)
+		bundler.NewArtifactStore(), domainService, backupSvc, jobService, fm, credentialRotationRepository, eventBus, cfg)
+	mn.MigrateDatabases(ctx)
+	go mn.WatchReplicas(ctx)
+	if err := mn.ScheduleJobs(ctx); err != nil {
+		return nil, err, nil
+	}
 	apiHandler := httphandlers.NewApiHandler(mn, logsManager, eventBus, logger.GetLogger())
 	routes := httphandlers.Routes(apiHandler)
 
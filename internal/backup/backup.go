@@ -2,25 +2,56 @@ package backup
 
 import (
 	"context"
+	"fmt"
+	"sarabi/internal/integrations/docker"
 	"sarabi/internal/storage"
 	types "sarabi/internal/types"
 )
 
 type (
 	Params struct {
-		Environment       string
-		DatabaseVars      []*types.Secret
-		StorageCredential *types.StorageCredentials
-		Application       *types.Application
+		Environment  string
+		DatabaseVars []*types.Secret
+		// Destinations are where the backup file is replicated to
+		Destinations []storage.Destination
+		Application  *types.Application
+		// Sidecar is the image the dump tools run in for a database run outside sarabi,
+		// they run in the container of the engine when it is empty
+		Sidecar string
+		// Volume is the volume of the backend a volume executor takes a snapshot of
+		Volume *types.Volume
+		// Portable leaves ownership and grants out of the dump, so it loads as whoever restores it
+		Portable bool
 	}
 
 	Result struct {
-		Location    string
-		StorageType storage.Type
-		Size        int64
+		Location string
+		Size     int64
+		// Copies holds the outcome of saving the backup file into each destination
+		Copies []storage.Copy
 	}
 
 	Executor interface {
 		Execute(ctx context.Context, params Params) (Result, error)
 	}
 )
+
+// NewExecutor returns the executor dumping the given storage engine
+func NewExecutor(se types.StorageEngine, dc docker.Docker) (Executor, error) {
+	switch se {
+	case types.StorageEnginePostgres:
+		return NewPostgres(dc), nil
+	case types.StorageEngineMysql:
+		return NewMysql(dc), nil
+	case types.StorageEngineMongo:
+		return NewMongo(dc), nil
+	case types.StorageEngineRedis:
+		return NewRedis(dc), nil
+	case types.StorageEngineRabbitMQ:
+		return NewRabbitMQ(dc), nil
+	case types.StorageEngineMinio:
+		return NewMinio(dc), nil
+	default:
+		return nil, fmt.Errorf("no backup executor for %s", se)
+	}
+}
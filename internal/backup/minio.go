@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"os"
+	"sarabi/internal/bundler"
+	"sarabi/internal/integrations/docker"
+	storage "sarabi/internal/storage"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"time"
+)
+
+type (
+	minioBackupExecutor struct {
+		dockerClient docker.Docker
+	}
+)
+
+func NewMinio(dc docker.Docker) Executor {
+	return &minioBackupExecutor{dockerClient: dc}
+}
+
+// Execute mirrors every bucket the credentials of the application can list into a directory, one
+// directory per bucket, and saves it as a gzipped tar
+func (m minioBackupExecutor) Execute(ctx context.Context, params Params) (Result, error) {
+	logger.Info("starting minio backup",
+		zap.String("application", params.Application.Name),
+		zap.String("env", params.Environment))
+	accessKey, err := findVar("MINIO_ACCESS_KEY_ID", params.DatabaseVars)
+	if err != nil {
+		return Result{}, err
+	}
+	secretKey, err := findVar("MINIO_SECRET_ACCESS_KEY", params.DatabaseVars)
+	if err != nil {
+		return Result{}, err
+	}
+
+	endpoint := "http://127.0.0.1:9000"
+	containerName := fmt.Sprintf("minio-%s-%s", params.Application.Name, params.Environment)
+	if params.Sidecar != "" {
+		external, err := findVar("MINIO_ENDPOINT", params.DatabaseVars)
+		if err != nil {
+			return Result{}, err
+		}
+		endpoint = external.Value
+
+		sidecar, stop, err := startSidecar(ctx, m.dockerClient, params, types.StorageEngineMinio)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to start backup sidecar")
+		}
+		defer stop()
+		containerName = sidecar
+	}
+
+	resultPath := fmt.Sprintf("/tmp/%s", uuid.NewString())
+	script := `set -e
+mc() { command mc --config-dir /tmp/.mc "$@"; }
+mc alias set backup "$1" "$2" "$3" >/dev/null
+mkdir -p "$0"
+mc ls backup | awk '{print $NF}' | while IFS= read -r bucket; do
+  mc mirror --quiet "backup/$bucket" "$0/$bucket" >/dev/null
+done`
+	_, err = m.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           strslice.StrSlice{"sh", "-c", script, resultPath, endpoint, accessKey.Value, secretKey.Value},
+	})
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to execute mc mirror")
+	}
+	defer func() {
+		_, _ = m.dockerClient.ContainerExec(context.Background(), docker.ContainerExecParams{
+			ContainerName: containerName,
+			Cmd:           strslice.StrSlice{"rm", "-rf", resultPath},
+		})
+	}()
+
+	location := fmt.Sprintf("%s/%s-%s/minio-%s.tar.gz", storage.BackupDir, params.Application.Name, params.Environment, time.Now().Format("2006_01_02_03_04pm"))
+	dmpFile, err := m.dockerClient.CopyFromContainer(ctx, containerName, resultPath)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to copy mirrored buckets")
+	}
+
+	defer func() {
+		_ = dmpFile.Content.Close()
+		_ = os.RemoveAll(dmpFile.Stat.Name)
+	}()
+
+	fi, err := bundler.GzipToReader(dmpFile.Stat.Name)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to gzip file")
+	}
+	defer func() {
+		_ = fi.Close()
+		_ = os.Remove(fi.Name())
+	}()
+
+	stat, err := fi.Stat()
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to get file stat")
+	}
+
+	copies, err := storage.Replicate(ctx, params.Destinations, location, types.File{
+		Content: fi,
+		Stat: types.FileStat{
+			Name: stat.Name(),
+			Size: stat.Size(),
+		},
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Location: location,
+		Copies:   copies,
+		Size:     stat.Size(),
+	}, nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"net/url"
 	"os"
 	"sarabi/internal/bundler"
 	"sarabi/internal/integrations/docker"
@@ -39,20 +40,6 @@ func (m mongoBackupExecutor) Execute(ctx context.Context, params Params) (Result
 		return Result{}, err
 	}
 
-	var st storage.Storage
-	var stType storage.Type
-	if params.StorageCredential == nil {
-		logger.Info("Object storage credential not configured, using File system storage for backup")
-		st = storage.NewFileStorage()
-		stType = storage.TypeFS
-	} else {
-		st, err = storage.NewObjectStorage(*params.StorageCredential)
-		if err != nil {
-			return Result{}, errors.Wrap(err, "invalid object storage credential")
-		}
-		stType = storage.TypeS3
-	}
-
 	resultPath := fmt.Sprintf("tmp/%s.tar", uuid.NewString())
 	//  mongodump -u user -p password --out file.tar
 	cmd := strslice.StrSlice{
@@ -62,9 +49,28 @@ func (m mongoBackupExecutor) Execute(ctx context.Context, params Params) (Result
 		"--out",
 		resultPath,
 	}
+	// a replica set has an oplog, the writes made while the dump runs are kept with it so that it is consistent
+	if databaseUrl, err := findVar("MONGO_DATABASE_URL", params.DatabaseVars); err == nil && replicaSet(databaseUrl.Value) {
+		cmd = append(cmd, "--oplog")
+	}
 
 	location := fmt.Sprintf("%s/%s-%s/mongo-%s.tar", storage.BackupDir, params.Application.Name, params.Environment, time.Now().Format("2006_01_02_03_04pm"))
 	containerName := fmt.Sprintf("mongo-%s-%s", params.Application.Name, params.Environment)
+	if params.Sidecar != "" {
+		databaseUrl, err := findVar("MONGO_DATABASE_URL", params.DatabaseVars)
+		if err != nil {
+			return Result{}, err
+		}
+		// the users of managed databases can seldom read the oplog, it is left out
+		cmd = strslice.StrSlice{"mongodump", "--uri", databaseUrl.Value, "--out", resultPath}
+
+		sidecar, stop, err := startSidecar(ctx, m.dockerClient, params, types.StorageEngineMongo)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to start backup sidecar")
+		}
+		defer stop()
+		containerName = sidecar
+	}
 	_, err = m.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
 		ContainerName: containerName,
 		Cmd:           cmd,
@@ -94,19 +100,26 @@ func (m mongoBackupExecutor) Execute(ctx context.Context, params Params) (Result
 		return Result{}, errors.Wrap(err, "failed to get file stat")
 	}
 
-	if err := st.Save(ctx, location, types.File{
+	copies, err := storage.Replicate(ctx, params.Destinations, location, types.File{
 		Content: fi,
 		Stat: types.FileStat{
 			Name: stat.Name(),
 			Size: stat.Size(),
 		},
-	}); err != nil {
-		return Result{}, errors.Wrap(err, "failed to save file in storage")
+	})
+	if err != nil {
+		return Result{}, err
 	}
 
 	return Result{
-		Location:    location,
-		StorageType: stType,
-		Size:        stat.Size(),
+		Location: location,
+		Copies:   copies,
+		Size:     stat.Size(),
 	}, nil
 }
+
+// replicaSet reports whether a connection URL names a replica set
+func replicaSet(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Query().Get("replicaSet") != ""
+}
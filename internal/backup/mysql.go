@@ -10,6 +10,7 @@ import (
 	"os"
 	"sarabi/internal/integrations/docker"
 	storage "sarabi/internal/storage"
+	"sarabi/internal/types"
 	"sarabi/logger"
 	"time"
 )
@@ -43,24 +44,30 @@ func (m mysqlBackupExecutor) Execute(ctx context.Context, params Params) (Result
 		return Result{}, err
 	}
 
-	var st storage.Storage
-	var stType storage.Type
-	if params.StorageCredential == nil {
-		logger.Info("Object storage credential not configured, using File system storage for backup")
-		st = storage.NewFileStorage()
-		stType = storage.TypeFS
-	} else {
-		st, err = storage.NewObjectStorage(*params.StorageCredential)
+	// sh -c 'mysqldump -u sample-go-stage-user -p"password" mysql-sample-go-stage > /tmp/uuid.sql'
+	resultPath := fmt.Sprintf("/tmp/%s.sql", uuid.NewString())
+	containerName := fmt.Sprintf("mysql-%s-%s", params.Application.Name, params.Environment)
+	var remote string
+	if params.Sidecar != "" {
+		host, err := findVar("MYSQL_HOST", params.DatabaseVars)
 		if err != nil {
-			return Result{}, errors.Wrap(err, "invalid object storage credential")
+			return Result{}, err
 		}
-		stType = storage.TypeS3
-	}
+		port, err := findVar("MYSQL_PORT", params.DatabaseVars)
+		if err != nil {
+			return Result{}, err
+		}
+		remote = fmt.Sprintf(" -h %s -P %s --single-transaction", host.Value, port.Value)
 
-	// sh -c 'mysqldump -u sample-go-stage-user -p"password" mysql-sample-go-stage > /tmp/uuid.sql'
-	resultPath := fmt.Sprintf("/tmp/%s.sql", uuid.NewString())
-	cmdStr := fmt.Sprintf(`mysqldump -u %s -p"%s" %s > %s`,
-		username.Value, password.Value, dbName.Value, resultPath)
+		sidecar, stop, err := startSidecar(ctx, m.dockerClient, params, types.StorageEngineMysql)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to start backup sidecar")
+		}
+		defer stop()
+		containerName = sidecar
+	}
+	cmdStr := fmt.Sprintf(`mysqldump%s -u %s -p"%s" %s > %s`,
+		remote, username.Value, password.Value, dbName.Value, resultPath)
 	cmd := strslice.StrSlice{
 		"sh",
 		"-c",
@@ -70,7 +77,6 @@ func (m mysqlBackupExecutor) Execute(ctx context.Context, params Params) (Result
 		"MYSQL_PWD=" + password.Value,
 	}
 
-	containerName := fmt.Sprintf("mysql-%s-%s", params.Application.Name, params.Environment)
 	_, err = m.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
 		ContainerName: containerName,
 		Cmd:           cmd,
@@ -91,13 +97,14 @@ func (m mysqlBackupExecutor) Execute(ctx context.Context, params Params) (Result
 		_ = os.Remove(dmpFile.Stat.Name)
 	}()
 
-	if err := st.Save(ctx, location, dmpFile); err != nil {
-		return Result{}, errors.Wrap(err, "failed to save file in storage")
+	copies, err := storage.Replicate(ctx, params.Destinations, location, dmpFile)
+	if err != nil {
+		return Result{}, err
 	}
 
 	return Result{
-		Location:    location,
-		StorageType: stType,
-		Size:        dmpFile.Stat.Size,
+		Location: location,
+		Copies:   copies,
+		Size:     dmpFile.Stat.Size,
 	}, nil
 }
@@ -0,0 +1,507 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sarabi/internal/components/database/providers/postgres"
+	"sarabi/internal/integrations/docker"
+	storage "sarabi/internal/storage"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"strings"
+	"time"
+)
+
+const (
+	postgresDataPath = "/var/lib/postgresql/data"
+	// walArchiveDir is where archive_command drops finished WAL segments inside the postgres container.
+	// it is on its own volume, outside the data volume so that base backups don't carry it around.
+	// it matches ArchivePath of the postgres provider
+	walArchiveDir = "/var/lib/postgresql/wal_archive"
+	// walRestoreDir is where the shipped segments are staged for restore_command, relative to the data directory
+	walRestoreDir = "sarabi_wal_restore"
+
+	promotionTimeout = 30 * time.Minute
+)
+
+type (
+	// RestoreParams describes a point-in-time recovery. StagingDir is a host directory holding
+	// the base backup as base.tar.gz and the WAL segments needed to replay it under wal/
+	RestoreParams struct {
+		Environment  string
+		DatabaseVars []*types.Secret
+		Application  *types.Application
+		StagingDir   string
+		TargetTime   time.Time
+	}
+
+	RestoreResult struct {
+		// SafetyVolume holds a copy of the data directory as it was before the restore
+		SafetyVolume string
+	}
+
+	Segment struct {
+		Name     string
+		Location string
+		Size     int64
+		Copies   []storage.Copy
+	}
+
+	// PITR manages point-in-time recovery of a postgres database: WAL archiving, base backups and restore
+	PITR interface {
+		Enable(ctx context.Context, params Params) error
+		Disable(ctx context.Context, params Params) error
+		BaseBackup(ctx context.Context, params Params) (Result, error)
+		ShipWAL(ctx context.Context, params Params) ([]Segment, error)
+		Restore(ctx context.Context, params RestoreParams) (RestoreResult, error)
+	}
+
+	postgresPITR struct {
+		dockerClient docker.Docker
+	}
+)
+
+func NewPostgresPITR(dc docker.Docker) PITR {
+	return &postgresPITR{dockerClient: dc}
+}
+
+// Enable turns on WAL archiving. archive_mode can only be changed at server start, so the container is restarted
+func (p postgresPITR) Enable(ctx context.Context, params Params) error {
+	containerName := postgresContainerName(params.Application, params.Environment)
+	if err := p.prepareArchiveDir(ctx, containerName); err != nil {
+		return err
+	}
+
+	archiveCommand := fmt.Sprintf("test ! -f %[1]s/%%f && cp %%p %[1]s/%%f.tmp && mv %[1]s/%%f.tmp %[1]s/%%f", walArchiveDir)
+	err := p.psql(ctx, containerName, params.DatabaseVars,
+		"ALTER SYSTEM SET archive_mode = 'on'",
+		fmt.Sprintf("ALTER SYSTEM SET archive_command = '%s'", archiveCommand),
+		"ALTER SYSTEM SET archive_timeout = '60'")
+	if err != nil {
+		return errors.Wrap(err, "failed to configure WAL archiving")
+	}
+
+	if err := p.dockerClient.RestartContainer(ctx, containerName); err != nil {
+		return err
+	}
+	return p.waitReady(ctx, containerName, params.DatabaseVars)
+}
+
+func (p postgresPITR) Disable(ctx context.Context, params Params) error {
+	containerName := postgresContainerName(params.Application, params.Environment)
+	err := p.psql(ctx, containerName, params.DatabaseVars,
+		"ALTER SYSTEM RESET archive_mode",
+		"ALTER SYSTEM RESET archive_command",
+		"ALTER SYSTEM RESET archive_timeout")
+	if err != nil {
+		return errors.Wrap(err, "failed to reset WAL archiving")
+	}
+
+	if err := p.dockerClient.RestartContainer(ctx, containerName); err != nil {
+		return err
+	}
+	return p.waitReady(ctx, containerName, params.DatabaseVars)
+}
+
+// BaseBackup takes a compressed physical copy of the data directory with pg_basebackup.
+// the WAL needed to make the copy consistent is included, so it can be restored on its own
+func (p postgresPITR) BaseBackup(ctx context.Context, params Params) (Result, error) {
+	logger.Info("starting postgres base backup",
+		zap.String("application", params.Application.Name),
+		zap.String("env", params.Environment))
+	username, err := findVar("POSTGRES_USER", params.DatabaseVars)
+	if err != nil {
+		return Result{}, err
+	}
+
+	password, err := findVar("POSTGRES_PASSWORD", params.DatabaseVars)
+	if err != nil {
+		return Result{}, err
+	}
+
+	containerName := postgresContainerName(params.Application, params.Environment)
+	resultDir := fmt.Sprintf("/tmp/%s", uuid.NewString())
+	_, err = p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd: strslice.StrSlice{
+			"pg_basebackup",
+			"-U", username.Value,
+			"-D", resultDir,
+			"-Ft", "-z",
+			"-X", "fetch",
+		},
+		Envs: []string{"PGPASSWORD=" + password.Value},
+	})
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to execute pg_basebackup")
+	}
+
+	defer func() {
+		_, _ = p.dockerClient.ContainerExec(context.Background(), docker.ContainerExecParams{
+			ContainerName: containerName,
+			Cmd:           strslice.StrSlice{"rm", "-rf", resultDir},
+		})
+	}()
+
+	baseFile, err := p.dockerClient.CopyFromContainer(ctx, containerName, resultDir+"/base.tar.gz")
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to copy base backup")
+	}
+
+	defer func() {
+		_ = baseFile.Content.Close()
+		_ = os.Remove(baseFile.Stat.Name)
+	}()
+
+	location := fmt.Sprintf("%s/%s-%s/postgres-base-%s.tar.gz", storage.BackupDir, params.Application.Name, params.Environment, time.Now().Format("2006_01_02_03_04pm"))
+	copies, err := storage.Replicate(ctx, params.Destinations, location, baseFile)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Location: location,
+		Size:     baseFile.Stat.Size,
+		Copies:   copies,
+	}, nil
+}
+
+// ShipWAL moves the segments archived by postgres since the last run into the backup storage
+func (p postgresPITR) ShipWAL(ctx context.Context, params Params) ([]Segment, error) {
+	containerName := postgresContainerName(params.Application, params.Environment)
+	// containers created before the archive had its own volume don't have the directory
+	if err := p.prepareArchiveDir(ctx, containerName); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           strslice.StrSlice{"ls", "-1", walArchiveDir},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list archived WAL")
+	}
+
+	out, _, err := docker.ReadExecResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Segment, 0)
+	for _, name := range strings.Fields(out) {
+		if strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+
+		segment, err := p.shipSegment(ctx, params, containerName, name)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, segment)
+	}
+	return result, nil
+}
+
+func (p postgresPITR) shipSegment(ctx context.Context, params Params, containerName, name string) (Segment, error) {
+	path := walArchiveDir + "/" + name
+	file, err := p.dockerClient.CopyFromContainer(ctx, containerName, path)
+	if err != nil {
+		return Segment{}, errors.Wrap(err, "failed to copy WAL segment "+name)
+	}
+
+	defer func() {
+		_ = file.Content.Close()
+		_ = os.Remove(file.Stat.Name)
+	}()
+
+	location := fmt.Sprintf("%s/%s-%s/wal/%s", storage.BackupDir, params.Application.Name, params.Environment, name)
+	copies, err := storage.Replicate(ctx, params.Destinations, location, file)
+	if err != nil {
+		return Segment{}, errors.Wrap(err, "failed to save WAL segment "+name)
+	}
+
+	_, err = p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           strslice.StrSlice{"rm", "-f", path},
+	})
+	if err != nil {
+		return Segment{}, errors.Wrap(err, "failed to remove shipped WAL segment "+name)
+	}
+
+	return Segment{
+		Name:     name,
+		Location: location,
+		Size:     file.Stat.Size,
+		Copies:   copies,
+	}, nil
+}
+
+// Restore recovers the database to params.TargetTime.
+// the base backup and WAL are first extracted into a fresh volume, so a broken backup never touches the live data.
+// the fresh volume is then swapped into the database volume and postgres replays the WAL on start.
+// a copy of the previous data is kept in a safety volume and put back if anything goes wrong after the swap
+func (p postgresPITR) Restore(ctx context.Context, params RestoreParams) (RestoreResult, error) {
+	containerName := postgresContainerName(params.Application, params.Environment)
+	dep := &types.Deployment{ApplicationID: params.Application.ID, Environment: params.Environment}
+	volume := dep.VolumeName(types.StorageEnginePostgres)
+	freshVolume := volume + "-restore"
+	safetyVolume := fmt.Sprintf("%s-pre-restore-%s", volume, time.Now().Format("20060102150405"))
+
+	if err := p.dockerClient.CreateVolume(ctx, freshVolume); err != nil {
+		return RestoreResult{}, err
+	}
+
+	defer func() {
+		if err := p.dockerClient.RemoveVolume(context.Background(), freshVolume); err != nil {
+			logger.Warn("failed to remove restore volume",
+				zap.String("volume", freshVolume),
+				zap.Error(err))
+		}
+	}()
+
+	target := recoveryTargetTime(params.TargetTime)
+	script := fmt.Sprintf(`set -e
+find /target -mindepth 1 -delete
+tar -xzf /staging/base.tar.gz -C /target
+mkdir -p /target/%[1]s
+cp -r /staging/wal/. /target/%[1]s/
+touch /target/recovery.signal
+cat >> /target/postgresql.auto.conf <<EOF
+restore_command = 'cp %[2]s/%[1]s/%%f "%%p"'
+recovery_target_time = '%[3]s'
+recovery_target_action = 'promote'
+EOF
+chown -R postgres:postgres /target
+chmod 700 /target`, walRestoreDir, postgresDataPath, target)
+	image := postgresImage(params.Application, params.Environment)
+	err := p.runScript(ctx, image, script, map[string]string{freshVolume: "/target"}, params.StagingDir+":/staging:ro")
+	if err != nil {
+		return RestoreResult{}, errors.Wrap(err, "failed to prepare restore volume")
+	}
+
+	if err := p.dockerClient.StopContainer(ctx, containerName); err != nil {
+		return RestoreResult{}, err
+	}
+
+	if err := p.dockerClient.CreateVolume(ctx, safetyVolume); err != nil {
+		return RestoreResult{}, err
+	}
+
+	if err := p.copyVolume(ctx, image, volume, safetyVolume); err != nil {
+		_ = p.dockerClient.RestartContainer(ctx, containerName)
+		return RestoreResult{}, errors.Wrap(err, "failed to copy current data into safety volume")
+	}
+
+	rollback := func(cause error) (RestoreResult, error) {
+		logger.Error("restore failed, putting back previous data",
+			zap.String("volume", volume),
+			zap.Error(cause))
+		_ = p.dockerClient.StopContainer(context.Background(), containerName)
+		if err := p.copyVolume(context.Background(), image, safetyVolume, volume); err != nil {
+			return RestoreResult{}, fmt.Errorf("%w: rollback failed, previous data is kept in volume %s: %s", cause, safetyVolume, err.Error())
+		}
+		_ = p.dockerClient.RestartContainer(context.Background(), containerName)
+		return RestoreResult{}, cause
+	}
+
+	if err := p.copyVolume(ctx, image, freshVolume, volume); err != nil {
+		return rollback(errors.Wrap(err, "failed to swap restore volume in"))
+	}
+
+	if err := p.dockerClient.RestartContainer(ctx, containerName); err != nil {
+		return rollback(err)
+	}
+
+	if err := p.waitPromotion(ctx, containerName, params.DatabaseVars); err != nil {
+		return rollback(err)
+	}
+
+	err = p.psql(ctx, containerName, params.DatabaseVars,
+		"ALTER SYSTEM RESET restore_command",
+		"ALTER SYSTEM RESET recovery_target_time",
+		"ALTER SYSTEM RESET recovery_target_action",
+		"SELECT pg_reload_conf()")
+	if err != nil {
+		logger.Warn("failed to reset recovery settings", zap.Error(err))
+	}
+
+	_, err = p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           strslice.StrSlice{"rm", "-rf", postgresDataPath + "/" + walRestoreDir},
+	})
+	if err != nil {
+		logger.Warn("failed to remove restored WAL", zap.Error(err))
+	}
+
+	return RestoreResult{SafetyVolume: safetyVolume}, nil
+}
+
+// waitPromotion waits for postgres to finish replaying the WAL and accept writes
+func (p postgresPITR) waitPromotion(ctx context.Context, containerName string, vars []*types.Secret) error {
+	username, err := findVar("POSTGRES_USER", vars)
+	if err != nil {
+		return err
+	}
+
+	dbName, err := findVar("POSTGRES_DB", vars)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, promotionTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.New("timed out waiting for recovery to complete")
+		case <-ticker.C:
+		}
+
+		status, err := p.dockerClient.ContainerStatus(ctx, containerName)
+		if err != nil {
+			return err
+		}
+		if status == "exited" || status == "dead" {
+			return errors.New("postgres stopped during recovery, the target time is probably not covered by the archived WAL")
+		}
+
+		resp, err := p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+			ContainerName: containerName,
+			Cmd:           strslice.StrSlice{"psql", "-U", username.Value, "-d", dbName.Value, "-tAc", "SELECT pg_is_in_recovery()"},
+		})
+		if err != nil {
+			// still starting up
+			continue
+		}
+
+		out, _, err := docker.ReadExecResponse(resp)
+		if err == nil && strings.TrimSpace(out) == "f" {
+			return nil
+		}
+	}
+}
+
+func (p postgresPITR) waitReady(ctx context.Context, containerName string, vars []*types.Secret) error {
+	username, err := findVar("POSTGRES_USER", vars)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 30; i++ {
+		_, err = p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+			ContainerName: containerName,
+			Cmd:           strslice.StrSlice{"pg_isready", "-U", username.Value},
+		})
+		if err == nil {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return errors.Wrap(err, "postgres did not become ready")
+}
+
+func (p postgresPITR) prepareArchiveDir(ctx context.Context, containerName string) error {
+	_, err := p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           strslice.StrSlice{"sh", "-c", fmt.Sprintf("mkdir -p %[1]s && chown postgres:postgres %[1]s", walArchiveDir)},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create WAL archive directory")
+	}
+	return nil
+}
+
+// psql runs each statement on its own, ALTER SYSTEM is not allowed inside a transaction block
+func (p postgresPITR) psql(ctx context.Context, containerName string, vars []*types.Secret, statements ...string) error {
+	username, err := findVar("POSTGRES_USER", vars)
+	if err != nil {
+		return err
+	}
+
+	password, err := findVar("POSTGRES_PASSWORD", vars)
+	if err != nil {
+		return err
+	}
+
+	dbName, err := findVar("POSTGRES_DB", vars)
+	if err != nil {
+		return err
+	}
+
+	cmd := strslice.StrSlice{"psql", "-v", "ON_ERROR_STOP=1", "-U", username.Value, "-d", dbName.Value}
+	for _, next := range statements {
+		cmd = append(cmd, "-c", next)
+	}
+	_, err = p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           cmd,
+		Envs:          []string{"PGPASSWORD=" + password.Value},
+	})
+	return err
+}
+
+func (p postgresPITR) copyVolume(ctx context.Context, image, from, to string) error {
+	return p.runScript(ctx, image, "set -e\nfind /to -mindepth 1 -delete\ncp -a /from/. /to/",
+		map[string]string{from: "/from", to: "/to"})
+}
+
+// runScript runs a shell script in a throwaway container of image, so file ownership matches the database container
+func (p postgresPITR) runScript(ctx context.Context, image, script string, mounts map[string]string, binds ...string) error {
+	result, err := p.dockerClient.RunContainer(ctx, docker.StartContainerParams{
+		Image:   image,
+		Cmd:     []string{"sh", "-c", script},
+		Mounts:  mounts,
+		Volumes: binds,
+		User:    "root",
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.ExitCode != 0 {
+		return fmt.Errorf("exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// StageFile writes a backup file into a restore staging directory
+func StageFile(dir, name string, file *types.File) error {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := out.ReadFrom(file.Content); err != nil {
+		return err
+	}
+	return nil
+}
+
+// recoveryTargetTime formats a restore target for recovery_target_time, in UTC whatever the zone of the server
+func recoveryTargetTime(target time.Time) string {
+	return target.UTC().Format("2006-01-02 15:04:05") + "+00"
+}
+
+// postgresImage is the image the database of an environment runs, the data directory is only readable by that version
+func postgresImage(application *types.Application, environment string) string {
+	return postgres.New(application.EngineVersion(types.StorageEnginePostgres, environment)).Image()
+}
+
+func postgresContainerName(application *types.Application, environment string) string {
+	return fmt.Sprintf("postgres-%s-%s", application.Name, environment)
+}
@@ -0,0 +1,23 @@
+package backup
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sarabi/internal/types"
+	"testing"
+	"time"
+)
+
+func TestRecoveryTargetTime(t *testing.T) {
+	lagos := time.FixedZone("WAT", 60*60)
+	assert.Equal(t, "2026-10-01 13:32:00+00", recoveryTargetTime(time.Date(2026, 10, 1, 14, 32, 0, 0, lagos)))
+	assert.Equal(t, "2026-10-01 14:32:05+00", recoveryTargetTime(time.Date(2026, 10, 1, 14, 32, 5, 999, time.UTC)))
+}
+
+func TestPostgresImage(t *testing.T) {
+	app := &types.Application{}
+	assert.Equal(t, "postgres:"+types.DefaultEngineVersions[types.StorageEnginePostgres], postgresImage(app, "prod"))
+
+	app.SetEngineVersion(types.StorageEnginePostgres, "prod", "16")
+	assert.Equal(t, "postgres:16", postgresImage(app, "prod"))
+	assert.Equal(t, "postgres:"+types.DefaultEngineVersions[types.StorageEnginePostgres], postgresImage(app, "staging"))
+}
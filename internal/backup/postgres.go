@@ -44,20 +44,6 @@ func (p postgresBackupExecutor) Execute(ctx context.Context, params Params) (Res
 		return Result{}, err
 	}
 
-	var st storage.Storage
-	var stType storage.Type
-	if params.StorageCredential == nil {
-		logger.Info("Object storage credential not configured, using File system storage for backup")
-		st = storage.NewFileStorage()
-		stType = storage.TypeFS
-	} else {
-		st, err = storage.NewObjectStorage(*params.StorageCredential)
-		if err != nil {
-			return Result{}, errors.Wrap(err, "invalid object storage credential")
-		}
-		stType = storage.TypeS3
-	}
-
 	resultPath := fmt.Sprintf("/tmp/%s.sql", uuid.NewString())
 	cmd := strslice.StrSlice{
 		"pg_dump",
@@ -71,6 +57,25 @@ func (p postgresBackupExecutor) Execute(ctx context.Context, params Params) (Res
 
 	location := fmt.Sprintf("%s/%s-%s/postgres-%s.sql", storage.BackupDir, params.Application.Name, params.Environment, time.Now().Format("2006_01_02_03_04pm"))
 	containerName := fmt.Sprintf("postgres-%s-%s", params.Application.Name, params.Environment)
+	if params.Sidecar != "" {
+		// an external database is dumped through its URL, it carries the TLS settings the server expects
+		databaseUrl, err := findVar("POSTGRES_DATABASE_URL", params.DatabaseVars)
+		if err != nil {
+			return Result{}, err
+		}
+		cmd = strslice.StrSlice{"pg_dump", "-d", databaseUrl.Value, "-f", resultPath}
+
+		sidecar, stop, err := startSidecar(ctx, p.dockerClient, params, types.StorageEnginePostgres)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to start backup sidecar")
+		}
+		defer stop()
+		containerName = sidecar
+	}
+	if params.Portable {
+		// the users of another environment differ, the dump is loaded as whoever restores it
+		cmd = append(cmd, "--no-owner", "--no-privileges")
+	}
 	_, err = p.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
 		ContainerName: containerName,
 		Cmd:           cmd,
@@ -90,14 +95,15 @@ func (p postgresBackupExecutor) Execute(ctx context.Context, params Params) (Res
 		_ = os.Remove(dmpFile.Stat.Name)
 	}()
 
-	if err := st.Save(ctx, location, dmpFile); err != nil {
-		return Result{}, errors.Wrap(err, "failed to save file in storage")
+	copies, err := storage.Replicate(ctx, params.Destinations, location, dmpFile)
+	if err != nil {
+		return Result{}, err
 	}
 
 	return Result{
-		Location:    location,
-		StorageType: stType,
-		Size:        dmpFile.Stat.Size,
+		Location: location,
+		Copies:   copies,
+		Size:     dmpFile.Stat.Size,
 	}, nil
 }
 
@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"go.uber.org/zap"
+	"os"
+	"sarabi/internal/integrations/docker"
+	storage "sarabi/internal/storage"
+	"sarabi/logger"
+	"time"
+)
+
+type (
+	rabbitmqBackupExecutor struct {
+		dockerClient docker.Docker
+	}
+)
+
+func NewRabbitMQ(dc docker.Docker) Executor {
+	return &rabbitmqBackupExecutor{dockerClient: dc}
+}
+
+// Execute exports the definitions of the broker: users, vhosts, permissions, queues, exchanges,
+// bindings and policies. messages are not part of it, they are expected to be consumed
+func (m rabbitmqBackupExecutor) Execute(ctx context.Context, params Params) (Result, error) {
+	if params.Sidecar != "" {
+		// rabbitmqctl talks to a broker with its erlang cookie, a broker run outside sarabi does not share it
+		return Result{}, errors.New("backups of a rabbitmq run outside sarabi are not supported")
+	}
+
+	logger.Info("starting rabbitmq backup",
+		zap.String("application", params.Application.Name),
+		zap.String("env", params.Environment))
+
+	resultPath := fmt.Sprintf("/tmp/%s.json", uuid.NewString())
+	location := fmt.Sprintf("%s/%s-%s/rabbitmq-%s.json", storage.BackupDir, params.Application.Name, params.Environment, time.Now().Format("2006_01_02_03_04pm"))
+	containerName := fmt.Sprintf("rabbitmq-%s-%s", params.Application.Name, params.Environment)
+	_, err := m.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           []string{"rabbitmqctl", "-q", "export_definitions", resultPath},
+	})
+	if err != nil {
+		return Result{}, errorpkg.Wrap(err, "failed to execute rabbitmqctl export_definitions")
+	}
+
+	dmpFile, err := m.dockerClient.CopyFromContainer(ctx, containerName, resultPath)
+	if err != nil {
+		return Result{}, errorpkg.Wrap(err, "failed to copy definitions file")
+	}
+
+	defer func() {
+		_ = dmpFile.Content.Close()
+		_ = os.Remove(dmpFile.Stat.Name)
+	}()
+
+	copies, err := storage.Replicate(ctx, params.Destinations, location, dmpFile)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Location: location,
+		Copies:   copies,
+		Size:     dmpFile.Stat.Size,
+	}, nil
+}
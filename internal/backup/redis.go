@@ -3,11 +3,13 @@ package backup
 import (
 	"context"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"os"
 	"sarabi/internal/integrations/docker"
 	storage "sarabi/internal/storage"
+	"sarabi/internal/types"
 	"sarabi/logger"
 	"time"
 )
@@ -27,24 +29,32 @@ func (m redisBackupExecutor) Execute(ctx context.Context, params Params) (Result
 		zap.String("application", params.Application.Name),
 		zap.String("env", params.Environment))
 
-	var st storage.Storage
-	var stType storage.Type
-	var err error
-	if params.StorageCredential == nil {
-		logger.Info("Object storage credential not configured, using File system storage for backup")
-		st = storage.NewFileStorage()
-		stType = storage.TypeFS
-	} else {
-		st, err = storage.NewObjectStorage(*params.StorageCredential)
-		if err != nil {
-			return Result{}, errors.Wrap(err, "invalid object storage credential")
-		}
-		stType = storage.TypeS3
-	}
-
 	resultPath := "/data/dump.rdb"
 	location := fmt.Sprintf("%s/%s-%s/redis-%s.rdb", storage.BackupDir, params.Application.Name, params.Environment, time.Now().Format("2006_01_02_03_04pm"))
 	containerName := fmt.Sprintf("redis-%s-%s", params.Application.Name, params.Environment)
+	if params.Sidecar != "" {
+		// an external redis is not on a volume sarabi can read, redis-cli asks it for a snapshot instead
+		redisUrl, err := findVar("REDIS_URL", params.DatabaseVars)
+		if err != nil {
+			return Result{}, err
+		}
+
+		sidecar, stop, err := startSidecar(ctx, m.dockerClient, params, types.StorageEngineRedis)
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to start backup sidecar")
+		}
+		defer stop()
+		containerName = sidecar
+
+		resultPath = fmt.Sprintf("/tmp/%s.rdb", uuid.NewString())
+		_, err = m.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+			ContainerName: containerName,
+			Cmd:           []string{"redis-cli", "--no-auth-warning", "-u", redisUrl.Value, "--rdb", resultPath},
+		})
+		if err != nil {
+			return Result{}, errors.Wrap(err, "failed to execute redis-cli --rdb")
+		}
+	}
 	dmpFile, err := m.dockerClient.CopyFromContainer(ctx, containerName, resultPath)
 	if err != nil {
 		return Result{}, errors.Wrap(err, "failed to copy dump file")
@@ -55,13 +65,14 @@ func (m redisBackupExecutor) Execute(ctx context.Context, params Params) (Result
 		_ = os.Remove(dmpFile.Stat.Name)
 	}()
 
-	if err := st.Save(ctx, location, dmpFile); err != nil {
-		return Result{}, errors.Wrap(err, "failed to save file in storage")
+	copies, err := storage.Replicate(ctx, params.Destinations, location, dmpFile)
+	if err != nil {
+		return Result{}, err
 	}
 
 	return Result{
-		Location:    location,
-		StorageType: stType,
-		Size:        dmpFile.Stat.Size,
+		Location: location,
+		Copies:   copies,
+		Size:     dmpFile.Stat.Size,
 	}, nil
 }
@@ -0,0 +1,30 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/types"
+)
+
+// startSidecar runs a container of params.Sidecar for the dump tools of an external database to run in,
+// the returned function removes it
+func startSidecar(ctx context.Context, dc docker.Docker, params Params, se types.StorageEngine) (string, func(), error) {
+	if err := dc.PullImage(ctx, params.Sidecar); err != nil {
+		return "", nil, err
+	}
+
+	name := fmt.Sprintf("%s-%s-%s-backup", se, params.Application.Name, params.Environment)
+	_, err := dc.StartContainerAndWait(ctx, docker.StartContainerParams{
+		Image:     params.Sidecar,
+		Container: name,
+		Cmd:       []string{"sleep", "infinity"},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, func() {
+		_ = dc.StopAndRemoveContainer(context.Background(), docker.StopContainerParams{ContainerName: name})
+	}, nil
+}
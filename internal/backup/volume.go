@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"os"
+	"sarabi/internal/integrations/docker"
+	storage "sarabi/internal/storage"
+	"sarabi/logger"
+	"strings"
+	"time"
+)
+
+// snapshotImage has the tar the snapshots of volumes are taken with
+const snapshotImage = "alpine:3.20"
+
+type (
+	volumeBackupExecutor struct {
+		dockerClient docker.Docker
+	}
+)
+
+func NewVolume(dc docker.Docker) Executor {
+	return &volumeBackupExecutor{dockerClient: dc}
+}
+
+// Execute saves a gzipped tar of params.Volume, it is mounted into a container of its own
+// so that the backend containers are left as they are
+func (v volumeBackupExecutor) Execute(ctx context.Context, params Params) (Result, error) {
+	if params.Volume == nil {
+		return Result{}, errors.New("no volume to snapshot")
+	}
+
+	logger.Info("starting volume backup",
+		zap.String("application", params.Application.Name),
+		zap.String("env", params.Environment),
+		zap.String("volume", params.Volume.Name))
+	if err := v.dockerClient.PullImage(ctx, snapshotImage); err != nil {
+		return Result{}, err
+	}
+
+	containerName := fmt.Sprintf("volume-%s-%s-%s-backup", params.Volume.Name, params.Application.Name, params.Environment)
+	_, err := v.dockerClient.StartContainerAndWait(ctx, docker.StartContainerParams{
+		Image:     snapshotImage,
+		Container: containerName,
+		Cmd:       []string{"sleep", "infinity"},
+		Mounts:    map[string]string{params.Volume.DockerVolume(): "/volume"},
+	})
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to start snapshot container")
+	}
+	defer func() {
+		_ = v.dockerClient.StopAndRemoveContainer(context.Background(), docker.StopContainerParams{ContainerName: containerName})
+	}()
+
+	resultPath := "/tmp/snapshot.tar.gz"
+	out, err := v.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           []string{"tar", "-czf", resultPath, "-C", "/volume", "."},
+	})
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to execute tar")
+	}
+	if _, stderr, err := docker.ReadExecResponse(out); err != nil {
+		return Result{}, err
+	} else if strings.TrimSpace(stderr) != "" {
+		return Result{}, errors.New("failed to execute tar: " + strings.TrimSpace(stderr))
+	}
+
+	location := fmt.Sprintf("%s/%s-%s/volume-%s-%s.tar.gz", storage.BackupDir, params.Application.Name, params.Environment,
+		params.Volume.Name, time.Now().Format("2006_01_02_03_04pm"))
+	snapshot, err := v.dockerClient.CopyFromContainer(ctx, containerName, resultPath)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to copy snapshot")
+	}
+
+	defer func() {
+		_ = snapshot.Content.Close()
+		_ = os.Remove(snapshot.Stat.Name)
+	}()
+
+	copies, err := storage.Replicate(ctx, params.Destinations, location, snapshot)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Location: location,
+		Copies:   copies,
+		Size:     snapshot.Stat.Size,
+	}, nil
+}
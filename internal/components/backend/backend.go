@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
+	"github.com/samber/lo"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 	"sarabi/internal/components"
@@ -15,6 +16,7 @@ import (
 	"sarabi/internal/service"
 	"sarabi/internal/types"
 	"sarabi/logger"
+	"strings"
 )
 
 type (
@@ -56,11 +58,16 @@ func (b *backendComponent) Run(ctx context.Context, deploymentID uuid.UUID) (*co
 		return nil, err
 	}
 
-	currentlyActives, err := b.appService.FindCurrentlyActiveDeployments(ctx, deployment.ApplicationID, types.InstanceTypeBackend)
+	actives, err := b.appService.FindCurrentlyActiveDeployments(ctx, deployment.ApplicationID, types.InstanceTypeBackend)
 	if err != nil {
 		return nil, err
 	}
 
+	// the deployment replaces the one of the same service in its environment, the other services keep running
+	currentlyActives := lo.Filter(actives, func(item *types.Deployment, index int) bool {
+		return item.Environment == deployment.Environment && item.Service == deployment.Service
+	})
+
 	_, err = b.dockerClient.BuildImage(ctx, deployment)
 	if err != nil {
 		return nil, err
@@ -71,11 +78,11 @@ func (b *backendComponent) Run(ctx context.Context, deploymentID uuid.UUID) (*co
 		return nil, err
 	}
 
-	var envs []string
-	for _, ss := range secrets {
-		envs = append(envs, ss.Env())
+	envs := environment(deployment, secrets)
+	mounts, err := b.mounts(ctx, deployment)
+	if err != nil {
+		return nil, err
 	}
-	envs = append(envs, "ENVIRONMENT="+deployment.Environment)
 
 	g, ctx := errgroup.WithContext(ctx)
 	for idx := 0; idx < deployment.Instances; idx++ {
@@ -88,7 +95,9 @@ func (b *backendComponent) Run(ctx context.Context, deploymentID uuid.UUID) (*co
 				Container:    deployment.ContainerName(idx),
 				Network:      &networkName,
 				Volumes:      []string{},
+				Mounts:       mounts,
 				Environments: envs,
+				Cmd:          deployment.Processes.Cmd(types.ProcessWeb),
 				ExposedPorts: []nat.Port{httpPort},
 			}
 			newInfo, err := b.dockerClient.StartContainerAndWait(ctx, params)
@@ -108,18 +117,50 @@ func (b *backendComponent) Run(ctx context.Context, deploymentID uuid.UUID) (*co
 		})
 	}
 
+	// the workers share the image and variables of web, they are not routed
+	for idx := 0; idx < deployment.ProcessReplicas(types.ProcessWorker); idx++ {
+		g.Go(func() error {
+			b.eb.Broadcast(deployment.Identifier, eventbus.Info, fmt.Sprintf("Starting worker container: replicaID=%d", idx+1))
+			networkName := deployment.NetworkName()
+			params := docker.StartContainerParams{
+				Image:        deployment.ImageName(),
+				Container:    deployment.ProcessContainerName(types.ProcessWorker, idx),
+				Network:      &networkName,
+				Volumes:      []string{},
+				Mounts:       mounts,
+				Environments: envs,
+				Cmd:          deployment.Processes.Cmd(types.ProcessWorker),
+			}
+			if _, err := b.dockerClient.StartContainerAndWait(ctx, params); err != nil {
+				return err
+			}
+			return nil
+		})
+	}
+
 	if err := g.Wait(); err != nil {
 		b.eb.Broadcast(deployment.Identifier, eventbus.Error, "Failed to start application container: "+err.Error())
 		return nil, err
 	}
 
-	err = b.caddyClient.ApplyConfig(context.Background(), types.InstanceTypeBackend, deployment)
-	if err != nil {
+	if err := b.connectInternal(context.Background(), deployment); err != nil {
 		return nil, err
 	}
 
-	if err := b.dockerClient.ConnectContainer(context.Background(), proxycomponent.ProxyServerName, deployment.NetworkName()); err != nil {
-		logger.Warn("container connection error: ", zap.Error(err))
+	if deployment.Settings.Internal() {
+		// the route of a previous public deployment is removed, the backend is only reached at its internal host
+		if err := b.caddyClient.RemoveConfig(context.Background(), deployment); err != nil {
+			return nil, err
+		}
+	} else {
+		err = b.caddyClient.ApplyConfig(context.Background(), types.InstanceTypeBackend, deployment)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := b.dockerClient.ConnectContainer(context.Background(), proxycomponent.ProxyServerName, deployment.NetworkName()); err != nil {
+			logger.Warn("container connection error: ", zap.Error(err))
+		}
 	}
 
 	return &components.BuilderResult{
@@ -134,10 +175,11 @@ func (b *backendComponent) Cleanup(ctx context.Context, result *components.Build
 	}
 
 	for _, deployment := range result.PreviousActive {
-		for idx := 0; idx < deployment.Instances; idx++ {
+		for _, name := range deployment.ProcessContainerNames() {
+			// only the anonymous volumes of the container are removed, the named volumes of the environment are kept
 			err := b.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{
 				RemoveVolumes: true,
-				ContainerName: deployment.ContainerName(idx),
+				ContainerName: name,
 			})
 			if err != nil {
 				return err
@@ -154,3 +196,108 @@ func (b *backendComponent) Cleanup(ctx context.Context, result *components.Build
 
 	return nil
 }
+
+// connectInternal connects the containers of a deployment to the networks of the internal accesses to and from its application
+func (b *backendComponent) connectInternal(ctx context.Context, deployment *types.Deployment) error {
+	accesses, err := b.appService.InternalAccesses(ctx, deployment.ApplicationID)
+	if err != nil {
+		return err
+	}
+
+	for _, next := range append(accesses, types.SelfAccess(deployment.ApplicationID)) {
+		if err := ConnectInternal(ctx, b.dockerClient, deployment, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConnectInternal connects the web and worker containers of a deployment to the network of an internal access,
+// the web containers of the application reached are resolved by the internal host of the deployment on it
+func ConnectInternal(ctx context.Context, dc docker.Docker, deployment *types.Deployment, access *types.InternalAccess) error {
+	if err := dc.CreateNetwork(ctx, access.NetworkName()); err != nil {
+		return err
+	}
+
+	var aliases []string
+	if access.ApplicationID == deployment.ApplicationID {
+		aliases = append(aliases, deployment.InternalHost())
+	}
+	for idx := 0; idx < deployment.Instances; idx++ {
+		if err := dc.ConnectContainer(ctx, deployment.ContainerName(idx), access.NetworkName(), aliases...); err != nil {
+			return err
+		}
+	}
+	for idx := 0; idx < deployment.ProcessReplicas(types.ProcessWorker); idx++ {
+		if err := dc.ConnectContainer(ctx, deployment.ProcessContainerName(types.ProcessWorker, idx), access.NetworkName()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunOnce runs a command in a container with the image, variables and volumes of the web replicas of a deployment,
+// it returns once the container exited and was removed e.g a run of the cron process or of a job
+func RunOnce(ctx context.Context, dc docker.Docker,
+	appService service.ApplicationService,
+	sc service.SecretService,
+	deployment *types.Deployment,
+	container string,
+	cmd []string) (*docker.RunContainerResult, error) {
+	b := &backendComponent{dockerClient: dc, appService: appService, secretService: sc}
+	secrets, err := b.secretService.FindDeploymentSecrets(ctx, deployment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts, err := b.mounts(ctx, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	networkName := deployment.NetworkName()
+	return b.dockerClient.RunContainer(ctx, docker.StartContainerParams{
+		Image:        deployment.ImageName(),
+		Container:    container,
+		Network:      &networkName,
+		Volumes:      []string{},
+		Mounts:       mounts,
+		Environments: environment(deployment, secrets),
+		Cmd:          cmd,
+	})
+}
+
+// mounts creates the volumes of the environment of a deployment, they are mounted into every container
+// of the backend and their data outlives the containers
+func (b *backendComponent) mounts(ctx context.Context, deployment *types.Deployment) (map[string]string, error) {
+	volumes, err := b.appService.Volumes(ctx, deployment.ApplicationID, deployment.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make(map[string]string, len(volumes))
+	for _, next := range volumes {
+		if err := b.dockerClient.CreateVolume(ctx, next.DockerVolume()); err != nil {
+			return nil, err
+		}
+		mounts[next.DockerVolume()] = next.Path
+	}
+	return mounts, nil
+}
+
+func environment(deployment *types.Deployment, secrets []*types.Secret) []string {
+	serviceEnvs := deployment.ServiceEnvs()
+	overridden := lo.Map(serviceEnvs, func(item string, index int) string {
+		key, _, _ := strings.Cut(item, "=")
+		return key
+	})
+
+	var envs []string
+	for _, ss := range secrets {
+		if !lo.Contains(overridden, ss.Name) {
+			envs = append(envs, ss.Env())
+		}
+	}
+	envs = append(envs, serviceEnvs...)
+	return append(envs, "ENVIRONMENT="+deployment.Environment)
+}
@@ -0,0 +1,39 @@
+package backendcomponent
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sarabi/internal/types"
+	"testing"
+)
+
+func TestEnvironment(t *testing.T) {
+	secrets := []*types.Secret{
+		{Name: "DATABASE_URL", Value: "postgres://db"},
+		{Name: "LOG_LEVEL", Value: "info"},
+		{Name: "PORT", Value: "3000"},
+	}
+
+	tests := []struct {
+		name       string
+		deployment *types.Deployment
+		expected   []string
+	}{
+		{
+			name:       "backend",
+			deployment: &types.Deployment{Environment: "prod", Port: "3000"},
+			expected:   []string{"DATABASE_URL=postgres://db", "LOG_LEVEL=info", "PORT=3000", "ENVIRONMENT=prod"},
+		},
+		{
+			name: "named service overrides the variables of the environment and its port",
+			deployment: &types.Deployment{Environment: "prod", Port: "8080", Service: "api",
+				Settings: types.ServiceSettings{Variables: map[string]string{"LOG_LEVEL": "debug", "WORKERS": "4"}}},
+			expected: []string{"DATABASE_URL=postgres://db", "PORT=8080", "LOG_LEVEL=debug", "WORKERS=4", "ENVIRONMENT=prod"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, environment(test.deployment, secrets))
+		})
+	}
+}
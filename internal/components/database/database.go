@@ -2,7 +2,6 @@ package databasecomponent
 
 import (
 	"context"
-	"fmt"
 	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -59,15 +58,16 @@ func (d *databaseComponent) Run(ctx context.Context, deploymentID uuid.UUID) (*c
 		return nil, err
 	}
 	if running {
+		if err := d.waitReady(ctx, deployment); err != nil {
+			return nil, err
+		}
 		return &components.BuilderResult{ID: info.ID, Name: info.Name}, nil
 	}
 
-	resources, err := deployment.Application.ResourcesAllocation(d.dbProvider.Engine())
+	dbParams, err := d.storedVars(ctx, deployment, d.dbProvider.EnvVars(deployment))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get resources allocation")
+		return nil, err
 	}
-
-	dbParams := d.dbProvider.EnvVars(deployment)
 	dbVars, err := d.secretService.CreateAll(ctx, dbParams...)
 	if err != nil {
 		return nil, err
@@ -78,64 +78,193 @@ func (d *databaseComponent) Run(ctx context.Context, deploymentID uuid.UUID) (*c
 		return nil, err
 	}
 
-	if err := d.dbProvider.Setup(); err != nil {
+	var envs []string
+	for _, ss := range dbVars {
+		envs = append(envs, ss.Env())
+	}
+
+	startResp, err := d.startContainer(ctx, deployment, containerSpec{
+		name:     d.dbProvider.ContainerName(deployment),
+		volume:   deployment.VolumeName(d.dbProvider.Engine()),
+		envs:     envs,
+		hostPort: deployment.Port,
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if err := d.dockerClient.CreateNetwork(ctx, deployment.NetworkName()); err != nil {
+	// the container reports running long before the engine accepts connections, backends started
+	// in between crash while the data directory is still being initialised
+	if err := d.waitReady(ctx, deployment); err != nil {
 		return nil, err
 	}
 
-	volumeName := fmt.Sprintf("%s-%s-%s", deployment.ApplicationID, deployment.Environment, d.dbProvider.Engine().String())
-	if err := d.dockerClient.CreateVolume(ctx, volumeName); err != nil {
+	overrides, err := d.appService.DatabaseConfig(ctx, deployment.ApplicationID, deployment.Environment, d.dbProvider.Engine())
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string, len(dbVars))
+	for _, ss := range dbVars {
+		vars[ss.Name] = ss.Value
+	}
+	if _, err := UpdateConfigVars(ctx, d.secretService, d.dbProvider, deployment, vars, overrides); err != nil {
 		return nil, err
 	}
 
-	if err := d.dockerClient.PullImage(ctx, d.dbProvider.Image()); err != nil {
+	err = d.appService.UpdateDeploymentStatus(ctx, deploymentID, types.DeploymentStatusActive)
+	if err != nil {
 		return nil, err
 	}
 
-	var envs []string
-	for _, ss := range dbVars {
-		envs = append(envs, ss.Env())
+	d.eb.Broadcast(deployment.Identifier, eventbus.Success, "Database provisioning completed: "+d.dbProvider.Image())
+
+	return &components.BuilderResult{
+		ID:   startResp.ID,
+		Name: startResp.Name,
+	}, nil
+}
+
+// storedVars replaces the generated values of params by the ones stored for the engine. the data kept in the
+// volume of the engine, e.g when it is added again or the environment deployed again, was initialised with them
+func (d *databaseComponent) storedVars(ctx context.Context, deployment *types.Deployment, params []types.CreateSecretParams) ([]types.CreateSecretParams, error) {
+	secrets, err := d.secretService.FindAll(ctx, deployment.ApplicationID)
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO: Add support for health checks
-	mounts := map[string]string{
-		volumeName: d.dbProvider.DataPath(),
+	stored := make(map[string]string)
+	for _, next := range secrets {
+		if next.Environment == deployment.Environment && types.InstanceType(next.InstanceType) == types.InstanceTypeDatabase {
+			stored[next.Name] = next.Value
+		}
 	}
-	tcpPort, _ := nat.NewPort("tcp", d.dbProvider.Port())
-	exposedPorts := []nat.Port{tcpPort}
-	portBindings := nat.PortMap{
-		tcpPort: []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: deployment.Port}},
+	for i, next := range params {
+		if value, ok := stored[next.Key]; ok {
+			params[i].Value = value
+		}
 	}
+	return params, nil
+}
+
+// containerSpec is what differs between the containers of an engine, e.g the primary and its replicas
+type containerSpec struct {
+	name   string
+	volume string
+	envs   []string
+	// hostPort is the port of the host the engine is bound to, it is not reachable from the host when empty
+	hostPort string
+	aliases  []string
+	// replica makes a read replica of the container, given the command the configuration of the engine starts it with
+	replica func(cmd []string) types.ReplicaContainer
+}
+
+// startContainer runs a container of the engine on the network of the deployment with its data on spec.volume,
+// configured and limited to the resources allocated to the engine
+func (d *databaseComponent) startContainer(ctx context.Context, deployment *types.Deployment, spec containerSpec) (*docker.ContainerInfo, error) {
+	resources, err := deployment.Application.ResourcesAllocation(d.dbProvider.Engine())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get resources allocation")
+	}
+
+	overrides, err := d.appService.DatabaseConfig(ctx, deployment.ApplicationID, deployment.Environment, d.dbProvider.Engine())
+	if err != nil {
+		return nil, err
+	}
+
+	config, configBind, err := WriteConfig(d.dbProvider, deployment, resources, overrides)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to write database configuration")
+	}
+
+	if err := d.dockerClient.CreateNetwork(ctx, deployment.NetworkName()); err != nil {
+		return nil, err
+	}
+
+	mounts := map[string]string{spec.volume: d.dbProvider.DataPath()}
+	if spec.replica == nil {
+		mounts = Mounts(d.dbProvider, deployment, spec.volume)
+	}
+	for volume := range mounts {
+		if err := d.dockerClient.CreateVolume(ctx, volume); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.dockerClient.PullImage(ctx, d.dbProvider.Image()); err != nil {
+		return nil, err
+	}
+
+	cmd, envs, user := config.Cmd, append(append([]string{}, spec.envs...), config.Envs...), ""
+	if spec.replica != nil {
+		replica := spec.replica(config.Cmd)
+		cmd, envs, user = replica.Cmd, append(envs, replica.Envs...), replica.User
+	}
+
 	networkName := deployment.NetworkName()
 	params := docker.StartContainerParams{
 		Image:        d.dbProvider.Image(),
-		Container:    d.dbProvider.ContainerName(deployment),
+		Container:    spec.name,
+		Hostname:     spec.name,
 		Network:      &networkName,
+		Aliases:      spec.aliases,
 		Environments: envs,
-		ExposedPorts: exposedPorts,
-		PortBindings: portBindings,
+		Cmd:          cmd,
+		Volumes:      []string{configBind},
 		Mounts:       mounts,
 		Resources:    resources,
+		User:         user,
 	}
-	startResp, err := d.dockerClient.StartContainerAndWait(ctx, params)
-	if err != nil {
-		return nil, err
+	if spec.hostPort != "" {
+		params.ExposedPorts, params.PortBindings = PortBindings(d.dbProvider, spec.hostPort)
 	}
+	return d.dockerClient.StartContainerAndWait(ctx, params)
+}
 
-	err = d.appService.UpdateDeploymentStatus(ctx, deploymentID, types.DeploymentStatusActive)
+// PortBindings binds the port of an engine to the loopback of the host only, the engine is reached
+// from outside the server through a tunnel
+func PortBindings(provider Provider, hostPort string) ([]nat.Port, nat.PortMap) {
+	tcpPort, _ := nat.NewPort("tcp", provider.Port())
+	return []nat.Port{tcpPort}, nat.PortMap{
+		tcpPort: []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: hostPort}},
+	}
+}
+
+// UpdateConfigVars saves the variables of an engine that depend on its settings and reports whether any changed,
+// vars holds the current variables of the engine
+func UpdateConfigVars(ctx context.Context, secretService service.SecretService, provider Provider,
+	deployment *types.Deployment, vars map[string]string, overrides types.ConfigSettings) (bool, error) {
+	configured, ok := provider.(Configured)
+	if !ok {
+		return false, nil
+	}
+
+	params, err := configured.ConfigVars(deployment, vars, overrides)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	d.eb.Broadcast(deployment.Identifier, eventbus.Success, "Database provisioning completed: "+d.dbProvider.Image())
+	changed := make([]types.CreateSecretParams, 0, len(params))
+	for _, next := range params {
+		if vars[next.Key] != next.Value {
+			changed = append(changed, next)
+		}
+	}
+	if len(changed) == 0 {
+		return false, nil
+	}
 
-	return &components.BuilderResult{
-		ID:   startResp.ID,
-		Name: startResp.Name,
-	}, nil
+	if _, err := secretService.CreateAll(ctx, changed...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *databaseComponent) waitReady(ctx context.Context, deployment *types.Deployment) error {
+	d.eb.Broadcast(deployment.Identifier, eventbus.Info, "Waiting for database to be ready: "+d.dbProvider.Image())
+	if err := WaitReady(ctx, d.dockerClient, d.dbProvider.ContainerName(deployment), d.dbProvider, ReadyTimeout); err != nil {
+		return errors.Wrap(err, "database is not ready")
+	}
+	return nil
 }
 
 func (d *databaseComponent) Cleanup(ctx context.Context, result *components.BuilderResult) error {
@@ -0,0 +1,95 @@
+package databasecomponent
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sarabi/internal/types"
+	"unicode/utf8"
+)
+
+// dumpHeaderSize is how much of a dump is looked at to tell its format
+const dumpHeaderSize = 4096
+
+var (
+	gzipMagic         = []byte{0x1f, 0x8b}
+	postgresMagic     = []byte("PGDMP")
+	redisMagic        = []byte("REDIS")
+	mongoArchiveMagic = []byte{0x6d, 0xe2, 0x99, 0x81}
+	// tarMagic is found at tarMagicOffset of every tar archive, sarabi backs up mongodump directories in them
+	tarMagic       = []byte("ustar")
+	tarMagicOffset = 257
+
+	ErrUnknownDumpFormat = errors.New("unknown dump format, expected plain SQL, a pg_dump custom archive, " +
+		"a mysqldump, a mongodump archive or directory or a Redis RDB file")
+)
+
+// OpenDump tells the format of a dump and returns a reader of its content, gzip compressed dumps are decompressed
+func OpenDump(r io.Reader) (io.Reader, types.DumpFormat, error) {
+	br := bufio.NewReaderSize(r, dumpHeaderSize)
+	head, err := br.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, "", err
+	}
+
+	if bytes.Equal(head, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, "", err
+		}
+		br = bufio.NewReaderSize(gz, dumpHeaderSize)
+	}
+
+	head, err = br.Peek(dumpHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, "", err
+	}
+
+	format, err := DetectDumpFormat(head)
+	if err != nil {
+		return nil, "", err
+	}
+	return br, format, nil
+}
+
+// DetectDumpFormat tells the format of a dump from its first bytes
+func DetectDumpFormat(head []byte) (types.DumpFormat, error) {
+	switch {
+	case bytes.HasPrefix(head, postgresMagic):
+		return types.DumpFormatPostgresCustom, nil
+	case bytes.HasPrefix(head, redisMagic):
+		return types.DumpFormatRedisRDB, nil
+	case bytes.HasPrefix(head, mongoArchiveMagic):
+		return types.DumpFormatMongoArchive, nil
+	case len(head) >= tarMagicOffset+len(tarMagic) && bytes.Equal(head[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return types.DumpFormatMongoDirectory, nil
+	}
+
+	if !isText(head) {
+		return "", ErrUnknownDumpFormat
+	}
+
+	text := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(text, []byte("-- MySQL dump")) || bytes.HasPrefix(text, []byte("-- MariaDB dump")) {
+		return types.DumpFormatMysqldump, nil
+	}
+	return types.DumpFormatSQL, nil
+}
+
+// isText reports whether head is the start of a text file, it may end in the middle of a character
+func isText(head []byte) bool {
+	if len(bytes.TrimSpace(head)) == 0 || bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size == 1 {
+			return !utf8.FullRune(head)
+		}
+		head = head[size:]
+	}
+	return true
+}
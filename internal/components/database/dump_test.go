@@ -0,0 +1,114 @@
+package databasecomponent
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"sarabi/internal/types"
+	"strings"
+	"testing"
+)
+
+func TestOpenDump(t *testing.T) {
+	archive := func(name, content string) []byte {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))})
+		_, _ = tw.Write([]byte(content))
+		_ = tw.Close()
+		return buf.Bytes()
+	}
+	compress := func(content string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write([]byte(content))
+		_ = gz.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		format  types.DumpFormat
+		err     error
+	}{
+		{
+			name:    "plain sql",
+			content: []byte("--\n-- PostgreSQL database dump\n--\nCREATE TABLE users (id int);\n"),
+			format:  types.DumpFormatSQL,
+		},
+		{
+			name:    "mysqldump",
+			content: []byte("-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)\n--\n-- Host: localhost\n"),
+			format:  types.DumpFormatMysqldump,
+		},
+		{
+			name:    "gzip compressed mysqldump",
+			content: compress("\n-- MariaDB dump 10.19  Distrib 10.11.6-MariaDB\n"),
+			format:  types.DumpFormatMysqldump,
+		},
+		{
+			name:    "pg_dump custom archive",
+			content: append([]byte("PGDMP\x01\x0f\x00"), 0x04, 0x08, 0x01),
+			format:  types.DumpFormatPostgresCustom,
+		},
+		{
+			name:    "mongodump archive",
+			content: []byte{0x6d, 0xe2, 0x99, 0x81, 0x1a, 0x00, 0x00, 0x00},
+			format:  types.DumpFormatMongoArchive,
+		},
+		{
+			name:    "gzip compressed mongodump directory",
+			content: compress(string(archive("dump/mongo-app-prod/users.bson", "\x05\x00\x00\x00\x00"))),
+			format:  types.DumpFormatMongoDirectory,
+		},
+		{
+			name:    "redis rdb",
+			content: []byte("REDIS0011\xfa\tredis-ver\x057.2.4"),
+			format:  types.DumpFormatRedisRDB,
+		},
+		{
+			name:    "long sql cut in the middle of a character",
+			content: []byte(strings.Repeat("INSERT INTO names VALUES ('é');\n", 200)),
+			format:  types.DumpFormatSQL,
+		},
+		{
+			name:    "binary",
+			content: []byte{0x00, 0x01, 0x02, 0xff},
+			err:     ErrUnknownDumpFormat,
+		},
+		{
+			name:    "empty",
+			content: []byte{},
+			err:     ErrUnknownDumpFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, format, err := OpenDump(bytes.NewReader(tt.content))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+
+			// nothing is lost to the detection
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			want := tt.content
+			if bytes.HasPrefix(want, gzipMagic) {
+				gz, err := gzip.NewReader(bytes.NewReader(want))
+				require.NoError(t, err)
+				want, err = io.ReadAll(gz)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, want, content)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package databasecomponent
+
+import (
+	"context"
+	"fmt"
+	"sarabi/internal/integrations/docker"
+	"time"
+)
+
+const (
+	// ReadyTimeout is how long a database is given to accept connections after its container started,
+	// the first start of an engine initialises its data directory and takes the longest
+	ReadyTimeout  = 5 * time.Minute
+	ProbeTimeout  = 5 * time.Second
+	probeInterval = 2 * time.Second
+)
+
+// Probe runs the readiness probe of a storage engine once in its container
+func Probe(ctx context.Context, dc docker.Docker, containerName string, provider Provider) error {
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+
+	_, err := dc.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           provider.ReadyCommand(),
+	})
+	return err
+}
+
+// WaitReady blocks until a storage engine accepts connections. it gives up when the timeout is reached
+// or the container stops, e.g because the engine rejected its configuration
+func WaitReady(ctx context.Context, dc docker.Docker, containerName string, provider Provider, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	for {
+		err := Probe(ctx, dc, containerName, provider)
+		if err == nil {
+			return nil
+		}
+
+		status, statusErr := dc.ContainerStatus(ctx, containerName)
+		if statusErr == nil && (status == "exited" || status == "dead") {
+			return fmt.Errorf("%s stopped before it was ready, check the database logs", provider.Image())
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s was not ready after %s: %w", provider.Image(), timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package databasecomponent
 
 import (
+	"os"
+	"path/filepath"
+	"sarabi/internal/components/database/providers/minio"
 	"sarabi/internal/components/database/providers/mongo"
 	"sarabi/internal/components/database/providers/mysql"
 	"sarabi/internal/components/database/providers/postgres"
+	"sarabi/internal/components/database/providers/rabbitmq"
 	"sarabi/internal/components/database/providers/redis"
 	types "sarabi/internal/types"
 	"strings"
@@ -15,29 +19,158 @@ type (
 
 		Image() string
 
-		Setup() error
+		Version() string
+
+		// Setup renders the configuration file of the engine, tuned for the resources allocated to it.
+		// overrides are the settings the user set, they win over the tuned ones
+		Setup(resources types.ResourceAllocation, overrides types.ConfigSettings) (types.DatabaseConfigFile, error)
 
 		EnvVars(dep *types.Deployment) []types.CreateSecretParams
 
+		// ExternalVars are the variables of a database of the engine run outside sarabi at rawURL,
+		// named like the ones of EnvVars so that applications find it the same way
+		ExternalVars(dep *types.Deployment, rawURL string) ([]types.CreateSecretParams, error)
+
+		// PingCommand logs in to the database its variables point to from a container of Image
+		PingCommand() []string
+
+		// RotateCredentials plans the move of the application to new credentials of the engine,
+		// vars holds the current variables of the engine
+		RotateCredentials(dep *types.Deployment, vars map[string]string) (types.RotationPlan, error)
+
 		DataPath() string
 
 		Port() string
 
 		Engine() types.StorageEngine
+
+		// ReadyCommand exits successfully once the engine accepts connections
+		ReadyCommand() []string
+
+		// ShellVars are the variables ShellCommand logs in to the engine with
+		ShellVars() []string
+
+		// ShellCommand starts the native client of the engine, it runs command and exits when one is given
+		ShellCommand(command string) []string
+
+		// ImportCommand copies all the data of the engine listening on host into the container it runs in
+		ImportCommand(host string) []string
+
+		// ChecksumCommand prints a summary of the data held by the engine, two copies of
+		// the same data print the same summary
+		ChecksumCommand() []string
+
+		// LoadCommand loads a dump in the given format, read from its input, into the engine of dep.
+		// from is the deployment a sarabi backup was taken from, nil for dumps made elsewhere
+		LoadCommand(format types.DumpFormat, from, dep *types.Deployment) ([]string, error)
+
+		// AnonymiseCommand replaces the values named by rules in the database of dep with ones that can
+		// not be traced back to them. salt is mixed into the hashes, they differ from one clone to the next
+		AnonymiseCommand(dep *types.Deployment, rules []types.AnonymisationRule, salt string) ([]string, error)
+
+		// WipeCommand deletes all the data of the application from the engine
+		WipeCommand() []string
+	}
+
+	// Configured is implemented by the providers of the engines whose variables depend on their settings
+	Configured interface {
+		// ConfigVars are the variables of the engine once it runs with overrides, vars holds its current variables
+		ConfigVars(dep *types.Deployment, vars map[string]string, overrides types.ConfigSettings) ([]types.CreateSecretParams, error)
+	}
+
+	// Archiver is implemented by the providers of the engines that archive their log out of the data directory,
+	// e.g the WAL of postgres shipped for point-in-time recovery
+	Archiver interface {
+		// ArchivePath is kept on its own volume, what is not shipped yet survives the container being created again
+		ArchivePath() string
+	}
+
+	// Replicator is implemented by the providers of the engines that can run read replicas.
+	// replicas are numbered from 1, each one streams from the primary through its own slot
+	Replicator interface {
+		ReplicaName(dep *types.Deployment, index int) string
+
+		ReplicaSlot(index int) string
+
+		// ReadHost is the address the replicas of dep are reached at together
+		ReadHost(dep *types.Deployment) string
+
+		// ReplicationVars are new credentials replicas stream from the primary with
+		ReplicationVars(dep *types.Deployment) []types.CreateSecretParams
+
+		// ReadVars are the variables applications send reads to the replicas with, vars holds the current variables of the engine
+		ReadVars(dep *types.Deployment, vars map[string]string, replicas int) ([]types.CreateSecretParams, error)
+
+		// ReplicaVarKeys are the names of the variables replicas add to the engine
+		ReplicaVarKeys() []string
+
+		// ReplicationGrant runs on the primary, it lets the replication credentials in and creates slots
+		ReplicationGrant(vars map[string]string, slots []string) ([]string, error)
+
+		// DropSlotsCommand runs on the primary once the replicas of slots are removed
+		DropSlotsCommand(slots []string) []string
+
+		// ReplicaContainer wraps cmd, the command the primary runs with, into the container of the replica at index
+		ReplicaContainer(dep *types.Deployment, index int, cmd []string) types.ReplicaContainer
+
+		// LagCommand runs on the primary and prints the state of the replicas, ParseLag reads it by slot
+		LagCommand() []string
+
+		ParseLag(output string) (map[string]types.ReplicaStatus, error)
 	}
 )
 
-func NewProvider(engine types.StorageEngine) Provider {
-	switch types.StorageEngine(strings.ToLower(string(engine))) {
+// NewProvider returns the provider of a storage engine running the given version,
+// the default version of the engine is used when version is empty
+func NewProvider(engine types.StorageEngine, version string) Provider {
+	se := types.StorageEngine(strings.ToLower(string(engine)))
+	if version == "" {
+		version = types.DefaultEngineVersions[se]
+	}
+
+	switch se {
 	case types.StorageEnginePostgres:
-		return postgres.New()
+		return postgres.New(version)
 	case types.StorageEngineMysql:
-		return mysql.New()
+		return mysql.New(version)
 	case types.StorageEngineMongo:
-		return mongo.New()
+		return mongo.New(version)
 	case types.StorageEngineRedis:
-		return redis.New()
+		return redis.New(version)
+	case types.StorageEngineRabbitMQ:
+		return rabbitmq.New(version)
+	case types.StorageEngineMinio:
+		return minio.New(version)
 	default:
 		panic("unsupported engine " + string(engine))
 	}
 }
+
+// Mounts are the volumes of the container serving a storage engine in the environment of dep, its data is on volume
+func Mounts(provider Provider, dep *types.Deployment, volume string) map[string]string {
+	mounts := map[string]string{volume: provider.DataPath()}
+	if archiver, ok := provider.(Archiver); ok {
+		mounts[dep.ArchiveVolumeName(provider.Engine())] = archiver.ArchivePath()
+	}
+	return mounts
+}
+
+// WriteConfig renders the configuration file of a storage engine and writes it where the container mounts it from.
+// it returns the file and its bind mount
+func WriteConfig(provider Provider, dep *types.Deployment, resources types.ResourceAllocation, overrides types.ConfigSettings) (types.DatabaseConfigFile, string, error) {
+	file, err := provider.Setup(resources, overrides)
+	if err != nil {
+		return types.DatabaseConfigFile{}, "", err
+	}
+
+	path := dep.DatabaseConfigPath(file.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return types.DatabaseConfigFile{}, "", err
+	}
+
+	// the engines run as their own user, the file must be readable by them
+	if err := os.WriteFile(path, file.Content, 0644); err != nil {
+		return types.DatabaseConfigFile{}, "", err
+	}
+	return file, path + ":" + file.Path + ":ro", nil
+}
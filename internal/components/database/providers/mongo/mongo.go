@@ -1,34 +1,122 @@
 package mongo
 
 import (
+	"errors"
 	"fmt"
+	"gopkg.in/yaml.v3"
+	"math"
+	"sarabi/internal/components/database/providers/tuning"
 	"sarabi/internal/misc"
 	types "sarabi/internal/types"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type mongoProvider struct{}
+const configPath = "/etc/mongo/mongod.conf"
 
-func New() *mongoProvider {
-	return &mongoProvider{}
+type mongoProvider struct {
+	version string
+}
+
+func New(version string) *mongoProvider {
+	return &mongoProvider{version: version}
 }
 
 func (p mongoProvider) ContainerName(dep *types.Deployment) string {
 	return fmt.Sprintf("mongo-%s-%s", dep.Application.Name, dep.Environment)
 }
 
+// databaseName is the database the application of dep is given in an environment
+func databaseName(dep *types.Deployment) string {
+	return fmt.Sprintf("mongo-%s-%s", dep.Application.Name, dep.Environment)
+}
+
 func (p mongoProvider) Image() string {
-	return "mongo:8.0.3"
+	return "mongo:" + p.version
+}
+
+func (p mongoProvider) Version() string {
+	return p.version
+}
+
+func (p mongoProvider) Setup(resources types.ResourceAllocation, overrides types.ConfigSettings) (types.DatabaseConfigFile, error) {
+	// the WiredTiger default, computed from the container memory instead of the host's
+	cacheSize := max(0.25, (float64(tuning.MemoryMB(resources))/1024-1)*0.5)
+	tuned := types.ConfigSettings{
+		"net.port":      p.Port(),
+		"net.bindIpAll": "true",
+		"storage.wiredTiger.engineConfig.cacheSizeGB": strconv.FormatFloat(math.Round(cacheSize*100)/100, 'f', -1, 64),
+	}
+
+	settings, err := tuning.Merge(tuned, overrides)
+	if err != nil {
+		return types.DatabaseConfigFile{}, err
+	}
+
+	// the replica set is started from the command line, the image drops it while it creates the root user
+	cmd := []string{"mongod", "--config", configPath}
+	if name := settings[replicaSetSetting]; name != "" {
+		if cmd, err = replicaSetCmd(name, p.Port(), cmd); err != nil {
+			return types.DatabaseConfigFile{}, err
+		}
+	}
+	delete(settings, replicaSetSetting)
+
+	// settings are dotted paths into the yaml document
+	doc := make(map[string]interface{})
+	for _, k := range tuning.Keys(settings) {
+		parts := strings.Split(k, ".")
+		node := doc
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				if _, exists := node[part]; exists {
+					return types.DatabaseConfigFile{}, fmt.Errorf("setting %s conflicts with %s", k, part)
+				}
+				child = make(map[string]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+
+		leaf := parts[len(parts)-1]
+		if _, exists := node[leaf]; exists {
+			return types.DatabaseConfigFile{}, fmt.Errorf("setting %s conflicts with another one", k)
+		}
+		node[leaf] = yamlValue(settings[k])
+	}
+
+	content, err := yaml.Marshal(doc)
+	if err != nil {
+		return types.DatabaseConfigFile{}, err
+	}
+
+	return types.DatabaseConfigFile{
+		Name:    "mongod.conf",
+		Path:    configPath,
+		Content: append([]byte("# generated by sarabi, use sarabi db config set to change it\n"), content...),
+		Cmd:     cmd,
+	}, nil
 }
 
-func (p mongoProvider) Setup() error {
-	// TODO:
-	// 1. write mongo config
-	return nil
+// yamlValue keeps numbers and booleans unquoted, mongod rejects them as strings
+func yamlValue(value string) interface{} {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return value
 }
 
 func (p mongoProvider) EnvVars(dep *types.Deployment) []types.CreateSecretParams {
 	password, _ := misc.DefaultRandomIdGenerator.Generate(64)
-	dbName := fmt.Sprintf("mongo-%s-%s", dep.Application.Name, dep.Environment)
+	dbName := databaseName(dep)
 	username := fmt.Sprintf("%s-%s-user", dep.Application.Name, dep.Environment)
 	host := fmt.Sprintf("mongo-%s-%s", dep.Application.Name, dep.Environment)
 	databaseUrl := misc.FormatURI("mongo", username, password, host, p.Port(), dbName, "disable")
@@ -41,6 +129,74 @@ func (p mongoProvider) EnvVars(dep *types.Deployment) []types.CreateSecretParams
 	}
 }
 
+// RotateCredentials creates a new root user, mongo users only have one password. the previous user is
+// dropped by the new one. the container must be created again, the tools run in it authenticate with its variables
+func (p mongoProvider) RotateCredentials(dep *types.Deployment, vars map[string]string) (types.RotationPlan, error) {
+	previous, host := vars["MONGO_INITDB_ROOT_USERNAME"], vars["MONGO_HOST"]
+	if previous == "" || host == "" {
+		return types.RotationPlan{}, errors.New("mongo variables are missing")
+	}
+
+	password, err := misc.DefaultRandomIdGenerator.Generate(64)
+	if err != nil {
+		return types.RotationPlan{}, err
+	}
+	user := fmt.Sprintf("%s-%s-user-%s", dep.Application.Name, dep.Environment, time.Now().Format("20060102150405"))
+	dbName := databaseName(dep)
+
+	grant := fmt.Sprintf(`db.getSiblingDB("admin").createUser({user: %q, pwd: %q, roles: [{role: "root", db: "admin"}]})`, user, password)
+	revoke := fmt.Sprintf(`db.getSiblingDB("admin").dropUser(%q)`, previous)
+
+	databaseUrl := misc.FormatURI("mongo", user, password, host, p.Port(), dbName, "disable")
+	if name := replicaSetName(vars["MONGO_DATABASE_URL"]); name != "" {
+		if databaseUrl, err = replicaSetURL(databaseUrl, name); err != nil {
+			return types.RotationPlan{}, err
+		}
+	}
+	return types.RotationPlan{
+		PreviousUser: previous,
+		User:         user,
+		Vars: []types.CreateSecretParams{
+			{Key: "MONGO_INITDB_ROOT_USERNAME", Value: user, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+			{Key: "MONGO_INITDB_ROOT_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+			{Key: "MONGO_DATABASE_URL", Value: databaseUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		},
+		Grant: []string{"sh", "-c", `mongosh --quiet -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin --eval "$0"`, grant},
+		Revoke: []string{"sh", "-c", `mongosh --quiet -u "$1" -p "$2" --authenticationDatabase admin --eval "$0"`,
+			revoke, user, password},
+		Restart: true,
+	}, nil
+}
+
+// ExternalVars keeps the URL as it is, it may list several hosts or be a mongodb+srv one.
+// the user of the URL stands in for the root user of a mongo run by sarabi
+func (p mongoProvider) ExternalVars(dep *types.Deployment, rawURL string) ([]types.CreateSecretParams, error) {
+	u, err := misc.ParseURI(rawURL, "mongodb", "mongodb+srv")
+	if err != nil {
+		return nil, err
+	}
+	if u.User.Username() == "" {
+		return nil, errors.New("the URL must have a user")
+	}
+
+	password, _ := u.User.Password()
+	port := u.Port()
+	if port == "" && u.Scheme == "mongodb" {
+		port = p.Port()
+	}
+	return []types.CreateSecretParams{
+		{Key: "MONGO_INITDB_ROOT_USERNAME", Value: u.User.Username(), Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MONGO_HOST", Value: u.Hostname(), Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MONGO_PORT", Value: port, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MONGO_INITDB_ROOT_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MONGO_DATABASE_URL", Value: rawURL, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}, nil
+}
+
+func (p mongoProvider) PingCommand() []string {
+	return []string{"sh", "-c", `mongosh "$MONGO_DATABASE_URL" --quiet --eval 'db.runCommand({ ping: 1 })' >/dev/null`}
+}
+
 func (p mongoProvider) DataPath() string {
 	return "/data/db"
 }
@@ -52,3 +208,117 @@ func (p mongoProvider) Port() string {
 func (p mongoProvider) Engine() types.StorageEngine {
 	return types.StorageEngineMongo
 }
+
+func (p mongoProvider) ReadyCommand() []string {
+	// through the container address, the server running the init scripts only listens on localhost.
+	// a member of a replica set only takes writes once it is the primary
+	return []string{"sh", "-c", `mongosh --quiet --host "$(hostname)" -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin --eval 'quit(db.hello().isWritablePrimary ? 0 : 1)'`}
+}
+
+func (p mongoProvider) ShellVars() []string {
+	return []string{"MONGO_INITDB_ROOT_USERNAME", "MONGO_INITDB_ROOT_PASSWORD"}
+}
+
+func (p mongoProvider) ShellCommand(command string) []string {
+	cmd := []string{"sh", "-c", `exec mongosh --host "$(hostname)" -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin "$@"`, "mongosh"}
+	if command != "" {
+		cmd = append(cmd, "--quiet", "--eval", command)
+	}
+	return cmd
+}
+
+func (p mongoProvider) ImportCommand(host string) []string {
+	// the admin database is left out, the root user already exists in the new container
+	script := fmt.Sprintf(`set -eo pipefail
+mongodump --host %s -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin --archive |
+  mongorestore -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin --archive --drop --nsExclude 'admin.*' --nsExclude 'config.*'`, host)
+	return []string{"bash", "-c", script}
+}
+
+func (p mongoProvider) ChecksumCommand() []string {
+	script := `db.getMongo().getDBNames().filter(n => !["admin", "config", "local"].includes(n)).sort().forEach(n => {
+  const d = db.getSiblingDB(n);
+  d.getCollectionNames().sort().forEach(c => print(n + "." + c + "=" + d.getCollection(c).countDocuments({})));
+})`
+	return []string{"sh", "-c", `mongosh --quiet -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin --eval "$0"`, script}
+}
+
+// LoadCommand restores a mongodump archive or directory. the database of the environment a sarabi
+// backup was taken from is restored under the name of the database of dep
+func (p mongoProvider) LoadCommand(format types.DumpFormat, from, dep *types.Deployment) ([]string, error) {
+	restore := `mongorestore -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin --nsExclude 'admin.*' --nsExclude 'config.*'`
+	var args []string
+	// the entries of the oplog name the database they were written to, they can only be replayed into the same one
+	replay := `[ ! -f "$dump/oplog.bson" ] || replay=--oplogReplay`
+	if from != nil && databaseName(from) != databaseName(dep) {
+		args = []string{"--nsFrom", databaseName(from) + ".*", "--nsTo", databaseName(dep) + ".*"}
+		replay = ""
+	}
+
+	switch format {
+	case types.DumpFormatMongoArchive:
+		return append([]string{"sh", "-c", "exec " + restore + ` --archive "$@"`, "mongorestore"}, args...), nil
+	case types.DumpFormatMongoDirectory:
+		// the dump directory is wherever the folders of the databases are in the archive. the oplog of a dump
+		// taken from a replica set sits next to them, it is replayed so that the data is as it was when the dump ended
+		script := `set -eo pipefail
+dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+tar -x -C "$dir" -f -
+bson=$(find "$dir" -name '*.bson' ! -name oplog.bson -print -quit)
+[ -n "$bson" ] || exit 0
+dump=$(dirname "$(dirname "$bson")")
+replay=
+` + replay + `
+` + restore + ` --dir "$dump" $replay "$@"`
+		return append([]string{"bash", "-c", script, "mongorestore"}, args...), nil
+	default:
+		return nil, fmt.Errorf("a %s dump can not be imported into mongo", format)
+	}
+}
+
+// AnonymiseCommand updates the fields named by rules in the database of dep, a field is a dotted path into
+// the documents of a collection. hashes are md5 of the salted value
+func (p mongoProvider) AnonymiseCommand(dep *types.Deployment, rules []types.AnonymisationRule, salt string) ([]string, error) {
+	var script strings.Builder
+	script.WriteString(fmt.Sprintf(`const crypto = require("crypto");
+const target = db.getSiblingDB(%q);
+const hash = v => crypto.createHash("md5").update(%q + String(v)).digest("hex");
+const get = (doc, path) => path.split(".").reduce((o, k) => o == null ? o : o[k], doc);
+`, databaseName(dep), salt))
+
+	for _, rule := range rules {
+		var value string
+		switch rule.Strategy {
+		case types.AnonymisationHash:
+			value = "hash(v)"
+		case types.AnonymisationNull:
+			value = "null"
+		case types.AnonymisationFake:
+			switch rule.FakeKind() {
+			case types.FakeEmail:
+				value = `"user_" + hash(v).slice(0, 12) + "@example.com"`
+			case types.FakeName:
+				value = `"Person " + hash(v).slice(0, 6).toUpperCase()`
+			case types.FakePhone:
+				value = `"+1555" + String(parseInt(hash(v).slice(0, 7), 16) % 10000000).padStart(7, "0")`
+			default:
+				value = "hash(v).slice(0, 16)"
+			}
+		default:
+			return nil, fmt.Errorf("unknown anonymisation strategy %q", rule.Strategy)
+		}
+
+		script.WriteString(fmt.Sprintf(`target.getCollection(%[1]q).find({ %[2]q: { $ne: null } }).forEach(doc => {
+  const v = get(doc, %[2]q);
+  target.getCollection(%[1]q).updateOne({ _id: doc._id }, { $set: { %[2]q: %[3]s } });
+});
+`, rule.Table, rule.Column, value))
+	}
+	return []string{"sh", "-c", `mongosh --quiet -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin --eval "$0"`, script.String()}, nil
+}
+
+func (p mongoProvider) WipeCommand() []string {
+	script := `db.getMongo().getDBNames().filter(n => !["admin", "config", "local"].includes(n)).forEach(n => db.getSiblingDB(n).dropDatabase())`
+	return []string{"sh", "-c", `mongosh --quiet -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin --eval "$0"`, script}
+}
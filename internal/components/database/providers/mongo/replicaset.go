@@ -0,0 +1,95 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	types "sarabi/internal/types"
+)
+
+const (
+	// replicaSetSetting names the replica set mongo runs as, it runs standalone when it is not set
+	replicaSetSetting = "replication.replSetName"
+	// keyFile is where the members of the set find the key they authenticate each other with,
+	// it is created in the data volume on the first start and kept with the data
+	keyFile = "/data/db/sarabi.key"
+)
+
+var replicaSetNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// replicaSetCmd runs cmd as the only member of a replica set. the set is initiated once mongod accepts
+// connections with the root user, a set whose member was named after another container, e.g after an
+// upgrade copied its data, is pointed at this one
+func replicaSetCmd(name, port string, cmd []string) ([]string, error) {
+	if !replicaSetNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid replica set name: %s", name)
+	}
+
+	initiate := fmt.Sprintf(`const host = require("os").hostname() + ":%[2]s";
+let conf = null;
+try {
+  conf = rs.conf();
+} catch (e) {
+  if (e.codeName !== "NotYetInitialized") throw e;
+}
+if (conf === null) {
+  rs.initiate({ _id: %[1]q, members: [{ _id: 0, host: host }] });
+} else if (conf.members.length === 1 && conf.members[0].host !== host) {
+  conf.members[0].host = host;
+  rs.reconfig(conf, { force: true });
+}`, name, port)
+
+	script := fmt.Sprintf(`set -e
+if [ ! -s %[1]s ]; then
+  head -c 756 /dev/urandom | base64 | tr -d '\n' > %[1]s
+fi
+chown mongodb:mongodb %[1]s
+chmod 400 %[1]s
+(
+  until mongosh --quiet --host "$(hostname)" -u "$MONGO_INITDB_ROOT_USERNAME" -p "$MONGO_INITDB_ROOT_PASSWORD" \
+    --authenticationDatabase admin --eval "$0" >/dev/null 2>&1; do
+    sleep 2
+  done
+) &
+exec docker-entrypoint.sh "$@" --replSet %[2]s --keyFile %[1]s`, keyFile, name)
+	return append([]string{"sh", "-c", script, initiate}, cmd...), nil
+}
+
+// ConfigVars names the replica set in MONGO_DATABASE_URL, drivers then connect to the primary of the set
+// and can run transactions. the name is taken out again once mongo runs standalone
+func (p mongoProvider) ConfigVars(dep *types.Deployment, vars map[string]string, overrides types.ConfigSettings) ([]types.CreateSecretParams, error) {
+	databaseUrl, err := replicaSetURL(vars["MONGO_DATABASE_URL"], overrides[replicaSetSetting])
+	if err != nil {
+		return nil, err
+	}
+	return []types.CreateSecretParams{
+		{Key: "MONGO_DATABASE_URL", Value: databaseUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}, nil
+}
+
+// replicaSetURL sets the replica set of a connection URL, it is removed when name is empty
+func replicaSetURL(rawURL, name string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || rawURL == "" {
+		return "", errors.New("mongo variables are missing")
+	}
+
+	q := u.Query()
+	if name == "" {
+		q.Del("replicaSet")
+	} else {
+		q.Set("replicaSet", name)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// replicaSetName is the replica set a connection URL names, empty when it has none
+func replicaSetName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("replicaSet")
+}
@@ -1,15 +1,21 @@
 package mysql
 
 import (
+	"errors"
 	"fmt"
+	"sarabi/internal/components/database/providers/tuning"
 	"sarabi/internal/misc"
 	types "sarabi/internal/types"
+	"strconv"
+	"strings"
 )
 
-type mysqlProvider struct{}
+type mysqlProvider struct {
+	version string
+}
 
-func New() *mysqlProvider {
-	return &mysqlProvider{}
+func New(version string) *mysqlProvider {
+	return &mysqlProvider{version: version}
 }
 
 func (p mysqlProvider) ContainerName(dep *types.Deployment) string {
@@ -17,13 +23,42 @@ func (p mysqlProvider) ContainerName(dep *types.Deployment) string {
 }
 
 func (p mysqlProvider) Image() string {
-	return "mysql:9.1.0"
+	return "mysql:" + p.version
+}
+
+func (p mysqlProvider) Version() string {
+	return p.version
 }
 
-func (p mysqlProvider) Setup() error {
-	// TODO:
-	// 1. write mysql config
-	return nil
+func (p mysqlProvider) Setup(resources types.ResourceAllocation, overrides types.ConfigSettings) (types.DatabaseConfigFile, error) {
+	cpus := tuning.CPUs(resources)
+	bufferPool := max(128, tuning.MemoryMB(resources)/2)
+	tuned := types.ConfigSettings{
+		"max_connections":              "151",
+		"innodb_buffer_pool_size":      fmt.Sprintf("%dM", bufferPool),
+		"innodb_buffer_pool_instances": strconv.FormatInt(min(8, max(1, bufferPool/1024)), 10),
+		"innodb_log_buffer_size":       "16M",
+		"innodb_read_io_threads":       strconv.Itoa(max(4, cpus)),
+		"innodb_write_io_threads":      strconv.Itoa(max(4, cpus)),
+	}
+
+	settings, err := tuning.Merge(tuned, overrides)
+	if err != nil {
+		return types.DatabaseConfigFile{}, err
+	}
+
+	var content strings.Builder
+	content.WriteString("# generated by sarabi, use sarabi db config set to change it\n[mysqld]\n")
+	for _, k := range tuning.Keys(settings) {
+		_, _ = fmt.Fprintf(&content, "%s = %s\n", k, settings[k])
+	}
+
+	// the image loads every file of conf.d
+	return types.DatabaseConfigFile{
+		Name:    "my.cnf",
+		Path:    "/etc/mysql/conf.d/sarabi.cnf",
+		Content: []byte(content.String()),
+	}, nil
 }
 
 func (p mysqlProvider) EnvVars(dep *types.Deployment) []types.CreateSecretParams {
@@ -44,6 +79,85 @@ func (p mysqlProvider) EnvVars(dep *types.Deployment) []types.CreateSecretParams
 	}
 }
 
+// RotateCredentials gives the application user a second password, mysql keeps accepting the
+// current one until it is discarded
+func (p mysqlProvider) RotateCredentials(dep *types.Deployment, vars map[string]string) (types.RotationPlan, error) {
+	if types.MajorVersion(p.version) < 8 {
+		return types.RotationPlan{}, fmt.Errorf("rotating credentials requires mysql 8 or later, running %s", p.version)
+	}
+
+	user, dbName, host := vars["MYSQL_USER"], vars["MYSQL_DATABASE"], vars["MYSQL_HOST"]
+	if user == "" || dbName == "" || host == "" {
+		return types.RotationPlan{}, errors.New("mysql variables are missing")
+	}
+
+	password, err := misc.DefaultRandomIdGenerator.Generate(64)
+	if err != nil {
+		return types.RotationPlan{}, err
+	}
+
+	account := quoteLiteral(user) + "@'%'"
+	databaseUrl := misc.FormatURI("mysql", user, password, host, p.Port(), dbName, "disable")
+	return types.RotationPlan{
+		PreviousUser: user,
+		User:         user,
+		Vars: []types.CreateSecretParams{
+			{Key: "MYSQL_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+			{Key: "MYSQL_DATABASE_URL", Value: databaseUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		},
+		Grant:  mysql(fmt.Sprintf("ALTER USER %s IDENTIFIED BY %s RETAIN CURRENT PASSWORD", account, quoteLiteral(password))),
+		Revoke: mysql(fmt.Sprintf("ALTER USER %s DISCARD OLD PASSWORD", account)),
+	}, nil
+}
+
+func mysql(statement string) []string {
+	return []string{"sh", "-c", `mysql -h 127.0.0.1 -u root -p"$MYSQL_ROOT_PASSWORD" -e "$0"`, statement}
+}
+
+func quoteIdentifier(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ExternalVars turns the URL into the DSN applications get from a mysql run by sarabi, TLS is on
+// unless the URL sets tls. there is no root password, the tools log in as the user of the URL
+func (p mysqlProvider) ExternalVars(dep *types.Deployment, rawURL string) ([]types.CreateSecretParams, error) {
+	u, err := misc.ParseURI(rawURL, "mysql")
+	if err != nil {
+		return nil, err
+	}
+
+	dbName := strings.TrimPrefix(u.Path, "/")
+	if dbName == "" || u.User.Username() == "" {
+		return nil, errors.New("the URL must have a user and name a database")
+	}
+	password, _ := u.User.Password()
+	port := u.Port()
+	if port == "" {
+		port = p.Port()
+	}
+	tls := u.Query().Get("tls")
+	if tls == "" {
+		tls = "true"
+	}
+	databaseUrl := misc.FormatURI("mysql", u.User.Username(), password, u.Hostname(), port, dbName, tls)
+	return []types.CreateSecretParams{
+		{Key: "MYSQL_DATABASE", Value: dbName, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MYSQL_USER", Value: u.User.Username(), Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MYSQL_HOST", Value: u.Hostname(), Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MYSQL_PORT", Value: port, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MYSQL_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "MYSQL_DATABASE_URL", Value: databaseUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}, nil
+}
+
+func (p mysqlProvider) PingCommand() []string {
+	return []string{"sh", "-c", `MYSQL_PWD="$MYSQL_PASSWORD" mysql -h "$MYSQL_HOST" -P "$MYSQL_PORT" -u "$MYSQL_USER" -e 'SELECT 1' "$MYSQL_DATABASE" >/dev/null`}
+}
+
 func (p mysqlProvider) DataPath() string {
 	return "/var/lib/mysql"
 }
@@ -55,3 +169,83 @@ func (p mysqlProvider) Port() string {
 func (p mysqlProvider) Engine() types.StorageEngine {
 	return types.StorageEngineMysql
 }
+
+func (p mysqlProvider) ReadyCommand() []string {
+	return []string{"sh", "-c", `mysqladmin ping -h 127.0.0.1 -u root -p"$MYSQL_ROOT_PASSWORD" --silent`}
+}
+
+func (p mysqlProvider) ShellVars() []string {
+	return []string{"MYSQL_USER", "MYSQL_PASSWORD", "MYSQL_DATABASE"}
+}
+
+func (p mysqlProvider) ShellCommand(command string) []string {
+	cmd := []string{"sh", "-c", `MYSQL_PWD="$MYSQL_PASSWORD" exec mysql -h 127.0.0.1 -u "$MYSQL_USER" "$@" "$MYSQL_DATABASE"`, "mysql"}
+	if command != "" {
+		cmd = append(cmd, "-e", command)
+	}
+	return cmd
+}
+
+func (p mysqlProvider) ImportCommand(host string) []string {
+	// only the application database is copied, the users are created again by the new container from the same variables
+	script := fmt.Sprintf(`set -eo pipefail
+mysqldump -h %s -u root -p"$MYSQL_ROOT_PASSWORD" --single-transaction --routines --triggers --events --databases "$MYSQL_DATABASE" |
+  mysql -h 127.0.0.1 -u root -p"$MYSQL_ROOT_PASSWORD"`, host)
+	return []string{"bash", "-c", script}
+}
+
+func (p mysqlProvider) ChecksumCommand() []string {
+	script := `set -eo pipefail
+for t in $(mysql -N -h 127.0.0.1 -u root -p"$MYSQL_ROOT_PASSWORD" -e 'SHOW TABLES' "$MYSQL_DATABASE" | sort); do
+  echo "$t=$(mysql -N -h 127.0.0.1 -u root -p"$MYSQL_ROOT_PASSWORD" -e "SELECT count(*) FROM ` + "`$t`" + `" "$MYSQL_DATABASE")"
+done`
+	return []string{"bash", "-c", script}
+}
+
+func (p mysqlProvider) LoadCommand(format types.DumpFormat, from, dep *types.Deployment) ([]string, error) {
+	switch format {
+	case types.DumpFormatSQL, types.DumpFormatMysqldump:
+		return []string{"sh", "-c", `MYSQL_PWD="$MYSQL_ROOT_PASSWORD" exec mysql -h 127.0.0.1 -u root "$MYSQL_DATABASE"`}, nil
+	default:
+		return nil, fmt.Errorf("a %s dump can not be imported into mysql", format)
+	}
+}
+
+// AnonymiseCommand updates the columns named by rules in one transaction. hashes are md5 of the salted value
+func (p mysqlProvider) AnonymiseCommand(dep *types.Deployment, rules []types.AnonymisationRule, salt string) ([]string, error) {
+	statements := []string{"START TRANSACTION;"}
+	for _, rule := range rules {
+		column := quoteIdentifier(rule.Column)
+		hash := fmt.Sprintf("MD5(CONCAT(%s, %s))", quoteLiteral(salt), column)
+		var value string
+		switch rule.Strategy {
+		case types.AnonymisationHash:
+			value = hash
+		case types.AnonymisationNull:
+			value = "NULL"
+		case types.AnonymisationFake:
+			switch rule.FakeKind() {
+			case types.FakeEmail:
+				value = fmt.Sprintf("CONCAT('user_', LEFT(%s, 12), '@example.com')", hash)
+			case types.FakeName:
+				value = fmt.Sprintf("CONCAT('Person ', UPPER(LEFT(%s, 6)))", hash)
+			case types.FakePhone:
+				value = fmt.Sprintf("CONCAT('+1555', LPAD(CONV(LEFT(%s, 7), 16, 10) %% 10000000, 7, '0'))", hash)
+			default:
+				value = fmt.Sprintf("LEFT(%s, 16)", hash)
+			}
+		default:
+			return nil, fmt.Errorf("unknown anonymisation strategy %q", rule.Strategy)
+		}
+
+		statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NOT NULL;",
+			quoteIdentifier(rule.Table), column, value, column))
+	}
+	statements = append(statements, "COMMIT;")
+	return []string{"sh", "-c", `MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysql -h 127.0.0.1 -u root -e "$0" "$MYSQL_DATABASE"`, strings.Join(statements, "\n")}, nil
+}
+
+func (p mysqlProvider) WipeCommand() []string {
+	// the grants of the application user are kept, they are bound to the name of the database
+	return []string{"sh", "-c", "MYSQL_PWD=\"$MYSQL_ROOT_PASSWORD\" mysql -h 127.0.0.1 -u root -e \"DROP DATABASE IF EXISTS \\`$MYSQL_DATABASE\\`; CREATE DATABASE \\`$MYSQL_DATABASE\\`\""}
+}
@@ -1,15 +1,24 @@
 package postgres
 
 import (
+	"errors"
 	"fmt"
+	"sarabi/internal/components/database/providers/tuning"
 	"sarabi/internal/misc"
 	types "sarabi/internal/types"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type postgresProvider struct{}
+const configPath = "/etc/postgresql/postgresql.conf"
 
-func New() *postgresProvider {
-	return &postgresProvider{}
+type postgresProvider struct {
+	version string
+}
+
+func New(version string) *postgresProvider {
+	return &postgresProvider{version: version}
 }
 
 func (p postgresProvider) ContainerName(dep *types.Deployment) string {
@@ -17,19 +26,50 @@ func (p postgresProvider) ContainerName(dep *types.Deployment) string {
 }
 
 func (p postgresProvider) Image() string {
-	return "postgres:17"
+	return "postgres:" + p.version
+}
+
+func (p postgresProvider) Version() string {
+	return p.version
 }
 
-func (p postgresProvider) Setup() error {
-	// TODO:
-	// 1. write postgresql config type
-	//  PostgresSQLConfig struct {
-	//    MaxConnections      int // 300
-	//    SharedBuffers       string // 30% of total memory
-	//    WorkMem             string // 16MB
-	//    MaintenanceWorkMem  string // 200MB
-	//}
-	return nil
+func (p postgresProvider) Setup(resources types.ResourceAllocation, overrides types.ConfigSettings) (types.DatabaseConfigFile, error) {
+	memory := tuning.MemoryMB(resources)
+	cpus := tuning.CPUs(resources)
+	maxConnections := tuning.Int(overrides, "max_connections", 100)
+	tuned := types.ConfigSettings{
+		"listen_addresses":                 "*",
+		"max_connections":                  strconv.Itoa(maxConnections),
+		"shared_buffers":                   fmt.Sprintf("%dMB", memory/4),
+		"effective_cache_size":             fmt.Sprintf("%dMB", memory*3/4),
+		"maintenance_work_mem":             fmt.Sprintf("%dMB", min(memory/16, 2048)),
+		"work_mem":                         fmt.Sprintf("%dkB", max(4096, memory*1024/4/int64(maxConnections*3))),
+		"wal_buffers":                      "16MB",
+		"max_worker_processes":             strconv.Itoa(max(8, cpus)),
+		"max_parallel_workers":             strconv.Itoa(cpus),
+		"max_parallel_workers_per_gather":  strconv.Itoa(max(1, cpus/2)),
+		"max_parallel_maintenance_workers": strconv.Itoa(max(1, cpus/2)),
+		"random_page_cost":                 "1.1",
+		"effective_io_concurrency":         "200",
+	}
+
+	settings, err := tuning.Merge(tuned, overrides)
+	if err != nil {
+		return types.DatabaseConfigFile{}, err
+	}
+
+	var content strings.Builder
+	content.WriteString("# generated by sarabi, use sarabi db config set to change it\n")
+	for _, k := range tuning.Keys(settings) {
+		_, _ = fmt.Fprintf(&content, "%s = '%s'\n", k, strings.ReplaceAll(settings[k], "'", "''"))
+	}
+
+	return types.DatabaseConfigFile{
+		Name:    "postgresql.conf",
+		Path:    configPath,
+		Content: []byte(content.String()),
+		Cmd:     []string{"postgres", "-c", "config_file=" + configPath},
+	}, nil
 }
 
 func (p postgresProvider) EnvVars(dep *types.Deployment) []types.CreateSecretParams {
@@ -48,10 +88,100 @@ func (p postgresProvider) EnvVars(dep *types.Deployment) []types.CreateSecretPar
 	}
 }
 
+// RotateCredentials moves the application to a new login role, postgres roles only have one password.
+// the new role acts as the role owning the data. the previous login keeps its role but loses its password,
+// the tools run in the container connect over the local socket without one
+func (p postgresProvider) RotateCredentials(dep *types.Deployment, vars map[string]string) (types.RotationPlan, error) {
+	previous, dbName, host := vars["POSTGRES_USER"], vars["POSTGRES_DB"], vars["POSTGRES_HOST"]
+	if previous == "" || dbName == "" || host == "" {
+		return types.RotationPlan{}, errors.New("postgres variables are missing")
+	}
+
+	password, err := misc.DefaultRandomIdGenerator.Generate(64)
+	if err != nil {
+		return types.RotationPlan{}, err
+	}
+	owner := fmt.Sprintf("%s-%s-user", dep.Application.Name, dep.Environment)
+	user := fmt.Sprintf("%s-%s", owner, time.Now().Format("20060102150405"))
+
+	grant := fmt.Sprintf(`CREATE ROLE %s LOGIN PASSWORD %s IN ROLE %s;
+ALTER ROLE %s SET role = %s;`, quoteIdentifier(user), quoteLiteral(password), quoteIdentifier(owner), quoteIdentifier(user), quoteLiteral(owner))
+	revoke := fmt.Sprintf(`ALTER ROLE %s PASSWORD NULL;
+SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE usename = %s AND client_addr IS NOT NULL AND pid <> pg_backend_pid();`,
+		quoteIdentifier(previous), quoteLiteral(previous))
+
+	databaseUrl := misc.FormatURI("postgres", user, password, host, p.Port(), dbName, "disable")
+	newVars := []types.CreateSecretParams{
+		{Key: "POSTGRES_USER", Value: user, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "POSTGRES_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "POSTGRES_DATABASE_URL", Value: databaseUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}
+	// the replicas hold the same roles, the read URL only changes credentials
+	if readAt := readHost(vars); readAt != "" {
+		readUrl := misc.FormatURI("postgres", user, password, readAt, p.Port(), dbName, "disable")
+		newVars = append(newVars, types.CreateSecretParams{Key: "POSTGRES_READ_URL", Value: readUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID})
+	}
+
+	return types.RotationPlan{
+		PreviousUser: previous,
+		User:         user,
+		Vars:         newVars,
+		Grant:        psql(grant),
+		Revoke:       psql(revoke),
+	}, nil
+}
+
+func psql(statements string) []string {
+	return []string{"sh", "-c", `psql -q -v ON_ERROR_STOP=1 -U "$POSTGRES_USER" -d "$POSTGRES_DB" -c "$0"`, statements}
+}
+
+func quoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ExternalVars keeps the URL as it is, its parameters e.g sslmode are what the server expects
+func (p postgresProvider) ExternalVars(dep *types.Deployment, rawURL string) ([]types.CreateSecretParams, error) {
+	u, err := misc.ParseURI(rawURL, "postgres", "postgresql")
+	if err != nil {
+		return nil, err
+	}
+
+	dbName := strings.TrimPrefix(u.Path, "/")
+	if dbName == "" || u.User.Username() == "" {
+		return nil, errors.New("the URL must have a user and name a database")
+	}
+	password, _ := u.User.Password()
+	port := u.Port()
+	if port == "" {
+		port = p.Port()
+	}
+	return []types.CreateSecretParams{
+		{Key: "POSTGRES_DB", Value: dbName, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "POSTGRES_USER", Value: u.User.Username(), Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "POSTGRES_HOST", Value: u.Hostname(), Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "POSTGRES_PORT", Value: port, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "POSTGRES_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "POSTGRES_DATABASE_URL", Value: rawURL, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}, nil
+}
+
+func (p postgresProvider) PingCommand() []string {
+	return []string{"sh", "-c", `psql "$POSTGRES_DATABASE_URL" -qtAc 'SELECT 1' >/dev/null`}
+}
+
 func (p postgresProvider) DataPath() string {
 	return "/var/lib/postgresql/data"
 }
 
+// ArchivePath is where archive_command drops finished WAL segments until they are shipped
+func (p postgresProvider) ArchivePath() string {
+	return "/var/lib/postgresql/wal_archive"
+}
+
 func (p postgresProvider) Port() string {
 	return "5432"
 }
@@ -59,3 +189,99 @@ func (p postgresProvider) Port() string {
 func (p postgresProvider) Engine() types.StorageEngine {
 	return types.StorageEnginePostgres
 }
+
+func (p postgresProvider) ReadyCommand() []string {
+	// over tcp, the server running the init scripts only listens on the unix socket
+	return []string{"sh", "-c", `pg_isready -h 127.0.0.1 -U "$POSTGRES_USER" -d "$POSTGRES_DB"`}
+}
+
+func (p postgresProvider) ShellVars() []string {
+	return []string{"POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB"}
+}
+
+func (p postgresProvider) ShellCommand(command string) []string {
+	cmd := []string{"sh", "-c", `PGPASSWORD="$POSTGRES_PASSWORD" exec psql -h 127.0.0.1 -U "$POSTGRES_USER" -d "$POSTGRES_DB" "$@"`, "psql"}
+	if command != "" {
+		cmd = append(cmd, "-v", "ON_ERROR_STOP=1", "-c", command)
+	}
+	return cmd
+}
+
+func (p postgresProvider) ImportCommand(host string) []string {
+	script := fmt.Sprintf(`set -eo pipefail
+PGPASSWORD="$POSTGRES_PASSWORD" pg_dump -h %s -U "$POSTGRES_USER" -d "$POSTGRES_DB" --no-owner --no-privileges |
+  psql -v ON_ERROR_STOP=1 -q -U "$POSTGRES_USER" -d "$POSTGRES_DB"`, host)
+	return []string{"bash", "-c", script}
+}
+
+func (p postgresProvider) ChecksumCommand() []string {
+	// exact row count of every table, pg_stat estimates are not reliable right after an import
+	query := `SELECT table_schema || '.' || table_name || '=' ||
+  (xpath('/row/c/text()', query_to_xml(format('SELECT count(*) AS c FROM %I.%I', table_schema, table_name), false, true, '')))[1]::text
+FROM information_schema.tables
+WHERE table_type = 'BASE TABLE' AND table_schema NOT IN ('pg_catalog', 'information_schema')
+ORDER BY 1`
+	return []string{"sh", "-c", `psql -At -v ON_ERROR_STOP=1 -U "$POSTGRES_USER" -d "$POSTGRES_DB" -c "$0"`, query}
+}
+
+func (p postgresProvider) LoadCommand(format types.DumpFormat, from, dep *types.Deployment) ([]string, error) {
+	// in one transaction, a dump failing half-way leaves nothing behind
+	switch format {
+	case types.DumpFormatSQL:
+		return []string{"sh", "-c", `exec psql -1 -q -v ON_ERROR_STOP=1 -U "$POSTGRES_USER" -d "$POSTGRES_DB"`}, nil
+	case types.DumpFormatPostgresCustom:
+		return []string{"sh", "-c", `exec pg_restore --single-transaction --exit-on-error --no-owner --no-privileges -U "$POSTGRES_USER" -d "$POSTGRES_DB"`}, nil
+	default:
+		return nil, fmt.Errorf("a %s dump can not be imported into postgres", format)
+	}
+}
+
+// AnonymiseCommand updates the columns named by rules in one transaction. hashes are md5 of the salted value
+func (p postgresProvider) AnonymiseCommand(dep *types.Deployment, rules []types.AnonymisationRule, salt string) ([]string, error) {
+	statements := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		table := make([]string, 0, 2)
+		for _, part := range strings.SplitN(rule.Table, ".", 2) {
+			table = append(table, quoteIdentifier(part))
+		}
+		column := quoteIdentifier(rule.Column)
+
+		hash := fmt.Sprintf("md5(%s || %s::text)", quoteLiteral(salt), column)
+		var value string
+		switch rule.Strategy {
+		case types.AnonymisationHash:
+			value = hash
+		case types.AnonymisationNull:
+			value = "NULL"
+		case types.AnonymisationFake:
+			switch rule.FakeKind() {
+			case types.FakeEmail:
+				value = fmt.Sprintf("'user_' || left(%s, 12) || '@example.com'", hash)
+			case types.FakeName:
+				value = fmt.Sprintf("'Person ' || upper(left(%s, 6))", hash)
+			case types.FakePhone:
+				value = fmt.Sprintf("'+1555' || lpad((('x' || left(%s, 7))::bit(28)::int %% 10000000)::text, 7, '0')", hash)
+			default:
+				value = fmt.Sprintf("left(%s, 16)", hash)
+			}
+		default:
+			return nil, fmt.Errorf("unknown anonymisation strategy %q", rule.Strategy)
+		}
+
+		statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NOT NULL;",
+			strings.Join(table, "."), column, value, column))
+	}
+	return []string{"sh", "-c", `psql -1 -q -v ON_ERROR_STOP=1 -U "$POSTGRES_USER" -d "$POSTGRES_DB" -c "$0"`, strings.Join(statements, "\n")}, nil
+}
+
+func (p postgresProvider) WipeCommand() []string {
+	query := `DO $$
+DECLARE s text;
+BEGIN
+  FOR s IN SELECT nspname FROM pg_namespace WHERE nspname NOT LIKE 'pg\_%' AND nspname <> 'information_schema' LOOP
+    EXECUTE format('DROP SCHEMA %I CASCADE', s);
+  END LOOP;
+END $$;
+CREATE SCHEMA public;`
+	return []string{"sh", "-c", `psql -q -v ON_ERROR_STOP=1 -U "$POSTGRES_USER" -d "$POSTGRES_DB" -c "$0"`, query}
+}
@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sarabi/internal/misc"
+	types "sarabi/internal/types"
+	"strconv"
+	"strings"
+)
+
+// replicationUser is the role replicas stream from the primary with, it only has the REPLICATION attribute
+const replicationUser = "sarabi_replicator"
+
+func (p postgresProvider) ReplicaName(dep *types.Deployment, index int) string {
+	return fmt.Sprintf("%s-replica-%d", p.ContainerName(dep), index)
+}
+
+// ReplicaSlot is the replication slot of a replica, the primary keeps the WAL it has not received yet
+func (p postgresProvider) ReplicaSlot(index int) string {
+	return fmt.Sprintf("replica_%d", index)
+}
+
+// ReadHost is the network alias shared by the replicas of dep, connections to it are spread across them
+func (p postgresProvider) ReadHost(dep *types.Deployment) string {
+	return p.ContainerName(dep) + "-read"
+}
+
+func (p postgresProvider) ReplicationVars(dep *types.Deployment) []types.CreateSecretParams {
+	password, _ := misc.DefaultRandomIdGenerator.Generate(64)
+	return []types.CreateSecretParams{
+		{Key: "POSTGRES_REPLICATION_USER", Value: replicationUser, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "POSTGRES_REPLICATION_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}
+}
+
+// ReadVars points POSTGRES_READ_URL at the replicas, or at the primary when there are none so that
+// applications reading from it keep working
+func (p postgresProvider) ReadVars(dep *types.Deployment, vars map[string]string, replicas int) ([]types.CreateSecretParams, error) {
+	user, password, dbName, host := vars["POSTGRES_USER"], vars["POSTGRES_PASSWORD"], vars["POSTGRES_DB"], vars["POSTGRES_HOST"]
+	if user == "" || dbName == "" || host == "" {
+		return nil, errors.New("postgres variables are missing")
+	}
+	if replicas > 0 {
+		host = p.ReadHost(dep)
+	}
+
+	readUrl := misc.FormatURI("postgres", user, password, host, p.Port(), dbName, "disable")
+	return []types.CreateSecretParams{
+		{Key: "POSTGRES_READ_URL", Value: readUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}, nil
+}
+
+func (p postgresProvider) ReplicaVarKeys() []string {
+	return []string{"POSTGRES_REPLICATION_USER", "POSTGRES_REPLICATION_PASSWORD", "POSTGRES_READ_URL"}
+}
+
+// ReplicationGrant lets the replication user stream from the primary and creates the slots of the replicas.
+// pg_hba of the image only lets it in from the container itself, the rule is added once
+func (p postgresProvider) ReplicationGrant(vars map[string]string, slots []string) ([]string, error) {
+	password := vars["POSTGRES_REPLICATION_PASSWORD"]
+	if password == "" {
+		return nil, errors.New("postgres replication variables are missing")
+	}
+
+	grant := fmt.Sprintf(`DO $$
+BEGIN
+  IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = %[1]s) THEN
+    CREATE ROLE %[2]s LOGIN REPLICATION;
+  END IF;
+END $$;
+ALTER ROLE %[2]s PASSWORD %[3]s;
+SELECT pg_create_physical_replication_slot(s) FROM unnest(ARRAY[%[4]s]::text[]) s
+WHERE s NOT IN (SELECT slot_name FROM pg_replication_slots);
+SELECT pg_reload_conf();`, quoteLiteral(replicationUser), quoteIdentifier(replicationUser), quoteLiteral(password), quoteLiterals(slots))
+
+	script := fmt.Sprintf(`set -e
+grep -q '# sarabi replication' "$PGDATA/pg_hba.conf" || echo 'host replication %s all scram-sha-256 # sarabi replication' >> "$PGDATA/pg_hba.conf"
+psql -q -v ON_ERROR_STOP=1 -U "$POSTGRES_USER" -d "$POSTGRES_DB" -c "$0"`, replicationUser)
+	return []string{"sh", "-c", script, grant}, nil
+}
+
+// DropSlotsCommand drops the slots of replicas that were removed, the WAL they held back is recycled
+func (p postgresProvider) DropSlotsCommand(slots []string) []string {
+	query := fmt.Sprintf(`SELECT pg_terminate_backend(active_pid) FROM pg_replication_slots WHERE slot_name = ANY(ARRAY[%[1]s]::text[]) AND active;
+SELECT pg_sleep(1);
+SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = ANY(ARRAY[%[1]s]::text[]);`, quoteLiterals(slots))
+	return psql(query)
+}
+
+// ReplicaContainer copies the data directory of the primary with pg_basebackup on its first start,
+// then runs as a hot standby streaming from the slot of the replica
+func (p postgresProvider) ReplicaContainer(dep *types.Deployment, index int, cmd []string) types.ReplicaContainer {
+	script := `set -e
+if [ ! -s "$PGDATA/PG_VERSION" ]; then
+  PGPASSWORD="$POSTGRES_REPLICATION_PASSWORD" pg_basebackup -h "$PRIMARY_HOST" -U "$POSTGRES_REPLICATION_USER" \
+    -D "$PGDATA" -X stream -S "$REPLICA_SLOT" --checkpoint=fast
+  touch "$PGDATA/standby.signal"
+fi
+chmod 700 "$PGDATA"
+exec "$@" -c primary_conninfo="host=$PRIMARY_HOST port=$POSTGRES_PORT user=$POSTGRES_REPLICATION_USER password=$POSTGRES_REPLICATION_PASSWORD application_name=$REPLICA_SLOT" \
+  -c primary_slot_name="$REPLICA_SLOT"`
+
+	return types.ReplicaContainer{
+		Cmd: append([]string{"sh", "-c", script, "sh"}, cmd...),
+		Envs: []string{
+			"PRIMARY_HOST=" + p.ContainerName(dep),
+			"REPLICA_SLOT=" + p.ReplicaSlot(index),
+		},
+		User: "postgres",
+	}
+}
+
+// LagCommand prints one line per replica streaming from the primary: slot|state|lag in seconds|lag in bytes
+func (p postgresProvider) LagCommand() []string {
+	query := `SELECT application_name, state,
+  COALESCE(EXTRACT(EPOCH FROM replay_lag), 0)::numeric(12, 1),
+  COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn), 0)::bigint
+FROM pg_stat_replication WHERE application_name LIKE 'replica\_%'`
+	return []string{"sh", "-c", `psql -At -v ON_ERROR_STOP=1 -U "$POSTGRES_USER" -d "$POSTGRES_DB" -c "$0"`, query}
+}
+
+// ParseLag reads the output of LagCommand by slot
+func (p postgresProvider) ParseLag(output string) (map[string]types.ReplicaStatus, error) {
+	result := make(map[string]types.ReplicaStatus)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected replication status %q", line)
+		}
+		seconds, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		result[fields[0]] = types.ReplicaStatus{State: fields[1], LagSeconds: seconds, LagBytes: bytes}
+	}
+	return result, nil
+}
+
+// readHost is the host POSTGRES_READ_URL points at, empty when it is not set
+func readHost(vars map[string]string) string {
+	u, err := url.Parse(vars["POSTGRES_READ_URL"])
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func quoteLiterals(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, quoteLiteral(v))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,197 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"sarabi/internal/components/database/providers/tuning"
+	"sarabi/internal/misc"
+	"sarabi/internal/types"
+	"strings"
+	"time"
+)
+
+type rabbitmqProvider struct {
+	version string
+}
+
+func New(version string) *rabbitmqProvider {
+	return &rabbitmqProvider{version: version}
+}
+
+func (p rabbitmqProvider) ContainerName(dep *types.Deployment) string {
+	return fmt.Sprintf("rabbitmq-%s-%s", dep.Application.Name, dep.Environment)
+}
+
+func (p rabbitmqProvider) Image() string {
+	return "rabbitmq:" + p.version
+}
+
+func (p rabbitmqProvider) Version() string {
+	return p.version
+}
+
+func (p rabbitmqProvider) Setup(resources types.ResourceAllocation, overrides types.ConfigSettings) (types.DatabaseConfigFile, error) {
+	// publishers are blocked once the broker uses this much memory, the rest is left to the erlang runtime
+	tuned := types.ConfigSettings{
+		"vm_memory_high_watermark.absolute": fmt.Sprintf("%dMiB", tuning.MemoryMB(resources)*3/5),
+	}
+
+	settings, err := tuning.Merge(tuned, overrides)
+	if err != nil {
+		return types.DatabaseConfigFile{}, err
+	}
+
+	var content strings.Builder
+	content.WriteString("# generated by sarabi, use sarabi db config set to change it\n")
+	for _, k := range tuning.Keys(settings) {
+		_, _ = fmt.Fprintf(&content, "%s = %s\n", k, settings[k])
+	}
+
+	// the image loads every file of conf.d after its own defaults
+	return types.DatabaseConfigFile{
+		Name:    "rabbitmq.conf",
+		Path:    "/etc/rabbitmq/conf.d/90-sarabi.conf",
+		Content: []byte(content.String()),
+	}, nil
+}
+
+func (p rabbitmqProvider) EnvVars(dep *types.Deployment) []types.CreateSecretParams {
+	password, _ := misc.DefaultRandomIdGenerator.Generate(64)
+	username := fmt.Sprintf("%s-%s-user", dep.Application.Name, dep.Environment)
+	host := p.ContainerName(dep)
+	return p.vars(dep, username, password, host, p.Port(), misc.FormatURI("amqp", username, password, host, p.Port(), "", ""))
+}
+
+// RotateCredentials creates a new administrator with the permissions of the previous one on every vhost.
+// rabbitmqctl talks to the broker with the erlang cookie of the container, the container is left as it is
+func (p rabbitmqProvider) RotateCredentials(dep *types.Deployment, vars map[string]string) (types.RotationPlan, error) {
+	previous, host := vars["RABBITMQ_DEFAULT_USER"], vars["RABBITMQ_HOST"]
+	if previous == "" || host == "" {
+		return types.RotationPlan{}, errors.New("rabbitmq variables are missing")
+	}
+
+	password, err := misc.DefaultRandomIdGenerator.Generate(64)
+	if err != nil {
+		return types.RotationPlan{}, err
+	}
+
+	username := fmt.Sprintf("%s-%s-user-%d", dep.Application.Name, dep.Environment, time.Now().Unix())
+	amqpUrl := misc.FormatURI("amqp", username, password, host, p.Port(), "", "")
+	grant := `set -e
+rabbitmqctl -q add_user "$0" "$1"
+rabbitmqctl -q set_user_tags "$0" administrator
+rabbitmqctl -q list_vhosts --no-table-headers | while IFS= read -r vhost; do
+  rabbitmqctl -q set_permissions -p "$vhost" "$0" '.*' '.*' '.*'
+done`
+	return types.RotationPlan{
+		PreviousUser: previous,
+		User:         username,
+		Vars: []types.CreateSecretParams{
+			{Key: "RABBITMQ_DEFAULT_USER", Value: username, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+			{Key: "RABBITMQ_DEFAULT_PASS", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+			{Key: "RABBITMQ_URL", Value: amqpUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		},
+		Grant:  []string{"sh", "-c", grant, username, password},
+		Revoke: []string{"rabbitmqctl", "-q", "delete_user", previous},
+	}, nil
+}
+
+// ExternalVars keeps the URL as it is, amqps URLs connect over TLS
+func (p rabbitmqProvider) ExternalVars(dep *types.Deployment, rawURL string) ([]types.CreateSecretParams, error) {
+	u, err := misc.ParseURI(rawURL, "amqp", "amqps")
+	if err != nil {
+		return nil, err
+	}
+
+	password, _ := u.User.Password()
+	port := u.Port()
+	if port == "" {
+		port = p.Port()
+		if u.Scheme == "amqps" {
+			port = "5671"
+		}
+	}
+	return p.vars(dep, u.User.Username(), password, u.Hostname(), port, rawURL), nil
+}
+
+func (p rabbitmqProvider) vars(dep *types.Deployment, username, password, host, port, amqpUrl string) []types.CreateSecretParams {
+	return []types.CreateSecretParams{
+		{Key: "RABBITMQ_DEFAULT_USER", Value: username, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "RABBITMQ_HOST", Value: host, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "RABBITMQ_PORT", Value: port, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "RABBITMQ_DEFAULT_PASS", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "RABBITMQ_URL", Value: amqpUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}
+}
+
+// PingCommand opens an AMQP connection to the broker, it answers the protocol header with connection.start.
+// the image has no AMQP client, the credentials are checked by the backends when they connect
+func (p rabbitmqProvider) PingCommand() []string {
+	return []string{"bash", "-c", `set -e
+exec 3<>"/dev/tcp/$RABBITMQ_HOST/$RABBITMQ_PORT"
+printf 'AMQP\x00\x00\x09\x01' >&3
+[ "$(timeout 10 head -c 1 <&3 | od -An -tx1 | tr -d ' ')" = "01" ]`}
+}
+
+func (p rabbitmqProvider) DataPath() string {
+	return "/var/lib/rabbitmq"
+}
+
+func (p rabbitmqProvider) Port() string {
+	return "5672"
+}
+
+func (p rabbitmqProvider) Engine() types.StorageEngine {
+	return types.StorageEngineRabbitMQ
+}
+
+func (p rabbitmqProvider) ReadyCommand() []string {
+	return []string{"rabbitmq-diagnostics", "-q", "check_port_connectivity"}
+}
+
+func (p rabbitmqProvider) ShellVars() []string {
+	return []string{}
+}
+
+// ShellCommand runs rabbitmqctl, rabbitmq has no interactive client so a shell is started when no command is given
+func (p rabbitmqProvider) ShellCommand(command string) []string {
+	if command != "" {
+		return []string{"sh", "-c", `eval "exec rabbitmqctl $0"`, command}
+	}
+	return []string{"bash"}
+}
+
+// ImportCommand fails, the definitions and messages of a broker can not be streamed into another one
+// without joining it to its cluster
+func (p rabbitmqProvider) ImportCommand(host string) []string {
+	return []string{"sh", "-c", `echo "the data of rabbitmq can not be copied from $0" >&2; exit 1`, host}
+}
+
+func (p rabbitmqProvider) ChecksumCommand() []string {
+	return []string{"sh", "-c", `rabbitmqctl -q list_vhosts --no-table-headers | sort | while IFS= read -r vhost; do
+  echo "$vhost"
+  rabbitmqctl -q list_queues -p "$vhost" --no-table-headers name durable | sort
+  rabbitmqctl -q list_exchanges -p "$vhost" --no-table-headers name type | sort
+done`}
+}
+
+func (p rabbitmqProvider) LoadCommand(format types.DumpFormat, from, dep *types.Deployment) ([]string, error) {
+	return nil, fmt.Errorf("a %s dump can not be imported into rabbitmq", format)
+}
+
+func (p rabbitmqProvider) AnonymiseCommand(dep *types.Deployment, rules []types.AnonymisationRule, salt string) ([]string, error) {
+	if len(rules) > 0 {
+		return nil, errors.New("rabbitmq messages have no columns, anonymisation rules can not be applied to them")
+	}
+	return []string{"true"}, nil
+}
+
+// WipeCommand deletes the queues of every vhost with the messages they hold, users and vhosts are kept
+func (p rabbitmqProvider) WipeCommand() []string {
+	return []string{"sh", "-c", `set -e
+rabbitmqctl -q list_vhosts --no-table-headers | while IFS= read -r vhost; do
+  rabbitmqctl -q list_queues -p "$vhost" --no-table-headers name | while IFS= read -r queue; do
+    rabbitmqctl -q delete_queue -p "$vhost" "$queue"
+  done
+done`}
+}
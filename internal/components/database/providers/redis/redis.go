@@ -1,15 +1,24 @@
 package redis
 
 import (
+	"errors"
 	"fmt"
+	"sarabi/internal/components/database/providers/tuning"
 	"sarabi/internal/misc"
 	"sarabi/internal/types"
+	"strconv"
+	"strings"
 )
 
-type redisProvider struct{}
+// loadPort is where the server LoadCommand starts listens, next to the engine
+const loadPort = "6380"
 
-func New() *redisProvider {
-	return &redisProvider{}
+type redisProvider struct {
+	version string
+}
+
+func New(version string) *redisProvider {
+	return &redisProvider{version: version}
 }
 
 func (p redisProvider) ContainerName(dep *types.Deployment) string {
@@ -17,13 +26,40 @@ func (p redisProvider) ContainerName(dep *types.Deployment) string {
 }
 
 func (p redisProvider) Image() string {
-	return "bitnami/redis:7.4"
+	return "bitnami/redis:" + p.version
 }
 
-func (p redisProvider) Setup() error {
-	// TODO:
-	// 1. write mongo config
-	return nil
+func (p redisProvider) Version() string {
+	return p.version
+}
+
+func (p redisProvider) Setup(resources types.ResourceAllocation, overrides types.ConfigSettings) (types.DatabaseConfigFile, error) {
+	cpus := tuning.CPUs(resources)
+	tuned := types.ConfigSettings{
+		"maxmemory":        fmt.Sprintf("%dmb", tuning.MemoryMB(resources)*3/4),
+		"maxmemory-policy": "noeviction",
+	}
+	if cpus > 1 {
+		tuned["io-threads"] = strconv.Itoa(min(4, cpus))
+	}
+
+	settings, err := tuning.Merge(tuned, overrides)
+	if err != nil {
+		return types.DatabaseConfigFile{}, err
+	}
+
+	var content strings.Builder
+	content.WriteString("# generated by sarabi, use sarabi db config set to change it\n")
+	for _, k := range tuning.Keys(settings) {
+		_, _ = fmt.Fprintf(&content, "%s %s\n", k, settings[k])
+	}
+
+	// the image appends overrides.conf to the configuration it generates
+	return types.DatabaseConfigFile{
+		Name:    "redis.conf",
+		Path:    "/opt/bitnami/redis/mounted-etc/overrides.conf",
+		Content: []byte(content.String()),
+	}, nil
 }
 
 func (p redisProvider) EnvVars(dep *types.Deployment) []types.CreateSecretParams {
@@ -41,8 +77,72 @@ func (p redisProvider) EnvVars(dep *types.Deployment) []types.CreateSecretParams
 	}
 }
 
+// RotateCredentials gives the default user a second password, redis accepts both until the current one is
+// removed. the container must be created again, the image sets the password from its variables on every start
+func (p redisProvider) RotateCredentials(dep *types.Deployment, vars map[string]string) (types.RotationPlan, error) {
+	user, previous, host := vars["REDIS_USER"], vars["REDIS_PASSWORD"], vars["REDIS_HOST"]
+	if user == "" || previous == "" || host == "" {
+		return types.RotationPlan{}, errors.New("redis variables are missing")
+	}
+
+	password, err := misc.DefaultRandomIdGenerator.Generate(64)
+	if err != nil {
+		return types.RotationPlan{}, err
+	}
+
+	dbName := fmt.Sprintf("redis-%s-%s", dep.Application.Name, dep.Environment)
+	databaseUrl := misc.FormatURI("redis", user, password, host, p.Port(), dbName, "disable")
+	return types.RotationPlan{
+		PreviousUser: user,
+		User:         user,
+		Vars: []types.CreateSecretParams{
+			{Key: "REDIS_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+			{Key: "REDIS_URL", Value: databaseUrl, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		},
+		Grant:   aclSetUser(">" + password),
+		Revoke:  aclSetUser("<" + previous),
+		Restart: true,
+	}, nil
+}
+
+// aclSetUser changes the default user, the server replies with an error message without failing the command
+func aclSetUser(rule string) []string {
+	return []string{"sh", "-c", `redis-cli --no-auth-warning -a "$REDIS_PASSWORD" ACL SETUSER default "$0" | grep -q '^OK$'`, rule}
+}
+
+// ExternalVars keeps the URL as it is, rediss URLs connect over TLS. the user is the default one when the URL has none
+func (p redisProvider) ExternalVars(dep *types.Deployment, rawURL string) ([]types.CreateSecretParams, error) {
+	u, err := misc.ParseURI(rawURL, "redis", "rediss")
+	if err != nil {
+		return nil, err
+	}
+
+	username := u.User.Username()
+	if username == "" {
+		username = "default"
+	}
+	password, _ := u.User.Password()
+	port := u.Port()
+	if port == "" {
+		port = p.Port()
+	}
+	return []types.CreateSecretParams{
+		{Key: "REDIS_USER", Value: username, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "REDIS_HOST", Value: u.Hostname(), Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "REDIS_PORT", Value: port, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "REDIS_PASSWORD", Value: password, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+		{Key: "REDIS_URL", Value: rawURL, Environment: dep.Environment, InstanceType: types.InstanceTypeDatabase, ApplicationID: dep.ApplicationID},
+	}, nil
+}
+
+func (p redisProvider) PingCommand() []string {
+	return []string{"sh", "-c", `redis-cli --no-auth-warning -u "$REDIS_URL" PING | grep -q PONG`}
+}
+
+// DataPath is where the image writes its data, the containers created when the volume was mounted at /data are
+// moved over when the server starts
 func (p redisProvider) DataPath() string {
-	return "/data"
+	return "/bitnami/redis/data"
 }
 
 func (p redisProvider) Port() string {
@@ -50,5 +150,73 @@ func (p redisProvider) Port() string {
 }
 
 func (p redisProvider) Engine() types.StorageEngine {
-	return types.StorageEngineMongo
+	return types.StorageEngineRedis
+}
+
+func (p redisProvider) ReadyCommand() []string {
+	return []string{"sh", "-c", `redis-cli --no-auth-warning -a "$REDIS_PASSWORD" PING | grep -q PONG`}
+}
+
+func (p redisProvider) ShellVars() []string {
+	return []string{"REDIS_PASSWORD"}
+}
+
+func (p redisProvider) ShellCommand(command string) []string {
+	if command != "" {
+		// redis-cli reads the command from its input as it would be typed at the prompt
+		return []string{"sh", "-c", `printf '%s\n' "$1" | REDISCLI_AUTH="$REDIS_PASSWORD" redis-cli`, "redis-cli", command}
+	}
+	return []string{"sh", "-c", `REDISCLI_AUTH="$REDIS_PASSWORD" exec redis-cli`}
+}
+
+func (p redisProvider) ImportCommand(host string) []string {
+	// the new server replicates from the old one until it is in sync then takes over as a primary,
+	// newer redis versions always understand the replication stream of older ones
+	script := fmt.Sprintf(`set -eo pipefail
+cli() { redis-cli --no-auth-warning -a "$REDIS_PASSWORD" "$@"; }
+cli CONFIG SET masterauth "$REDIS_PASSWORD" >/dev/null
+cli REPLICAOF %s %s >/dev/null
+until cli INFO replication | grep -q 'master_link_status:up' && ! cli INFO replication | grep -q 'master_sync_in_progress:1'; do sleep 1; done
+cli REPLICAOF NO ONE >/dev/null`, host, p.Port())
+	return []string{"bash", "-c", script}
+}
+
+func (p redisProvider) ChecksumCommand() []string {
+	return []string{"sh", "-c", `redis-cli --no-auth-warning -a "$REDIS_PASSWORD" INFO keyspace | grep '^db' | cut -d, -f1 | sort`}
+}
+
+// LoadCommand loads an RDB file into a second server in the container, the engine then replicates from it
+// the same way ImportCommand does. the data of the engine is replaced by the one of the file
+func (p redisProvider) LoadCommand(format types.DumpFormat, from, dep *types.Deployment) ([]string, error) {
+	if format != types.DumpFormatRedisRDB {
+		return nil, fmt.Errorf("a %s dump can not be imported into redis", format)
+	}
+
+	script := fmt.Sprintf(`set -eo pipefail
+dir=$(mktemp -d)
+cat > "$dir/dump.rdb"
+redis-server --port %[1]s --bind 127.0.0.1 --dir "$dir" --dbfilename dump.rdb --appendonly no --save '' --requirepass "$REDIS_PASSWORD" >"$dir/server.log" 2>&1 &
+pid=$!
+trap 'kill $pid 2>/dev/null; rm -rf "$dir"' EXIT
+cli() { redis-cli --no-auth-warning -a "$REDIS_PASSWORD" "$@"; }
+until cli -p %[1]s PING 2>/dev/null | grep -q PONG; do
+  kill -0 $pid 2>/dev/null || { cat "$dir/server.log" >&2; exit 1; }
+  sleep 1
+done
+cli CONFIG SET masterauth "$REDIS_PASSWORD" >/dev/null
+cli REPLICAOF 127.0.0.1 %[1]s >/dev/null
+until cli INFO replication | grep -q 'master_link_status:up' && ! cli INFO replication | grep -q 'master_sync_in_progress:1'; do sleep 1; done
+cli REPLICAOF NO ONE >/dev/null`, loadPort)
+	return []string{"bash", "-c", script}, nil
+}
+
+func (p redisProvider) AnonymiseCommand(dep *types.Deployment, rules []types.AnonymisationRule, salt string) ([]string, error) {
+	if len(rules) > 0 {
+		return nil, errors.New("redis values have no columns, anonymisation rules can not be applied to them")
+	}
+	return []string{"true"}, nil
+}
+
+func (p redisProvider) WipeCommand() []string {
+	return []string{"sh", "-c", `redis-cli --no-auth-warning -a "$REDIS_PASSWORD" FLUSHALL | grep -q '^OK$'`}
 }
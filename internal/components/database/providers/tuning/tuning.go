@@ -0,0 +1,76 @@
+package tuning
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sarabi/internal/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unlimitedMemoryMB is assumed when the memory of an engine isn't limited
+const unlimitedMemoryMB = 512
+
+var keyRegex = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// CPUs is the number of cpus allocated to an engine, at least one
+func CPUs(resources types.ResourceAllocation) int {
+	return max(1, int(math.Ceil(float64(resources.CPU)/1e9)))
+}
+
+// MemoryMB is the memory allocated to an engine in megabytes
+func MemoryMB(resources types.ResourceAllocation) int64 {
+	if resources.Memory <= 0 {
+		return unlimitedMemoryMB
+	}
+	return resources.Memory / (1024 * 1024)
+}
+
+// Int reads an integer setting, fallback is returned when it isn't set or isn't a number
+func Int(settings types.ConfigSettings, key string, fallback int) int {
+	n, err := strconv.Atoi(settings[key])
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+// Merge applies the settings set by the user on top of the tuned ones
+func Merge(tuned, overrides types.ConfigSettings) (types.ConfigSettings, error) {
+	result := make(types.ConfigSettings, len(tuned)+len(overrides))
+	for k, v := range tuned {
+		result[k] = v
+	}
+
+	for k, v := range overrides {
+		if err := Validate(k, v); err != nil {
+			return nil, err
+		}
+		result[k] = v
+	}
+	return result, nil
+}
+
+// Validate rejects settings that could not be written to a config file as a single line
+func Validate(key, value string) error {
+	if !keyRegex.MatchString(key) {
+		return fmt.Errorf("invalid setting name: %s", key)
+	}
+
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("invalid value for %s: must be a single line", key)
+	}
+	return nil
+}
+
+// Keys returns the setting names in order, so a config file renders the same every time
+func Keys(settings types.ConfigSettings) []string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
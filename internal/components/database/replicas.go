@@ -0,0 +1,228 @@
+package databasecomponent
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/service"
+	"sarabi/internal/types"
+	"sarabi/logger"
+)
+
+// Replicas runs the read replicas of a storage engine next to the container the database component runs
+type Replicas struct {
+	databaseComponent
+	replicator Replicator
+}
+
+func NewReplicas(dc docker.Docker,
+	appSvc service.ApplicationService,
+	secretService service.SecretService,
+	dbProvider Provider,
+	eb eventbus.Bus) (*Replicas, error) {
+	replicator, ok := dbProvider.(Replicator)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support read replicas", dbProvider.Engine())
+	}
+
+	return &Replicas{
+		databaseComponent: databaseComponent{
+			dockerClient:  dc,
+			appService:    appSvc,
+			secretService: secretService,
+			dbProvider:    dbProvider,
+			eb:            eb,
+		},
+		replicator: replicator,
+	}, nil
+}
+
+// Scale runs replicas 1 to count, the ones already running are left as they are. replicas above count up to
+// current are removed with their data, a replica added later copies the data of the primary again
+func (r *Replicas) Scale(ctx context.Context, deployment *types.Deployment, current, count int) error {
+	vars, err := r.vars(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	// the replication credentials are created with the first replica and kept from then on
+	replicationVars := r.replicator.ReplicationVars(deployment)
+	if _, ok := vars[replicationVars[0].Key]; !ok && count > 0 {
+		if _, err := r.secretService.CreateAll(ctx, replicationVars...); err != nil {
+			return err
+		}
+		if vars, err = r.vars(ctx, deployment); err != nil {
+			return err
+		}
+	}
+
+	slots := make([]string, 0, count)
+	for idx := 1; idx <= count; idx++ {
+		slots = append(slots, r.replicator.ReplicaSlot(idx))
+	}
+
+	primary := r.dbProvider.ContainerName(deployment)
+	if count > 0 {
+		grant, err := r.replicator.ReplicationGrant(vars, slots)
+		if err != nil {
+			return err
+		}
+		if _, err := r.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{ContainerName: primary, Cmd: grant}); err != nil {
+			return errors.Wrap(err, "failed to set up replication")
+		}
+	}
+
+	envs := make([]string, 0, len(vars))
+	for k, v := range vars {
+		envs = append(envs, k+"="+v)
+	}
+	for idx := 1; idx <= count; idx++ {
+		if err := r.start(ctx, deployment, idx, envs); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to start replica %d", idx))
+		}
+	}
+
+	removed := make([]string, 0)
+	for idx := count + 1; idx <= current; idx++ {
+		r.eb.Broadcast(deployment.Identifier, eventbus.Info, "Removing "+r.replicator.ReplicaName(deployment, idx))
+		if err := r.remove(ctx, deployment, idx); err != nil {
+			return err
+		}
+		removed = append(removed, r.replicator.ReplicaSlot(idx))
+	}
+	if len(removed) > 0 {
+		if _, err := r.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{ContainerName: primary, Cmd: r.replicator.DropSlotsCommand(removed)}); err != nil {
+			return errors.Wrap(err, "failed to drop replication slots")
+		}
+	}
+
+	readVars, err := r.replicator.ReadVars(deployment, vars, count)
+	if err != nil {
+		return err
+	}
+	_, err = r.secretService.CreateAll(ctx, readVars...)
+	return err
+}
+
+// Remove removes replicas 1 to count with their data, the primary is left as it is
+func (r *Replicas) Remove(ctx context.Context, deployment *types.Deployment, count int) error {
+	for idx := 1; idx <= count; idx++ {
+		if err := r.remove(ctx, deployment, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forget removes replicas 1 to count and the variables they were set up with
+func (r *Replicas) Forget(ctx context.Context, deployment *types.Deployment, count int) error {
+	if err := r.Remove(ctx, deployment, count); err != nil {
+		return err
+	}
+	return r.secretService.Delete(ctx, deployment.ApplicationID, deployment.Environment, types.InstanceTypeDatabase, r.replicator.ReplicaVarKeys()...)
+}
+
+// Status returns the state of replicas 1 to count as seen by the primary
+func (r *Replicas) Status(ctx context.Context, deployment *types.Deployment, count int) ([]types.ReplicaStatus, error) {
+	output, err := r.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: r.dbProvider.ContainerName(deployment),
+		Cmd:           r.replicator.LagCommand(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read replication status")
+	}
+
+	out, _, err := docker.ReadExecResponse(output)
+	if err != nil {
+		return nil, err
+	}
+
+	lag, err := r.replicator.ParseLag(out)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.ReplicaStatus, 0, count)
+	for idx := 1; idx <= count; idx++ {
+		status := lag[r.replicator.ReplicaSlot(idx)]
+		status.Name = r.replicator.ReplicaSlot(idx)
+		status.Container = r.replicator.ReplicaName(deployment, idx)
+		status.Status, err = r.dockerClient.ContainerStatus(ctx, status.Container)
+		if err != nil {
+			status.Status = "missing"
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+func (r *Replicas) start(ctx context.Context, deployment *types.Deployment, idx int, envs []string) error {
+	name := r.replicator.ReplicaName(deployment, idx)
+	running, _, err := r.dockerClient.IsContainerRunning(ctx, name)
+	if err != nil {
+		return err
+	}
+	if running {
+		return nil
+	}
+
+	// a replica that stopped is started over from a fresh copy, its slot may not hold the WAL it missed
+	if err := r.remove(ctx, deployment, idx); err != nil {
+		return err
+	}
+
+	r.eb.Broadcast(deployment.Identifier, eventbus.Info, "Provisioning "+name)
+	_, err = r.startContainer(ctx, deployment, containerSpec{
+		name:    name,
+		volume:  r.volumeName(deployment, idx),
+		envs:    envs,
+		aliases: []string{r.replicator.ReadHost(deployment)},
+		replica: func(cmd []string) types.ReplicaContainer {
+			return r.replicator.ReplicaContainer(deployment, idx, cmd)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// copying the data of the primary takes a while, the replica accepts connections once it caught up
+	if err := WaitReady(ctx, r.dockerClient, name, r.dbProvider, ReadyTimeout); err != nil {
+		return errors.Wrap(err, "replica is not ready")
+	}
+	return nil
+}
+
+func (r *Replicas) remove(ctx context.Context, deployment *types.Deployment, idx int) error {
+	_ = r.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{
+		ContainerName: r.replicator.ReplicaName(deployment, idx),
+	})
+	if err := r.dockerClient.RemoveVolume(ctx, r.volumeName(deployment, idx)); err != nil {
+		logger.Warn("failed to remove replica volume",
+			zap.String("volume", r.volumeName(deployment, idx)),
+			zap.Error(err))
+	}
+	return nil
+}
+
+func (r *Replicas) volumeName(deployment *types.Deployment, idx int) string {
+	return fmt.Sprintf("%s-replica-%d", deployment.VolumeName(r.dbProvider.Engine()), idx)
+}
+
+// vars returns the variables of the storage engines of the environment of deployment by name
+func (r *Replicas) vars(ctx context.Context, deployment *types.Deployment) (map[string]string, error) {
+	secrets, err := r.secretService.FindAll(ctx, deployment.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	for _, next := range secrets {
+		if types.InstanceType(next.InstanceType) == types.InstanceTypeDatabase && next.Environment == deployment.Environment {
+			vars[next.Name] = next.Value
+		}
+	}
+	return vars, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 	"io"
 	"os"
@@ -13,10 +14,13 @@ import (
 	"sarabi/internal/integrations/docker"
 	"sarabi/internal/integrations/loki"
 	"sarabi/internal/service"
+	"sarabi/internal/storage"
+	"sarabi/logger"
 )
 
 const (
 	tmpConfigPath = "/etc/loki/config.yaml"
+	caCertPath    = "/etc/loki/ca.pem"
 	imageName     = "grafana/loki:3.3.2"
 )
 
@@ -52,17 +56,40 @@ func (l *logCollector) Run(ctx context.Context, deploymentID uuid.UUID) (*compon
 		return nil, err
 	}
 
+	volumes := []string{
+		fmt.Sprintf("/etc/loki/config.yaml:/etc/loki/config.yaml"),
+	}
+
 	cfg := loki.DefaultConfig()
-	// use filesystem as log store if S3 compatible storage is not configured
-	storageCred, err := l.varsService.FindStorageCredentials(ctx, uuid.Nil)
-	if err == nil && storageCred != nil {
-		cfg.StorageConfig.AWS.S3 = storageCred.URI()
+	// use filesystem as log store if no storage Loki can write to is configured
+	store, found := l.findLogStore(ctx)
+	switch {
+	case found && store.ObjectStore == "s3":
+		cfg.StorageConfig.AWS.S3 = store.S3URI
+		cfg.StorageConfig.AWS.S3ForcePathStyle = store.PathStyle
+		cfg.StorageConfig.AWS.Insecure = store.Insecure
+		if store.CACert != "" {
+			if err := os.MkdirAll(filepath.Dir(caCertPath), 0755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(caCertPath, []byte(store.CACert), 0644); err != nil {
+				return nil, err
+			}
+			cfg.StorageConfig.AWS.HTTPConfig = &loki.AWSHTTPConfig{CAFile: caCertPath}
+			volumes = append(volumes, caCertPath+":"+caCertPath+":ro")
+		}
 		cfg.StorageConfig.FileSystem = nil
 		cfg.SchemaConfig.Configs[0].ObjectStore = "s3"
-	} else {
+	default:
 		cfg.StorageConfig.AWS = nil
 		cfg.SchemaConfig.Configs[0].ObjectStore = "filesystem"
 		cfg.StorageConfig.FileSystem = &loki.FileSystemConfig{Directory: "/var/loki/chunks"}
+		if found && store.Directory != "" {
+			if err := prepareChunksDir(store.Directory); err != nil {
+				return nil, err
+			}
+			volumes = append(volumes, store.Directory+":/var/loki/chunks")
+		}
 	}
 
 	cfgContent, err := yaml.Marshal(cfg)
@@ -82,9 +109,6 @@ func (l *logCollector) Run(ctx context.Context, deploymentID uuid.UUID) (*compon
 		"-config.file=/etc/loki/config.yaml",
 	}
 
-	volumes := []string{
-		fmt.Sprintf("/etc/loki/config.yaml:/etc/loki/config.yaml"),
-	}
 	tcpPort, _ := nat.NewPort("tcp", "3100")
 	exposedPorts := []nat.Port{tcpPort}
 	portBindings := nat.PortMap{
@@ -107,6 +131,41 @@ func (l *logCollector) Run(ctx context.Context, deploymentID uuid.UUID) (*compon
 	return &components.BuilderResult{ID: resp.ID, Name: info.Name}, nil
 }
 
+// findLogStore returns the first server-wide storage Loki can keep its chunks in.
+// drivers Loki has no client for(SFTP, WebDAV) are only used for backups
+func (l *logCollector) findLogStore(ctx context.Context) (storage.LokiStore, bool) {
+	configs, err := l.varsService.FindApplicationServerConfigs(ctx, uuid.Nil)
+	if err != nil {
+		return storage.LokiStore{}, false
+	}
+
+	for _, next := range configs {
+		driver, ok := storage.LookupConfig(next.Name)
+		if !ok {
+			continue
+		}
+
+		st, err := driver.Open([]byte(next.Value))
+		if err != nil {
+			logger.Warn("skipping invalid log storage", zap.String("type", driver.Type.String()), zap.Error(err))
+			continue
+		}
+
+		if ls, ok := st.(storage.LogStore); ok {
+			return ls.LokiStore(), true
+		}
+	}
+	return storage.LokiStore{}, false
+}
+
+// prepareChunksDir creates a mounted chunks directory owned by the loki user
+func prepareChunksDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.Chown(dir, 10001, 10001)
+}
+
 func (l *logCollector) Cleanup(ctx context.Context, result *components.BuilderResult) error {
 	return nil
 }
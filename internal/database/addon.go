@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sarabi/internal/types"
+)
+
+type addonRepository struct {
+	db *gorm.DB
+}
+
+func NewAddonRepository(db *gorm.DB) AddonRepository {
+	return &addonRepository{db: db}
+}
+
+func (a addonRepository) Save(ctx context.Context, addon *types.Addon) error {
+	return a.db.WithContext(ctx).Save(addon).Error
+}
+
+func (a addonRepository) Find(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Addon, error) {
+	addon := &types.Addon{}
+	err := a.db.WithContext(ctx).
+		Where("application_id = ? AND environment = ? AND name = ?", applicationID, environment, name).
+		First(addon).Error
+	return addon, err
+}
+
+func (a addonRepository) FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.Addon, error) {
+	var result []*types.Addon
+	err := a.db.WithContext(ctx).
+		Where("application_id = ?", applicationID).
+		Order("environment, name").
+		Find(&result).Error
+	return result, err
+}
+
+func (a addonRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return a.db.WithContext(ctx).Delete(&types.Addon{}, "id = ?", id).Error
+}
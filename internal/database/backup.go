@@ -15,6 +15,10 @@ type (
 	backupRepository struct {
 		db *gorm.DB
 	}
+
+	backupCopyRepository struct {
+		db *gorm.DB
+	}
 )
 
 func NewBackupSettingsRepository(db *gorm.DB) BackupSettingsRepository {
@@ -47,7 +51,10 @@ func (b backupRepository) Save(ctx context.Context, bc *types.Backup) error {
 
 func (b backupRepository) FindByApplicationID(ctx context.Context, applicationID uuid.UUID) ([]*types.Backup, error) {
 	result := make([]*types.Backup, 0)
-	err := b.db.WithContext(ctx).Where("application_id = ?", applicationID).Find(&result).Error
+	err := b.db.WithContext(ctx).
+		Where("application_id = ?", applicationID).
+		Order("created_at desc").
+		Find(&result).Error
 	return result, err
 }
 
@@ -63,3 +70,46 @@ func (b backupSettingsRepository) UpdateExpression(ctx context.Context, id uuid.
 		Where("id = ?", id).
 		Update("cron_expression", cronExpression).Error
 }
+
+func (b backupSettingsRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return b.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&types.BackupSettings{}).Error
+}
+
+func (b backupRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return b.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&types.Backup{}).Error
+}
+
+// FailRunning marks the attempts that were still running as failed, they were cut short by a restart of the server
+func (b backupRepository) FailRunning(ctx context.Context, reason string) error {
+	return b.db.WithContext(ctx).
+		Model(&types.Backup{}).
+		Where("status = ?", types.BackupStatusRunning).
+		Updates(map[string]interface{}{"status": types.BackupStatusFailed, "error": reason}).Error
+}
+
+func NewBackupCopyRepository(db *gorm.DB) BackupCopyRepository {
+	return &backupCopyRepository{db: db}
+}
+
+func (b backupCopyRepository) Save(ctx context.Context, c *types.BackupCopy) error {
+	return b.db.WithContext(ctx).Save(c).Error
+}
+
+func (b backupCopyRepository) FindByBackupID(ctx context.Context, backupID uuid.UUID) ([]*types.BackupCopy, error) {
+	result := make([]*types.BackupCopy, 0)
+	err := b.db.WithContext(ctx).
+		Where("backup_id = ?", backupID).
+		Order("created_at asc").
+		Find(&result).Error
+	return result, err
+}
+
+func (b backupCopyRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return b.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&types.BackupCopy{}).Error
+}
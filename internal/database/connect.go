@@ -22,6 +22,17 @@ func Open(dir string) (*gorm.DB, error) {
 		&types.BackupSettings{},
 		&types.ServerConfig{},
 		&types.Backup{},
+		&types.PITRSettings{},
+		&types.WALSegment{},
+		&types.BackupCopy{},
+		&types.DatabaseConfig{},
+		&types.CredentialRotation{},
+		&types.ExternalDatabase{},
+		&types.Addon{},
+		&types.Volume{},
+		&types.Job{},
+		&types.JobRun{},
+		&types.InternalAccess{},
 		&types.NetworkAccess{},
 		&types.Log{}); err != nil {
 		return nil, err
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"sarabi/internal/types"
+)
+
+type credentialRotationRepository struct {
+	db *gorm.DB
+}
+
+func NewCredentialRotationRepository(db *gorm.DB) CredentialRotationRepository {
+	return &credentialRotationRepository{db: db}
+}
+
+func (c credentialRotationRepository) Save(ctx context.Context, rotation *types.CredentialRotation) error {
+	return c.db.WithContext(ctx).Save(rotation).Error
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sarabi/internal/types"
+)
+
+type databaseConfigRepository struct {
+	db *gorm.DB
+}
+
+func NewDatabaseConfigRepository(db *gorm.DB) DatabaseConfigRepository {
+	return &databaseConfigRepository{db: db}
+}
+
+func (d databaseConfigRepository) Save(ctx context.Context, config *types.DatabaseConfig) error {
+	return d.db.WithContext(ctx).Save(config).Error
+}
+
+func (d databaseConfigRepository) Find(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (*types.DatabaseConfig, error) {
+	config := &types.DatabaseConfig{}
+	err := d.db.WithContext(ctx).
+		Where("application_id = ? AND environment = ? AND storage_engine = ?", applicationID, environment, se).
+		First(config).Error
+	return config, err
+}
+
+func (d databaseConfigRepository) FindReplicated(ctx context.Context) ([]*types.DatabaseConfig, error) {
+	values := make([]*types.DatabaseConfig, 0)
+	err := d.db.WithContext(ctx).
+		Where("replicas > 0").
+		Find(&values).Error
+	return values, err
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sarabi/internal/types"
+)
+
+type externalDatabaseRepository struct {
+	db *gorm.DB
+}
+
+func NewExternalDatabaseRepository(db *gorm.DB) ExternalDatabaseRepository {
+	return &externalDatabaseRepository{db: db}
+}
+
+func (e externalDatabaseRepository) Save(ctx context.Context, external *types.ExternalDatabase) error {
+	return e.db.WithContext(ctx).Save(external).Error
+}
+
+func (e externalDatabaseRepository) Find(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (*types.ExternalDatabase, error) {
+	external := &types.ExternalDatabase{}
+	err := e.db.WithContext(ctx).
+		Where("application_id = ? AND environment = ? AND storage_engine = ?", applicationID, environment, se).
+		First(external).Error
+	return external, err
+}
+
+func (e externalDatabaseRepository) FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.ExternalDatabase, error) {
+	var result []*types.ExternalDatabase
+	err := e.db.WithContext(ctx).
+		Where("application_id = ?", applicationID).
+		Order("environment, storage_engine").
+		Find(&result).Error
+	return result, err
+}
+
+func (e externalDatabaseRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return e.db.WithContext(ctx).Delete(&types.ExternalDatabase{}, "id = ?", id).Error
+}
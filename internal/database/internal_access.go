@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sarabi/internal/types"
+)
+
+type internalAccessRepository struct {
+	db *gorm.DB
+}
+
+func NewInternalAccessRepository(db *gorm.DB) InternalAccessRepository {
+	return &internalAccessRepository{db: db}
+}
+
+func (i internalAccessRepository) Save(ctx context.Context, access *types.InternalAccess) error {
+	return i.db.WithContext(ctx).Save(access).Error
+}
+
+func (i internalAccessRepository) Find(ctx context.Context, applicationID, fromApplicationID uuid.UUID) (*types.InternalAccess, error) {
+	access := &types.InternalAccess{}
+	err := i.db.WithContext(ctx).
+		Where("application_id = ? AND from_application_id = ?", applicationID, fromApplicationID).
+		First(access).Error
+	return access, err
+}
+
+// FindAll returns the accesses to an application and the ones from it
+func (i internalAccessRepository) FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.InternalAccess, error) {
+	var result []*types.InternalAccess
+	err := i.db.WithContext(ctx).
+		Where("application_id = ? OR from_application_id = ?", applicationID, applicationID).
+		Order("created_at").
+		Find(&result).Error
+	return result, err
+}
+
+func (i internalAccessRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return i.db.WithContext(ctx).Delete(&types.InternalAccess{}, "id = ?", id).Error
+}
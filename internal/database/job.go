@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sarabi/internal/types"
+)
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+type jobRunRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &jobRepository{db: db}
+}
+
+func NewJobRunRepository(db *gorm.DB) JobRunRepository {
+	return &jobRunRepository{db: db}
+}
+
+func (j jobRepository) Save(ctx context.Context, job *types.Job) error {
+	return j.db.WithContext(ctx).Save(job).Error
+}
+
+func (j jobRepository) Find(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Job, error) {
+	job := &types.Job{}
+	err := j.db.WithContext(ctx).
+		Where("application_id = ? AND environment = ? AND name = ?", applicationID, environment, name).
+		First(job).Error
+	return job, err
+}
+
+func (j jobRepository) FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.Job, error) {
+	var result []*types.Job
+	err := j.db.WithContext(ctx).
+		Where("application_id = ?", applicationID).
+		Order("environment, name").
+		Find(&result).Error
+	return result, err
+}
+
+func (j jobRepository) List(ctx context.Context) ([]*types.Job, error) {
+	var result []*types.Job
+	err := j.db.WithContext(ctx).Find(&result).Error
+	return result, err
+}
+
+func (j jobRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return j.db.WithContext(ctx).Delete(&types.Job{}, "id = ?", id).Error
+}
+
+func (j jobRunRepository) Save(ctx context.Context, run *types.JobRun) error {
+	return j.db.WithContext(ctx).Save(run).Error
+}
+
+// FindAll returns the last runs of a job, the latest first
+func (j jobRunRepository) FindAll(ctx context.Context, jobID uuid.UUID, limit int) ([]*types.JobRun, error) {
+	var result []*types.JobRun
+	err := j.db.WithContext(ctx).
+		Where("job_id = ?", jobID).
+		Order("started_at DESC").
+		Limit(limit).
+		Find(&result).Error
+	return result, err
+}
+
+// Prune deletes the runs of a job older than the last keep runs
+func (j jobRunRepository) Prune(ctx context.Context, jobID uuid.UUID, keep int) error {
+	kept := j.db.Model(&types.JobRun{}).
+		Select("id").
+		Where("job_id = ?", jobID).
+		Order("started_at DESC").
+		Limit(keep)
+	return j.db.WithContext(ctx).
+		Where("job_id = ? AND id NOT IN (?)", jobID, kept).
+		Delete(&types.JobRun{}).Error
+}
+
+func (j jobRunRepository) DeleteAll(ctx context.Context, jobID uuid.UUID) error {
+	return j.db.WithContext(ctx).Delete(&types.JobRun{}, "job_id = ?", jobID).Error
+}
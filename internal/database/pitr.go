@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sarabi/internal/types"
+	"time"
+)
+
+type (
+	pitrSettingsRepository struct {
+		db *gorm.DB
+	}
+
+	walSegmentRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewPITRSettingsRepository(db *gorm.DB) PITRSettingsRepository {
+	return &pitrSettingsRepository{db: db}
+}
+
+func (p pitrSettingsRepository) Save(ctx context.Context, settings *types.PITRSettings) error {
+	return p.db.WithContext(ctx).Save(settings).Error
+}
+
+func (p pitrSettingsRepository) FindAll(ctx context.Context) ([]*types.PITRSettings, error) {
+	result := make([]*types.PITRSettings, 0)
+	err := p.db.WithContext(ctx).Find(&result).Error
+	return result, err
+}
+
+func (p pitrSettingsRepository) Find(ctx context.Context, applicationID uuid.UUID, environment string) (*types.PITRSettings, error) {
+	settings := &types.PITRSettings{}
+	err := p.db.WithContext(ctx).
+		Where("application_id = ? AND environment = ?", applicationID, environment).
+		First(settings).Error
+	return settings, err
+}
+
+func (p pitrSettingsRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return p.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&types.PITRSettings{}).Error
+}
+
+func NewWALSegmentRepository(db *gorm.DB) WALSegmentRepository {
+	return &walSegmentRepository{db: db}
+}
+
+func (w walSegmentRepository) Save(ctx context.Context, segment *types.WALSegment) error {
+	return w.db.WithContext(ctx).Save(segment).Error
+}
+
+func (w walSegmentRepository) FindSince(ctx context.Context, applicationID uuid.UUID, environment string, since time.Time) ([]*types.WALSegment, error) {
+	result := make([]*types.WALSegment, 0)
+	err := w.db.WithContext(ctx).
+		Where("application_id = ? AND environment = ? AND archived_at >= ?", applicationID, environment, since).
+		Order("name asc").
+		Find(&result).Error
+	return result, err
+}
+
+func (w walSegmentRepository) FindByApplicationID(ctx context.Context, applicationID uuid.UUID) ([]*types.WALSegment, error) {
+	result := make([]*types.WALSegment, 0)
+	err := w.db.WithContext(ctx).
+		Where("application_id = ?", applicationID).
+		Find(&result).Error
+	return result, err
+}
+
+func (w walSegmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return w.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&types.WALSegment{}).Error
+}
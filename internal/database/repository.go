@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/google/uuid"
 	"sarabi/internal/types"
+	"time"
 )
 
 type ApplicationRepository interface {
@@ -18,6 +19,7 @@ type SecretRepository interface {
 	FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.Secret, error)
 	FindBy(ctx context.Context, applicationID uuid.UUID, name, env, instanceType string) (*types.Secret, error)
 	UpdateValue(ctx context.Context, id uuid.UUID, newValue string) error
+	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 type DeploymentSecretRepository interface {
@@ -47,6 +49,27 @@ type BackupSettingsRepository interface {
 	FindAll(ctx context.Context) ([]*types.BackupSettings, error)
 	FindByApplicationID(ctx context.Context, applicationID uuid.UUID) ([]*types.BackupSettings, error)
 	UpdateExpression(ctx context.Context, id uuid.UUID, cronExpression string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type BackupCopyRepository interface {
+	Save(ctx context.Context, c *types.BackupCopy) error
+	FindByBackupID(ctx context.Context, backupID uuid.UUID) ([]*types.BackupCopy, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type PITRSettingsRepository interface {
+	Save(ctx context.Context, settings *types.PITRSettings) error
+	FindAll(ctx context.Context) ([]*types.PITRSettings, error)
+	Find(ctx context.Context, applicationID uuid.UUID, environment string) (*types.PITRSettings, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type WALSegmentRepository interface {
+	Save(ctx context.Context, segment *types.WALSegment) error
+	FindSince(ctx context.Context, applicationID uuid.UUID, environment string, since time.Time) ([]*types.WALSegment, error)
+	FindByApplicationID(ctx context.Context, applicationID uuid.UUID) ([]*types.WALSegment, error)
+	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 type ServerConfigRepository interface {
@@ -60,6 +83,8 @@ type BackupRepository interface {
 	Save(ctx context.Context, bc *types.Backup) error
 	FindByApplicationID(ctx context.Context, applicationID uuid.UUID) ([]*types.Backup, error)
 	FindByID(ctx context.Context, id uuid.UUID) (*types.Backup, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	FailRunning(ctx context.Context, reason string) error
 }
 
 type NetworkAccessRepository interface {
@@ -73,3 +98,56 @@ type LogsRepository interface {
 	Save(ctx context.Context, log *types.Log) error
 	FindAll(ctx context.Context, applicationID uuid.UUID, filter types.Filter) ([]*types.Log, error)
 }
+
+type DatabaseConfigRepository interface {
+	Save(ctx context.Context, config *types.DatabaseConfig) error
+	Find(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (*types.DatabaseConfig, error)
+	FindReplicated(ctx context.Context) ([]*types.DatabaseConfig, error)
+}
+
+type CredentialRotationRepository interface {
+	Save(ctx context.Context, rotation *types.CredentialRotation) error
+}
+
+type ExternalDatabaseRepository interface {
+	Save(ctx context.Context, external *types.ExternalDatabase) error
+	Find(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (*types.ExternalDatabase, error)
+	FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.ExternalDatabase, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type InternalAccessRepository interface {
+	Save(ctx context.Context, access *types.InternalAccess) error
+	Find(ctx context.Context, applicationID, fromApplicationID uuid.UUID) (*types.InternalAccess, error)
+	FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.InternalAccess, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type VolumeRepository interface {
+	Save(ctx context.Context, volume *types.Volume) error
+	Find(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Volume, error)
+	FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.Volume, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type AddonRepository interface {
+	Save(ctx context.Context, addon *types.Addon) error
+	Find(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Addon, error)
+	FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.Addon, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type JobRepository interface {
+	Save(ctx context.Context, job *types.Job) error
+	Find(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Job, error)
+	FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.Job, error)
+	List(ctx context.Context) ([]*types.Job, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type JobRunRepository interface {
+	Save(ctx context.Context, run *types.JobRun) error
+	FindAll(ctx context.Context, jobID uuid.UUID, limit int) ([]*types.JobRun, error)
+	Prune(ctx context.Context, jobID uuid.UUID, keep int) error
+	DeleteAll(ctx context.Context, jobID uuid.UUID) error
+}
@@ -50,3 +50,13 @@ func (s *secretRepository) UpdateValue(ctx context.Context, id uuid.UUID, newVal
 		Update("value", newValue).
 		Error
 }
+
+// Delete removes a secret and detaches it from the deployments using it
+func (s *secretRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("secret_id = ?", id).Delete(&types.DeploymentSecret{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&types.Secret{}).Error
+	})
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sarabi/internal/types"
+)
+
+type volumeRepository struct {
+	db *gorm.DB
+}
+
+func NewVolumeRepository(db *gorm.DB) VolumeRepository {
+	return &volumeRepository{db: db}
+}
+
+func (v volumeRepository) Save(ctx context.Context, volume *types.Volume) error {
+	return v.db.WithContext(ctx).Save(volume).Error
+}
+
+func (v volumeRepository) Find(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Volume, error) {
+	volume := &types.Volume{}
+	err := v.db.WithContext(ctx).
+		Where("application_id = ? AND environment = ? AND name = ?", applicationID, environment, name).
+		First(volume).Error
+	return volume, err
+}
+
+func (v volumeRepository) FindAll(ctx context.Context, applicationID uuid.UUID) ([]*types.Volume, error) {
+	var result []*types.Volume
+	err := v.db.WithContext(ctx).
+		Where("application_id = ?", applicationID).
+		Order("environment, name").
+		Find(&result).Error
+	return result, err
+}
+
+func (v volumeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return v.db.WithContext(ctx).Delete(&types.Volume{}, "id = ?", id).Error
+}
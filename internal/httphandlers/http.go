@@ -6,16 +6,20 @@ import (
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"io"
+	"net"
 	"net/http"
 	"sarabi/internal/eventbus"
 	"sarabi/internal/logs"
 	"sarabi/internal/manager"
 	"sarabi/internal/misc"
+	"sarabi/internal/tunnel"
 	"sarabi/internal/types"
 	"sarabi/logger"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -23,6 +27,13 @@ import (
 
 var (
 	maxUploadSize = 2 << 30 // 2GB
+	// maxImportChunkSize bounds one chunk of a database dump, dumps themselves have no size limit
+	maxImportChunkSize = 64 << 20 // 64MB
+
+	tunnelUpgrader = websocket.Upgrader{
+		ReadBufferSize:  tunnel.BufferSize,
+		WriteBufferSize: tunnel.BufferSize,
+	}
 )
 
 type (
@@ -38,6 +49,38 @@ func NewApiHandler(mn manager.Manager, lm logs.Manager, eb eventbus.Bus, l *zap.
 	return &ApiHandler{mn: mn, lm: lm, eb: eb, logger: l}
 }
 
+// stream runs fn in the background and writes the events published under identifier to the client until the
+// operation completes or the client goes away. fn is not tied to the request, so that an operation is never
+// interrupted half-way by a disconnected client, an error it returns ends the stream.
+func (handler *ApiHandler) stream(w http.ResponseWriter, r *http.Request, identifier string, fn func(ctx context.Context) error) {
+	ch := handler.eb.Register(identifier)
+	go func() {
+		if err := fn(context.Background()); err != nil {
+			ch <- eventbus.Event{
+				Type:    eventbus.Error,
+				Message: err.Error(),
+			}
+			ch <- eventbus.Event{Type: eventbus.Complete}
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for {
+		select {
+		case ev := <-ch:
+			_ = writeSSELine(w, ev)
+			if ev.Type == eventbus.Complete {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
 func (handler *ApiHandler) CreateApplication(w http.ResponseWriter, r *http.Request) {
 	var params types.CreateApplicationParams
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
@@ -62,9 +105,12 @@ func (handler *ApiHandler) Deploy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body struct {
-		ApplicationID uuid.UUID `json:"application_id"`
-		Instances     int       `json:"instances"`
-		Environment   string    `json:"environment"`
+		ApplicationID uuid.UUID             `json:"application_id"`
+		Instances     int                   `json:"instances"`
+		Environment   string                `json:"environment"`
+		Processes     types.Processes       `json:"processes"`
+		Services      []types.ServiceParams `json:"services"`
+		Visibility    types.Visibility      `json:"visibility"`
 	}
 
 	if err := json.Unmarshal([]byte(r.FormValue("json")), &body); err != nil {
@@ -83,6 +129,9 @@ func (handler *ApiHandler) Deploy(w http.ResponseWriter, r *http.Request) {
 		Instances:     body.Instances,
 		Environment:   body.Environment,
 		Identifier:    identifier,
+		Processes:     body.Processes,
+		Services:      body.Services,
+		Visibility:    body.Visibility,
 	}
 	for _, ff := range r.MultipartForm.File["files"] {
 		if !strings.HasSuffix(ff.Filename, ".tar.gz") {
@@ -103,6 +152,17 @@ func (handler *ApiHandler) Deploy(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// a named service is uploaded as service-<name>.tar.gz
+		if name, ok := strings.CutPrefix(strings.TrimSuffix(ff.Filename, ".tar.gz"), "service-"); ok {
+			idx := slices.IndexFunc(param.Services, func(item types.ServiceParams) bool { return item.Name == name })
+			if idx == -1 {
+				badRequest(w, errors.New("unknown service: "+name))
+				return
+			}
+			param.Services[idx].Artifact = file
+			continue
+		}
+
 		if strings.Contains(ff.Filename, "frontend") {
 			param.Frontend = file
 		}
@@ -112,39 +172,12 @@ func (handler *ApiHandler) Deploy(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	ch := handler.eb.Register(identifier)
-
 	logger.Info("starting deployment",
 		zap.Any("application_id", param.ApplicationID))
-	go func(ctx context.Context) {
-		err = handler.mn.Deploy(ctx, param)
-		if err != nil {
-			ch <- eventbus.Event{
-				Type:    eventbus.Error,
-				Message: err.Error(),
-			}
-		}
-	}(r.Context())
-
-	for {
-		select {
-		case ev := <-ch:
-			data, err := json.Marshal(ev)
-			if err != nil {
-				serverError(w, err)
-				continue
-			}
-
-			_, _ = w.Write(data)
-			_, _ = w.Write(misc.Seperator)
-			flusher, ok := w.(http.Flusher)
-			if ok {
-				flusher.Flush()
-			}
-		case <-r.Context().Done():
-			return
-		}
-	}
+	handler.stream(w, r, identifier, func(context.Context) error {
+		// the uploads only live as long as the request, the deployment is cancelled with it
+		return handler.mn.Deploy(r.Context(), param)
+	})
 }
 
 func (handler *ApiHandler) UpdateVariables(w http.ResponseWriter, r *http.Request) {
@@ -213,6 +246,7 @@ func (handler *ApiHandler) Scale(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Count       int    `json:"count"`
 		Environment string `json:"environment"`
+		Process     string `json:"process"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -220,7 +254,14 @@ func (handler *ApiHandler) Scale(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if body.Count <= 0 {
+	process, err := types.ParseProcessType(body.Process)
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	// a worker can be scaled down to no replicas
+	if body.Count < 0 || (body.Count == 0 && process == types.ProcessWeb) {
 		badRequest(w, fmt.Errorf("invalid instance count: %d", body.Count))
 		return
 	}
@@ -233,7 +274,7 @@ func (handler *ApiHandler) Scale(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	result, err := handler.mn.Scale(ctx, applicationID, body.Environment, body.Count)
+	result, err := handler.mn.Scale(ctx, applicationID, body.Environment, process, body.Count)
 	if err != nil {
 		serverError(w, err)
 		return
@@ -305,6 +346,10 @@ func (handler *ApiHandler) AddCredentials(w http.ResponseWriter, r *http.Request
 	ok(w, "credentials added", result)
 }
 
+func (handler *ApiHandler) ListStorageDrivers(w http.ResponseWriter, r *http.Request) {
+	ok(w, "storage drivers", handler.mn.ListStorageDrivers())
+}
+
 func (handler *ApiHandler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
 	backupID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
@@ -393,9 +438,7 @@ func (handler *ApiHandler) Destroy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var body struct {
-		Environment string `json:"environment"`
-	}
+	var body types.DestroyParams
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		badRequest(w, err)
 		return
@@ -404,7 +447,7 @@ func (handler *ApiHandler) Destroy(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	err = handler.mn.Destroy(ctx, applicationID, body.Environment)
+	err = handler.mn.Destroy(ctx, applicationID, body)
 	if err != nil {
 		logger.Error("destroy failed",
 			zap.Error(err))
@@ -464,7 +507,16 @@ func (handler *ApiHandler) GetApplication(w http.ResponseWriter, r *http.Request
 	ok(w, "success", application)
 }
 
+// WhitelistIP and BlacklistIP are kept for the clients still calling them, they only point to sarabi db connect
 func (handler *ApiHandler) WhitelistIP(w http.ResponseWriter, r *http.Request) {
+	handler.manageDatabaseNetworkAccess(w, r, manager.OpAdd)
+}
+
+func (handler *ApiHandler) BlacklistIP(w http.ResponseWriter, r *http.Request) {
+	handler.manageDatabaseNetworkAccess(w, r, manager.OpRemove)
+}
+
+func (handler *ApiHandler) manageDatabaseNetworkAccess(w http.ResponseWriter, r *http.Request, op manager.Op) {
 	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
 	if err != nil {
 		badRequest(w, err)
@@ -480,64 +532,918 @@ func (handler *ApiHandler) WhitelistIP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.mn.ManageDatabaseNetworkAccess(r.Context(), applicationID, body.Environment, body.IP, manager.OpAdd)
+	badRequest(w, handler.mn.ManageDatabaseNetworkAccess(r.Context(), applicationID, body.Environment, body.IP, op))
+}
+
+func (handler *ApiHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	var body struct {
+		CronExpression string `json:"cron_expression"`
+		Environment    string `json:"environment"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	err = handler.mn.CreateBackupSchedule(r.Context(), applicationID, body.Environment, body.CronExpression)
 	if err != nil {
 		serverError(w, err)
 		return
 	}
 
-	ok(w, "IP whitelisted!", nil)
+	ok(w, "backup created", nil)
 }
 
-func (handler *ApiHandler) BlacklistIP(w http.ResponseWriter, r *http.Request) {
+func (handler *ApiHandler) BackupNow(w http.ResponseWriter, r *http.Request) {
 	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
 	if err != nil {
 		badRequest(w, err)
 		return
 	}
 
-	var body struct {
-		IP          string `json:"ip"`
-		Environment string `json:"environment"`
+	environment := r.URL.Query().Get("environment")
+	if environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
 	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Hour)
+		defer cancel()
+		_, err := handler.mn.BackupNow(ctx, applicationID, environment, identifier)
+		return err
+	})
+}
+
+func (handler *ApiHandler) UpdatePITR(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	var body types.UpdatePITRParams
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		badRequest(w, err)
 		return
 	}
 
-	err = handler.mn.ManageDatabaseNetworkAccess(r.Context(), applicationID, body.Environment, body.IP, manager.OpRemove)
+	if body.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	if err := handler.mn.UpdatePITR(r.Context(), applicationID, body); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "point-in-time recovery settings updated", nil)
+}
+
+func (handler *ApiHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	environment := queries.Get("environment")
+	if environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	target, err := time.Parse(time.RFC3339, queries.Get("to"))
+	if err != nil {
+		badRequest(w, errors.Wrap(err, "invalid restore target time"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
 	if err != nil {
 		serverError(w, err)
 		return
 	}
 
-	ok(w, "IP blacklisted!", nil)
+	// a restore must not be interrupted half-way because the client went away
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.Restore(ctx, applicationID, environment, target, identifier)
+	})
 }
 
-func (handler *ApiHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+func (handler *ApiHandler) UpgradeDatabase(w http.ResponseWriter, r *http.Request) {
 	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
 	if err != nil {
 		badRequest(w, err)
 		return
 	}
 
-	var body struct {
-		CronExpression string `json:"cron_expression"`
-		Environment    string `json:"environment"`
+	queries := r.URL.Query()
+	params := types.UpgradeDatabaseParams{
+		Environment:   queries.Get("environment"),
+		StorageEngine: types.StorageEngine(queries.Get("engine")),
+		Version:       queries.Get("version"),
+	}
+	if params.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	if params.Version == "" {
+		badRequest(w, errors.New("version is required"))
+		return
 	}
 
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	// an upgrade must not be interrupted half-way because the client went away
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.UpgradeDatabase(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) UpdateDatabaseConfig(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	var body types.UpdateDatabaseConfigParams
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		badRequest(w, err)
 		return
 	}
 
-	err = handler.mn.CreateBackupSchedule(r.Context(), applicationID, body.Environment, body.CronExpression)
+	if body.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	if len(body.Settings) == 0 {
+		badRequest(w, errors.New("no settings provided"))
+		return
+	}
+
+	if err := handler.mn.UpdateDatabaseConfig(r.Context(), applicationID, body); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "database configuration updated", nil)
+}
+
+func (handler *ApiHandler) AddStorageEngine(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	params := types.AddStorageEngineParams{
+		StorageEngine: r.URL.Query().Get("engine"),
+	}
+	if params.StorageEngine == "" {
+		badRequest(w, errors.New("storage engine is required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
 	if err != nil {
 		serverError(w, err)
 		return
 	}
 
-	ok(w, "backup created", nil)
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.AddStorageEngine(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) RemoveStorageEngine(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	params := types.RemoveStorageEngineParams{
+		StorageEngine: types.StorageEngine(queries.Get("engine")),
+		KeepData:      queries.Get("keep_data") == "true",
+	}
+	if params.StorageEngine == "" {
+		badRequest(w, errors.New("storage engine is required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.RemoveStorageEngine(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) RotateCredentials(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	params := types.RotateCredentialsParams{
+		Environment:   queries.Get("environment"),
+		StorageEngine: types.StorageEngine(queries.Get("engine")),
+	}
+	if params.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	// credentials must not be left half-rotated because the client went away
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.RotateCredentials(ctx, applicationID, params, identifier)
+	})
+}
+
+// DatabaseTunnel carries a TCP connection to a storage engine of an environment over a websocket.
+// the engine is reached on its docker network, so it needs neither a published port nor a whitelisted IP
+func (handler *ApiHandler) DatabaseTunnel(w http.ResponseWriter, r *http.Request) {
+	if err := handler.mn.ValidateToken(r.Header.Get(accessKeyHeader)); err != nil {
+		unauthorized(w, err)
+		return
+	}
+
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	params := types.DatabaseTunnelParams{
+		Environment:   queries.Get("environment"),
+		StorageEngine: types.StorageEngine(queries.Get("engine")),
+	}
+	if params.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	address, err := handler.mn.DatabaseAddress(r.Context(), applicationID, params)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+	if err != nil {
+		serverError(w, errors.Wrap(err, "failed to reach database"))
+		return
+	}
+
+	ws, err := tunnelUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	handler.logger.Info("database tunnel opened",
+		zap.String("application_id", applicationID.String()),
+		zap.String("env", params.Environment),
+		zap.String("engine", params.StorageEngine.String()),
+		zap.String("remote_addr", r.RemoteAddr))
+	err = tunnel.Pipe(ws, conn)
+	handler.logger.Info("database tunnel closed",
+		zap.String("application_id", applicationID.String()),
+		zap.String("env", params.Environment),
+		zap.String("remote_addr", r.RemoteAddr),
+		zap.Error(err))
+}
+
+// DatabaseShell attaches a websocket to the native client of a storage engine of an environment,
+// with a command it prints the output of the command and exits
+func (handler *ApiHandler) DatabaseShell(w http.ResponseWriter, r *http.Request) {
+	if err := handler.mn.ValidateToken(r.Header.Get(accessKeyHeader)); err != nil {
+		unauthorized(w, err)
+		return
+	}
+
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	params := types.DatabaseShellParams{
+		Environment:   queries.Get("environment"),
+		StorageEngine: types.StorageEngine(queries.Get("engine")),
+		Command:       queries.Get("command"),
+	}
+	if params.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	session, err := handler.mn.DatabaseShell(r.Context(), applicationID, params)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer session.Close()
+
+	ws, err := tunnelUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	// the request context ends with the upgrade, the session outlives it
+	ctx := context.Background()
+	rows, _ := strconv.Atoi(queries.Get("rows"))
+	cols, _ := strconv.Atoi(queries.Get("cols"))
+	_ = session.Resize(ctx, uint(rows), uint(cols))
+
+	handler.logger.Info("database shell opened",
+		zap.String("application_id", applicationID.String()),
+		zap.String("env", params.Environment),
+		zap.String("engine", params.StorageEngine.String()),
+		zap.Bool("command", params.Command != ""),
+		zap.String("remote_addr", r.RemoteAddr))
+	err = tunnel.Attach(ws, session, session.Output(), func(size tunnel.Size) error {
+		return session.Resize(ctx, size.Rows, size.Cols)
+	})
+
+	exitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	code, exitErr := session.ExitCode(exitCtx)
+	if exitErr != nil {
+		code = 1
+	}
+	tunnel.Exit(ws, code)
+	handler.logger.Info("database shell closed",
+		zap.String("application_id", applicationID.String()),
+		zap.String("env", params.Environment),
+		zap.Int("exit_code", code),
+		zap.Error(err))
+}
+
+func (handler *ApiHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	dump, err := handler.mn.CreateImport(r.Context(), applicationID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "import created", dump)
+}
+
+// UploadImportChunk receives the chunk of a dump starting at offset as the raw body of the request
+func (handler *ApiHandler) UploadImportChunk(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	importID, err := uuid.Parse(chi.URLParam(r, "import_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		badRequest(w, errors.Wrap(err, "invalid chunk offset"))
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, int64(maxImportChunkSize))
+	dump, err := handler.mn.UploadImportChunk(r.Context(), applicationID, importID, offset, body)
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	ok(w, "chunk uploaded", dump)
+}
+
+func (handler *ApiHandler) RemoveImport(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	importID, err := uuid.Parse(chi.URLParam(r, "import_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	if err := handler.mn.RemoveImport(r.Context(), applicationID, importID); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "import removed", nil)
+}
+
+func (handler *ApiHandler) ImportDatabase(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	importID, err := uuid.Parse(chi.URLParam(r, "import_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	params := types.ImportDatabaseParams{
+		ImportID:      importID,
+		Environment:   queries.Get("environment"),
+		StorageEngine: types.StorageEngine(queries.Get("engine")),
+		Wipe:          queries.Get("wipe") == "true",
+	}
+	if params.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	// an import must not be interrupted half-way because the client went away
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.ImportDatabase(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) CloneDatabase(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	params := types.CloneDatabaseParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if params.From == "" || params.To == "" {
+		badRequest(w, errors.New("from and to environments are required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	// a clone must not be interrupted while the data of the target is replaced
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.CloneDatabase(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) LinkDatabase(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	params := types.LinkDatabaseParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if params.Environment == "" || params.StorageEngine == "" || params.URL == "" {
+		badRequest(w, errors.New("environment, storage engine and url are required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.LinkDatabase(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) UnlinkDatabase(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	params := types.UnlinkDatabaseParams{
+		Environment:   queries.Get("environment"),
+		StorageEngine: types.StorageEngine(queries.Get("engine")),
+	}
+	if params.Environment == "" || params.StorageEngine == "" {
+		badRequest(w, errors.New("environment and storage engine are required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.UnlinkDatabase(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) ScaleReplicas(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	params := types.ScaleReplicasParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if params.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.ScaleReplicas(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) ListReplicas(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	environment := r.URL.Query().Get("environment")
+	if environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	result, err := handler.mn.ListReplicas(r.Context(), applicationID, environment)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "success", result)
+}
+
+func (handler *ApiHandler) AddAddon(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	params := types.AddAddonParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if params.Environment == "" || params.Name == "" {
+		badRequest(w, errors.New("environment and name are required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.AddAddon(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) RemoveAddon(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	params := types.RemoveAddonParams{
+		Environment: queries.Get("environment"),
+		Name:        chi.URLParam(r, "name"),
+		KeepData:    queries.Get("keep_data") == "true",
+	}
+	if params.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.RemoveAddon(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) ListAddons(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	result, err := handler.mn.ListAddons(r.Context(), applicationID, r.URL.Query().Get("environment"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "success", result)
+}
+
+func (handler *ApiHandler) AddVolume(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	params := types.AddVolumeParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if params.Environment == "" || params.Name == "" || params.Path == "" {
+		badRequest(w, errors.New("environment, name and path are required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.AddVolume(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) RemoveVolume(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	params := types.RemoveVolumeParams{
+		Environment: queries.Get("environment"),
+		Name:        chi.URLParam(r, "name"),
+		KeepData:    queries.Get("keep_data") == "true",
+	}
+	if params.Environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	identifier, err := misc.DefaultRandomIdGenerator.Generate(10)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	handler.stream(w, r, identifier, func(ctx context.Context) error {
+		return handler.mn.RemoveVolume(ctx, applicationID, params, identifier)
+	})
+}
+
+func (handler *ApiHandler) ListVolumes(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	result, err := handler.mn.ListVolumes(r.Context(), applicationID, r.URL.Query().Get("environment"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "success", result)
+}
+
+func (handler *ApiHandler) AddJob(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	params := types.AddJobParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if params.Environment == "" || params.Name == "" {
+		badRequest(w, errors.New("environment and name are required"))
+		return
+	}
+
+	job, err := handler.mn.AddJob(r.Context(), applicationID, params)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "job scheduled", job)
+}
+
+func (handler *ApiHandler) RemoveJob(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	environment := r.URL.Query().Get("environment")
+	if environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+
+	if err := handler.mn.RemoveJob(r.Context(), applicationID, environment, chi.URLParam(r, "name")); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "job removed", nil)
+}
+
+func (handler *ApiHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	result, err := handler.mn.ListJobs(r.Context(), applicationID, r.URL.Query().Get("environment"))
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "success", result)
+}
+
+func (handler *ApiHandler) JobHistory(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	queries := r.URL.Query()
+	environment := queries.Get("environment")
+	if environment == "" {
+		badRequest(w, errors.New("environment is required"))
+		return
+	}
+	limit, _ := strconv.Atoi(queries.Get("limit"))
+
+	result, err := handler.mn.JobHistory(r.Context(), applicationID, environment, chi.URLParam(r, "name"), limit)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "success", result)
+}
+
+func (handler *ApiHandler) AllowInternalAccess(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	params := types.InternalAccessParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if params.Application == "" {
+		badRequest(w, errors.New("application is required"))
+		return
+	}
+
+	access, err := handler.mn.AllowInternalAccess(r.Context(), applicationID, params)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "access allowed", access)
+}
+
+func (handler *ApiHandler) DenyInternalAccess(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	if err := handler.mn.DenyInternalAccess(r.Context(), applicationID, chi.URLParam(r, "name")); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "access denied", nil)
+}
+
+func (handler *ApiHandler) InternalAccess(w http.ResponseWriter, r *http.Request) {
+	applicationID, err := uuid.Parse(chi.URLParam(r, "application_id"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+
+	result, err := handler.mn.InternalAccess(r.Context(), applicationID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	ok(w, "success", result)
 }
 
 func (handler *ApiHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
@@ -8,6 +8,8 @@ import (
 
 const (
 	authorizationHeader = "X-Access-Token"
+	// accessKeyHeader carries the access key the cli sends with every request
+	accessKeyHeader = "X-Access-Key"
 )
 
 type (
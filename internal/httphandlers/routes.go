@@ -20,8 +20,10 @@ func Routes(h *ApiHandler) chi.Router {
 		r.Put("/applications/{application_id}/domains", h.AddDomain)
 		r.Delete("/applications/{application_id}/domains", h.RemoveDomain)
 		r.Post("/applications/add-credentials", h.AddCredentials)
+		r.Get("/storage-drivers", h.ListStorageDrivers)
 		r.Get("/backups/{id}/download", h.DownloadBackup)
 		r.Get("/applications/{application_id}/backups", h.ListBackups)
+		r.Post("/applications/{application_id}/backups", h.BackupNow)
 		r.Get("/applications/{application_id}/deployments", h.ListDeployments)
 		r.Get("/applications", h.ListApplications)
 		r.Get("/application", h.GetApplication)
@@ -29,6 +31,37 @@ func Routes(h *ApiHandler) chi.Router {
 		r.Put("/applications/{application_id}/ip-whitelist", h.WhitelistIP)
 		r.Put("/applications/{application_id}/ip-blacklist", h.BlacklistIP)
 		r.Put("/applications/{application_id}/backup-settings", h.CreateBackup)
+		r.Put("/applications/{application_id}/pitr", h.UpdatePITR)
+		r.Post("/applications/{application_id}/restore", h.Restore)
+		r.Post("/applications/{application_id}/databases", h.AddStorageEngine)
+		r.Delete("/applications/{application_id}/databases", h.RemoveStorageEngine)
+		r.Post("/applications/{application_id}/databases/upgrade", h.UpgradeDatabase)
+		r.Put("/applications/{application_id}/databases/config", h.UpdateDatabaseConfig)
+		r.Post("/applications/{application_id}/databases/rotate-credentials", h.RotateCredentials)
+		r.Get("/applications/{application_id}/databases/tunnel", h.DatabaseTunnel)
+		r.Get("/applications/{application_id}/databases/shell", h.DatabaseShell)
+		r.Post("/applications/{application_id}/databases/imports", h.CreateImport)
+		r.Put("/applications/{application_id}/databases/imports/{import_id}", h.UploadImportChunk)
+		r.Delete("/applications/{application_id}/databases/imports/{import_id}", h.RemoveImport)
+		r.Post("/applications/{application_id}/databases/imports/{import_id}/load", h.ImportDatabase)
+		r.Post("/applications/{application_id}/databases/clone", h.CloneDatabase)
+		r.Post("/applications/{application_id}/databases/link", h.LinkDatabase)
+		r.Delete("/applications/{application_id}/databases/link", h.UnlinkDatabase)
+		r.Put("/applications/{application_id}/databases/replicas", h.ScaleReplicas)
+		r.Get("/applications/{application_id}/databases/replicas", h.ListReplicas)
+		r.Post("/applications/{application_id}/addons", h.AddAddon)
+		r.Get("/applications/{application_id}/addons", h.ListAddons)
+		r.Delete("/applications/{application_id}/addons/{name}", h.RemoveAddon)
+		r.Post("/applications/{application_id}/volumes", h.AddVolume)
+		r.Get("/applications/{application_id}/volumes", h.ListVolumes)
+		r.Delete("/applications/{application_id}/volumes/{name}", h.RemoveVolume)
+		r.Post("/applications/{application_id}/jobs", h.AddJob)
+		r.Get("/applications/{application_id}/jobs", h.ListJobs)
+		r.Delete("/applications/{application_id}/jobs/{name}", h.RemoveJob)
+		r.Get("/applications/{application_id}/jobs/{name}/runs", h.JobHistory)
+		r.Post("/applications/{application_id}/internal-access", h.AllowInternalAccess)
+		r.Get("/applications/{application_id}/internal-access", h.InternalAccess)
+		r.Delete("/applications/{application_id}/internal-access/{name}", h.DenyInternalAccess)
 		r.Get("/applications/{application_id}/logs", h.TailLogs)
 		r.Get("/applications/{application_id}/stream-logs", h.StreamLogs)
 
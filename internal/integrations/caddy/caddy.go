@@ -24,6 +24,8 @@ type Client interface {
 	ApplyConfig(ctx context.Context, instanceType types.InstanceType, deployment *types.Deployment) error
 	ApplyDomainConfig(ctx context.Context, domain *types.Domain, deployment *types.Deployment, op types.DomainOperation) error
 	RemoveConfig(ctx context.Context, deployment *types.Deployment) error
+	ApplyRoute(ctx context.Context, host, upstream string) error
+	RemoveRoute(ctx context.Context, host string) error
 	Wait(ctx context.Context) error
 }
 
@@ -135,7 +137,7 @@ func (c *caddyClient) patchBackendConfig(ctx context.Context, deployment *types.
 	}
 
 	routes := cfg.Apps.HTTP.Servers[mainServer].Routes
-	routeIdx := c.findRouteIndex(routes, deployment.AccessURL(types.InstanceTypeBackend))
+	routeIdx := c.findRouteIndex(routes, deployment.ServiceAccessURL())
 	upStreams := make([]Upstream, 0, deployment.Instances)
 	for idx := 0; idx < deployment.Instances; idx++ {
 		upStreams = append(upStreams, Upstream{
@@ -143,16 +145,21 @@ func (c *caddyClient) patchBackendConfig(ctx context.Context, deployment *types.
 		})
 	}
 
-	domains, err := c.domain.FindForEnvironmentAndInstanceType(ctx, deployment.ApplicationID, deployment.Environment, types.InstanceTypeBackend)
-	if err != nil {
-		return fmt.Errorf("error fetching domains: %s", err)
-	}
-
 	var hosts []string
-	for _, next := range domains {
-		hosts = append(hosts, next.Name)
+	if deployment.Service == "" {
+		domains, err := c.domain.FindForEnvironmentAndInstanceType(ctx, deployment.ApplicationID, deployment.Environment, types.InstanceTypeBackend)
+		if err != nil {
+			return fmt.Errorf("error fetching domains: %s", err)
+		}
+
+		for _, next := range domains {
+			hosts = append(hosts, next.Name)
+		}
+	} else {
+		// the domains of a named service are declared with it in .sarabi.yml
+		hosts = append(hosts, deployment.Settings.Domains...)
 	}
-	hosts = append(hosts, deployment.AccessURL(types.InstanceTypeBackend))
+	hosts = append(hosts, deployment.ServiceAccessURL())
 
 	handles := make([]Handle, 0)
 	handles = append(handles, Handle{Handler: "reverse_proxy", Upstreams: upStreams})
@@ -215,6 +222,41 @@ func (c *caddyClient) patchFrontendConfig(ctx context.Context, deployment *types
 }
 
 func (c *caddyClient) RemoveConfig(ctx context.Context, deployment *types.Deployment) error {
+	if deployment.InstanceType == types.InstanceTypeBackend {
+		return c.RemoveRoute(ctx, deployment.ServiceAccessURL())
+	}
+	return c.RemoveRoute(ctx, deployment.AccessURL(deployment.InstanceType))
+}
+
+// ApplyRoute proxies the requests for host to upstream, the route of host is replaced when it exists
+func (c *caddyClient) ApplyRoute(ctx context.Context, host, upstream string) error {
+	cfg := &Config{}
+	err := c.httpClient.Do(ctx, "GET", caddyUrl, nil, cfg)
+	if err != nil {
+		return err
+	}
+
+	routes := cfg.Apps.HTTP.Servers[mainServer].Routes
+	routeIdx := c.findRouteIndex(routes, host)
+	updatedRoute := Route{
+		Handle: []Handle{{Handler: "reverse_proxy", Upstreams: []Upstream{{Dial: upstream}}}},
+		Match: []Match{
+			{Host: []string{host}},
+		},
+	}
+
+	if routeIdx == -1 {
+		patchUrl := fmt.Sprintf("%sapps/http/servers/%s/routes", caddyUrl, mainServer)
+		routes = append(routes, updatedRoute)
+		return c.httpClient.Do(ctx, "PATCH", patchUrl, routes, nil)
+	}
+
+	patchUrl := fmt.Sprintf("%sapps/http/servers/%s/routes/%d", caddyUrl, mainServer, routeIdx)
+	return c.httpClient.Do(ctx, "PATCH", patchUrl, updatedRoute, nil)
+}
+
+// RemoveRoute removes the route of host, nothing is done when it has none
+func (c *caddyClient) RemoveRoute(ctx context.Context, host string) error {
 	cfg := &Config{}
 	err := c.httpClient.Do(ctx, "GET", caddyUrl, nil, cfg)
 	if err != nil {
@@ -222,7 +264,7 @@ func (c *caddyClient) RemoveConfig(ctx context.Context, deployment *types.Deploy
 	}
 
 	routes := cfg.Apps.HTTP.Servers[mainServer].Routes
-	routeIdx := c.findRouteIndex(routes, deployment.AccessURL(deployment.InstanceType))
+	routeIdx := c.findRouteIndex(routes, host)
 	if routeIdx == -1 {
 		return nil
 	}
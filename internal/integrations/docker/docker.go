@@ -43,13 +43,21 @@ type Docker interface {
 	StopAndRemoveContainer(ctx context.Context, param StopContainerParams) error
 	CopyFileIntoContainer(ctx context.Context, containerName, src, dest string) error
 	ExtractFiles(ctx context.Context, containerName, fileDir string) error
-	ConnectContainer(ctx context.Context, containerName, networkName string) error
+	ConnectContainer(ctx context.Context, containerName, networkName string, aliases ...string) error
+	RemoveNetwork(ctx context.Context, name string) error
 	ContainerExec(ctx context.Context, params ContainerExecParams) (io.Reader, error)
+	ContainerExecAttach(ctx context.Context, params ContainerExecParams) (*ExecSession, error)
 	CopyFromContainer(ctx context.Context, containerName, filePath string) (types.File, error)
 	ContainerStatus(ctx context.Context, name string) (string, error)
+	ContainerAddress(ctx context.Context, name, network string) (string, error)
 	ContainerLogs(ctx context.Context, name string) (io.ReadCloser, error)
 	ContainerEvents(ctx context.Context) (<-chan events.Message, <-chan error)
 	ListContainers(ctx context.Context) ([]ContainerInfo, error)
+	StopContainer(ctx context.Context, name string) error
+	RunContainer(ctx context.Context, params StartContainerParams) (*RunContainerResult, error)
+	RemoveVolume(ctx context.Context, name string) error
+	CopyIntoVolume(ctx context.Context, containerName, dir, volume string) error
+	VolumeSizes(ctx context.Context) (map[string]int64, error)
 }
 
 type dockerClient struct {
@@ -85,6 +93,7 @@ func (d *dockerClient) BuildImage(ctx context.Context, application *types.Deploy
 	imageName := application.ImageName()
 	response, err := d.hostClient.ImageBuild(ctx, &buildCtx, dockerclient.ImageBuildOptions{
 		Tags:        []string{imageName},
+		Dockerfile:  application.Settings.Dockerfile,
 		Remove:      true,
 		ForceRemove: true,
 	})
@@ -135,7 +144,14 @@ func (d *dockerClient) IsContainerRunning(ctx context.Context, container string)
 	}
 
 	if result.State.Running || result.State.Restarting {
-		return true, ContainerInfo{ID: result.ID, Name: result.Name}, nil
+		info := ContainerInfo{ID: result.ID, Name: result.Name, Mounts: make(map[string]string, len(result.Mounts))}
+		if result.HostConfig != nil {
+			info.PortBindings = result.HostConfig.PortBindings
+		}
+		for _, next := range result.Mounts {
+			info.Mounts[next.Name] = next.Destination
+		}
+		return true, info, nil
 	}
 
 	return false, ContainerInfo{}, nil
@@ -156,6 +172,24 @@ func (d *dockerClient) CreateNetwork(ctx context.Context, name string) error {
 	return nil
 }
 
+// RemoveNetwork disconnects the containers of a network and removes it, it is not an error when there is no such network
+func (d *dockerClient) RemoveNetwork(ctx context.Context, name string) error {
+	r, err := d.hostClient.NetworkInspect(ctx, name, network.InspectOptions{})
+	if client.IsErrNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for id := range r.Containers {
+		if err := d.hostClient.NetworkDisconnect(ctx, name, id, true); err != nil && !client.IsErrNotFound(err) {
+			return err
+		}
+	}
+	return d.hostClient.NetworkRemove(ctx, name)
+}
+
 func (d *dockerClient) PullImage(ctx context.Context, name string) error {
 	result, err := d.hostClient.ImagePull(ctx, name, image.PullOptions{})
 	if err != nil {
@@ -181,7 +215,7 @@ func (d *dockerClient) StartContainerAndWait(ctx context.Context, params StartCo
 	if params.Network != nil {
 		containerNetwork = &network.NetworkingConfig{
 			EndpointsConfig: map[string]*network.EndpointSettings{
-				*params.Network: {},
+				*params.Network: {Aliases: params.Aliases},
 			},
 		}
 	}
@@ -203,6 +237,7 @@ func (d *dockerClient) StartContainerAndWait(ctx context.Context, params StartCo
 			Labels:       params.DefaultLabels(),
 			Cmd:          params.Cmd,
 			User:         params.User,
+			Hostname:     params.Hostname,
 		},
 		&container.HostConfig{
 			Binds:        params.Volumes,
@@ -343,8 +378,14 @@ func (d *dockerClient) ExtractFiles(ctx context.Context, containerName, fileDir
 	return nil
 }
 
-func (d *dockerClient) ConnectContainer(ctx context.Context, containerName, networkName string) error {
-	err := d.hostClient.NetworkConnect(ctx, networkName, containerName, nil)
+// ConnectContainer connects a container to a network, the other containers of the network also resolve it by its aliases
+func (d *dockerClient) ConnectContainer(ctx context.Context, containerName, networkName string, aliases ...string) error {
+	var settings *network.EndpointSettings
+	if len(aliases) > 0 {
+		settings = &network.EndpointSettings{Aliases: aliases}
+	}
+
+	err := d.hostClient.NetworkConnect(ctx, networkName, containerName, settings)
 	if err != nil && strings.Contains(err.Error(), "already exists in network") {
 		return nil
 	}
@@ -396,6 +437,28 @@ func (d *dockerClient) ContainerExec(ctx context.Context, params ContainerExecPa
 	return hr.Conn, nil
 }
 
+// ContainerExecAttach starts a command in the specified container with its input and output attached,
+// unlike ContainerExec it returns while the command is still running
+func (d *dockerClient) ContainerExecAttach(ctx context.Context, params ContainerExecParams) (*ExecSession, error) {
+	execID, err := d.hostClient.ContainerExecCreate(ctx, params.ContainerName, container.ExecOptions{
+		Env:          params.Envs,
+		Cmd:          params.Cmd,
+		Tty:          params.Tty,
+		AttachStdin:  true,
+		AttachStderr: true,
+		AttachStdout: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hr, err := d.hostClient.ContainerExecAttach(ctx, execID.ID, container.ExecAttachOptions{Tty: params.Tty})
+	if err != nil {
+		return nil, err
+	}
+	return &ExecSession{id: execID.ID, tty: params.Tty, hostClient: d.hostClient, hr: hr}, nil
+}
+
 // CopyFromContainer copies a file from a container to the host
 func (d *dockerClient) CopyFromContainer(ctx context.Context, containerName, filePath string) (types.File, error) {
 	tempFile := fmt.Sprintf("%s.sql", uuid.NewString())
@@ -439,6 +502,21 @@ func (d *dockerClient) ContainerStatus(ctx context.Context, name string) (string
 	return result.State.Status, nil
 }
 
+// ContainerAddress returns the IP address of a container on a network, it is reachable from the host
+// without the container publishing any port
+func (d *dockerClient) ContainerAddress(ctx context.Context, name, network string) (string, error) {
+	result, err := d.hostClient.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	if result.NetworkSettings == nil || result.NetworkSettings.Networks[network] == nil ||
+		result.NetworkSettings.Networks[network].IPAddress == "" {
+		return "", fmt.Errorf("container %s is not connected to network %s", name, network)
+	}
+	return result.NetworkSettings.Networks[network].IPAddress, nil
+}
+
 func (d *dockerClient) ContainerLogs(ctx context.Context, name string) (io.ReadCloser, error) {
 	return d.hostClient.ContainerLogs(ctx, name, container.LogsOptions{
 		ShowStdout: true,
@@ -488,6 +566,176 @@ func (d *dockerClient) CreateVolume(ctx context.Context, name string) error {
 	return nil
 }
 
+func (d *dockerClient) RemoveVolume(ctx context.Context, name string) error {
+	return d.hostClient.VolumeRemove(ctx, name, true)
+}
+
+// CopyIntoVolume copies the content of a directory of a container, running or not, to the root of a volume.
+// the files keep their owner and mode, the volume is written through a container that is created but never started
+func (d *dockerClient) CopyIntoVolume(ctx context.Context, containerName, dir, volume string) error {
+	source, err := d.hostClient.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.hostClient.ContainerCreate(ctx,
+		&container.Config{Image: source.Image},
+		&container.HostConfig{Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: volume, Target: "/volume"}}},
+		nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = d.hostClient.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	content, _, err := d.hostClient.CopyFromContainer(ctx, containerName, dir)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	// the archive holds the directory itself, its entries are moved to the root of the volume
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(stripArchiveRoot(content, writer))
+	}()
+	defer reader.Close()
+
+	return d.hostClient.CopyToContainer(ctx, resp.ID, "/volume", reader, container.CopyToContainerOptions{
+		CopyUIDGID: true,
+	})
+}
+
+// stripArchiveRoot writes the entries of a tar archive of one directory without the directory
+func stripArchiveRoot(from io.Reader, to io.Writer) error {
+	tr, tw := tar.NewReader(from), tar.NewWriter(to)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		_, name, ok := strings.Cut(header.Name, "/")
+		if !ok || name == "" {
+			continue
+		}
+		header.Name = name
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// VolumeSizes returns how much every volume of the host holds in bytes by name, -1 when docker did not compute it
+func (d *dockerClient) VolumeSizes(ctx context.Context) (map[string]int64, error) {
+	usage, err := d.hostClient.DiskUsage(ctx, dockerclient.DiskUsageOptions{
+		Types: []dockerclient.DiskUsageObject{dockerclient.VolumeObject},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(usage.Volumes))
+	for _, next := range usage.Volumes {
+		size := int64(-1)
+		if next.UsageData != nil {
+			size = next.UsageData.Size
+		}
+		result[next.Name] = size
+	}
+	return result, nil
+}
+
+// StopContainer stops a container without removing it, it can be started again with RestartContainer
+func (d *dockerClient) StopContainer(ctx context.Context, name string) error {
+	return d.hostClient.ContainerStop(ctx, name, container.StopOptions{})
+}
+
+// RunContainer starts a short-lived container, waits for it to exit and removes it.
+// unlike StartContainerAndWait, the container is never restarted and its output is returned
+func (d *dockerClient) RunContainer(ctx context.Context, params StartContainerParams) (*RunContainerResult, error) {
+	mounts := make([]mount.Mount, 0)
+	for k, v := range params.Mounts {
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeVolume,
+			Source: k,
+			Target: v,
+		})
+	}
+
+	var containerNetwork *network.NetworkingConfig
+	if params.Network != nil {
+		containerNetwork = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				*params.Network: {},
+			},
+		}
+	}
+
+	resp, err := d.hostClient.ContainerCreate(ctx,
+		&container.Config{
+			Env:    params.Environments,
+			Image:  params.Image,
+			Labels: params.DefaultLabels(),
+			Cmd:    params.Cmd,
+			User:   params.User,
+		},
+		&container.HostConfig{
+			Binds:       params.Volumes,
+			NetworkMode: network.NetworkBridge,
+			Mounts:      mounts,
+			Resources: container.Resources{
+				Memory:   params.Resources.Memory,
+				NanoCPUs: params.Resources.CPU,
+			},
+		},
+		containerNetwork,
+		nil,
+		params.Container)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = d.hostClient.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	statusCh, errCh := d.hostClient.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+	if err := d.hostClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return nil, err
+	}
+
+	result := &RunContainerResult{ID: resp.ID}
+	select {
+	case status := <-statusCh:
+		result.ExitCode = status.StatusCode
+	case err := <-errCh:
+		return nil, err
+	}
+
+	logs, err := d.hostClient.ContainerLogs(ctx, resp.ID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+
+	result.Stdout, result.Stderr, err = ReadExecResponse(logs)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (d *dockerClient) wait(ctx context.Context, containerID string) {
 	isRunning, _, err := d.IsContainerRunning(ctx, containerID)
 	if err != nil {
@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"context"
+	dockerclient "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"io"
+	"time"
+)
+
+// ExecSession is a command running in a container with its input and output attached
+type ExecSession struct {
+	id         string
+	tty        bool
+	hostClient client.APIClient
+	hr         dockerclient.HijackedResponse
+}
+
+// Write sends p to the input of the command
+func (s *ExecSession) Write(p []byte) (int, error) {
+	return s.hr.Conn.Write(p)
+}
+
+// CloseInput ends the input of the command, it reads EOF once it consumed what was written
+func (s *ExecSession) CloseInput() error {
+	return s.hr.CloseWrite()
+}
+
+// Output returns what the command prints. without a terminal, docker multiplexes stdout and stderr
+// in one stream, they are put back together here
+func (s *ExecSession) Output() io.Reader {
+	if s.tty {
+		return s.hr.Reader
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, s.hr.Reader)
+		_ = pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// Resize changes the size of the terminal of the command
+func (s *ExecSession) Resize(ctx context.Context, rows, cols uint) error {
+	if !s.tty || rows == 0 || cols == 0 {
+		return nil
+	}
+	return s.hostClient.ContainerExecResize(ctx, s.id, container.ResizeOptions{Height: rows, Width: cols})
+}
+
+// ExitCode waits for the command to exit and returns its exit code
+func (s *ExecSession) ExitCode(ctx context.Context) (int, error) {
+	for {
+		inspect, err := s.hostClient.ContainerExecInspect(ctx, s.id)
+		if err != nil {
+			return 0, err
+		}
+
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Close detaches from the command, what it still prints is lost. CloseInput lets it finish first
+func (s *ExecSession) Close() error {
+	return s.hr.Conn.Close()
+}
@@ -29,15 +29,27 @@ type BuildImageResult struct {
 }
 
 type ContainerInfo struct {
-	ID    string
-	Name  string
-	State string
+	ID           string
+	Name         string
+	State        string
+	PortBindings nat.PortMap
+	// Mounts are the paths of the container by the volume mounted at them
+	Mounts map[string]string
+}
+
+type RunContainerResult struct {
+	ID       string
+	ExitCode int64
+	Stdout   string
+	Stderr   string
 }
 
 type StartContainerParams struct {
 	Image         string
 	Container     string
+	Hostname      string
 	Network       *string
+	Aliases       []string
 	Volumes       []string
 	Environments  []string
 	Cmd           []string
@@ -59,6 +71,8 @@ type ContainerExecParams struct {
 	ContainerName string
 	Cmd           strslice.StrSlice
 	Envs          []string
+	// Tty allocates a terminal to the command, only used by ContainerExecAttach
+	Tty bool
 }
 
 type StopContainerParams struct {
@@ -59,8 +59,14 @@ type TSDBShipperConfig struct {
 }
 
 type AWSConfig struct {
-	S3               string `yaml:"s3"`
-	S3ForcePathStyle bool   `yaml:"s3forcepathstyle"`
+	S3               string         `yaml:"s3"`
+	S3ForcePathStyle bool           `yaml:"s3forcepathstyle"`
+	Insecure         bool           `yaml:"insecure"`
+	HTTPConfig       *AWSHTTPConfig `yaml:"http_config,omitempty"`
+}
+
+type AWSHTTPConfig struct {
+	CAFile string `yaml:"ca_file"`
 }
 
 type FileSystemConfig struct {
@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	backendcomponent "sarabi/internal/components/backend"
+	"sarabi/internal/types"
+	"time"
+)
+
+// AllowInternalAccess allows the backends of another application to reach the backends of an application at their
+// internal hosts, the running backends of the two are connected straight away. the two share a network, the
+// application reached can reach the containers of the allowed one too, see types.InternalAccess
+func (m *manager) AllowInternalAccess(ctx context.Context, applicationID uuid.UUID, params types.InternalAccessParams) (*types.InternalAccess, error) {
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	from, err := m.appService.GetByName(ctx, params.Application)
+	if err != nil {
+		return nil, fmt.Errorf("application %s not found", params.Application)
+	}
+	if from.ID == app.ID {
+		return nil, errors.New("an application always reaches its own backends")
+	}
+
+	access, err := m.appService.InternalAccess(ctx, app.ID, from.ID)
+	if err != nil {
+		return nil, err
+	}
+	if access != nil {
+		return access, nil
+	}
+
+	access = &types.InternalAccess{
+		ID:                uuid.New(),
+		ApplicationID:     app.ID,
+		FromApplicationID: from.ID,
+		CreatedAt:         time.Now(),
+	}
+	if err := m.appService.SaveInternalAccess(ctx, access); err != nil {
+		return nil, err
+	}
+
+	for _, id := range []uuid.UUID{app.ID, from.ID} {
+		backends, err := m.appService.FindCurrentlyActiveDeployments(ctx, id, types.InstanceTypeBackend)
+		if err != nil {
+			return nil, err
+		}
+		for _, next := range backends {
+			if err := backendcomponent.ConnectInternal(ctx, m.dockerClient, next, access); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return access, nil
+}
+
+// DenyInternalAccess stops another application from reaching the backends of an application, their network is removed
+func (m *manager) DenyInternalAccess(ctx context.Context, applicationID uuid.UUID, name string) error {
+	from, err := m.appService.GetByName(ctx, name)
+	if err != nil {
+		return fmt.Errorf("application %s not found", name)
+	}
+
+	access, err := m.appService.InternalAccess(ctx, applicationID, from.ID)
+	if err != nil {
+		return err
+	}
+	if access == nil {
+		return fmt.Errorf("%s is not allowed to reach the application", name)
+	}
+
+	if err := m.dockerClient.RemoveNetwork(ctx, access.NetworkName()); err != nil {
+		return err
+	}
+	return m.appService.RemoveInternalAccess(ctx, access.ID)
+}
+
+// InternalAccess returns the internal addresses of the active backends of an application, the applications
+// allowed to reach them and the applications it is allowed to reach
+func (m *manager) InternalAccess(ctx context.Context, applicationID uuid.UUID) (*types.InternalAccessResponse, error) {
+	backends, err := m.appService.FindCurrentlyActiveDeployments(ctx, applicationID, types.InstanceTypeBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := m.appService.InternalAccesses(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &types.InternalAccessResponse{
+		Hosts: lo.Map(backends, func(item *types.Deployment, index int) string {
+			return item.InternalAddress()
+		}),
+		AllowedFrom: make([]string, 0),
+		Reaches:     make([]string, 0),
+	}
+	for _, next := range accesses {
+		if next.ApplicationID == applicationID {
+			from, err := m.appService.Get(ctx, next.FromApplicationID)
+			if err != nil {
+				return nil, err
+			}
+			response.AllowedFrom = append(response.AllowedFrom, from.Name)
+			continue
+		}
+
+		to, err := m.appService.Get(ctx, next.ApplicationID)
+		if err != nil {
+			return nil, err
+		}
+		response.Reaches = append(response.Reaches, to.Name)
+	}
+	return response, nil
+}
+
+// removeInternalAccesses removes the networks of an application and the accesses to and from it
+func (m *manager) removeInternalAccesses(ctx context.Context, applicationID uuid.UUID) error {
+	accesses, err := m.appService.InternalAccesses(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	for _, next := range accesses {
+		if err := m.dockerClient.RemoveNetwork(ctx, next.NetworkName()); err != nil {
+			return err
+		}
+		if err := m.appService.RemoveInternalAccess(ctx, next.ID); err != nil {
+			return err
+		}
+	}
+	return m.dockerClient.RemoveNetwork(ctx, types.SelfAccess(applicationID).NetworkName())
+}
+
+func validateVisibility(visibility types.Visibility) error {
+	switch visibility {
+	case "", types.VisibilityPublic, types.VisibilityInternal:
+		return nil
+	}
+	return fmt.Errorf("invalid visibility: %s, it must be one of public or internal", visibility)
+}
@@ -0,0 +1,366 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"path"
+	"regexp"
+	proxycomponent "sarabi/internal/components/proxy"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/misc"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"strconv"
+	"time"
+)
+
+var (
+	// nameRegex is the form of the names of add-ons, volumes and jobs, they are part of docker names and host names
+	nameRegex    = regexp.MustCompile(`^[a-z][a-z0-9-]{0,29}$`)
+	varNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// AddAddon runs an image next to the application in an environment, on the network of the environment with
+// the variables generated for it. the backends are deployed again so that they get its address and variables.
+// an add-on added again is started over with the new settings, its data and generated variables are kept
+func (m *manager) AddAddon(ctx context.Context, applicationID uuid.UUID, params types.AddAddonParams, identifier string) error {
+	if err := validateAddon(params); err != nil {
+		return err
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	// a service of the same name would be routed at the same host
+	backends, err := m.activeBackends(ctx, applicationID, params.Environment)
+	if err != nil {
+		return err
+	}
+	if lo.ContainsBy(backends, func(item *types.Deployment) bool { return item.Service == params.Name }) {
+		return fmt.Errorf("invalid add-on name: %s, it is the name of a service of %s", params.Name, params.Environment)
+	}
+
+	addons, err := m.appService.Addons(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	addon := &types.Addon{ID: uuid.New(), CreatedAt: time.Now()}
+	for _, next := range addons {
+		if next.Environment != params.Environment {
+			continue
+		}
+		if next.Name == params.Name {
+			addon = next
+			continue
+		}
+		for _, key := range params.Secrets {
+			if lo.Contains(next.Secrets, key) {
+				return fmt.Errorf("%s is already generated for add-on %s", key, next.Name)
+			}
+		}
+	}
+	previous := *addon
+
+	addon.ApplicationID = applicationID
+	addon.Environment = params.Environment
+	addon.Name = params.Name
+	addon.Image = params.Image
+	addon.Port = params.Port
+	addon.Volume = params.Volume
+	addon.Vars = params.Vars
+	addon.Secrets = params.Secrets
+	addon.Public = params.Public
+
+	deployment := &types.Deployment{ApplicationID: app.ID, Environment: params.Environment, Application: *app}
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Pulling %s...", addon.Image))
+	if err := m.dockerClient.PullImage(ctx, addon.Image); err != nil {
+		return errorpkg.Wrap(err, "failed to pull image: "+addon.Image)
+	}
+	if err := m.dockerClient.CreateNetwork(ctx, deployment.NetworkName()); err != nil {
+		return err
+	}
+
+	secrets, err := m.addonSecrets(ctx, deployment, addon)
+	if err != nil {
+		return err
+	}
+	// the variables of the previous settings that are not generated anymore are removed
+	if removed, _ := lo.Difference(previous.Secrets, addon.Secrets); len(removed) > 0 {
+		if err := m.secretService.Delete(ctx, applicationID, params.Environment, types.InstanceTypeAddon, removed...); err != nil {
+			return errorpkg.Wrap(err, "failed to remove variables of "+addon.Name)
+		}
+	}
+	if _, err := m.secretService.CreateAll(ctx, secrets...); err != nil {
+		return errorpkg.Wrap(err, "failed to save variables of "+addon.Name)
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Starting %s in %s", addon.Name, params.Environment))
+	if err := m.startAddon(ctx, deployment, addon, secrets); err != nil {
+		return errorpkg.Wrap(err, "failed to start "+addon.Name)
+	}
+
+	if addon.Public {
+		if err := m.dockerClient.ConnectContainer(ctx, proxycomponent.ProxyServerName, deployment.NetworkName()); err != nil {
+			return err
+		}
+		upstream := deployment.AddonContainerName(addon.Name) + ":" + addon.Port
+		if err := m.caddyClient.ApplyRoute(ctx, deployment.AddonAccessURL(addon.Name), upstream); err != nil {
+			return errorpkg.Wrap(err, "failed to route "+deployment.AddonAccessURL(addon.Name))
+		}
+	} else if previous.Public {
+		if err := m.caddyClient.RemoveRoute(ctx, deployment.AddonAccessURL(addon.Name)); err != nil {
+			return err
+		}
+	}
+
+	if err := m.appService.SaveAddon(ctx, addon); err != nil {
+		return err
+	}
+
+	if err := m.redeployBackends(ctx, applicationID, params.Environment, identifier,
+		fmt.Sprintf("Deploying %s with the variables of %s", params.Environment, addon.Name)); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("%s is running in %s at %s:%s", addon.Name, params.Environment, addon.Name, addon.Port)
+	if addon.Public {
+		message += ", public at " + m.toURL(deployment.AddonAccessURL(addon.Name))
+	}
+	m.eventBus.Broadcast(identifier, eventbus.Complete, message)
+	return nil
+}
+
+// RemoveAddon removes an add-on from an environment with its variables, the backends are deployed again without them.
+// the data of the add-on is deleted unless it is kept
+func (m *manager) RemoveAddon(ctx context.Context, applicationID uuid.UUID, params types.RemoveAddonParams, identifier string) error {
+	addon, err := m.appService.Addon(ctx, applicationID, params.Environment, params.Name)
+	if err != nil {
+		return err
+	}
+	if addon == nil {
+		return fmt.Errorf("%s has no add-on named %s", params.Environment, params.Name)
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.secretService.Delete(ctx, applicationID, addon.Environment, types.InstanceTypeAddon, addonVarKeys(addon)...); err != nil {
+		return errorpkg.Wrap(err, "failed to remove variables of "+addon.Name)
+	}
+
+	if err := m.redeployBackends(ctx, applicationID, params.Environment, identifier,
+		fmt.Sprintf("Deploying %s without the variables of %s", params.Environment, addon.Name)); err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Removing %s from %s", addon.Name, params.Environment))
+	deployment := &types.Deployment{ApplicationID: app.ID, Environment: addon.Environment, Application: *app}
+	if err := m.removeAddon(ctx, deployment, addon, params.KeepData); err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete, fmt.Sprintf("Removed %s from %s", addon.Name, params.Environment))
+	return nil
+}
+
+// ListAddons returns the add-ons of an environment with the status of their containers, the ones of every
+// environment when it is empty
+func (m *manager) ListAddons(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Addon, error) {
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	addons, err := m.appService.Addons(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*types.Addon, 0, len(addons))
+	for _, next := range addons {
+		if environment != "" && next.Environment != environment {
+			continue
+		}
+
+		deployment := &types.Deployment{ApplicationID: app.ID, Environment: next.Environment, Application: *app}
+		next.Status, err = m.dockerClient.ContainerStatus(ctx, deployment.AddonContainerName(next.Name))
+		if err != nil {
+			next.Status = "missing"
+		}
+		if next.Public {
+			next.URL = m.toURL(deployment.AddonAccessURL(next.Name))
+		}
+		result = append(result, next)
+	}
+	return result, nil
+}
+
+// addonSecrets returns the variables of an add-on, the ones generated before are kept as they are
+func (m *manager) addonSecrets(ctx context.Context, deployment *types.Deployment, addon *types.Addon) ([]types.CreateSecretParams, error) {
+	current, err := m.secretService.FindAll(ctx, deployment.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+	return addonVars(deployment, addon, current)
+}
+
+// addonVars returns the variables of an add-on, a secret is generated unless current has a value for it
+func addonVars(deployment *types.Deployment, addon *types.Addon, current []*types.Secret) ([]types.CreateSecretParams, error) {
+	values := make(map[string]string)
+	for _, next := range current {
+		if next.Environment == deployment.Environment && types.InstanceType(next.InstanceType) == types.InstanceTypeAddon {
+			values[next.Name] = next.Value
+		}
+	}
+
+	prefix := types.AddonVarPrefix(addon.Name)
+	vars := map[string]string{
+		prefix + "HOST": addon.Name,
+		prefix + "PORT": addon.Port,
+		prefix + "URL":  fmt.Sprintf("http://%s:%s", addon.Name, addon.Port),
+	}
+	for _, key := range addon.Secrets {
+		value, ok := values[key]
+		if !ok {
+			var err error
+			if value, err = misc.DefaultRandomIdGenerator.Generate(32); err != nil {
+				return nil, err
+			}
+		}
+		vars[key] = value
+	}
+
+	result := make([]types.CreateSecretParams, 0, len(vars))
+	for _, key := range addonVarKeys(addon) {
+		result = append(result, types.CreateSecretParams{
+			Key:           key,
+			Value:         vars[key],
+			Environment:   deployment.Environment,
+			InstanceType:  types.InstanceTypeAddon,
+			ApplicationID: deployment.ApplicationID,
+		})
+	}
+	return result, nil
+}
+
+func (m *manager) startAddon(ctx context.Context, deployment *types.Deployment, addon *types.Addon, secrets []types.CreateSecretParams) error {
+	name := deployment.AddonContainerName(addon.Name)
+	_ = m.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{ContainerName: name})
+
+	envs := make([]string, 0, len(addon.Vars)+len(secrets))
+	for _, next := range secrets {
+		envs = append(envs, next.Key+"="+next.Value)
+	}
+	for k, v := range addon.Vars {
+		envs = append(envs, k+"="+v)
+	}
+
+	networkName := deployment.NetworkName()
+	params := docker.StartContainerParams{
+		Image:        addon.Image,
+		Container:    name,
+		Hostname:     addon.Name,
+		Network:      &networkName,
+		Aliases:      []string{addon.Name},
+		Environments: envs,
+	}
+	if addon.Volume != "" {
+		volume := deployment.AddonVolumeName(addon.Name)
+		if err := m.dockerClient.CreateVolume(ctx, volume); err != nil {
+			return err
+		}
+		params.Mounts = map[string]string{volume: addon.Volume}
+	}
+
+	_, err := m.dockerClient.StartContainerAndWait(ctx, params)
+	return err
+}
+
+// removeAddon removes the container, route and record of an add-on, its volume too unless keepData is set
+func (m *manager) removeAddon(ctx context.Context, deployment *types.Deployment, addon *types.Addon, keepData bool) error {
+	_ = m.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{
+		ContainerName: deployment.AddonContainerName(addon.Name),
+	})
+
+	if addon.Public {
+		if err := m.caddyClient.RemoveRoute(ctx, deployment.AddonAccessURL(addon.Name)); err != nil {
+			return err
+		}
+	}
+
+	if !keepData && addon.Volume != "" {
+		if err := m.dockerClient.RemoveVolume(ctx, deployment.AddonVolumeName(addon.Name)); err != nil {
+			logger.Warn("failed to remove add-on volume",
+				zap.String("volume", deployment.AddonVolumeName(addon.Name)),
+				zap.Error(err))
+		}
+	}
+	return m.appService.RemoveAddon(ctx, addon.ID)
+}
+
+// addonVarKeys returns the names of the variables of an add-on, the backends get all of them
+func addonVarKeys(addon *types.Addon) []string {
+	prefix := types.AddonVarPrefix(addon.Name)
+	return append([]string{prefix + "HOST", prefix + "PORT", prefix + "URL"}, addon.Secrets...)
+}
+
+func validateAddon(params types.AddAddonParams) error {
+	if params.Environment == "" {
+		return errors.New("environment is required")
+	}
+	if params.Image == "" {
+		return errors.New("image is required")
+	}
+	if !nameRegex.MatchString(params.Name) {
+		return fmt.Errorf("invalid add-on name: %s, it must start with a letter and contain lower case letters, numbers and hyphens", params.Name)
+	}
+
+	switch types.InstanceType(params.Name) {
+	case types.InstanceTypeFrontend, types.InstanceTypeBackend, types.InstanceTypeProxy, types.InstanceTypeDatabase:
+		return fmt.Errorf("%s is reserved, choose another name for the add-on", params.Name)
+	}
+
+	if port, err := strconv.Atoi(params.Port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port: %s", params.Port)
+	}
+	if params.Volume != "" && !path.IsAbs(params.Volume) {
+		return fmt.Errorf("invalid volume: %s, it must be an absolute path in the container", params.Volume)
+	}
+
+	reserved := addonVarKeys(&types.Addon{Name: params.Name})
+	for _, key := range params.Secrets {
+		if !varNameRegex.MatchString(key) {
+			return fmt.Errorf("invalid secret name: %s", key)
+		}
+		if lo.Contains(reserved, key) || key == "PORT" {
+			return fmt.Errorf("%s is set by sarabi, choose another name for the secret", key)
+		}
+	}
+	if len(lo.Uniq(params.Secrets)) != len(params.Secrets) {
+		return errors.New("secrets must not be repeated")
+	}
+
+	for key := range params.Vars {
+		if !varNameRegex.MatchString(key) {
+			return fmt.Errorf("invalid variable name: %s", key)
+		}
+		if lo.Contains(reserved, key) {
+			return fmt.Errorf("%s is set by sarabi, it can not be a variable of the add-on", key)
+		}
+		if lo.Contains(params.Secrets, key) {
+			return fmt.Errorf("%s is a generated secret, it can not be a variable of the add-on too", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"os"
+	"sarabi/internal/backup"
+	databasecomponent "sarabi/internal/components/database"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/misc"
+	"sarabi/internal/storage"
+	"sarabi/internal/types"
+	"sarabi/logger"
+)
+
+// CloneDatabase replaces the data of the storage engines of an environment with the data of another one.
+// a fresh dump of the source is loaded into a scratch container where the anonymisation rules are applied,
+// only the anonymised data reaches the target. the target is backed up before its data is replaced
+func (m *manager) CloneDatabase(ctx context.Context, applicationID uuid.UUID, params types.CloneDatabaseParams, identifier string) error {
+	if params.From == "" || params.To == "" {
+		return errors.New("source and target environments are required")
+	}
+	if params.From == params.To {
+		return errors.New("an environment can not be cloned into itself")
+	}
+
+	for _, env := range []string{params.From, params.To} {
+		if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, env); err != nil {
+			return errorpkg.Wrap(err, "no database running in environment: "+env)
+		}
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	engines := app.StorageEngines
+	if params.StorageEngine != "" {
+		se, err := storageEngineOf(app, params.StorageEngine)
+		if err != nil {
+			return err
+		}
+		engines = []types.StorageEngine{se}
+	}
+
+	rules, err := anonymisationRules(app, engines, params.Rules)
+	if err != nil {
+		return err
+	}
+
+	// a new salt for every clone, hashed values can not be matched with the ones of an earlier clone
+	salt, err := misc.DefaultRandomIdGenerator.Generate(32)
+	if err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Taking a backup of %s before replacing its data...", params.To))
+	if err := m.backupService.SafetyBackup(ctx, applicationID, params.To, types.BackupTriggerClone); err != nil {
+		return errorpkg.Wrap(err, "backup before clone failed")
+	}
+
+	for _, se := range engines {
+		if err := m.cloneEngine(ctx, app, se, params, rules[se], salt, identifier); err != nil {
+			return errorpkg.Wrap(err, "failed to clone "+se.String())
+		}
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete, fmt.Sprintf("Cloned the database of %s into %s", params.From, params.To))
+	return nil
+}
+
+func (m *manager) cloneEngine(ctx context.Context, app *types.Application, se types.StorageEngine,
+	params types.CloneDatabaseParams, rules []types.AnonymisationRule, salt, identifier string) error {
+	source, err := m.databaseSpec(ctx, app, params.From, se)
+	if err != nil {
+		return err
+	}
+
+	target, err := m.databaseSpec(ctx, app, params.To, se)
+	if err != nil {
+		return err
+	}
+
+	var anonymise []string
+	if len(rules) > 0 {
+		// built before anything runs, rules the engine can not apply fail the clone early
+		anonymise, err = target.provider.AnonymiseCommand(target.deployment, rules, salt)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Dumping %s in %s", se, params.From))
+	dumpPath, err := m.dumpDatabase(ctx, app, params.From, se)
+	if err != nil {
+		return errorpkg.Wrap(err, "failed to dump "+params.From)
+	}
+	defer os.Remove(dumpPath)
+
+	containerName := target.provider.ContainerName(target.deployment)
+	scratchContainer := containerName + "-clone"
+	scratchVolume := target.deployment.VolumeName(se) + "-clone"
+	if err := m.dockerClient.CreateVolume(ctx, scratchVolume); err != nil {
+		return err
+	}
+	defer func() {
+		if err := m.dockerClient.RemoveVolume(context.Background(), scratchVolume); err != nil {
+			logger.Warn("failed to remove clone volume",
+				zap.String("volume", scratchVolume),
+				zap.Error(err))
+		}
+	}()
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Provisioning a scratch "+se.String())
+	if err := m.startDatabase(ctx, target, target.provider, scratchContainer, scratchVolume, false); err != nil {
+		return errorpkg.Wrap(err, "failed to provision scratch database")
+	}
+	defer func() {
+		_ = m.dockerClient.StopAndRemoveContainer(context.Background(), docker.StopContainerParams{
+			ContainerName: scratchContainer,
+		})
+	}()
+
+	if err := m.waitDatabaseReady(ctx, scratchContainer, target.provider); err != nil {
+		return err
+	}
+
+	fi, err := os.Open(dumpPath)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	dump, format, err := databasecomponent.OpenDump(fi)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := target.provider.LoadCommand(format, source.deployment, target.deployment)
+	if err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Loading the dump of %s into the scratch %s", params.From, se))
+	if err := m.loadDump(ctx, scratchContainer, cmd, target.envs, dump); err != nil {
+		return errorpkg.Wrap(err, "failed to load dump")
+	}
+
+	if len(anonymise) > 0 {
+		m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Anonymising %d column(s) of %s", len(rules), se))
+		if err := m.databaseExec(ctx, scratchContainer, anonymise); err != nil {
+			return errorpkg.Wrap(err, "failed to anonymise data")
+		}
+	}
+
+	// nothing may write to the target while its data is replaced
+	restartBackends := m.stopBackends(ctx, app.ID, params.To)
+	defer restartBackends()
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Replacing the data of %s in %s", se, params.To))
+	if err := m.databaseExec(ctx, containerName, target.provider.WipeCommand()); err != nil {
+		return errorpkg.Wrap(err, "failed to wipe "+params.To)
+	}
+	if err := m.databaseExec(ctx, containerName, target.provider.ImportCommand(scratchContainer)); err != nil {
+		return errorpkg.Wrap(err, "failed to copy data into "+params.To)
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Verifying copied data...")
+	expected, err := m.databaseOutput(ctx, scratchContainer, target.provider.ChecksumCommand())
+	if err != nil {
+		return errorpkg.Wrap(err, "failed to summarize cloned data")
+	}
+
+	actual, err := m.databaseOutput(ctx, containerName, target.provider.ChecksumCommand())
+	if err != nil {
+		return errorpkg.Wrap(err, "failed to summarize copied data")
+	}
+
+	if expected != actual {
+		return fmt.Errorf("copied data does not match, expected:\n%s\ngot:\n%s", expected, actual)
+	}
+	return nil
+}
+
+// dumpDatabase takes a dump of a storage engine with its backup executor and returns the path of the dump.
+// it is kept apart from the backups of the environment and never replicated
+func (m *manager) dumpDatabase(ctx context.Context, app *types.Application, environment string, se types.StorageEngine) (string, error) {
+	executor, err := backup.NewExecutor(se, m.dockerClient)
+	if err != nil {
+		return "", err
+	}
+
+	appVars, err := m.secretService.FindAll(ctx, app.ID)
+	if err != nil {
+		return "", err
+	}
+
+	path := importPath(app.ID, uuid.New())
+	_, err = executor.Execute(ctx, backup.Params{
+		Environment: environment,
+		DatabaseVars: lo.Filter(appVars, func(item *types.Secret, index int) bool {
+			return types.InstanceType(item.InstanceType) == types.InstanceTypeDatabase &&
+				item.Environment == environment
+		}),
+		Destinations: []storage.Destination{{
+			Name:    "clone",
+			Type:    storage.TypeFS,
+			Storage: dumpStorage{Storage: storage.NewFileStorage(), path: path},
+		}},
+		Application: app,
+		Portable:    true,
+	})
+	if err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// dumpStorage saves a dump at path whatever the location of the backup it was taken for
+type dumpStorage struct {
+	storage.Storage
+	path string
+}
+
+func (s dumpStorage) Save(ctx context.Context, location string, f types.File) error {
+	return s.Storage.Save(ctx, s.path, f)
+}
+
+// anonymisationRules groups the rules of a clone by the storage engine they apply to,
+// the rules of the engines left out of the clone are dropped
+func anonymisationRules(app *types.Application, engines []types.StorageEngine, rules []types.AnonymisationRule) (map[types.StorageEngine][]types.AnonymisationRule, error) {
+	result := make(map[types.StorageEngine][]types.AnonymisationRule)
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+
+		se, err := storageEngineOf(app, rule.StorageEngine)
+		if err != nil {
+			return nil, errorpkg.Wrap(err, fmt.Sprintf("anonymisation rule %s.%s", rule.Table, rule.Column))
+		}
+		if lo.Contains(engines, se) {
+			result[se] = append(result[se], rule)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,155 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"go.uber.org/zap"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"time"
+)
+
+// RotateCredentials moves an environment to new credentials of a storage engine. the new credentials are created
+// next to the current ones, the backend is deployed again with them and the current ones are revoked last, so the
+// backend can connect at all times. every rotation is recorded along with its outcome
+func (m *manager) RotateCredentials(ctx context.Context, applicationID uuid.UUID, params types.RotateCredentialsParams, identifier string) error {
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, params.Environment); err != nil {
+		return errorpkg.Wrap(err, "no database running in environment: "+params.Environment)
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	se, err := storageEngineOf(app, params.StorageEngine)
+	if err != nil {
+		return err
+	}
+
+	spec, err := m.databaseSpec(ctx, app, params.Environment, se)
+	if err != nil {
+		return err
+	}
+
+	vars, err := m.databaseVars(ctx, applicationID, params.Environment)
+	if err != nil {
+		return err
+	}
+
+	plan, err := spec.provider.RotateCredentials(spec.deployment, vars)
+	if err != nil {
+		return err
+	}
+
+	rotation := &types.CredentialRotation{
+		ID:            uuid.New(),
+		ApplicationID: applicationID,
+		Environment:   params.Environment,
+		StorageEngine: se,
+		PreviousUser:  plan.PreviousUser,
+		User:          plan.User,
+		Status:        types.CredentialRotationStarted,
+		CreatedAt:     time.Now(),
+	}
+	if err := m.rotationRepository.Save(ctx, rotation); err != nil {
+		return err
+	}
+	logger.Info("rotating database credentials",
+		zap.String("application", app.Name),
+		zap.String("env", params.Environment),
+		zap.String("engine", se.String()),
+		zap.String("previous_user", plan.PreviousUser),
+		zap.String("user", plan.User))
+
+	err = m.rotateCredentials(ctx, app, spec, plan, identifier)
+	m.completeRotation(rotation, err)
+	if err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete,
+		fmt.Sprintf("Rotated the credentials of %s in %s, connecting as %s", se, params.Environment, plan.User))
+	return nil
+}
+
+func (m *manager) rotateCredentials(ctx context.Context, app *types.Application, spec *databaseSpec, plan types.RotationPlan, identifier string) error {
+	environment := spec.deployment.Environment
+	containerName := spec.provider.ContainerName(spec.deployment)
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Creating new credentials...")
+	if err := m.databaseExec(ctx, containerName, plan.Grant); err != nil {
+		return errorpkg.Wrap(err, "failed to create new credentials")
+	}
+
+	if _, err := m.secretService.CreateAll(ctx, plan.Vars...); err != nil {
+		return errorpkg.Wrap(err, "failed to save new credentials, the current ones are still in use")
+	}
+
+	// the secrets of the running backend are updated in place, deploying it again with no new
+	// variables hands it the new credentials
+	if backends, err := m.activeBackends(ctx, app.ID, environment); err == nil && len(backends) > 0 {
+		m.eventBus.Broadcast(identifier, eventbus.Info, "Deploying the backend with the new credentials...")
+		if err := m.UpdateVariables(ctx, app.ID, environment); err != nil {
+			return errorpkg.Wrap(err, "failed to deploy the backend with the new credentials, the previous ones were not revoked")
+		}
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Revoking previous credentials...")
+	if err := m.databaseExec(ctx, containerName, plan.Revoke); err != nil {
+		return errorpkg.Wrap(err, "failed to revoke previous credentials")
+	}
+
+	if !plan.Restart {
+		return nil
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Restarting the database with the new credentials...")
+	spec, err := m.databaseSpec(ctx, app, environment, spec.engine)
+	if err != nil {
+		return err
+	}
+	return m.restartDatabase(ctx, spec, containerName, spec.deployment.VolumeName(spec.engine))
+}
+
+func (m *manager) completeRotation(rotation *types.CredentialRotation, err error) {
+	completedAt := time.Now()
+	rotation.CompletedAt = &completedAt
+	rotation.Status = types.CredentialRotationCompleted
+	if err != nil {
+		rotation.Status = types.CredentialRotationFailed
+		rotation.Error = err.Error()
+	}
+
+	if saveErr := m.rotationRepository.Save(context.Background(), rotation); saveErr != nil {
+		logger.Error("failed to record database credentials rotation",
+			zap.String("rotation_id", rotation.ID.String()),
+			zap.Error(saveErr))
+	}
+
+	logger.Info("database credentials rotation finished",
+		zap.String("rotation_id", rotation.ID.String()),
+		zap.String("env", rotation.Environment),
+		zap.String("engine", rotation.StorageEngine.String()),
+		zap.String("status", string(rotation.Status)),
+		zap.Error(err))
+}
+
+// databaseVars returns the variables of the storage engines of an environment by name
+func (m *manager) databaseVars(ctx context.Context, applicationID uuid.UUID, environment string) (map[string]string, error) {
+	secrets, err := m.secretService.FindAll(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	for _, next := range secrets {
+		if types.InstanceType(next.InstanceType) == types.InstanceTypeDatabase && next.Environment == environment {
+			vars[next.Name] = next.Value
+		}
+	}
+	return vars, nil
+}
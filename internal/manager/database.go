@@ -0,0 +1,423 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"net"
+	databasecomponent "sarabi/internal/components/database"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/service"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"strings"
+)
+
+// databaseSpec is what is needed to create the container of a storage engine running in an environment again
+type databaseSpec struct {
+	deployment *types.Deployment
+	engine     types.StorageEngine
+	provider   databasecomponent.Provider
+	envs       []string
+	// hostPort is the port of the host the engine is bound to
+	hostPort  string
+	resources types.ResourceAllocation
+	overrides types.ConfigSettings
+}
+
+// UpdateDatabaseConfig changes the settings of a storage engine of an environment.
+// the container is created again with the new configuration, the previous one is put back
+// if the engine doesn't come up with it
+func (m *manager) UpdateDatabaseConfig(ctx context.Context, applicationID uuid.UUID, params types.UpdateDatabaseConfigParams) error {
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, params.Environment); err != nil {
+		return errorpkg.Wrap(err, "no database running in environment: "+params.Environment)
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	se, err := storageEngineOf(app, params.StorageEngine)
+	if err != nil {
+		return err
+	}
+
+	spec, err := m.databaseSpec(ctx, app, params.Environment, se)
+	if err != nil {
+		return err
+	}
+
+	previous := spec.overrides
+	overrides := make(types.ConfigSettings, len(previous)+len(params.Settings))
+	for k, v := range previous {
+		overrides[k] = v
+	}
+	for k, v := range params.Settings {
+		// an empty value goes back to the tuned setting
+		if v == "" {
+			delete(overrides, k)
+			continue
+		}
+		overrides[k] = v
+	}
+
+	// invalid settings are rejected before the running container is touched
+	if _, err := spec.provider.Setup(spec.resources, overrides); err != nil {
+		return err
+	}
+
+	containerName := spec.provider.ContainerName(spec.deployment)
+	volume := spec.deployment.VolumeName(se)
+	spec.overrides = overrides
+	if err := m.restartDatabase(ctx, spec, containerName, volume); err != nil {
+		spec.overrides = previous
+		if rollbackErr := m.restartDatabase(context.Background(), spec, containerName, volume); rollbackErr != nil {
+			return fmt.Errorf("%w: failed to put back the previous configuration: %s", err, rollbackErr.Error())
+		}
+		return errorpkg.Wrap(err, "the previous configuration was put back")
+	}
+
+	if err := m.appService.UpdateDatabaseConfig(ctx, applicationID, params.Environment, se, overrides); err != nil {
+		return err
+	}
+	if err := m.restartReplicas(ctx, spec); err != nil {
+		return err
+	}
+
+	vars, err := m.databaseVars(ctx, applicationID, params.Environment)
+	if err != nil {
+		return err
+	}
+	changed, err := databasecomponent.UpdateConfigVars(ctx, m.secretService, spec.provider, spec.deployment, vars, overrides)
+	if err != nil {
+		return errorpkg.Wrap(err, "configuration updated but failed to update variables")
+	}
+
+	// e.g the connection URL of mongo names its replica set, the backends connect with the new one
+	if backends, err := m.activeBackends(ctx, applicationID, params.Environment); changed && err == nil && len(backends) > 0 {
+		return m.UpdateVariables(ctx, applicationID, params.Environment)
+	}
+	return nil
+}
+
+// DatabaseAddress returns the address of a storage engine of an environment on its docker network,
+// tunnels reach it there without the engine publishing its port on the host
+func (m *manager) DatabaseAddress(ctx context.Context, applicationID uuid.UUID, params types.DatabaseTunnelParams) (string, error) {
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return "", err
+	}
+
+	se, err := storageEngineOf(app, params.StorageEngine)
+	if err != nil {
+		return "", err
+	}
+
+	external, err := m.appService.ExternalDatabase(ctx, app.ID, params.Environment, se)
+	if err != nil {
+		return "", err
+	}
+	if external != nil {
+		return "", fmt.Errorf("%s in %s is an external database, connect to it at %s", se, params.Environment, external.Host)
+	}
+
+	deployment := &types.Deployment{
+		ApplicationID: app.ID,
+		Environment:   params.Environment,
+		Application:   *app,
+	}
+	provider := databasecomponent.NewProvider(se, app.EngineVersion(se, params.Environment))
+	ip, err := m.dockerClient.ContainerAddress(ctx, provider.ContainerName(deployment), deployment.NetworkName())
+	if err != nil {
+		return "", errorpkg.Wrap(err, fmt.Sprintf("%s is not running in environment: %s", se, params.Environment))
+	}
+	return net.JoinHostPort(ip, provider.Port()), nil
+}
+
+// DatabaseShell starts the native client of a storage engine of an environment inside its container,
+// logged in with the credentials stored for the environment rather than the ones the container started with
+func (m *manager) DatabaseShell(ctx context.Context, applicationID uuid.UUID, params types.DatabaseShellParams) (*docker.ExecSession, error) {
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	se, err := storageEngineOf(app, params.StorageEngine)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := &types.Deployment{
+		ApplicationID: app.ID,
+		Environment:   params.Environment,
+		Application:   *app,
+	}
+	provider := databasecomponent.NewProvider(se, app.EngineVersion(se, params.Environment))
+	containerName := provider.ContainerName(deployment)
+	running, _, err := m.dockerClient.IsContainerRunning(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+	if !running {
+		return nil, fmt.Errorf("%s is not running in environment: %s", se, params.Environment)
+	}
+
+	appVars, err := m.secretService.FindAll(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	envVars := lo.Filter(appVars, func(item *types.Secret, index int) bool {
+		return types.InstanceType(item.InstanceType) == types.InstanceTypeDatabase && item.Environment == params.Environment
+	})
+	envs := make([]string, 0, len(provider.ShellVars()))
+	for _, key := range provider.ShellVars() {
+		secret, err := service.FindSecret(key, envVars)
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, secret.Env())
+	}
+
+	return m.dockerClient.ContainerExecAttach(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           provider.ShellCommand(params.Command),
+		Envs:          envs,
+		Tty:           params.Command == "",
+	})
+}
+
+// storageEngineOf returns the storage engine of an application an operation applies to,
+// it can be left out when the application has only one
+func storageEngineOf(app *types.Application, se types.StorageEngine) (types.StorageEngine, error) {
+	if se == "" {
+		if len(app.StorageEngines) != 1 {
+			return "", errors.New("application has more than one storage engine, please specify one")
+		}
+		return app.StorageEngines[0], nil
+	}
+
+	if !lo.Contains(app.StorageEngines, se) {
+		return "", fmt.Errorf("application has no %s storage engine", se)
+	}
+	return se, nil
+}
+
+func (m *manager) databaseSpec(ctx context.Context, app *types.Application, environment string, se types.StorageEngine) (*databaseSpec, error) {
+	external, err := m.appService.ExternalDatabase(ctx, app.ID, environment, se)
+	if err != nil {
+		return nil, err
+	}
+	if external != nil {
+		return nil, fmt.Errorf("%s in %s is an external database, it is managed by its provider", se, environment)
+	}
+
+	deployment := &types.Deployment{
+		ApplicationID: app.ID,
+		Environment:   environment,
+		Application:   *app,
+	}
+	provider := databasecomponent.NewProvider(se, app.EngineVersion(se, environment))
+	running, info, err := m.dockerClient.IsContainerRunning(ctx, provider.ContainerName(deployment))
+	if err != nil {
+		return nil, err
+	}
+	if !running {
+		return nil, fmt.Errorf("%s is not running in environment: %s", se, environment)
+	}
+
+	appVars, err := m.secretService.FindAll(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := lo.Map(provider.EnvVars(deployment), func(item types.CreateSecretParams, index int) string {
+		return item.Key
+	})
+	envs := lo.FilterMap(appVars, func(item *types.Secret, index int) (string, bool) {
+		return item.Env(), types.InstanceType(item.InstanceType) == types.InstanceTypeDatabase &&
+			item.Environment == environment &&
+			lo.Contains(keys, item.Name)
+	})
+
+	resources, err := app.ResourcesAllocation(se)
+	if err != nil {
+		return nil, errorpkg.Wrap(err, "failed to get resources allocation")
+	}
+
+	overrides, err := m.appService.DatabaseConfig(ctx, app.ID, environment, se)
+	if err != nil {
+		return nil, err
+	}
+
+	return &databaseSpec{
+		deployment: deployment,
+		engine:     se,
+		provider:   provider,
+		envs:       envs,
+		hostPort:   hostPort(info.PortBindings),
+		resources:  resources,
+		overrides:  overrides,
+	}, nil
+}
+
+// MigrateDatabases moves the data of the database containers created before the data path of their engine changed,
+// e.g redis, into the volume of the engine. it is called once when the server starts, before any of them is created again
+func (m *manager) MigrateDatabases(ctx context.Context) {
+	apps, err := m.appService.List(ctx)
+	if err != nil {
+		logger.Error("failed to list applications", zap.Error(err))
+		return
+	}
+
+	for _, app := range apps {
+		deployments, err := m.appService.FindCurrentlyActiveDeployments(ctx, app.ID, types.InstanceTypeDatabase)
+		if err != nil {
+			logger.Error("failed to list database deployments", zap.String("application", app.Name), zap.Error(err))
+			continue
+		}
+
+		for _, dep := range deployments {
+			for _, se := range app.StorageEngines {
+				if err := m.migrateDataPath(ctx, app, dep.Environment, se); err != nil {
+					logger.Error("failed to move the data of a database into its volume",
+						zap.String("application", app.Name),
+						zap.String("env", dep.Environment),
+						zap.String("engine", se.String()),
+						zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// migrateDataPath copies the data of a container whose volume is not mounted where its engine writes into the volume,
+// then creates the container again with the volume mounted there. the container is left running as it was on failure
+func (m *manager) migrateDataPath(ctx context.Context, app *types.Application, environment string, se types.StorageEngine) error {
+	deployment := &types.Deployment{ApplicationID: app.ID, Environment: environment, Application: *app}
+	provider := databasecomponent.NewProvider(se, app.EngineVersion(se, environment))
+	containerName := provider.ContainerName(deployment)
+	running, info, err := m.dockerClient.IsContainerRunning(ctx, containerName)
+	if err != nil || !running {
+		return err
+	}
+
+	volume := deployment.VolumeName(provider.Engine())
+	if info.Mounts[volume] == provider.DataPath() {
+		return nil
+	}
+
+	spec, err := m.databaseSpec(ctx, app, environment, se)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("moving the data of a database into its volume",
+		zap.String("container", containerName),
+		zap.String("volume", volume))
+
+	// the engine writes what it holds in memory to disk when it is stopped
+	if err := m.dockerClient.StopContainer(ctx, containerName); err != nil {
+		return err
+	}
+	if err := m.dockerClient.CreateVolume(ctx, volume); err != nil {
+		_ = m.dockerClient.RestartContainer(ctx, containerName)
+		return err
+	}
+	if err := m.dockerClient.CopyIntoVolume(ctx, containerName, provider.DataPath(), volume); err != nil {
+		_ = m.dockerClient.RestartContainer(ctx, containerName)
+		return errorpkg.Wrap(err, "failed to copy the data into the volume")
+	}
+	return m.restartDatabase(ctx, spec, containerName, volume)
+}
+
+// restartDatabase replaces the container of a storage engine and waits for it to accept connections
+func (m *manager) restartDatabase(ctx context.Context, spec *databaseSpec, containerName, volume string) error {
+	err := m.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{ContainerName: containerName})
+	if err != nil {
+		return err
+	}
+
+	if err := m.startDatabase(ctx, spec, spec.provider, containerName, volume, true); err != nil {
+		return err
+	}
+	return m.waitDatabaseReady(ctx, containerName, spec.provider)
+}
+
+// startDatabase runs a database container with the variables and configuration of the environment.
+// the host port is only bound by the container serving the environment
+func (m *manager) startDatabase(ctx context.Context, spec *databaseSpec, provider databasecomponent.Provider, name, volume string, bindPorts bool) error {
+	config, bind, err := databasecomponent.WriteConfig(provider, spec.deployment, spec.resources, spec.overrides)
+	if err != nil {
+		return err
+	}
+
+	// the archive of the engine belongs to the container serving the environment
+	mounts := map[string]string{volume: provider.DataPath()}
+	if bindPorts {
+		mounts = databasecomponent.Mounts(provider, spec.deployment, volume)
+	}
+
+	networkName := spec.deployment.NetworkName()
+	params := docker.StartContainerParams{
+		Image:        provider.Image(),
+		Container:    name,
+		Hostname:     name,
+		Network:      &networkName,
+		Environments: append(append([]string{}, spec.envs...), config.Envs...),
+		Cmd:          config.Cmd,
+		Volumes:      []string{bind},
+		Mounts:       mounts,
+		Resources:    spec.resources,
+	}
+	if bindPorts && spec.hostPort != "" {
+		params.ExposedPorts, params.PortBindings = databasecomponent.PortBindings(provider, spec.hostPort)
+	}
+
+	_, err = m.dockerClient.StartContainerAndWait(ctx, params)
+	return err
+}
+
+// hostPort returns the port of the host a container is bound to, containers created before the ports were bound
+// to the loopback only are bound to it again on the same port
+func hostPort(bindings nat.PortMap) string {
+	for _, next := range bindings {
+		for _, binding := range next {
+			if binding.HostPort != "" {
+				return binding.HostPort
+			}
+		}
+	}
+	return ""
+}
+
+func (m *manager) waitDatabaseReady(ctx context.Context, containerName string, provider databasecomponent.Provider) error {
+	return databasecomponent.WaitReady(ctx, m.dockerClient, containerName, provider, databasecomponent.ReadyTimeout)
+}
+
+func (m *manager) databaseExec(ctx context.Context, containerName string, cmd []string) error {
+	_, err := m.databaseOutput(ctx, containerName, cmd)
+	return err
+}
+
+func (m *manager) databaseOutput(ctx context.Context, containerName string, cmd []string) (string, error) {
+	resp, err := m.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           cmd,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	out, _, err := docker.ReadExecResponse(resp)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
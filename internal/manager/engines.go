@@ -0,0 +1,270 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"os"
+	backendcomponent "sarabi/internal/components/backend"
+	databasecomponent "sarabi/internal/components/database"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/types"
+	"sarabi/logger"
+)
+
+// AddStorageEngine adds a storage engine to an application. it is provisioned in every environment the
+// application is deployed to, and the backends are deployed again so that they get its variables
+func (m *manager) AddStorageEngine(ctx context.Context, applicationID uuid.UUID, params types.AddStorageEngineParams, identifier string) error {
+	se, version, err := types.ParseStorageEngine(params.StorageEngine)
+	if err != nil {
+		return err
+	}
+
+	app, err := m.appService.AddStorageEngine(ctx, applicationID, se, version)
+	if err != nil {
+		return err
+	}
+
+	backends, err := m.appService.FindCurrentlyActiveDeployments(ctx, applicationID, types.InstanceTypeBackend)
+	if err != nil {
+		return err
+	}
+	environments := lo.Uniq(lo.Map(backends, func(item *types.Deployment, index int) string {
+		return item.Environment
+	}))
+
+	for _, env := range environments {
+		m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Adding %s to %s", se, env))
+		// the data kept when the engine was removed was initialised with its variables
+		deployment := &types.Deployment{ApplicationID: app.ID, Environment: env, Application: *app}
+		keys := databaseVarKeys(databasecomponent.NewProvider(se, app.EngineVersion(se, env)), deployment)
+		if err := m.moveVars(ctx, applicationID, env, types.InstanceTypeKeptDatabase, types.InstanceTypeDatabase, keys); err != nil {
+			return errorpkg.Wrap(err, "failed to restore variables of "+se.String())
+		}
+		// the engine stays on the application when this fails, the next deployment provisions it
+		if err := m.deployDatabase(ctx, app, env, se, identifier); err != nil {
+			return errorpkg.Wrap(err, "failed to provision "+se.String()+" in environment: "+env)
+		}
+
+		if err := m.redeployBackends(ctx, applicationID, env, identifier, ""); err != nil {
+			return err
+		}
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete,
+		fmt.Sprintf("Added %s %s to %d environment(s)", se, app.EngineVersion(se, ""), len(environments)))
+	return nil
+}
+
+// RemoveStorageEngine removes a storage engine from an application. its variables are removed and the backends
+// deployed again without them before its containers are removed. the data is deleted unless it is kept,
+// kept data keeps its credentials too
+func (m *manager) RemoveStorageEngine(ctx context.Context, applicationID uuid.UUID, params types.RemoveStorageEngineParams, identifier string) error {
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	if !lo.Contains(app.StorageEngines, params.StorageEngine) {
+		return fmt.Errorf("application has no %s storage engine", params.StorageEngine)
+	}
+	se := params.StorageEngine
+
+	databases, err := m.appService.FindCurrentlyActiveDeployments(ctx, applicationID, types.InstanceTypeDatabase)
+	if err != nil {
+		return err
+	}
+	environments := lo.Uniq(lo.Map(databases, func(item *types.Deployment, index int) string {
+		return item.Environment
+	}))
+
+	// the containers are named after the versions, they must be known before the versions are forgotten
+	providers := make(map[string]databasecomponent.Provider, len(environments))
+	for _, env := range environments {
+		providers[env] = databasecomponent.NewProvider(se, app.EngineVersion(se, env))
+	}
+
+	if _, err := m.appService.RemoveStorageEngine(ctx, applicationID, se, params.KeepData); err != nil {
+		return err
+	}
+
+	for _, env := range environments {
+		deployment := &types.Deployment{ApplicationID: app.ID, Environment: env, Application: *app}
+		external, err := m.appService.ExternalDatabase(ctx, applicationID, env, se)
+		if err != nil {
+			return err
+		}
+		keys := databaseVarKeys(providers[env], deployment)
+		if err := m.removeEngineVars(ctx, applicationID, env, keys, external != nil, params.KeepData); err != nil {
+			return errorpkg.Wrap(err, "failed to remove variables of "+se.String())
+		}
+		if err := m.removeReplicas(ctx, deployment, providers[env], params.KeepData); err != nil {
+			return errorpkg.Wrap(err, "failed to remove read replicas of "+se.String())
+		}
+
+		if err := m.redeployBackends(ctx, applicationID, env, identifier, fmt.Sprintf("Deploying %s without %s", env, se)); err != nil {
+			return err
+		}
+
+		m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Removing %s from %s", se, env))
+		if err := m.removeDatabase(ctx, deployment, providers[env], params.KeepData); err != nil {
+			return err
+		}
+
+		// the external database itself is left as it is
+		if external != nil {
+			if err := m.appService.UnlinkDatabase(ctx, external.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	message := fmt.Sprintf("Removed %s from %d environment(s)", se, len(environments))
+	if params.KeepData {
+		message += ", its data is kept and comes back when it is added again"
+	}
+	m.eventBus.Broadcast(identifier, eventbus.Complete, message)
+	return nil
+}
+
+// removeEngineVars removes the variables of a storage engine removed from an environment. the variables of kept data
+// are moved aside so that they come back with it, a linked engine keeps the ones of the container sarabi ran only when
+// its data is kept
+func (m *manager) removeEngineVars(ctx context.Context, applicationID uuid.UUID, environment string, keys []string, linked, keepData bool) error {
+	switch {
+	case linked && keepData:
+		// the variables of the container sarabi ran were kept when the engine was linked
+		return m.secretService.Delete(ctx, applicationID, environment, types.InstanceTypeDatabase, keys...)
+	case linked:
+		if err := m.secretService.Delete(ctx, applicationID, environment, types.InstanceTypeDatabase, keys...); err != nil {
+			return err
+		}
+		return m.secretService.Delete(ctx, applicationID, environment, types.InstanceTypeKeptDatabase, keys...)
+	case keepData:
+		return m.moveVars(ctx, applicationID, environment, types.InstanceTypeDatabase, types.InstanceTypeKeptDatabase, keys)
+	default:
+		return m.secretService.Delete(ctx, applicationID, environment, types.InstanceTypeDatabase, keys...)
+	}
+}
+
+// moveVars moves the variables of an environment named after one of keys from one instance type to another
+func (m *manager) moveVars(ctx context.Context, applicationID uuid.UUID, environment string, from, to types.InstanceType, keys []string) error {
+	secrets, err := m.secretService.FindAll(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	params := make([]types.CreateSecretParams, 0, len(keys))
+	for _, next := range secrets {
+		if next.Environment == environment && types.InstanceType(next.InstanceType) == from && lo.Contains(keys, next.Name) {
+			params = append(params, types.CreateSecretParams{
+				Key:           next.Name,
+				Value:         next.Value,
+				Environment:   environment,
+				InstanceType:  to,
+				ApplicationID: applicationID,
+			})
+		}
+	}
+	if _, err := m.secretService.CreateAll(ctx, params...); err != nil {
+		return err
+	}
+	return m.secretService.Delete(ctx, applicationID, environment, from, keys...)
+}
+
+func databaseVarKeys(provider databasecomponent.Provider, deployment *types.Deployment) []string {
+	return lo.Map(provider.EnvVars(deployment), func(item types.CreateSecretParams, index int) string {
+		return item.Key
+	})
+}
+
+// removeDatabase removes the container of a storage engine, and its data, configuration and settings
+// unless keepData is set
+func (m *manager) removeDatabase(ctx context.Context, deployment *types.Deployment, provider databasecomponent.Provider, keepData bool) error {
+	_ = m.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{
+		ContainerName: provider.ContainerName(deployment),
+	})
+
+	if keepData {
+		return nil
+	}
+
+	if err := m.dockerClient.RemoveVolume(ctx, deployment.VolumeName(provider.Engine())); err != nil {
+		return errorpkg.Wrap(err, "failed to remove data of "+provider.Engine().String())
+	}
+	if _, ok := provider.(databasecomponent.Archiver); ok {
+		if err := m.dockerClient.RemoveVolume(ctx, deployment.ArchiveVolumeName(provider.Engine())); err != nil {
+			return errorpkg.Wrap(err, "failed to remove archive of "+provider.Engine().String())
+		}
+	}
+
+	if file, err := provider.Setup(types.ResourceAllocation{}, nil); err == nil {
+		if err := os.Remove(deployment.DatabaseConfigPath(file.Name)); err != nil && !os.IsNotExist(err) {
+			logger.Warn("failed to remove database configuration",
+				zap.String("path", deployment.DatabaseConfigPath(file.Name)),
+				zap.Error(err))
+		}
+	}
+
+	return m.appService.UpdateDatabaseConfig(ctx, deployment.ApplicationID, deployment.Environment, provider.Engine(), types.ConfigSettings{})
+}
+
+// redeployBackends deploys the backend and the named services of an environment again, see redeployBackend.
+// message is broadcast first when the environment has any
+func (m *manager) redeployBackends(ctx context.Context, applicationID uuid.UUID, environment, identifier, message string) error {
+	backends, err := m.activeBackends(ctx, applicationID, environment)
+	if err != nil {
+		return err
+	}
+
+	if len(backends) > 0 && message != "" {
+		m.eventBus.Broadcast(identifier, eventbus.Info, message)
+	}
+	for _, next := range backends {
+		if err := m.redeployBackend(ctx, next, identifier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redeployBackend deploys the artifact of a backend again with the current variables of its environment
+func (m *manager) redeployBackend(ctx context.Context, active *types.Deployment, identifier string) error {
+	deployment, err := m.appService.CreateDeployment(ctx, types.CreateDeploymentParams{
+		ApplicationID: active.ApplicationID,
+		Environment:   active.Environment,
+		Instances:     active.Instances,
+		Port:          active.Port,
+		InstanceType:  types.InstanceTypeBackend,
+		Identifier:    identifier,
+		Processes:     active.Processes,
+		Service:       active.Service,
+		Settings:      active.Settings,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := m.store.Copy(ctx, active, deployment); err != nil {
+		return err
+	}
+
+	if err := m.setupAppVariables(ctx, deployment); err != nil {
+		return errorpkg.Wrap(err, "failed to setup app variables")
+	}
+
+	backend := backendcomponent.New(m.dockerClient, m.appService, m.secretService, m.caddyClient, m.eventBus)
+	result, err := backend.Run(ctx, deployment.ID)
+	if err != nil {
+		return errorpkg.Wrap(err, "failed to run backend component")
+	}
+
+	if err := backend.Cleanup(ctx, result); err != nil {
+		logger.Warn("backend cleanup failed: ", zap.Error(err))
+	}
+	return nil
+}
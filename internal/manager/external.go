@@ -0,0 +1,211 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"github.com/samber/lo"
+	"net/url"
+	databasecomponent "sarabi/internal/components/database"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/types"
+	"strings"
+	"time"
+)
+
+// LinkDatabase links a storage engine of an environment to a database run outside sarabi, e.g a managed database.
+// the database must be reachable from the server, its variables replace the ones of the container sarabi ran
+// which is stopped with its data kept. the backends are deployed again so that they get the new variables
+func (m *manager) LinkDatabase(ctx context.Context, applicationID uuid.UUID, params types.LinkDatabaseParams, identifier string) error {
+	if params.Environment == "" {
+		return errors.New("environment is required")
+	}
+
+	se, version, err := types.ParseStorageEngine(params.StorageEngine)
+	if err != nil {
+		return err
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	if version == "" {
+		version = app.EngineVersion(se, params.Environment)
+	}
+	deployment := &types.Deployment{ApplicationID: app.ID, Environment: params.Environment, Application: *app}
+	provider := databasecomponent.NewProvider(se, version)
+	vars, err := provider.ExternalVars(deployment, params.URL)
+	if err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Checking the connection to %s...", se))
+	if err := m.pingExternalDatabase(ctx, provider, vars); err != nil {
+		return errorpkg.Wrap(err, "failed to connect to "+se.String())
+	}
+
+	if !lo.Contains(app.StorageEngines, se) {
+		if app, err = m.appService.AddStorageEngine(ctx, applicationID, se, version); err != nil {
+			return err
+		}
+	}
+
+	previous, err := m.appService.ExternalDatabase(ctx, applicationID, params.Environment, se)
+	if err != nil {
+		return err
+	}
+
+	// the variables of the container sarabi ran are not all replaced, e.g the root password of mysql.
+	// they are kept with its data so that it runs with them again when the engine is unlinked
+	managed := databasecomponent.NewProvider(se, app.EngineVersion(se, params.Environment))
+	keys := secretKeys(managed.EnvVars(deployment))
+	if previous == nil {
+		err = m.moveVars(ctx, applicationID, params.Environment, types.InstanceTypeDatabase, types.InstanceTypeKeptDatabase, keys)
+	} else {
+		err = m.secretService.Delete(ctx, applicationID, params.Environment, types.InstanceTypeDatabase, keys...)
+	}
+	if err != nil {
+		return errorpkg.Wrap(err, "failed to remove variables of "+se.String())
+	}
+	// reads go to the external database, its own replicas are up to its provider
+	if err := m.removeReplicas(ctx, deployment, managed, true); err != nil {
+		return errorpkg.Wrap(err, "failed to remove read replicas of "+se.String())
+	}
+	if _, err := m.secretService.CreateAll(ctx, vars...); err != nil {
+		return errorpkg.Wrap(err, "failed to save variables of "+se.String())
+	}
+
+	u, _ := url.Parse(params.URL)
+	external := &types.ExternalDatabase{
+		ID:            uuid.New(),
+		ApplicationID: applicationID,
+		Environment:   params.Environment,
+		StorageEngine: se,
+		Host:          u.Host,
+		Image:         provider.Image(),
+		CreatedAt:     time.Now(),
+	}
+	if previous != nil {
+		external.ID = previous.ID
+	}
+	if err := m.appService.LinkDatabase(ctx, external); err != nil {
+		return err
+	}
+
+	// the environment has a database from now on, the operations that need one see it
+	if err := m.activateExternalDatabase(ctx, app, params.Environment, identifier); err != nil {
+		return err
+	}
+
+	if err := m.redeployBackends(ctx, applicationID, params.Environment, identifier,
+		fmt.Sprintf("Deploying %s with the linked %s", params.Environment, se)); err != nil {
+		return err
+	}
+
+	if err := m.removeDatabase(ctx, deployment, managed, true); err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete, fmt.Sprintf("Linked %s in %s to %s", se, params.Environment, external.Host))
+	return nil
+}
+
+// UnlinkDatabase forgets the database a storage engine of an environment is linked to, nothing is deleted from it.
+// the container sarabi ran for the engine is provisioned again with the data and variables it had, and the
+// backends are deployed again so that they stop using the external database
+func (m *manager) UnlinkDatabase(ctx context.Context, applicationID uuid.UUID, params types.UnlinkDatabaseParams, identifier string) error {
+	external, err := m.appService.ExternalDatabase(ctx, applicationID, params.Environment, params.StorageEngine)
+	if err != nil {
+		return err
+	}
+	if external == nil {
+		return fmt.Errorf("%s in %s is not linked to an external database", params.StorageEngine, params.Environment)
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	se := params.StorageEngine
+	deployment := &types.Deployment{ApplicationID: app.ID, Environment: params.Environment, Application: *app}
+	provider := databasecomponent.NewProvider(se, app.EngineVersion(se, params.Environment))
+	// the container sarabi ran gets back the variables its data was initialised with
+	keys := secretKeys(provider.EnvVars(deployment))
+	if err := m.secretService.Delete(ctx, applicationID, params.Environment, types.InstanceTypeDatabase, keys...); err != nil {
+		return errorpkg.Wrap(err, "failed to remove variables of "+se.String())
+	}
+	if err := m.moveVars(ctx, applicationID, params.Environment, types.InstanceTypeKeptDatabase, types.InstanceTypeDatabase, keys); err != nil {
+		return errorpkg.Wrap(err, "failed to restore variables of "+se.String())
+	}
+
+	if err := m.appService.UnlinkDatabase(ctx, external.ID); err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Provisioning %s in %s", se, params.Environment))
+	if err := m.deployDatabase(ctx, app, params.Environment, se, identifier); err != nil {
+		return errorpkg.Wrap(err, "failed to provision "+se.String())
+	}
+
+	if err := m.redeployBackends(ctx, applicationID, params.Environment, identifier,
+		fmt.Sprintf("Deploying %s without the external %s", params.Environment, se)); err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete, fmt.Sprintf("Unlinked %s in %s from %s", se, params.Environment, external.Host))
+	return nil
+}
+
+// pingExternalDatabase runs the client of an engine against a database run outside sarabi
+func (m *manager) pingExternalDatabase(ctx context.Context, provider databasecomponent.Provider, vars []types.CreateSecretParams) error {
+	if err := m.dockerClient.PullImage(ctx, provider.Image()); err != nil {
+		return err
+	}
+
+	result, err := m.dockerClient.RunContainer(ctx, docker.StartContainerParams{
+		Image: provider.Image(),
+		Cmd:   provider.PingCommand(),
+		Environments: lo.Map(vars, func(item types.CreateSecretParams, index int) string {
+			return item.Key + "=" + item.Value
+		}),
+	})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("exit status %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// activateExternalDatabase records an active database deployment in an environment with a linked storage engine
+// when it has none, nothing runs for it
+func (m *manager) activateExternalDatabase(ctx context.Context, app *types.Application, environment, identifier string) error {
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, app.ID, types.InstanceTypeDatabase, environment); err == nil {
+		return nil
+	}
+
+	deployment, err := m.appService.CreateDeployment(ctx, types.CreateDeploymentParams{
+		ApplicationID: app.ID,
+		Environment:   environment,
+		InstanceType:  types.InstanceTypeDatabase,
+		Identifier:    identifier,
+		Instances:     1,
+	})
+	if err != nil {
+		return errorpkg.Wrap(err, "failed to schedule database deployment")
+	}
+	return m.appService.UpdateDeploymentStatus(ctx, deployment.ID, types.DeploymentStatusActive)
+}
+
+func secretKeys(params []types.CreateSecretParams) []string {
+	return lo.Map(params, func(item types.CreateSecretParams, index int) string {
+		return item.Key
+	})
+}
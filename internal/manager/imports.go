@@ -0,0 +1,305 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"go.uber.org/zap"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	databasecomponent "sarabi/internal/components/database"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/storage"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// importOutputSize is how much of the output of the tool loading a dump is kept to explain a failure
+	importOutputSize = 4096
+	// importTTL is how long an upload is kept without being written to, it was abandoned by then
+	importTTL           = 24 * time.Hour
+	importSweepSchedule = "0 * * * *"
+)
+
+var importSweepID = uuid.NewSHA1(uuid.Nil, []byte("import-sweep"))
+
+// CreateImport starts the upload of a dump to be imported into a storage engine of the application
+func (m *manager) CreateImport(ctx context.Context, applicationID uuid.UUID) (*types.DatabaseImport, error) {
+	if _, err := m.appService.Get(ctx, applicationID); err != nil {
+		return nil, err
+	}
+
+	dump := &types.DatabaseImport{ID: uuid.New()}
+	path := importPath(applicationID, dump.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	fi, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return dump, fi.Close()
+}
+
+// UploadImportChunk writes the next chunk of a dump at offset. a chunk sent again after a failed
+// request replaces what was received of it the first time
+func (m *manager) UploadImportChunk(ctx context.Context, applicationID, importID uuid.UUID, offset int64, chunk io.Reader) (*types.DatabaseImport, error) {
+	fi, err := os.OpenFile(importPath(applicationID, importID), os.O_WRONLY, 0600)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("import %s not found", importID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	stat, err := fi.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || offset > stat.Size() {
+		return nil, fmt.Errorf("chunk at offset %d is out of order, %d bytes were received", offset, stat.Size())
+	}
+
+	if err := fi.Truncate(offset); err != nil {
+		return nil, err
+	}
+	if _, err := fi.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	n, err := io.Copy(fi, chunk)
+	if err != nil {
+		return nil, err
+	}
+	return &types.DatabaseImport{ID: importID, Size: offset + n}, nil
+}
+
+// RemoveImport deletes the upload of a dump that will not be imported, e.g after its upload failed
+func (m *manager) RemoveImport(ctx context.Context, applicationID, importID uuid.UUID) error {
+	if err := os.Remove(importPath(applicationID, importID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ImportDatabase loads an uploaded dump into a storage engine of an environment with the engine's native tool.
+// the format of the dump is told from its content. when wiping, a backup is taken before the data of the engine is deleted
+func (m *manager) ImportDatabase(ctx context.Context, applicationID uuid.UUID, params types.ImportDatabaseParams, identifier string) error {
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, params.Environment); err != nil {
+		return errorpkg.Wrap(err, "no database running in environment: "+params.Environment)
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	se, err := storageEngineOf(app, params.StorageEngine)
+	if err != nil {
+		return err
+	}
+
+	spec, err := m.databaseSpec(ctx, app, params.Environment, se)
+	if err != nil {
+		return err
+	}
+
+	path := importPath(applicationID, params.ImportID)
+	fi, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("import %s not found", params.ImportID)
+	}
+	if err != nil {
+		return err
+	}
+	// the dump is removed whatever the outcome, it is uploaded again to try another time
+	defer os.Remove(path)
+	defer fi.Close()
+
+	stat, err := fi.Stat()
+	if err != nil {
+		return err
+	}
+
+	progress := &importProgress{
+		reader:     fi,
+		total:      stat.Size(),
+		identifier: identifier,
+		eventBus:   m.eventBus,
+	}
+	dump, format, err := databasecomponent.OpenDump(progress)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := spec.provider.LoadCommand(format, nil, spec.deployment)
+	if err != nil {
+		return err
+	}
+	if format == types.DumpFormatRedisRDB && !params.Wipe {
+		return errors.New("an RDB file replaces all the data of redis, it can only be imported with wipe")
+	}
+
+	containerName := spec.provider.ContainerName(spec.deployment)
+	if params.Wipe {
+		m.eventBus.Broadcast(identifier, eventbus.Info, "Taking a backup before wiping...")
+		if err := m.backupService.SafetyBackup(ctx, applicationID, params.Environment, types.BackupTriggerImport); err != nil {
+			return errorpkg.Wrap(err, "backup before wiping failed")
+		}
+
+		m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Wiping %s in %s", se, params.Environment))
+		_, err := m.dockerClient.ContainerExec(ctx, docker.ContainerExecParams{
+			ContainerName: containerName,
+			Cmd:           spec.provider.WipeCommand(),
+			Envs:          spec.envs,
+		})
+		if err != nil {
+			return errorpkg.Wrap(err, "failed to wipe "+se.String())
+		}
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Loading %s dump into %s", format, se))
+	if err := m.loadDump(ctx, containerName, cmd, spec.envs, dump); err != nil {
+		return errorpkg.Wrap(err, "failed to load dump")
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete, fmt.Sprintf("Imported dump into %s in %s", se, params.Environment))
+	return nil
+}
+
+// loadDump runs cmd in a database container with the dump as its input.
+// the tool is killed when the dump can't be written to it in full, it would commit what it read so far on EOF
+func (m *manager) loadDump(ctx context.Context, containerName string, cmd, envs []string, dump io.Reader) error {
+	// the shell is replaced by the tool, the file holds the pid of the tool in the container
+	pidFile := "/tmp/sarabi-import-" + uuid.NewString() + ".pid"
+	session, err := m.dockerClient.ContainerExecAttach(ctx, docker.ContainerExecParams{
+		ContainerName: containerName,
+		Cmd:           append([]string{"sh", "-c", `echo $$ > "$0" && exec "$@"`, pidFile}, cmd...),
+		Envs:          envs,
+	})
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	defer func() {
+		_, _ = m.dockerClient.ContainerExec(context.Background(), docker.ContainerExecParams{
+			ContainerName: containerName,
+			Cmd:           []string{"rm", "-f", pidFile},
+		})
+	}()
+
+	// the output is read while the dump is written, the tool would stop once its output is full otherwise
+	output := &tailBuffer{size: importOutputSize}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(output, session.Output())
+	}()
+
+	if _, err := io.Copy(session, dump); err != nil {
+		_, killErr := m.dockerClient.ContainerExec(context.Background(), docker.ContainerExecParams{
+			ContainerName: containerName,
+			Cmd:           []string{"sh", "-c", `kill -9 "$(cat "$0")"`, pidFile},
+		})
+		if killErr != nil {
+			return fmt.Errorf("%w: failed to stop the import, part of the dump may be loaded: %s", err, killErr.Error())
+		}
+		return errorpkg.Wrap(err, "the dump was not loaded")
+	}
+
+	copyErr := session.CloseInput()
+	<-done
+
+	code, err := session.ExitCode(ctx)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("exit status %d: %s", code, strings.TrimSpace(output.String()))
+	}
+	return copyErr
+}
+
+// sweepImports removes the uploads that were not written to for importTTL
+func (m *manager) sweepImports(ctx context.Context) {
+	err := filepath.WalkDir(storage.ImportDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if time.Since(info.ModTime()) > importTTL {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			logger.Info("removed abandoned import", zap.String("path", path))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Warn("failed to sweep imports", zap.Error(err))
+	}
+}
+
+func importPath(applicationID, importID uuid.UUID) string {
+	return filepath.Join(storage.ImportDir, applicationID.String(), importID.String())
+}
+
+// importProgress reports how much of a dump was loaded every tenth of it
+type importProgress struct {
+	reader     io.Reader
+	total      int64
+	read       int64
+	reported   int64
+	identifier string
+	eventBus   eventbus.Bus
+}
+
+func (p *importProgress) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.read += int64(n)
+	if p.total > 0 {
+		if percent := p.read * 100 / p.total; percent >= p.reported+10 {
+			p.reported = percent - percent%10
+			p.eventBus.Broadcast(p.identifier, eventbus.Info, fmt.Sprintf("Loaded %d%%", p.reported))
+		}
+	}
+	return n, err
+}
+
+// tailBuffer keeps the last size bytes written to it
+type tailBuffer struct {
+	size int
+	buf  []byte
+	mu   sync.Mutex
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.size {
+		t.buf = t.buf[len(t.buf)-t.size:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
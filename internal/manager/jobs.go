@@ -0,0 +1,289 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	backendcomponent "sarabi/internal/components/backend"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultJobTimeout is how long a run of a job may take when no timeout is given
+	defaultJobTimeout = time.Hour
+	maxJobTimeout     = 24 * time.Hour
+	// jobOutputLimit is how much of the output of a run is recorded, the end of the output is kept
+	jobOutputLimit = 64 << 10
+	// defaultJobHistory is how many runs of a job are listed when no limit is given
+	defaultJobHistory = 20
+)
+
+// jobRun is a run of a job in progress, it is stopped when a new run replaces it
+type jobRun struct {
+	cancel   context.CancelFunc
+	replaced atomic.Bool
+}
+
+// AddJob schedules a job in an environment, a job of the same name is replaced.
+// the runs start from the image of the backend that is active when they are due
+func (m *manager) AddJob(ctx context.Context, applicationID uuid.UUID, params types.AddJobParams) (*types.Job, error) {
+	if err := validateJob(params); err != nil {
+		return nil, err
+	}
+	if _, err := m.appService.Get(ctx, applicationID); err != nil {
+		return nil, err
+	}
+
+	job, err := m.jobService.Job(ctx, applicationID, params.Environment, params.Name)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		job = &types.Job{
+			ID:            uuid.New(),
+			ApplicationID: applicationID,
+			Environment:   params.Environment,
+			Name:          params.Name,
+			CreatedAt:     time.Now(),
+		}
+	}
+
+	job.Schedule = params.Schedule
+	job.Command = params.Command
+	job.Timeout = params.Timeout
+	if job.Timeout == 0 {
+		job.Timeout = int(defaultJobTimeout.Seconds())
+	}
+	job.ConcurrencyPolicy = params.ConcurrencyPolicy
+	if job.ConcurrencyPolicy == "" {
+		job.ConcurrencyPolicy = types.ConcurrencyForbid
+	}
+
+	if err := m.jobService.Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	// the runs outlive the request scheduling the job
+	if err := m.jobService.Schedule(context.Background(), job, m.runJobBG); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RemoveJob stops scheduling a job and deletes its history
+func (m *manager) RemoveJob(ctx context.Context, applicationID uuid.UUID, environment, name string) error {
+	job, err := m.jobService.Job(ctx, applicationID, environment, name)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("%s has no job named %s", environment, name)
+	}
+	if name == cronJobName {
+		return errors.New("the cron job is the cron process of the backend, remove it from .sarabi.yml and deploy")
+	}
+	return m.jobService.Remove(ctx, job)
+}
+
+// ListJobs returns the jobs of an application with the time they are due next, every environment when it is empty
+func (m *manager) ListJobs(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Job, error) {
+	jobs, err := m.jobService.Jobs(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*types.Job, 0, len(jobs))
+	for _, next := range jobs {
+		if environment == "" || next.Environment == environment {
+			result = append(result, next)
+		}
+	}
+	return result, nil
+}
+
+// JobHistory returns the last runs of a job, the latest first
+func (m *manager) JobHistory(ctx context.Context, applicationID uuid.UUID, environment, name string, limit int) ([]*types.JobRun, error) {
+	job, err := m.jobService.Job(ctx, applicationID, environment, name)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("%s has no job named %s", environment, name)
+	}
+
+	if limit <= 0 {
+		limit = defaultJobHistory
+	}
+	return m.jobService.Runs(ctx, job.ID, limit)
+}
+
+// ScheduleJobs schedules the jobs of every application and the tasks of the server, it is called once when the server starts
+func (m *manager) ScheduleJobs(ctx context.Context) error {
+	if err := m.jobService.ScheduleTask(ctx, importSweepID, importSweepSchedule, m.sweepImports); err != nil {
+		return err
+	}
+
+	jobs, err := m.jobService.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, next := range jobs {
+		if err := m.jobService.Schedule(ctx, next, m.runJobBG); err != nil {
+			return err
+		}
+	}
+
+	// backends deployed before their cron process ran as a job get one
+	apps, err := m.appService.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, app := range apps {
+		actives, err := m.appService.FindCurrentlyActiveDeployments(ctx, app.ID, types.InstanceTypeBackend)
+		if err != nil {
+			return err
+		}
+		for _, next := range actives {
+			if _, ok := next.Processes[types.ProcessCron]; !ok || next.Service != "" {
+				continue
+			}
+			if err := m.syncCronProcess(ctx, next); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeJobs removes the jobs of an environment, or of every environment when it is empty
+func (m *manager) removeJobs(ctx context.Context, applicationID uuid.UUID, environment string) error {
+	jobs, err := m.ListJobs(ctx, applicationID, environment)
+	if err != nil {
+		return err
+	}
+
+	for _, next := range jobs {
+		if err := m.jobService.Remove(ctx, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runJobBG returns straight away so that a long run doesn't hold a slot of the scheduler the backups run on
+func (m *manager) runJobBG(ctx context.Context, job *types.Job) {
+	go m.runJob(ctx, job)
+}
+
+func (m *manager) runJob(ctx context.Context, job *types.Job) {
+	run := &types.JobRun{
+		ID:        uuid.New(),
+		JobID:     job.ID,
+		Status:    types.JobStatusRunning,
+		StartedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(job.Timeout)*time.Second)
+	defer cancel()
+
+	current := &jobRun{cancel: cancel}
+	done, ok := m.startJobRun(job, current)
+	if !ok {
+		m.finishJobRun(run, types.JobStatusSkipped, -1, "the previous run has not exited")
+		return
+	}
+	defer done()
+
+	if err := m.jobService.SaveRun(ctx, run); err != nil {
+		logger.Error("failed to save job run", zap.String("job", job.Name), zap.Error(err))
+	}
+
+	dep, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, job.ApplicationID, types.InstanceTypeBackend, job.Environment)
+	if err != nil {
+		m.finishJobRun(run, types.JobStatusFailed, -1, "no backend running in environment: "+job.Environment)
+		return
+	}
+
+	result, err := backendcomponent.RunOnce(ctx, m.dockerClient, m.appService, m.secretService, dep,
+		dep.JobContainerName(job.Name, run.StartedAt), job.Command)
+	switch {
+	case err != nil && current.replaced.Load():
+		m.finishJobRun(run, types.JobStatusReplaced, -1, "stopped by the next run")
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		m.finishJobRun(run, types.JobStatusTimedOut, -1, fmt.Sprintf("stopped after %s", time.Duration(job.Timeout)*time.Second))
+	case err != nil:
+		m.finishJobRun(run, types.JobStatusFailed, -1, err.Error())
+	case result.ExitCode != 0:
+		m.finishJobRun(run, types.JobStatusFailed, result.ExitCode, result.Stdout+result.Stderr)
+	default:
+		m.finishJobRun(run, types.JobStatusSucceeded, result.ExitCode, result.Stdout+result.Stderr)
+	}
+}
+
+// startJobRun applies the concurrency policy of a job to a new run, it reports false when the run is skipped.
+// done is called once the run exits
+func (m *manager) startJobRun(job *types.Job, current *jobRun) (done func(), ok bool) {
+	release := func() { m.jobRuns.CompareAndDelete(job.ID, current) }
+	switch job.ConcurrencyPolicy {
+	case types.ConcurrencyForbid:
+		if _, running := m.jobRuns.LoadOrStore(job.ID, current); running {
+			return nil, false
+		}
+		return release, true
+	case types.ConcurrencyReplace:
+		if previous, running := m.jobRuns.Swap(job.ID, current); running {
+			previous.(*jobRun).replaced.Store(true)
+			previous.(*jobRun).cancel()
+		}
+		return release, true
+	}
+	return func() {}, true
+}
+
+// finishJobRun records the outcome of a run, the context of the run may be done by then
+func (m *manager) finishJobRun(run *types.JobRun, status types.JobStatus, exitCode int64, output string) {
+	if len(output) > jobOutputLimit {
+		output = output[len(output)-jobOutputLimit:]
+	}
+
+	run.Status = status
+	run.ExitCode = exitCode
+	run.Output = output
+	run.FinishedAt = time.Now()
+	if err := m.jobService.SaveRun(context.Background(), run); err != nil {
+		logger.Error("failed to save job run", zap.String("run", run.ID.String()), zap.Error(err))
+	}
+}
+
+func validateJob(params types.AddJobParams) error {
+	if params.Environment == "" {
+		return errors.New("environment is required")
+	}
+	if !nameRegex.MatchString(params.Name) {
+		return fmt.Errorf("invalid job name: %s, it must start with a letter and contain lower case letters, numbers and hyphens", params.Name)
+	}
+	if params.Name == cronJobName {
+		return fmt.Errorf("invalid job name: %s, it is the job of the cron process of the backend", params.Name)
+	}
+	if len(params.Command) == 0 || params.Command[0] == "" {
+		return errors.New("command is required")
+	}
+	if _, err := cronParser.Parse(params.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %q, it must be a cron expression e.g 0 2 * * *", params.Schedule)
+	}
+	if params.Timeout < 0 || time.Duration(params.Timeout)*time.Second > maxJobTimeout {
+		return fmt.Errorf("invalid timeout: %ds, it must be at most %s", params.Timeout, maxJobTimeout)
+	}
+
+	switch params.ConcurrencyPolicy {
+	case "", types.ConcurrencyAllow, types.ConcurrencyForbid, types.ConcurrencyReplace:
+		return nil
+	}
+	return fmt.Errorf("invalid concurrency policy: %s, it must be one of allow, forbid or replace", params.ConcurrencyPolicy)
+}
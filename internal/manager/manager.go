@@ -9,7 +9,7 @@ import (
 	errorpkg "github.com/pkg/errors"
 	"github.com/samber/lo"
 	"go.uber.org/zap"
-	"net"
+	"io"
 	"os"
 	"sarabi/internal/bundler"
 	backendcomponent "sarabi/internal/components/backend"
@@ -29,15 +29,22 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Op string
 
+// ErrDatabaseNetworkAccess is returned for the IPs given access to the databases, they are not reachable from outside the server
+var ErrDatabaseNetworkAccess = errors.New("the databases are only reachable from the server, connect to them with sarabi db connect")
+
 const (
 	defaultBackupInterval    = "*/30 * * * *" // 30 mins
 	OpAdd                 Op = "add"
 	OpRemove              Op = "remove"
+
+	// finalBackupTimeout bounds the backup taken before a destroy, apart from the deadline of the destroy itself
+	finalBackupTimeout = time.Hour
 )
 
 type (
@@ -47,13 +54,14 @@ type (
 		CreateApplication(ctx context.Context, param types.CreateApplicationParams) (*types.Application, error)
 		GetApplication(ctx context.Context, applicationID *uuid.UUID, name *string) (*types.Application, error)
 		Deploy(ctx context.Context, param *types.DeployParams) error
-		Destroy(ctx context.Context, applicationID uuid.UUID, environment string) error
+		Destroy(ctx context.Context, applicationID uuid.UUID, params types.DestroyParams) error
 		UpdateVariables(ctx context.Context, applicationID uuid.UUID, environment string, params ...types.CreateSecretParams) error
 		Rollback(ctx context.Context, identifier string) ([]*types.Deployment, error)
-		Scale(ctx context.Context, applicationID uuid.UUID, environment string, newInstanceCount int) ([]*types.Deployment, error)
+		Scale(ctx context.Context, applicationID uuid.UUID, environment string, process types.ProcessType, newInstanceCount int) ([]*types.Deployment, error)
 		AddDomain(ctx context.Context, applicationID uuid.UUID, params types.AddDomainParams) (*types.Domain, error)
 		RemoveDomain(ctx context.Context, applicationID uuid.UUID, name string) error
 		AddCredentials(ctx context.Context, params types.AddCredentialsParams) (*types.ServerConfigResponse, error)
+		ListStorageDrivers() []storage.Driver
 		DownloadBackup(ctx context.Context, backupID uuid.UUID) (*types.File, error)
 		ListBackups(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Backup, error)
 		ListDeployments(ctx context.Context, applicationID uuid.UUID) ([]types.Deployment, error)
@@ -61,21 +69,59 @@ type (
 		ManageDatabaseNetworkAccess(ctx context.Context, applicationID uuid.UUID, environment, ip string, op Op) error
 		ListVariables(ctx context.Context, applicationID uuid.UUID, environment *string) ([]types.VarResponse, error)
 		CreateBackupSchedule(ctx context.Context, applicationID uuid.UUID, environment string, cronExpression string) error
+		BackupNow(ctx context.Context, applicationID uuid.UUID, environment, identifier string) ([]*types.Backup, error)
+		UpdatePITR(ctx context.Context, applicationID uuid.UUID, params types.UpdatePITRParams) error
+		Restore(ctx context.Context, applicationID uuid.UUID, environment string, target time.Time, identifier string) error
+		UpgradeDatabase(ctx context.Context, applicationID uuid.UUID, params types.UpgradeDatabaseParams, identifier string) error
+		UpdateDatabaseConfig(ctx context.Context, applicationID uuid.UUID, params types.UpdateDatabaseConfigParams) error
+		AddStorageEngine(ctx context.Context, applicationID uuid.UUID, params types.AddStorageEngineParams, identifier string) error
+		RemoveStorageEngine(ctx context.Context, applicationID uuid.UUID, params types.RemoveStorageEngineParams, identifier string) error
+		RotateCredentials(ctx context.Context, applicationID uuid.UUID, params types.RotateCredentialsParams, identifier string) error
+		DatabaseAddress(ctx context.Context, applicationID uuid.UUID, params types.DatabaseTunnelParams) (string, error)
+		DatabaseShell(ctx context.Context, applicationID uuid.UUID, params types.DatabaseShellParams) (*docker.ExecSession, error)
+		CreateImport(ctx context.Context, applicationID uuid.UUID) (*types.DatabaseImport, error)
+		UploadImportChunk(ctx context.Context, applicationID, importID uuid.UUID, offset int64, chunk io.Reader) (*types.DatabaseImport, error)
+		RemoveImport(ctx context.Context, applicationID, importID uuid.UUID) error
+		ImportDatabase(ctx context.Context, applicationID uuid.UUID, params types.ImportDatabaseParams, identifier string) error
+		CloneDatabase(ctx context.Context, applicationID uuid.UUID, params types.CloneDatabaseParams, identifier string) error
+		LinkDatabase(ctx context.Context, applicationID uuid.UUID, params types.LinkDatabaseParams, identifier string) error
+		UnlinkDatabase(ctx context.Context, applicationID uuid.UUID, params types.UnlinkDatabaseParams, identifier string) error
+		ScaleReplicas(ctx context.Context, applicationID uuid.UUID, params types.ScaleReplicasParams, identifier string) error
+		ListReplicas(ctx context.Context, applicationID uuid.UUID, environment string) ([]types.ReplicaStatus, error)
+		WatchReplicas(ctx context.Context)
+		AddJob(ctx context.Context, applicationID uuid.UUID, params types.AddJobParams) (*types.Job, error)
+		RemoveJob(ctx context.Context, applicationID uuid.UUID, environment, name string) error
+		ListJobs(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Job, error)
+		JobHistory(ctx context.Context, applicationID uuid.UUID, environment, name string, limit int) ([]*types.JobRun, error)
+		ScheduleJobs(ctx context.Context) error
+		MigrateDatabases(ctx context.Context)
+		AllowInternalAccess(ctx context.Context, applicationID uuid.UUID, params types.InternalAccessParams) (*types.InternalAccess, error)
+		DenyInternalAccess(ctx context.Context, applicationID uuid.UUID, name string) error
+		InternalAccess(ctx context.Context, applicationID uuid.UUID) (*types.InternalAccessResponse, error)
+		AddAddon(ctx context.Context, applicationID uuid.UUID, params types.AddAddonParams, identifier string) error
+		RemoveAddon(ctx context.Context, applicationID uuid.UUID, params types.RemoveAddonParams, identifier string) error
+		ListAddons(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Addon, error)
+		AddVolume(ctx context.Context, applicationID uuid.UUID, params types.AddVolumeParams, identifier string) error
+		RemoveVolume(ctx context.Context, applicationID uuid.UUID, params types.RemoveVolumeParams, identifier string) error
+		ListVolumes(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Volume, error)
 	}
 )
 
 type manager struct {
-	appService      service.ApplicationService
-	secretService   service.SecretService
-	dockerClient    docker.Docker
-	caddyClient     caddy.Client
-	store           bundler.ArtifactStore
-	domainService   service.DomainService
-	backupService   service.BackupService
-	firewallManager firewall.Manager
-	naRepository    database.NetworkAccessRepository
-	eventBus        eventbus.Bus
-	cfg             config.Config
+	appService         service.ApplicationService
+	secretService      service.SecretService
+	dockerClient       docker.Docker
+	caddyClient        caddy.Client
+	store              bundler.ArtifactStore
+	domainService      service.DomainService
+	backupService      service.BackupService
+	jobService         service.JobService
+	firewallManager    firewall.Manager
+	rotationRepository database.CredentialRotationRepository
+	eventBus           eventbus.Bus
+	cfg                config.Config
+	// jobRuns holds the run in progress of the jobs that allow only one run at a time
+	jobRuns sync.Map
 }
 
 func New(
@@ -86,22 +132,24 @@ func New(
 	st bundler.ArtifactStore,
 	dms service.DomainService,
 	backup service.BackupService,
+	js service.JobService,
 	fm firewall.Manager,
-	naRepository database.NetworkAccessRepository,
+	rotationRepository database.CredentialRotationRepository,
 	eb eventbus.Bus,
 	cfg config.Config) Manager {
 	return &manager{
-		appService:      applicationService,
-		secretService:   secretService,
-		dockerClient:    dockerClient,
-		caddyClient:     caddyClient,
-		store:           st,
-		domainService:   dms,
-		backupService:   backup,
-		firewallManager: fm,
-		naRepository:    naRepository,
-		eventBus:        eb,
-		cfg:             cfg,
+		appService:         applicationService,
+		secretService:      secretService,
+		dockerClient:       dockerClient,
+		caddyClient:        caddyClient,
+		store:              st,
+		domainService:      dms,
+		backupService:      backup,
+		jobService:         js,
+		firewallManager:    fm,
+		rotationRepository: rotationRepository,
+		eventBus:           eb,
+		cfg:                cfg,
 	}
 }
 
@@ -158,37 +206,32 @@ func (m *manager) Deploy(ctx context.Context, param *types.DeployParams) error {
 		return err
 	}
 
-	if param.Backend != nil {
+	if err := validateProcesses(param.Processes); err != nil {
+		return err
+	}
+	if err := validateServices(param.Services); err != nil {
+		return err
+	}
+	if err := m.checkServiceAddons(ctx, app.ID, param.Environment, param.Services); err != nil {
+		return err
+	}
+	if err := validateVisibility(param.Visibility); err != nil {
+		return err
+	}
+
+	if param.Backend != nil || len(param.Services) > 0 {
 		for _, se := range app.StorageEngines {
-			dbPort, err := misc.DefaultPortGenerator.Generate()
-			if err != nil {
+			if err := m.deployDatabase(ctx, app, param.Environment, se, param.Identifier); err != nil {
 				return err
 			}
-
-			dbDeployment, err := m.appService.CreateDeployment(ctx, types.CreateDeploymentParams{
-				ApplicationID: param.ApplicationID,
-				Environment:   param.Environment,
-				InstanceType:  types.InstanceTypeDatabase,
-				Identifier:    param.Identifier,
-				Port:          dbPort,
-				Instances:     1,
-			})
-			if err != nil {
-				return errorpkg.Wrap(err, "failed to schedule database deployment")
-			}
-			dbComponent := databasecomponent.New(m.dockerClient, m.appService,
-				m.secretService, databasecomponent.NewProvider(se), m.caddyClient, m.eventBus)
-			if _, err := dbComponent.Run(ctx, dbDeployment.ID); err != nil {
-				return errorpkg.Wrap(err, "failed to run database component")
-			}
-
-			m.blockDatabaseAccess(app, dbDeployment, dbPort, se)
 		}
 
 		if err := m.backupService.CreateBackupSettings(ctx, param.ApplicationID, param.Environment, defaultBackupInterval, false); err != nil {
 			return errorpkg.Wrap(err, "failed to initialize auto-backup")
 		}
+	}
 
+	if param.Backend != nil {
 		appPort, err := misc.DefaultPortGenerator.Generate()
 		if err != nil {
 			return errorpkg.Wrap(err, "failed to allocate port")
@@ -201,6 +244,8 @@ func (m *manager) Deploy(ctx context.Context, param *types.DeployParams) error {
 			Port:          appPort,
 			InstanceType:  types.InstanceTypeBackend,
 			Identifier:    param.Identifier,
+			Processes:     param.Processes,
+			Settings:      types.ServiceSettings{Visibility: param.Visibility},
 		}
 		backendDeployment, err = m.appService.CreateDeployment(ctx, createBackend)
 		if err != nil {
@@ -216,6 +261,15 @@ func (m *manager) Deploy(ctx context.Context, param *types.DeployParams) error {
 		}
 	}
 
+	serviceDeployments := make([]*types.Deployment, 0, len(param.Services))
+	for _, next := range param.Services {
+		sd, err := m.createService(ctx, param, next)
+		if err != nil {
+			return err
+		}
+		serviceDeployments = append(serviceDeployments, sd)
+	}
+
 	if param.Frontend != nil {
 		createFrontend := types.CreateDeploymentParams{
 			ApplicationID: param.ApplicationID,
@@ -244,7 +298,28 @@ func (m *manager) Deploy(ctx context.Context, param *types.DeployParams) error {
 		if err := backend.Cleanup(ctx, result); err != nil {
 			logger.Warn("cleanup failed: ", zap.Error(err))
 		}
-		beDomains = append(beDomains, m.toURL(backendDeployment.AccessURL(types.InstanceTypeBackend)))
+		if err := m.syncCronProcess(ctx, backendDeployment); err != nil {
+			return errorpkg.Wrap(err, "failed to schedule cron process")
+		}
+		beDomains = append(beDomains, m.accessURL(backendDeployment))
+	}
+
+	serviceDomains := make(map[string][]string, len(serviceDeployments))
+	for _, next := range serviceDeployments {
+		m.eventBus.Broadcast(param.Identifier, eventbus.Info, "Deploying service "+next.Service+"...")
+		backend := backendcomponent.New(m.dockerClient, m.appService, m.secretService, m.caddyClient, m.eventBus)
+		result, err := backend.Run(ctx, next.ID)
+		if err != nil {
+			return errorpkg.Wrap(err, "failed to run service "+next.Service)
+		}
+
+		if err := backend.Cleanup(ctx, result); err != nil {
+			logger.Warn("cleanup failed: ", zap.Error(err))
+		}
+		serviceDomains[next.Service] = append(serviceDomains[next.Service], m.accessURL(next))
+		for _, domain := range next.Settings.Domains {
+			serviceDomains[next.Service] = append(serviceDomains[next.Service], m.toURL(domain))
+		}
 	}
 
 	if frontendDeployment != nil {
@@ -278,6 +353,7 @@ func (m *manager) Deploy(ctx context.Context, param *types.DeployParams) error {
 		AccessURL: types.AccessURL{
 			Frontend: feDomains,
 			Backend:  beDomains,
+			Services: serviceDomains,
 		},
 	}
 	data, _ := json.Marshal(resp)
@@ -285,6 +361,45 @@ func (m *manager) Deploy(ctx context.Context, param *types.DeployParams) error {
 	return nil
 }
 
+func (m *manager) deployDatabase(ctx context.Context, app *types.Application, environment string, se types.StorageEngine, identifier string) error {
+	external, err := m.appService.ExternalDatabase(ctx, app.ID, environment, se)
+	if err != nil {
+		return err
+	}
+	if external != nil {
+		m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Using the external %s at %s", se, external.Host))
+		return m.activateExternalDatabase(ctx, app, environment, identifier)
+	}
+
+	dbPort, err := misc.DefaultPortGenerator.Generate()
+	if err != nil {
+		return err
+	}
+
+	dbDeployment, err := m.appService.CreateDeployment(ctx, types.CreateDeploymentParams{
+		ApplicationID: app.ID,
+		Environment:   environment,
+		InstanceType:  types.InstanceTypeDatabase,
+		Identifier:    identifier,
+		Port:          dbPort,
+		Instances:     1,
+	})
+	if err != nil {
+		return errorpkg.Wrap(err, "failed to schedule database deployment")
+	}
+	dbComponent := databasecomponent.New(m.dockerClient, m.appService,
+		m.secretService, databasecomponent.NewProvider(se, app.EngineVersion(se, environment)), m.caddyClient, m.eventBus)
+	if _, err := dbComponent.Run(ctx, dbDeployment.ID); err != nil {
+		return errorpkg.Wrap(err, "failed to run database component")
+	}
+	if err := m.deployReplicas(ctx, app, environment, se, identifier); err != nil {
+		return errorpkg.Wrap(err, "failed to deploy read replicas")
+	}
+
+	m.blockDatabaseAccess(app, dbDeployment, dbPort, se)
+	return nil
+}
+
 func (m *manager) blockDatabaseAccess(
 	app *types.Application,
 	deployment *types.Deployment,
@@ -311,25 +426,19 @@ func (m *manager) UpdateVariables(ctx context.Context, applicationID uuid.UUID,
 	if err != nil {
 		return err
 	}
-	activeBackendDeployment, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeBackend, environment)
+	actives, err := m.activeBackends(ctx, applicationID, environment)
 	if err != nil {
 		return err
 	}
-
-	newBackendDeployment, err := m.appService.CreateDeployment(ctx, types.CreateDeploymentParams{
-		ApplicationID: applicationID,
-		Environment:   environment,
-		Instances:     activeBackendDeployment.Instances,
-		Port:          activeBackendDeployment.Port,
-		InstanceType:  types.InstanceTypeBackend,
-		Identifier:    identifier,
-	})
-	if err != nil {
-		return err
+	if len(actives) == 0 {
+		return errors.New("no active instance found for " + string(types.InstanceTypeBackend))
 	}
 
-	if err := m.store.Copy(ctx, activeBackendDeployment, newBackendDeployment); err != nil {
-		return err
+	// the variables of the environment are shared by the backend and the named services,
+	// only the ones of the backend have its PORT
+	activeBackendDeployment := actives[0]
+	if backend, ok := lo.Find(actives, func(item *types.Deployment) bool { return item.Service == "" }); ok {
+		activeBackendDeployment = backend
 	}
 
 	oldVars, err := m.secretService.FindDeploymentSecrets(ctx, activeBackendDeployment.ID)
@@ -353,20 +462,41 @@ func (m *manager) UpdateVariables(ctx context.Context, applicationID uuid.UUID,
 	if err != nil {
 		return err
 	}
-
 	logger.Info("merged vars", zap.Any("vars", createdVars))
-	err = m.secretService.CreateDeploymentSecrets(ctx, newBackendDeployment.ID, createdVars)
-	if err != nil {
-		return err
-	}
 
-	backend := backendcomponent.New(m.dockerClient, m.appService, m.secretService, m.caddyClient, m.eventBus)
-	r, err := backend.Run(ctx, newBackendDeployment.ID)
-	if err != nil {
-		return err
-	}
-	if err := backend.Cleanup(ctx, r); err != nil {
-		logger.Warn("backend cleanup error: ", zap.Error(err))
+	for _, active := range actives {
+		newBackendDeployment, err := m.appService.CreateDeployment(ctx, types.CreateDeploymentParams{
+			ApplicationID: applicationID,
+			Environment:   environment,
+			Instances:     active.Instances,
+			Port:          active.Port,
+			InstanceType:  types.InstanceTypeBackend,
+			Identifier:    identifier,
+			Processes:     active.Processes,
+			Service:       active.Service,
+			Settings:      active.Settings,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := m.store.Copy(ctx, active, newBackendDeployment); err != nil {
+			return err
+		}
+
+		err = m.secretService.CreateDeploymentSecrets(ctx, newBackendDeployment.ID, createdVars)
+		if err != nil {
+			return err
+		}
+
+		backend := backendcomponent.New(m.dockerClient, m.appService, m.secretService, m.caddyClient, m.eventBus)
+		r, err := backend.Run(ctx, newBackendDeployment.ID)
+		if err != nil {
+			return err
+		}
+		if err := backend.Cleanup(ctx, r); err != nil {
+			logger.Warn("backend cleanup error: ", zap.Error(err))
+		}
 	}
 
 	return nil
@@ -384,7 +514,6 @@ func (m *manager) Rollback(ctx context.Context, identifier string) ([]*types.Dep
 	}
 
 	result := make([]*types.Deployment, 0)
-	var beDeployment *types.Deployment
 	var feDeployment *types.Deployment
 
 	be := lo.Filter(deployments, func(item *types.Deployment, index int) bool {
@@ -393,14 +522,12 @@ func (m *manager) Rollback(ctx context.Context, identifier string) ([]*types.Dep
 	fe := lo.Filter(deployments, func(item *types.Deployment, index int) bool {
 		return item.InstanceType == types.InstanceTypeFrontend
 	})
-	if len(be) > 0 {
-		beDeployment = be[0]
-	}
 	if len(fe) > 0 {
 		feDeployment = fe[0]
 	}
 
-	if beDeployment != nil {
+	// the backend and the named services deployed together are rolled back together
+	for _, beDeployment := range be {
 		vars, err := m.secretService.FindDeploymentSecrets(ctx, beDeployment.ID)
 		if err != nil {
 			return nil, err
@@ -421,6 +548,9 @@ func (m *manager) Rollback(ctx context.Context, identifier string) ([]*types.Dep
 			Port:          beDeployment.Port,
 			InstanceType:  beDeployment.InstanceType,
 			Identifier:    newIdentifier,
+			Processes:     beDeployment.Processes,
+			Service:       beDeployment.Service,
+			Settings:      beDeployment.Settings,
 		})
 		if err != nil {
 			return nil, err
@@ -449,6 +579,11 @@ func (m *manager) Rollback(ctx context.Context, identifier string) ([]*types.Dep
 		if err := backend.Cleanup(ctx, r); err != nil {
 			logger.Warn("backend cleanup failed: ", zap.Error(err))
 		}
+		if newBeDeployment.Service == "" {
+			if err := m.syncCronProcess(ctx, newBeDeployment); err != nil {
+				return nil, errorpkg.Wrap(err, "failed to schedule cron process")
+			}
+		}
 		result = append(result, newBeDeployment)
 	}
 
@@ -484,14 +619,15 @@ func (m *manager) Rollback(ctx context.Context, identifier string) ([]*types.Dep
 	return result, nil
 }
 
-func (m *manager) Scale(ctx context.Context, applicationID uuid.UUID, environment string, newInstanceCount int) ([]*types.Deployment, error) {
+func (m *manager) Scale(ctx context.Context, applicationID uuid.UUID, environment string, process types.ProcessType, newInstanceCount int) ([]*types.Deployment, error) {
 	deployments, err := m.appService.FindCurrentlyActiveDeployments(ctx, applicationID, types.InstanceTypeBackend)
 	if err != nil {
 		return nil, err
 	}
 
+	// the named services are scaled with their replicas in .sarabi.yml
 	envDeployments := lo.Filter(deployments, func(item *types.Deployment, index int) bool {
-		return item.Environment == environment
+		return item.Environment == environment && item.Service == ""
 	})
 
 	if len(envDeployments) == 0 {
@@ -503,11 +639,16 @@ func (m *manager) Scale(ctx context.Context, applicationID uuid.UUID, environmen
 		return nil, err
 	}
 
-	sort.Slice(deployments, func(i, j int) bool {
-		return deployments[i].CreatedAt.Before(deployments[j].CreatedAt)
+	sort.Slice(envDeployments, func(i, j int) bool {
+		return envDeployments[i].CreatedAt.Before(envDeployments[j].CreatedAt)
 	})
 
-	beDeployment := deployments[0]
+	beDeployment := envDeployments[0]
+	instances, processes, err := scaleProcess(beDeployment, process, newInstanceCount)
+	if err != nil {
+		return nil, err
+	}
+
 	vars, err := m.secretService.FindDeploymentSecrets(ctx, beDeployment.ID)
 	if err != nil {
 		return nil, err
@@ -524,10 +665,11 @@ func (m *manager) Scale(ctx context.Context, applicationID uuid.UUID, environmen
 	newBeDeployment, err := m.appService.CreateDeployment(ctx, types.CreateDeploymentParams{
 		ApplicationID: beDeployment.ApplicationID,
 		Environment:   beDeployment.Environment,
-		Instances:     newInstanceCount,
+		Instances:     instances,
 		Port:          beDeployment.Port,
 		InstanceType:  beDeployment.InstanceType,
 		Identifier:    newIdentifier,
+		Processes:     processes,
 	})
 	if err != nil {
 		return nil, err
@@ -561,6 +703,13 @@ func (m *manager) Scale(ctx context.Context, applicationID uuid.UUID, environmen
 }
 
 func (m *manager) AddDomain(ctx context.Context, applicationID uuid.UUID, params types.AddDomainParams) (*types.Domain, error) {
+	if params.InstanceType == types.InstanceTypeBackend {
+		backend, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, params.InstanceType, params.Environment)
+		if err == nil && backend.Settings.Internal() {
+			return nil, fmt.Errorf("the backend of %s is internal, it has no route for a domain", params.Environment)
+		}
+	}
+
 	domain, err := m.domainService.AddDomain(ctx, applicationID, params)
 	if err != nil {
 		return nil, err
@@ -599,25 +748,28 @@ func (m *manager) RemoveDomain(ctx context.Context, applicationID uuid.UUID, nam
 }
 
 func (m *manager) AddCredentials(ctx context.Context, params types.AddCredentialsParams) (*types.ServerConfigResponse, error) {
-	cred := types.StorageCredentials{
-		Endpoint:    params.Value.Endpoint,
-		AccessKeyID: params.Value.AccessKeyID,
-		SecretKey:   params.Value.SecretKey,
-		Region:      params.Value.Region,
+	storageType := storage.TypeS3
+	if params.Type != "" {
+		storageType = storage.Type(params.Type)
+	}
+
+	driver, err := storage.Lookup(storageType)
+	if err != nil {
+		return nil, err
 	}
 
-	s, err := storage.NewObjectStorage(cred)
+	s, err := driver.Open(params.Value)
 	if err != nil {
-		return nil, errorpkg.Wrap(err, "failed to validate object storage credentials")
+		return nil, errorpkg.Wrap(err, "failed to validate storage credentials")
 	}
 
 	if err := s.Ping(ctx); err != nil {
-		return nil, errorpkg.Wrap(err, "failed to validate object storage credentials")
+		return nil, errorpkg.Wrap(err, "failed to validate storage credentials")
 	}
 
 	addConfigParams := types.CreateServerConfigParams{
 		ApplicationID: params.ApplicationID,
-		Name:          types.ServerConfigObjectStorage,
+		Name:          driver.ConfigName,
 		Provider:      params.Provider,
 		Value:         params.Value,
 	}
@@ -630,6 +782,10 @@ func (m *manager) AddCredentials(ctx context.Context, params types.AddCredential
 	return result, err
 }
 
+func (m *manager) ListStorageDrivers() []storage.Driver {
+	return storage.Drivers()
+}
+
 func (m *manager) DownloadBackup(ctx context.Context, backupID uuid.UUID) (*types.File, error) {
 	return m.backupService.Download(ctx, backupID)
 }
@@ -648,11 +804,20 @@ func (m *manager) ListBackups(ctx context.Context, applicationID uuid.UUID, envi
 	}), nil
 }
 
-func (m *manager) Destroy(ctx context.Context, applicationID uuid.UUID, environment string) error {
+func (m *manager) Destroy(ctx context.Context, applicationID uuid.UUID, params types.DestroyParams) error {
+	// the final backup would be deleted with the others straight after it is taken
+	if params.FinalBackup && params.DeleteBackups {
+		return errors.New("a final backup is deleted with the existing backups, choose either final backup or delete backups")
+	}
+
+	environment := params.Environment
 	logger.Info("destroying application",
 		zap.String("environment", environment),
 		zap.Any("applicationID", applicationID),
-		zap.Bool("destroy_all?", environment == ""))
+		zap.Bool("destroy_all?", environment == ""),
+		zap.Bool("final_backup", params.FinalBackup),
+		zap.Bool("delete_backups", params.DeleteBackups),
+		zap.Bool("keep_volumes", params.KeepVolumes))
 
 	application, err := m.appService.Get(ctx, applicationID)
 	if err != nil {
@@ -677,11 +842,32 @@ func (m *manager) Destroy(ctx context.Context, applicationID uuid.UUID, environm
 	frontendDeployments := m.findDeploymentsByInstanceType(toDestroy, types.InstanceTypeFrontend)
 	dbDeployments := m.findDeploymentsByInstanceType(toDestroy, types.InstanceTypeDatabase)
 
+	uniqueEnvs := make(map[string]bool)
+	var envs []string
+	for _, next := range toDestroy {
+		uniqueEnvs[next.Environment] = true
+	}
+	for k, _ := range uniqueEnvs {
+		envs = append(envs, k)
+	}
+
+	if params.FinalBackup {
+		// only environments with a running database can be backed up
+		for _, next := range dbDeployments {
+			if types.DeploymentStatus(next.Status) != types.DeploymentStatusActive {
+				continue
+			}
+			if err := m.finalBackup(ctx, applicationID, next.Environment); err != nil {
+				return errorpkg.Wrap(err, "destroy aborted")
+			}
+		}
+	}
+
 	for _, next := range backendDeployments {
-		for idx := 0; idx < next.Instances; idx++ {
+		for _, name := range next.ProcessContainerNames() {
 			_ = m.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{
 				RemoveVolumes: true,
-				ContainerName: next.ContainerName(idx),
+				ContainerName: name,
 			})
 		}
 		err = os.Remove(next.BinPath())
@@ -705,6 +891,13 @@ func (m *manager) Destroy(ctx context.Context, applicationID uuid.UUID, environm
 		}
 	}
 
+	if !params.KeepVolumes {
+		// the backend containers are removed by now, nothing uses the volumes anymore
+		if err := m.removeVolumes(ctx, applicationID, environment); err != nil {
+			return err
+		}
+	}
+
 	for _, next := range frontendDeployments {
 		err = os.Remove(next.SiteContentPath())
 		if err != nil {
@@ -723,21 +916,32 @@ func (m *manager) Destroy(ctx context.Context, applicationID uuid.UUID, environm
 		}
 	}
 
-	uniqueEnvs := make(map[string]bool)
-	var envs []string
-	for _, next := range toDestroy {
-		uniqueEnvs[next.Environment] = true
-	}
-	for k, _ := range uniqueEnvs {
-		envs = append(envs, k)
+	for _, env := range envs {
+		if err := m.backupService.RemoveBackupSettings(ctx, applicationID, env); err != nil {
+			return err
+		}
+
+		if params.DeleteBackups {
+			if err := m.backupService.DeleteBackups(ctx, applicationID, env); err != nil {
+				return err
+			}
+		}
 	}
-	// TODO: Delete scheduled backups
+
 	for _, se := range application.StorageEngines {
 		for _, env := range envs {
 			dbContainerName := fmt.Sprintf("%s-%s-%s", se, application.Name, env)
 			_ = m.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{
 				ContainerName: dbContainerName,
 			})
+			deployment := &types.Deployment{ApplicationID: applicationID, Environment: env, Application: *application}
+			provider := databasecomponent.NewProvider(se, application.EngineVersion(se, env))
+			if err := m.removeReplicas(ctx, deployment, provider, false); err != nil {
+				logger.Warn("failed to remove read replicas",
+					zap.String("env", env),
+					zap.String("database", se.String()),
+					zap.Error(err))
+			}
 		}
 	}
 
@@ -751,9 +955,42 @@ func (m *manager) Destroy(ctx context.Context, applicationID uuid.UUID, environm
 			return err
 		}
 	}
+
+	addons, err := m.appService.Addons(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+	for _, next := range addons {
+		if environment != "" && next.Environment != environment {
+			continue
+		}
+		deployment := &types.Deployment{ApplicationID: applicationID, Environment: next.Environment, Application: *application}
+		if err := m.secretService.Delete(ctx, applicationID, next.Environment, types.InstanceTypeAddon, addonVarKeys(next)...); err != nil {
+			return err
+		}
+		if err := m.removeAddon(ctx, deployment, next, false); err != nil {
+			return err
+		}
+	}
+	if err := m.removeJobs(ctx, applicationID, environment); err != nil {
+		return err
+	}
+
+	// the other applications can reach the application while any environment is left
+	if environment == "" {
+		return m.removeInternalAccesses(ctx, applicationID)
+	}
 	return nil
 }
 
+// finalBackup backs up the databases of an environment before it is destroyed. a large database takes longer than
+// the destroy is given, so the backup runs with a deadline of its own
+func (m *manager) finalBackup(ctx context.Context, applicationID uuid.UUID, environment string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalBackupTimeout)
+	defer cancel()
+	return m.backupService.SafetyBackup(ctx, applicationID, environment, types.BackupTriggerDestroy)
+}
+
 func (m *manager) ListDeployments(ctx context.Context, applicationID uuid.UUID) ([]types.Deployment, error) {
 	deployments, err := m.appService.FindDeploymentsByApplication(ctx, applicationID)
 	if err != nil {
@@ -762,154 +999,139 @@ func (m *manager) ListDeployments(ctx context.Context, applicationID uuid.UUID)
 
 	var (
 		result []types.Deployment
+		probes []databaseProbe
 	)
 
 	for _, dep := range deployments {
 		if types.DeploymentStatus(dep.Status) == types.DeploymentStatusActive {
 			switch dep.InstanceType {
 			case types.InstanceTypeBackend:
+				name := dep.Application.Name
+				if dep.Service != "" {
+					name += "-" + dep.Service
+				}
 				for idx := 0; idx < dep.Instances; idx++ {
 					dep.Status, err = m.dockerClient.ContainerStatus(ctx, dep.ContainerName(idx))
-					dep.Name = fmt.Sprintf("%s-%d", dep.Application.Name, idx)
+					dep.Name = fmt.Sprintf("%s-%d", name, idx)
 					result = append(result, *dep)
 				}
+				// the workers and cron are listed with their own replicas
+				process := *dep
+				process.Instances = dep.ProcessReplicas(types.ProcessWorker)
+				for idx := 0; idx < process.Instances; idx++ {
+					process.Status, err = m.dockerClient.ContainerStatus(ctx, dep.ProcessContainerName(types.ProcessWorker, idx))
+					process.Name = fmt.Sprintf("%s-worker-%d", name, idx)
+					result = append(result, process)
+				}
+				if _, ok := dep.Processes[types.ProcessCron]; ok {
+					process.Instances = 0
+					process.Status = cronStatus
+					process.Name = fmt.Sprintf("%s-cron", name)
+					result = append(result, process)
+				}
 			case types.InstanceTypeFrontend:
 				dep.Name = fmt.Sprintf("%s-frontend", dep.Application.Name)
 				result = append(result, *dep)
 			case types.InstanceTypeDatabase:
 				for _, se := range dep.Application.StorageEngines {
-					containerName := databasecomponent.NewProvider(se).
-						ContainerName(dep)
+					external, err := m.appService.ExternalDatabase(ctx, applicationID, dep.Environment, se)
+					if err != nil {
+						return nil, err
+					}
+					if external != nil {
+						dep.Name = se.String()
+						dep.Status = types.ExternalDatabaseStatus
+						dep.Health = ""
+						result = append(result, *dep)
+						continue
+					}
+
+					provider := databasecomponent.NewProvider(se, dep.Application.EngineVersion(se, dep.Environment))
+					containerName := provider.ContainerName(dep)
 					dep.Status, err = m.dockerClient.ContainerStatus(ctx, containerName)
 					dep.Name = se.String()
+					dep.Health = types.DatabaseHealthUnhealthy
+					if dep.Status == "running" {
+						probes = append(probes, databaseProbe{index: len(result), containerName: containerName, provider: provider})
+					}
 					result = append(result, *dep)
+
+					replicas, err := m.replicaStatus(ctx, &dep.Application, dep.Environment, se)
+					if err != nil {
+						logger.Warn("failed to check replicas", zap.String("env", dep.Environment), zap.Error(err))
+					}
+					for idx, next := range replicas {
+						dep.Name = fmt.Sprintf("%s-replica-%d", se, idx+1)
+						dep.Status = next.Status
+						dep.Health = types.DatabaseHealthUnhealthy
+						if replicaStreaming(next) && !replicaLagging(next) {
+							dep.Health = types.DatabaseHealthHealthy
+						}
+						result = append(result, *dep)
+					}
 				}
 			}
 		}
 	}
 
-	return result, nil
-}
-
-func (m *manager) ListApplications(ctx context.Context) ([]*types.Application, error) {
-	return m.appService.List(ctx)
-}
-
-func (m *manager) ManageDatabaseNetworkAccess(ctx context.Context, applicationID uuid.UUID, environment, ip string, op Op) error {
-	if net.ParseIP(ip) == nil {
-		return fmt.Errorf("invalid IP: %s", ip)
-	}
-
-	nas, err := m.naRepository.FindByIP(ctx, ip)
-	if err != nil {
-		return err
-	}
-
-	switch op {
-	case OpAdd:
-		return m.handleIpWhiteList(ctx, applicationID, nas, environment, ip)
-	case OpRemove:
-		return m.handleIpBlacklist(ctx, applicationID, nas, environment, ip)
-	default:
-		return fmt.Errorf("unknown operation: %s", op)
-	}
-}
-
-func (m *manager) handleIpWhiteList(
-	ctx context.Context,
-	applicationID uuid.UUID,
-	nas []*types.NetworkAccess,
-	env,
-	ip string) error {
-	for _, n := range nas {
-		if n.IP == ip && n.Environment == env {
-			return errors.New("IP already whitelisted")
-		}
-	}
+	m.probeDatabases(ctx, result, probes)
 
-	deployments, err := m.appService.FindCurrentlyActiveDeployments(ctx, applicationID, types.InstanceTypeDatabase)
+	// add-ons are not deployed, they are listed with the status of their containers
+	addons, err := m.ListAddons(ctx, applicationID, "")
 	if err != nil {
-		return err
-	}
-
-	dbDep := lo.Filter(deployments, func(item *types.Deployment, index int) bool {
-		return item.Environment == env
-	})
-	if len(dbDep) == 0 {
-		return errors.New("no active database deployment found for this environment")
+		return nil, err
 	}
-
-	for _, d := range dbDep {
-		p, err := strconv.Atoi(d.Port)
-		if err != nil {
-			return fmt.Errorf("invalid port: %s", err)
-		}
-
-		if err := m.firewallManager.WhitelistIP(ip, uint(p)); err != nil {
-			return fmt.Errorf("failed to whitelist IP: %s", err)
-		}
+	for _, next := range addons {
+		result = append(result, types.Deployment{
+			ID:            next.ID,
+			ApplicationID: applicationID,
+			Environment:   next.Environment,
+			Status:        next.Status,
+			Instances:     1,
+			Name:          "addon-" + next.Name,
+			Port:          next.Port,
+			InstanceType:  types.InstanceTypeAddon,
+			CreatedAt:     next.CreatedAt,
+		})
 	}
 
-	record := &types.NetworkAccess{
-		ApplicationID: applicationID,
-		IP:            ip,
-		Port:          "",
-		Environment:   env,
-		CreatedAt:     time.Now(),
-	}
-	err = m.naRepository.Save(ctx, record)
-	if err != nil {
-		return err
-	}
-	return nil
+	return result, nil
 }
 
-func (m *manager) handleIpBlacklist(ctx context.Context,
-	applicationID uuid.UUID,
-	nas []*types.NetworkAccess,
-	env,
-	ip string) error {
-	found := false
-	var na types.NetworkAccess
-	for _, n := range nas {
-		if n.IP == ip && n.Environment == env {
-			found = true
-			na = *n
-		}
-	}
-
-	if !found {
-		return fmt.Errorf("IP is not whitelisted for this application: %s", ip)
-	}
-
-	deployments, err := m.appService.FindCurrentlyActiveDeployments(ctx, applicationID, types.InstanceTypeDatabase)
-	if err != nil {
-		return err
-	}
+// databaseProbe is a readiness probe of a running database listed at index
+type databaseProbe struct {
+	index         int
+	containerName string
+	provider      databasecomponent.Provider
+}
 
-	dbDep := lo.Filter(deployments, func(item *types.Deployment, index int) bool {
-		return item.Environment == env
-	})
-	if len(dbDep) == 0 {
-		return fmt.Errorf("no active database deployment found for this environment: %s", env)
+// probeDatabases marks the listed databases that pass their readiness probe as healthy. the probes run
+// together under one deadline so that listing doesn't slow down with the number of databases
+func (m *manager) probeDatabases(ctx context.Context, result []types.Deployment, probes []databaseProbe) {
+	ctx, cancel := context.WithTimeout(ctx, databasecomponent.ProbeTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, next := range probes {
+		wg.Add(1)
+		go func(probe databaseProbe) {
+			defer wg.Done()
+			if databasecomponent.Probe(ctx, m.dockerClient, probe.containerName, probe.provider) == nil {
+				result[probe.index].Health = types.DatabaseHealthHealthy
+			}
+		}(next)
 	}
+	wg.Wait()
+}
 
-	for _, d := range dbDep {
-		p, err := strconv.Atoi(d.Port)
-		if err != nil {
-			return fmt.Errorf("invalid port: %s", err)
-		}
-
-		if err := m.firewallManager.BlacklistIP(ip, uint(p)); err != nil {
-			return fmt.Errorf("failed to blacklist IP: %s", err)
-		}
+func (m *manager) ListApplications(ctx context.Context) ([]*types.Application, error) {
+	return m.appService.List(ctx)
+}
 
-		err = m.naRepository.Remove(ctx, na.ID)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+// ManageDatabaseNetworkAccess used to open the port of the databases of an environment to an IP. the ports are only
+// bound to the loopback of the host now, the databases are reached through sarabi db connect instead
+func (m *manager) ManageDatabaseNetworkAccess(ctx context.Context, applicationID uuid.UUID, environment, ip string, op Op) error {
+	return ErrDatabaseNetworkAccess
 }
 
 func (m *manager) ListVariables(ctx context.Context, applicationID uuid.UUID, environment *string) ([]types.VarResponse, error) {
@@ -918,9 +1140,12 @@ func (m *manager) ListVariables(ctx context.Context, applicationID uuid.UUID, en
 		return nil, err
 	}
 
-	var filtered = secrets
+	// the variables of removed storage engines are only kept for when they are added again
+	var filtered = lo.Filter(secrets, func(item *types.Secret, index int) bool {
+		return types.InstanceType(item.InstanceType) != types.InstanceTypeKeptDatabase
+	})
 	if environment != nil && *environment != "" {
-		filtered = lo.Filter(secrets, func(item *types.Secret, index int) bool {
+		filtered = lo.Filter(filtered, func(item *types.Secret, index int) bool {
 			return item.Environment == *environment
 		})
 	}
@@ -939,6 +1164,42 @@ func (m *manager) CreateBackupSchedule(ctx context.Context, applicationID uuid.U
 	return m.backupService.CreateBackupSettings(ctx, applicationID, environment, cronExpression, true)
 }
 
+func (m *manager) BackupNow(ctx context.Context, applicationID uuid.UUID, environment, identifier string) ([]*types.Backup, error) {
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, environment); err != nil {
+		return nil, errorpkg.Wrap(err, "nothing to backup in environment: "+environment)
+	}
+	return m.backupService.RunNow(ctx, applicationID, environment, identifier)
+}
+
+func (m *manager) UpdatePITR(ctx context.Context, applicationID uuid.UUID, params types.UpdatePITRParams) error {
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, params.Environment); err != nil {
+		return errorpkg.Wrap(err, "no database running in environment: "+params.Environment)
+	}
+
+	if !params.Enabled {
+		return m.backupService.DisablePITR(ctx, applicationID, params.Environment)
+	}
+	external, err := m.appService.ExternalDatabase(ctx, applicationID, params.Environment, types.StorageEnginePostgres)
+	if err != nil {
+		return err
+	}
+	if external != nil {
+		return errors.New("point in time recovery of an external postgres is managed by its provider")
+	}
+	return m.backupService.EnablePITR(ctx, applicationID, params.Environment, params.BaseBackupCronExpression)
+}
+
+func (m *manager) Restore(ctx context.Context, applicationID uuid.UUID, environment string, target time.Time, identifier string) error {
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, environment); err != nil {
+		return errorpkg.Wrap(err, "no database running in environment: "+environment)
+	}
+	if err := m.backupService.Restore(ctx, applicationID, environment, target, identifier); err != nil {
+		return err
+	}
+	// the replicas hold the data from before the restore
+	return m.rebuildReplicas(ctx, applicationID, environment, types.StorageEnginePostgres, identifier)
+}
+
 func (m *manager) mergeSecrets(oldVars []*types.Secret, newVars []types.CreateSecretParams) []types.CreateSecretParams {
 	var mergedSecrets = append([]types.CreateSecretParams{}, newVars...)
 	for _, nextOldVar := range oldVars {
@@ -962,16 +1223,22 @@ func (m *manager) mergeSecrets(oldVars []*types.Secret, newVars []types.CreateSe
 	return mergedSecrets
 }
 
+// setupAppVariables links the variables of the environment to a deployment. the PORT of the environment is
+// the port of the backend, a named service gets its own when its containers start
 func (m *manager) setupAppVariables(ctx context.Context, deployment *types.Deployment) error {
-	secret, err := m.secretService.Create(ctx, types.CreateSecretParams{
-		Key:           "PORT",
-		Value:         deployment.Port,
-		Environment:   deployment.Environment,
-		InstanceType:  deployment.InstanceType,
-		ApplicationID: deployment.ApplicationID,
-	})
-	if err != nil {
-		return err
+	var secret *types.Secret
+	if deployment.Service == "" {
+		var err error
+		secret, err = m.secretService.Create(ctx, types.CreateSecretParams{
+			Key:           "PORT",
+			Value:         deployment.Port,
+			Environment:   deployment.Environment,
+			InstanceType:  deployment.InstanceType,
+			ApplicationID: deployment.ApplicationID,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	appSecrets, err := m.secretService.FindAll(ctx, deployment.ApplicationID)
@@ -980,12 +1247,23 @@ func (m *manager) setupAppVariables(ctx context.Context, deployment *types.Deplo
 	}
 
 	deploymentSecrets := lo.Filter(appSecrets, func(item *types.Secret, index int) bool {
-		return item.Environment == deployment.Environment
+		return item.Environment == deployment.Environment &&
+			types.InstanceType(item.InstanceType) != types.InstanceTypeKeptDatabase
 	})
-	deploymentSecrets = append(deploymentSecrets, secret)
+	if secret != nil {
+		deploymentSecrets = append(deploymentSecrets, secret)
+	}
 	return m.secretService.CreateDeploymentSecrets(ctx, deployment.ID, deploymentSecrets)
 }
 
+// accessURL is the address a backend is reached at, its internal address when it is internal
+func (m *manager) accessURL(deployment *types.Deployment) string {
+	if deployment.Settings.Internal() {
+		return deployment.InternalAddress()
+	}
+	return m.toURL(deployment.ServiceAccessURL())
+}
+
 func (m *manager) toURL(s string) string {
 	if strings.HasPrefix(s, "https") {
 		return s
@@ -1 +1,613 @@
 package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	databasecomponent "sarabi/internal/components/database"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/service"
+	"sarabi/internal/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAddon(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      types.AddAddonParams
+		expectedErr string
+	}{
+		{
+			name: "valid add-on",
+			params: types.AddAddonParams{Environment: "prod", Name: "meilisearch", Image: "getmeili/meilisearch:v1.8",
+				Port: "7700", Volume: "/meili_data", Secrets: []string{"MEILI_MASTER_KEY"}, Vars: types.ConfigSettings{"MEILI_ENV": "production"}},
+		},
+		{
+			name:        "name with upper case letters",
+			params:      types.AddAddonParams{Environment: "prod", Name: "Meili", Image: "getmeili/meilisearch:v1.8", Port: "7700"},
+			expectedErr: "invalid add-on name: Meili, it must start with a letter and contain lower case letters, numbers and hyphens",
+		},
+		{
+			name:        "name of an instance type",
+			params:      types.AddAddonParams{Environment: "prod", Name: "backend", Image: "getmeili/meilisearch:v1.8", Port: "7700"},
+			expectedErr: "backend is reserved, choose another name for the add-on",
+		},
+		{
+			name:        "port out of range",
+			params:      types.AddAddonParams{Environment: "prod", Name: "meilisearch", Image: "getmeili/meilisearch:v1.8", Port: "70000"},
+			expectedErr: "invalid port: 70000",
+		},
+		{
+			name: "relative volume",
+			params: types.AddAddonParams{Environment: "prod", Name: "meilisearch", Image: "getmeili/meilisearch:v1.8",
+				Port: "7700", Volume: "meili_data"},
+			expectedErr: "invalid volume: meili_data, it must be an absolute path in the container",
+		},
+		{
+			name: "secret named like the address variables",
+			params: types.AddAddonParams{Environment: "prod", Name: "meilisearch", Image: "getmeili/meilisearch:v1.8",
+				Port: "7700", Secrets: []string{"MEILISEARCH_URL"}},
+			expectedErr: "MEILISEARCH_URL is set by sarabi, choose another name for the secret",
+		},
+		{
+			name: "variable named like a secret",
+			params: types.AddAddonParams{Environment: "prod", Name: "meilisearch", Image: "getmeili/meilisearch:v1.8",
+				Port: "7700", Secrets: []string{"MEILI_MASTER_KEY"}, Vars: types.ConfigSettings{"MEILI_MASTER_KEY": "key"}},
+			expectedErr: "MEILI_MASTER_KEY is a generated secret, it can not be a variable of the add-on too",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateAddon(test.params)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestAddonVars(t *testing.T) {
+	dep := &types.Deployment{ApplicationID: uuid.New(), Environment: "prod"}
+	addon := &types.Addon{Name: "meili-search", Port: "7700", Secrets: types.AddonSecrets{"MEILI_MASTER_KEY", "MEILI_API_KEY"}}
+	current := []*types.Secret{
+		{Name: "MEILI_MASTER_KEY", Value: "kept", Environment: "prod", InstanceType: string(types.InstanceTypeAddon)},
+		// a secret of the same name in another environment or of the database is not the one of the add-on
+		{Name: "MEILI_API_KEY", Value: "staging", Environment: "staging", InstanceType: string(types.InstanceTypeAddon)},
+		{Name: "MEILI_API_KEY", Value: "database", Environment: "prod", InstanceType: string(types.InstanceTypeDatabase)},
+	}
+
+	vars, err := addonVars(dep, addon, current)
+	assert.NoError(t, err)
+
+	values := make(map[string]string, len(vars))
+	for _, next := range vars {
+		assert.Equal(t, types.InstanceTypeAddon, next.InstanceType)
+		assert.Equal(t, "prod", next.Environment)
+		values[next.Key] = next.Value
+	}
+	assert.Equal(t, "meili-search", values["MEILI_SEARCH_HOST"])
+	assert.Equal(t, "7700", values["MEILI_SEARCH_PORT"])
+	assert.Equal(t, "http://meili-search:7700", values["MEILI_SEARCH_URL"])
+	assert.Equal(t, "kept", values["MEILI_MASTER_KEY"])
+	assert.Len(t, values["MEILI_API_KEY"], 32)
+
+	// adding the add-on again keeps every secret it has by then
+	current = append(current, &types.Secret{
+		Name: "MEILI_API_KEY", Value: values["MEILI_API_KEY"], Environment: "prod", InstanceType: string(types.InstanceTypeAddon),
+	})
+	again, err := addonVars(dep, addon, current)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, vars, again)
+}
+
+func TestValidateVolume(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      types.AddVolumeParams
+		expectedErr string
+	}{
+		{
+			name:   "valid volume",
+			params: types.AddVolumeParams{Environment: "prod", Name: "uploads", Path: "/app/uploads"},
+		},
+		{
+			name:        "name with a slash",
+			params:      types.AddVolumeParams{Environment: "prod", Name: "app/uploads", Path: "/app/uploads"},
+			expectedErr: "invalid volume name: app/uploads, it must start with a letter and contain lower case letters, numbers and hyphens",
+		},
+		{
+			name:        "relative path",
+			params:      types.AddVolumeParams{Environment: "prod", Name: "uploads", Path: "uploads"},
+			expectedErr: "invalid path: uploads, it must be an absolute path in the container other than /",
+		},
+		{
+			name:        "root path",
+			params:      types.AddVolumeParams{Environment: "prod", Name: "uploads", Path: "/./"},
+			expectedErr: "invalid path: /./, it must be an absolute path in the container other than /",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateVolume(test.params)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestValidateProcesses(t *testing.T) {
+	tests := []struct {
+		name        string
+		processes   types.Processes
+		expectedErr string
+	}{
+		{
+			name: "valid processes",
+			processes: types.Processes{
+				types.ProcessWeb:    {Command: "./bin/server"},
+				types.ProcessWorker: {Command: "./bin/worker", Replicas: 2},
+				types.ProcessCron:   {Command: "./bin/cleanup", Schedule: "0 * * * *"},
+			},
+		},
+		{
+			name:        "unknown process type",
+			processes:   types.Processes{"release": {Command: "./bin/migrate"}},
+			expectedErr: "unknown process type: release, it must be one of web, worker or cron",
+		},
+		{
+			name:        "web replicas",
+			processes:   types.Processes{types.ProcessWeb: {Replicas: 2}},
+			expectedErr: "the replicas of web are the instances of the deployment",
+		},
+		{
+			name:        "worker without a command",
+			processes:   types.Processes{types.ProcessWorker: {Replicas: 1}},
+			expectedErr: "worker needs a command",
+		},
+		{
+			name:        "worker with a schedule",
+			processes:   types.Processes{types.ProcessWorker: {Command: "./bin/worker", Schedule: "0 * * * *"}},
+			expectedErr: "worker runs all the time, only cron has a schedule",
+		},
+		{
+			name:        "cron with an invalid schedule",
+			processes:   types.Processes{types.ProcessCron: {Command: "./bin/cleanup", Schedule: "hourly"}},
+			expectedErr: `invalid schedule of cron: "hourly", it must be a cron expression e.g */5 * * * *`,
+		},
+		{
+			name:        "cron with replicas",
+			processes:   types.Processes{types.ProcessCron: {Command: "./bin/cleanup", Schedule: "0 * * * *", Replicas: 2}},
+			expectedErr: "cron runs one container every time its schedule is due, it has no replicas",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateProcesses(test.processes)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestScaleProcess(t *testing.T) {
+	dep := &types.Deployment{
+		Environment: "prod",
+		Instances:   2,
+		Processes: types.Processes{
+			types.ProcessWorker: {Command: "./bin/worker", Replicas: 1},
+		},
+	}
+
+	instances, processes, err := scaleProcess(dep, types.ProcessWorker, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, instances)
+	assert.Equal(t, 4, processes[types.ProcessWorker].Replicas)
+	// the active deployment keeps its replicas
+	assert.Equal(t, 1, dep.Processes[types.ProcessWorker].Replicas)
+
+	instances, processes, err = scaleProcess(dep, types.ProcessWeb, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, instances)
+	assert.Equal(t, 1, processes[types.ProcessWorker].Replicas)
+
+	_, _, err = scaleProcess(dep, types.ProcessCron, 1)
+	assert.EqualError(t, err, "cron runs one container every time its schedule is due, it can not be scaled")
+
+	_, _, err = scaleProcess(&types.Deployment{Environment: "prod", Instances: 1}, types.ProcessWorker, 1)
+	assert.EqualError(t, err, "the backend of prod has no worker process, add it to .sarabi.yml and deploy")
+}
+
+func TestValidateJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      types.AddJobParams
+		expectedErr string
+	}{
+		{
+			name:   "valid job",
+			params: types.AddJobParams{Environment: "prod", Name: "nightly-report", Schedule: "0 2 * * *", Command: []string{"./bin/report", "--daily"}},
+		},
+		{
+			name: "valid job with a policy and a timeout",
+			params: types.AddJobParams{Environment: "prod", Name: "nightly-report", Schedule: "0 2 * * *", Command: []string{"./bin/report"},
+				ConcurrencyPolicy: types.ConcurrencyReplace, Timeout: 300},
+		},
+		{
+			name:        "name with a space",
+			params:      types.AddJobParams{Environment: "prod", Name: "nightly report", Schedule: "0 2 * * *", Command: []string{"./bin/report"}},
+			expectedErr: "invalid job name: nightly report, it must start with a letter and contain lower case letters, numbers and hyphens",
+		},
+		{
+			name:        "no command",
+			params:      types.AddJobParams{Environment: "prod", Name: "nightly-report", Schedule: "0 2 * * *"},
+			expectedErr: "command is required",
+		},
+		{
+			name:        "invalid schedule",
+			params:      types.AddJobParams{Environment: "prod", Name: "nightly-report", Schedule: "0 2 * *", Command: []string{"./bin/report"}},
+			expectedErr: `invalid schedule: "0 2 * *", it must be a cron expression e.g 0 2 * * *`,
+		},
+		{
+			name: "timeout longer than a day",
+			params: types.AddJobParams{Environment: "prod", Name: "nightly-report", Schedule: "0 2 * * *", Command: []string{"./bin/report"},
+				Timeout: 90000},
+			expectedErr: "invalid timeout: 90000s, it must be at most 24h0m0s",
+		},
+		{
+			name: "unknown concurrency policy",
+			params: types.AddJobParams{Environment: "prod", Name: "nightly-report", Schedule: "0 2 * * *", Command: []string{"./bin/report"},
+				ConcurrencyPolicy: "queue"},
+			expectedErr: "invalid concurrency policy: queue, it must be one of allow, forbid or replace",
+		},
+		{
+			name:        "name of the cron process",
+			params:      types.AddJobParams{Environment: "prod", Name: "cron", Schedule: "0 2 * * *", Command: []string{"./bin/report"}},
+			expectedErr: "invalid job name: cron, it is the job of the cron process of the backend",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateJob(test.params)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestStartJobRun(t *testing.T) {
+	m := &manager{}
+	cancelled := make(map[string]bool)
+	newRun := func(name string) *jobRun {
+		return &jobRun{cancel: func() { cancelled[name] = true }}
+	}
+
+	// forbid skips a run while the previous one runs
+	forbid := &types.Job{ID: uuid.New(), ConcurrencyPolicy: types.ConcurrencyForbid}
+	done, ok := m.startJobRun(forbid, newRun("forbid-1"))
+	assert.True(t, ok)
+	_, ok = m.startJobRun(forbid, newRun("forbid-2"))
+	assert.False(t, ok)
+	done()
+	_, ok = m.startJobRun(forbid, newRun("forbid-3"))
+	assert.True(t, ok)
+
+	// replace stops the previous run, the replaced run exiting doesn't release the run that replaced it
+	replace := &types.Job{ID: uuid.New(), ConcurrencyPolicy: types.ConcurrencyReplace}
+	first := newRun("replace-1")
+	firstDone, ok := m.startJobRun(replace, first)
+	assert.True(t, ok)
+	_, ok = m.startJobRun(replace, newRun("replace-2"))
+	assert.True(t, ok)
+	assert.True(t, cancelled["replace-1"])
+	assert.True(t, first.replaced.Load())
+	assert.False(t, cancelled["replace-2"])
+	firstDone()
+	_, ok = m.startJobRun(replace, newRun("replace-3"))
+	assert.True(t, ok)
+	assert.True(t, cancelled["replace-2"])
+
+	// allow runs next to the previous run
+	allow := &types.Job{ID: uuid.New(), ConcurrencyPolicy: types.ConcurrencyAllow}
+	allowed := newRun("allow-1")
+	_, ok = m.startJobRun(allow, allowed)
+	assert.True(t, ok)
+	_, ok = m.startJobRun(allow, newRun("allow-2"))
+	assert.True(t, ok)
+	assert.False(t, cancelled["allow-1"])
+	assert.False(t, allowed.replaced.Load())
+}
+
+func TestValidateServices(t *testing.T) {
+	artifact := strings.NewReader("artifact")
+
+	tests := []struct {
+		name        string
+		services    []types.ServiceParams
+		expectedErr string
+	}{
+		{
+			name: "valid service",
+			services: []types.ServiceParams{{Name: "api", Port: "8080", Replicas: 2, Artifact: artifact,
+				ServiceSettings: types.ServiceSettings{Dockerfile: "docker/Dockerfile.api", Domains: []string{"api.example.com"},
+					Variables: map[string]string{"LOG_LEVEL": "debug"}}}},
+		},
+		{
+			name:     "valid service with an allocated port",
+			services: []types.ServiceParams{{Name: "api", Replicas: 1, Artifact: artifact}},
+		},
+		{
+			name:        "reserved name",
+			services:    []types.ServiceParams{{Name: "backend", Port: "8080", Replicas: 1, Artifact: artifact}},
+			expectedErr: "invalid service name: backend, it is reserved for the backend of the application",
+		},
+		{
+			name:        "name of the proxy",
+			services:    []types.ServiceParams{{Name: "proxy", Port: "8080", Replicas: 1, Artifact: artifact}},
+			expectedErr: "invalid service name: proxy, it is reserved for the proxy of the application",
+		},
+		{
+			name:        "name with an underscore",
+			services:    []types.ServiceParams{{Name: "admin_api", Port: "8080", Replicas: 1, Artifact: artifact}},
+			expectedErr: "invalid service name: admin_api, it must start with a letter and contain lower case letters, numbers and hyphens",
+		},
+		{
+			name: "deployed twice",
+			services: []types.ServiceParams{
+				{Name: "api", Port: "8080", Replicas: 1, Artifact: artifact},
+				{Name: "api", Port: "8081", Replicas: 1, Artifact: artifact},
+			},
+			expectedErr: "service api is deployed twice",
+		},
+		{
+			name:        "no upload",
+			services:    []types.ServiceParams{{Name: "api", Port: "8080", Replicas: 1}},
+			expectedErr: "service api has no upload",
+		},
+		{
+			name:        "no replicas",
+			services:    []types.ServiceParams{{Name: "api", Port: "8080", Artifact: artifact}},
+			expectedErr: "invalid replicas of api: 0, it needs at least one replica",
+		},
+		{
+			name:        "port out of range",
+			services:    []types.ServiceParams{{Name: "api", Port: "70000", Replicas: 1, Artifact: artifact}},
+			expectedErr: "invalid port of api: 70000",
+		},
+		{
+			name: "dockerfile outside of the service",
+			services: []types.ServiceParams{{Name: "api", Port: "8080", Replicas: 1, Artifact: artifact,
+				ServiceSettings: types.ServiceSettings{Dockerfile: "../Dockerfile"}}},
+			expectedErr: "invalid dockerfile of api: ../Dockerfile, it must be a path in the directory of the service",
+		},
+		{
+			name: "PORT variable",
+			services: []types.ServiceParams{{Name: "api", Port: "8080", Replicas: 1, Artifact: artifact,
+				ServiceSettings: types.ServiceSettings{Variables: map[string]string{"PORT": "9000"}}}},
+			expectedErr: "PORT is set to the port of the service, set port in .sarabi.yml instead",
+		},
+		{
+			name: "internal service",
+			services: []types.ServiceParams{{Name: "api", Port: "8080", Replicas: 1, Artifact: artifact,
+				ServiceSettings: types.ServiceSettings{Visibility: types.VisibilityInternal}}},
+		},
+		{
+			name: "internal service with domains",
+			services: []types.ServiceParams{{Name: "api", Port: "8080", Replicas: 1, Artifact: artifact,
+				ServiceSettings: types.ServiceSettings{Visibility: types.VisibilityInternal, Domains: []string{"api.example.com"}}}},
+			expectedErr: "service api is internal, it has no route for its domains",
+		},
+		{
+			name: "unknown visibility",
+			services: []types.ServiceParams{{Name: "api", Port: "8080", Replicas: 1, Artifact: artifact,
+				ServiceSettings: types.ServiceSettings{Visibility: "private"}}},
+			expectedErr: "service api: invalid visibility: private, it must be one of public or internal",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateServices(test.services)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestServiceAccessURL(t *testing.T) {
+	app := types.Application{Name: "shop", Domain: "example.com"}
+	m := &manager{}
+
+	backend := &types.Deployment{Environment: "prod", Application: app, Port: "3000"}
+	assert.Equal(t, "https://backend-prod.example.com", m.accessURL(backend))
+
+	api := &types.Deployment{Environment: "prod", Application: app, Service: "api", Port: "8080"}
+	assert.Equal(t, "https://api-prod.example.com", m.accessURL(api))
+
+	// an internal service is only reached on the network of the application
+	api.Settings.Visibility = types.VisibilityInternal
+	assert.Equal(t, "api.prod.shop.internal:8080", m.accessURL(api))
+}
+
+// probedDocker answers the readiness probes of containers after a delay, a container it knows nothing of never answers
+type probedDocker struct {
+	docker.Docker
+	delays map[string]time.Duration
+	errs   map[string]error
+}
+
+func (d probedDocker) ContainerExec(ctx context.Context, params docker.ContainerExecParams) (io.Reader, error) {
+	delay, ok := d.delays[params.ContainerName]
+	if !ok {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(delay):
+	}
+	return strings.NewReader(""), d.errs[params.ContainerName]
+}
+
+func TestProbeDatabases(t *testing.T) {
+	m := &manager{dockerClient: probedDocker{
+		delays: map[string]time.Duration{"postgres": 200 * time.Millisecond, "redis": 200 * time.Millisecond, "mysql": 0},
+		errs:   map[string]error{"mysql": errors.New("exit code 1")},
+	}}
+	provider := databasecomponent.NewProvider(types.StorageEnginePostgres, "")
+	result := []types.Deployment{
+		{Name: "postgres", Health: types.DatabaseHealthUnhealthy},
+		{Name: "redis", Health: types.DatabaseHealthUnhealthy},
+		{Name: "mysql", Health: types.DatabaseHealthUnhealthy},
+		{Name: "mongo", Health: types.DatabaseHealthUnhealthy},
+		{Name: "stopped", Health: types.DatabaseHealthUnhealthy},
+	}
+	probes := []databaseProbe{
+		{index: 0, containerName: "postgres", provider: provider},
+		{index: 1, containerName: "redis", provider: provider},
+		{index: 2, containerName: "mysql", provider: provider},
+		{index: 3, containerName: "mongo", provider: provider},
+	}
+
+	// one after the other, the second slow probe would miss the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	m.probeDatabases(ctx, result, probes)
+
+	// the probe that never answers only holds the listing until the deadline
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, types.DatabaseHealthHealthy, result[0].Health)
+	assert.Equal(t, types.DatabaseHealthHealthy, result[1].Health)
+	assert.Equal(t, types.DatabaseHealthUnhealthy, result[2].Health)
+	assert.Equal(t, types.DatabaseHealthUnhealthy, result[3].Health)
+	assert.Equal(t, types.DatabaseHealthUnhealthy, result[4].Health)
+}
+
+// memorySecrets keeps the variables of applications in memory, a variable is replaced by one of the same name,
+// environment and instance type
+type memorySecrets struct {
+	service.SecretService
+	secrets []*types.Secret
+}
+
+func (s *memorySecrets) FindAll(_ context.Context, applicationID uuid.UUID) ([]*types.Secret, error) {
+	return lo.Filter(s.secrets, func(item *types.Secret, index int) bool {
+		return item.ApplicationID == applicationID
+	}), nil
+}
+
+func (s *memorySecrets) CreateAll(_ context.Context, params ...types.CreateSecretParams) ([]*types.Secret, error) {
+	result := make([]*types.Secret, 0, len(params))
+	for _, next := range params {
+		s.secrets = lo.Reject(s.secrets, func(item *types.Secret, index int) bool {
+			return item.ApplicationID == next.ApplicationID && item.Environment == next.Environment &&
+				item.InstanceType == string(next.InstanceType) && item.Name == next.Key
+		})
+		secret := &types.Secret{ApplicationID: next.ApplicationID, Name: next.Key, Value: next.Value,
+			Environment: next.Environment, InstanceType: string(next.InstanceType)}
+		s.secrets = append(s.secrets, secret)
+		result = append(result, secret)
+	}
+	return result, nil
+}
+
+func (s *memorySecrets) Delete(_ context.Context, applicationID uuid.UUID, environment string, instanceType types.InstanceType, keys ...string) error {
+	s.secrets = lo.Reject(s.secrets, func(item *types.Secret, index int) bool {
+		return item.ApplicationID == applicationID && item.Environment == environment &&
+			item.InstanceType == string(instanceType) && lo.Contains(keys, item.Name)
+	})
+	return nil
+}
+
+// vars lists the variables of an application as environment/instance type/name=value
+func (s *memorySecrets) vars() []string {
+	return lo.Map(s.secrets, func(item *types.Secret, index int) string {
+		return fmt.Sprintf("%s/%s/%s=%s", item.Environment, item.InstanceType, item.Name, item.Value)
+	})
+}
+
+func TestRemoveEngineVars(t *testing.T) {
+	applicationID := uuid.New()
+	keys := []string{"POSTGRES_PASSWORD", "DATABASE_URL"}
+	secrets := func() *memorySecrets {
+		s := &memorySecrets{}
+		_, _ = s.CreateAll(context.Background(),
+			types.CreateSecretParams{Key: "POSTGRES_PASSWORD", Value: "current", Environment: "prod", InstanceType: types.InstanceTypeDatabase, ApplicationID: applicationID},
+			types.CreateSecretParams{Key: "DATABASE_URL", Value: "url", Environment: "prod", InstanceType: types.InstanceTypeDatabase, ApplicationID: applicationID},
+			types.CreateSecretParams{Key: "POSTGRES_PASSWORD", Value: "container", Environment: "prod", InstanceType: types.InstanceTypeKeptDatabase, ApplicationID: applicationID},
+			types.CreateSecretParams{Key: "API_KEY", Value: "key", Environment: "prod", InstanceType: types.InstanceTypeBackend, ApplicationID: applicationID},
+			types.CreateSecretParams{Key: "POSTGRES_PASSWORD", Value: "staging", Environment: "staging", InstanceType: types.InstanceTypeDatabase, ApplicationID: applicationID})
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		linked   bool
+		keepData bool
+		expected []string
+	}{
+		{
+			name:     "data removed",
+			expected: []string{"prod/kept-database/POSTGRES_PASSWORD=container", "prod/backend/API_KEY=key", "staging/database/POSTGRES_PASSWORD=staging"},
+		},
+		{
+			name:     "data kept",
+			keepData: true,
+			expected: []string{"prod/backend/API_KEY=key", "staging/database/POSTGRES_PASSWORD=staging", "prod/kept-database/POSTGRES_PASSWORD=current", "prod/kept-database/DATABASE_URL=url"},
+		},
+		{
+			name:     "linked with the data of the container kept",
+			linked:   true,
+			keepData: true,
+			expected: []string{"prod/kept-database/POSTGRES_PASSWORD=container", "prod/backend/API_KEY=key", "staging/database/POSTGRES_PASSWORD=staging"},
+		},
+		{
+			name:     "linked",
+			linked:   true,
+			expected: []string{"prod/backend/API_KEY=key", "staging/database/POSTGRES_PASSWORD=staging"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := secrets()
+			m := &manager{secretService: s}
+			require.NoError(t, m.removeEngineVars(context.Background(), applicationID, "prod", keys, tt.linked, tt.keepData))
+			assert.ElementsMatch(t, tt.expected, s.vars())
+		})
+	}
+
+	// adding the engine back gives the kept data its variables again
+	s := secrets()
+	m := &manager{secretService: s}
+	require.NoError(t, m.removeEngineVars(context.Background(), applicationID, "prod", keys, false, true))
+	require.NoError(t, m.moveVars(context.Background(), applicationID, "prod", types.InstanceTypeKeptDatabase, types.InstanceTypeDatabase, keys))
+	assert.ElementsMatch(t, []string{"prod/database/POSTGRES_PASSWORD=current", "prod/database/DATABASE_URL=url", "prod/backend/API_KEY=key", "staging/database/POSTGRES_PASSWORD=staging"}, s.vars())
+}
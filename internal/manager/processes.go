@@ -0,0 +1,122 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"github.com/samber/lo"
+	"maps"
+	"sarabi/internal/types"
+	"time"
+)
+
+const (
+	// cronStatus is the status a cron process is listed with, it only has a container while it runs
+	cronStatus = "scheduled"
+	// cronJobName is the job the cron process of an environment runs as, no other job can be named after it
+	cronJobName = "cron"
+)
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// syncCronProcess schedules the cron process of a backend deployment as the job of its environment, its runs have
+// the timeout and history of a job and a run is skipped while the previous one has not exited.
+// the job is removed when the deployment has no cron process
+func (m *manager) syncCronProcess(ctx context.Context, dep *types.Deployment) error {
+	job, err := m.jobService.Job(ctx, dep.ApplicationID, dep.Environment, cronJobName)
+	if err != nil {
+		return err
+	}
+
+	process, ok := dep.Processes[types.ProcessCron]
+	if !ok {
+		if job == nil {
+			return nil
+		}
+		return m.jobService.Remove(ctx, job)
+	}
+
+	if job == nil {
+		job = &types.Job{
+			ID:                uuid.New(),
+			ApplicationID:     dep.ApplicationID,
+			Environment:       dep.Environment,
+			Name:              cronJobName,
+			Timeout:           int(defaultJobTimeout.Seconds()),
+			ConcurrencyPolicy: types.ConcurrencyForbid,
+			CreatedAt:         time.Now(),
+		}
+	}
+	job.Schedule = process.Schedule
+	job.Command = dep.Processes.Cmd(types.ProcessCron)
+	if err := m.jobService.Save(ctx, job); err != nil {
+		return err
+	}
+	// the runs outlive the deployment scheduling them
+	return m.jobService.Schedule(context.Background(), job, m.runJobBG)
+}
+
+// scaleProcess returns the web instances and processes of a deployment with a process type scaled to count
+func scaleProcess(dep *types.Deployment, process types.ProcessType, count int) (int, types.Processes, error) {
+	switch process {
+	case types.ProcessWeb:
+		if count <= 0 {
+			return 0, nil, fmt.Errorf("invalid instance count: %d, web needs at least one replica", count)
+		}
+		return count, dep.Processes, nil
+	case types.ProcessWorker:
+		worker, ok := dep.Processes[types.ProcessWorker]
+		if !ok {
+			return 0, nil, fmt.Errorf("the backend of %s has no worker process, add it to .sarabi.yml and deploy", dep.Environment)
+		}
+		if count < 0 {
+			return 0, nil, fmt.Errorf("invalid instance count: %d", count)
+		}
+
+		processes := maps.Clone(dep.Processes)
+		worker.Replicas = count
+		processes[types.ProcessWorker] = worker
+		return dep.Instances, processes, nil
+	case types.ProcessCron:
+		return 0, nil, errors.New("cron runs one container every time its schedule is due, it can not be scaled")
+	}
+	return 0, nil, fmt.Errorf("unknown process type: %s", process)
+}
+
+func validateProcesses(processes types.Processes) error {
+	for name, process := range processes {
+		if !lo.Contains(types.ProcessTypes, name) {
+			return fmt.Errorf("unknown process type: %s, it must be one of web, worker or cron", name)
+		}
+		if process.Replicas < 0 {
+			return fmt.Errorf("invalid replicas of %s: %d", name, process.Replicas)
+		}
+		if process.Schedule != "" && name != types.ProcessCron {
+			return fmt.Errorf("%s runs all the time, only cron has a schedule", name)
+		}
+
+		switch name {
+		case types.ProcessWeb:
+			if process.Replicas != 0 {
+				return errors.New("the replicas of web are the instances of the deployment")
+			}
+		case types.ProcessWorker:
+			if process.Command == "" {
+				return errors.New("worker needs a command")
+			}
+		case types.ProcessCron:
+			if process.Command == "" {
+				return errors.New("cron needs a command")
+			}
+			if process.Replicas != 0 {
+				return errors.New("cron runs one container every time its schedule is due, it has no replicas")
+			}
+			if _, err := cronParser.Parse(process.Schedule); err != nil {
+				return fmt.Errorf("invalid schedule of cron: %q, it must be a cron expression e.g */5 * * * *", process.Schedule)
+			}
+		}
+	}
+	return nil
+}
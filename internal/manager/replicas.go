@@ -0,0 +1,282 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"go.uber.org/zap"
+	databasecomponent "sarabi/internal/components/database"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"time"
+)
+
+const (
+	// maxReplicas is how many read replicas a storage engine of an environment can have
+	maxReplicas = 5
+	// replicaLagThreshold is how far a replica may fall behind its primary before it is reported
+	replicaLagThreshold = 30 * time.Second
+	// replicaWatchInterval is how often the replicas of every environment are checked
+	replicaWatchInterval = time.Minute
+)
+
+// ScaleReplicas runs as many read replicas of a storage engine of an environment as asked, streaming from
+// the engine's container on the network of the environment. the backends are deployed again so that they
+// get the address of the replicas
+func (m *manager) ScaleReplicas(ctx context.Context, applicationID uuid.UUID, params types.ScaleReplicasParams, identifier string) error {
+	if params.Replicas < 0 || params.Replicas > maxReplicas {
+		return fmt.Errorf("replicas must be between 0 and %d", maxReplicas)
+	}
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, params.Environment); err != nil {
+		return errorpkg.Wrap(err, "no database running in environment: "+params.Environment)
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	se := params.StorageEngine
+	if se == "" {
+		se = types.StorageEnginePostgres
+	}
+	if se, err = storageEngineOf(app, se); err != nil {
+		return err
+	}
+
+	spec, err := m.databaseSpec(ctx, app, params.Environment, se)
+	if err != nil {
+		return err
+	}
+
+	replicas, err := databasecomponent.NewReplicas(m.dockerClient, m.appService, m.secretService, spec.provider, m.eventBus)
+	if err != nil {
+		return err
+	}
+
+	current, err := m.appService.DatabaseReplicas(ctx, applicationID, params.Environment, se)
+	if err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info,
+		fmt.Sprintf("Scaling the read replicas of %s in %s from %d to %d", se, params.Environment, current, params.Replicas))
+	spec.deployment.Identifier = identifier
+	if err := replicas.Scale(ctx, spec.deployment, current, params.Replicas); err != nil {
+		// the replicas that were started are removed by the next scale
+		if params.Replicas > current {
+			_ = m.appService.UpdateDatabaseReplicas(context.Background(), applicationID, params.Environment, se, params.Replicas)
+		}
+		return err
+	}
+
+	if err := m.appService.UpdateDatabaseReplicas(ctx, applicationID, params.Environment, se, params.Replicas); err != nil {
+		return err
+	}
+
+	// POSTGRES_READ_URL is new to the backends the first time, they are deployed with all the variables of the environment
+	if err := m.redeployBackends(ctx, applicationID, params.Environment, identifier,
+		fmt.Sprintf("Deploying %s with the read replicas of %s", params.Environment, se)); err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete,
+		fmt.Sprintf("%s in %s has %d read replica(s)", se, params.Environment, params.Replicas))
+	return nil
+}
+
+// ListReplicas returns the read replicas of the storage engines of an environment with how far behind they are
+func (m *manager) ListReplicas(ctx context.Context, applicationID uuid.UUID, environment string) ([]types.ReplicaStatus, error) {
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.ReplicaStatus, 0)
+	for _, se := range app.StorageEngines {
+		status, err := m.replicaStatus(ctx, app, environment, se)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, status...)
+	}
+	return result, nil
+}
+
+// WatchReplicas checks the replicas of every environment until ctx is done,
+// the ones that stopped streaming or fell behind are logged
+func (m *manager) WatchReplicas(ctx context.Context) {
+	ticker := time.NewTicker(replicaWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			configs, err := m.appService.ReplicatedDatabases(ctx)
+			if err != nil {
+				logger.Error("failed to list replicated databases", zap.Error(err))
+				continue
+			}
+			for _, cfg := range configs {
+				m.checkReplicas(ctx, cfg)
+			}
+		}
+	}
+}
+
+func (m *manager) checkReplicas(ctx context.Context, cfg *types.DatabaseConfig) {
+	app, err := m.appService.Get(ctx, cfg.ApplicationID)
+	if err != nil {
+		return
+	}
+
+	status, err := m.replicaStatus(ctx, app, cfg.Environment, cfg.StorageEngine)
+	if err != nil {
+		logger.Warn("failed to check replicas",
+			zap.String("application", app.Name),
+			zap.String("env", cfg.Environment),
+			zap.Error(err))
+		return
+	}
+
+	for _, next := range status {
+		switch {
+		case !replicaStreaming(next):
+			logger.Warn("replica is not streaming from its primary",
+				zap.String("application", app.Name),
+				zap.String("env", cfg.Environment),
+				zap.String("replica", next.Container),
+				zap.String("status", next.Status),
+				zap.String("state", next.State))
+		case replicaLagging(next):
+			logger.Warn("replica is behind its primary",
+				zap.String("application", app.Name),
+				zap.String("env", cfg.Environment),
+				zap.String("replica", next.Container),
+				zap.Float64("lag_seconds", next.LagSeconds),
+				zap.Int64("lag_bytes", next.LagBytes))
+		}
+	}
+}
+
+// replicaStatus returns the replicas of a storage engine of an environment, none when it has no replicas
+func (m *manager) replicaStatus(ctx context.Context, app *types.Application, environment string, se types.StorageEngine) ([]types.ReplicaStatus, error) {
+	count, err := m.appService.DatabaseReplicas(ctx, app.ID, environment, se)
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	spec, err := m.databaseSpec(ctx, app, environment, se)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, err := databasecomponent.NewReplicas(m.dockerClient, m.appService, m.secretService, spec.provider, m.eventBus)
+	if err != nil {
+		return nil, err
+	}
+	return replicas.Status(ctx, spec.deployment, count)
+}
+
+// rebuildReplicas copies the data of a storage engine into its replicas again,
+// e.g after the data of the primary was replaced
+func (m *manager) rebuildReplicas(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine, identifier string) error {
+	count, err := m.appService.DatabaseReplicas(ctx, applicationID, environment, se)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	spec, err := m.databaseSpec(ctx, app, environment, se)
+	if err != nil {
+		return err
+	}
+
+	replicas, err := databasecomponent.NewReplicas(m.dockerClient, m.appService, m.secretService, spec.provider, m.eventBus)
+	if err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, fmt.Sprintf("Copying %s into its %d read replica(s) again", se, count))
+	spec.deployment.Identifier = identifier
+	if err := replicas.Remove(ctx, spec.deployment, count); err != nil {
+		return err
+	}
+	return replicas.Scale(ctx, spec.deployment, count, count)
+}
+
+// restartReplicas restarts the replicas of a storage engine so that they read its configuration again,
+// a standby does not start with lower limits than its primary
+func (m *manager) restartReplicas(ctx context.Context, spec *databaseSpec) error {
+	replicator, ok := spec.provider.(databasecomponent.Replicator)
+	if !ok {
+		return nil
+	}
+
+	count, err := m.appService.DatabaseReplicas(ctx, spec.deployment.ApplicationID, spec.deployment.Environment, spec.engine)
+	if err != nil {
+		return err
+	}
+	for idx := 1; idx <= count; idx++ {
+		if err := m.dockerClient.RestartContainer(ctx, replicator.ReplicaName(spec.deployment, idx)); err != nil {
+			return errorpkg.Wrap(err, "configuration updated but failed to restart read replica")
+		}
+	}
+	return nil
+}
+
+// deployReplicas starts the replicas of a storage engine that are not running after its container was deployed
+func (m *manager) deployReplicas(ctx context.Context, app *types.Application, environment string, se types.StorageEngine, identifier string) error {
+	count, err := m.appService.DatabaseReplicas(ctx, app.ID, environment, se)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	provider := databasecomponent.NewProvider(se, app.EngineVersion(se, environment))
+	replicas, err := databasecomponent.NewReplicas(m.dockerClient, m.appService, m.secretService, provider, m.eventBus)
+	if err != nil {
+		return err
+	}
+
+	deployment := &types.Deployment{ApplicationID: app.ID, Environment: environment, Application: *app, Identifier: identifier}
+	return replicas.Scale(ctx, deployment, count, count)
+}
+
+// removeReplicas removes the replicas of a storage engine and their variables,
+// the count is kept when keepCount is set so that they come back with the engine
+func (m *manager) removeReplicas(ctx context.Context, deployment *types.Deployment, provider databasecomponent.Provider, keepCount bool) error {
+	replicas, err := databasecomponent.NewReplicas(m.dockerClient, m.appService, m.secretService, provider, m.eventBus)
+	if err != nil {
+		// the engine has no replicas
+		return nil
+	}
+
+	count, err := m.appService.DatabaseReplicas(ctx, deployment.ApplicationID, deployment.Environment, provider.Engine())
+	if err != nil || count == 0 {
+		return err
+	}
+
+	if err := replicas.Forget(ctx, deployment, count); err != nil {
+		return err
+	}
+	if keepCount {
+		return nil
+	}
+	return m.appService.UpdateDatabaseReplicas(ctx, deployment.ApplicationID, deployment.Environment, provider.Engine(), 0)
+}
+
+func replicaStreaming(status types.ReplicaStatus) bool {
+	return status.Status == "running" && status.State == "streaming"
+}
+
+func replicaLagging(status types.ReplicaStatus) bool {
+	return status.LagSeconds > replicaLagThreshold.Seconds()
+}
@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"github.com/samber/lo"
+	"path/filepath"
+	"sarabi/internal/misc"
+	"sarabi/internal/types"
+	"strconv"
+)
+
+// reservedServiceNames are the names of the instance types, e.g the access URLs of the frontend and the backend
+var reservedServiceNames = []string{
+	string(types.InstanceTypeFrontend),
+	string(types.InstanceTypeBackend),
+	string(types.InstanceTypeProxy),
+	string(types.InstanceTypeDatabase),
+}
+
+// activeBackends returns the active deployments of the backend and the named services of an environment
+func (m *manager) activeBackends(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Deployment, error) {
+	actives, err := m.appService.FindCurrentlyActiveDeployments(ctx, applicationID, types.InstanceTypeBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Filter(actives, func(item *types.Deployment, index int) bool {
+		return item.Environment == environment
+	}), nil
+}
+
+// createService saves the deployment of a named service with its artifact, a port is allocated when it has none
+func (m *manager) createService(ctx context.Context, param *types.DeployParams, service types.ServiceParams) (*types.Deployment, error) {
+	port := service.Port
+	if port == "" {
+		var err error
+		port, err = misc.DefaultPortGenerator.Generate()
+		if err != nil {
+			return nil, errorpkg.Wrap(err, "failed to allocate port")
+		}
+	}
+
+	deployment, err := m.appService.CreateDeployment(ctx, types.CreateDeploymentParams{
+		ApplicationID: param.ApplicationID,
+		Environment:   param.Environment,
+		Instances:     service.Replicas,
+		Port:          port,
+		InstanceType:  types.InstanceTypeBackend,
+		Identifier:    param.Identifier,
+		Service:       service.Name,
+		Settings:      service.ServiceSettings,
+	})
+	if err != nil {
+		return nil, errorpkg.Wrap(err, "failed to save deployment of "+service.Name)
+	}
+
+	if err := m.store.Save(ctx, service.Artifact, deployment); err != nil {
+		return nil, errorpkg.Wrap(err, "failed to save artifact of "+service.Name)
+	}
+
+	if err := m.setupAppVariables(ctx, deployment); err != nil {
+		return nil, errorpkg.Wrap(err, "failed to setup app variables")
+	}
+	return deployment, nil
+}
+
+// checkServiceAddons fails when a service is named like an add-on of the environment, they would be routed at the same host
+func (m *manager) checkServiceAddons(ctx context.Context, applicationID uuid.UUID, environment string, services []types.ServiceParams) error {
+	addons, err := m.appService.Addons(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	for _, next := range addons {
+		if next.Environment != environment {
+			continue
+		}
+		if lo.ContainsBy(services, func(item types.ServiceParams) bool { return item.Name == next.Name }) {
+			return fmt.Errorf("invalid service name: %s, it is the name of an add-on of %s", next.Name, environment)
+		}
+	}
+	return nil
+}
+
+func validateServices(services []types.ServiceParams) error {
+	seen := make(map[string]bool, len(services))
+	for _, next := range services {
+		if !nameRegex.MatchString(next.Name) {
+			return fmt.Errorf("invalid service name: %s, it must start with a letter and contain lower case letters, numbers and hyphens", next.Name)
+		}
+		if lo.Contains(reservedServiceNames, next.Name) {
+			return fmt.Errorf("invalid service name: %s, it is reserved for the %s of the application", next.Name, next.Name)
+		}
+		if seen[next.Name] {
+			return fmt.Errorf("service %s is deployed twice", next.Name)
+		}
+		seen[next.Name] = true
+
+		if next.Artifact == nil {
+			return fmt.Errorf("service %s has no upload", next.Name)
+		}
+		if next.Replicas <= 0 {
+			return fmt.Errorf("invalid replicas of %s: %d, it needs at least one replica", next.Name, next.Replicas)
+		}
+		if next.Port != "" {
+			if port, err := strconv.Atoi(next.Port); err != nil || port <= 0 || port > 65535 {
+				return fmt.Errorf("invalid port of %s: %s", next.Name, next.Port)
+			}
+		}
+		if next.Dockerfile != "" && !filepath.IsLocal(next.Dockerfile) {
+			return fmt.Errorf("invalid dockerfile of %s: %s, it must be a path in the directory of the service", next.Name, next.Dockerfile)
+		}
+		if err := validateVisibility(next.Visibility); err != nil {
+			return fmt.Errorf("service %s: %w", next.Name, err)
+		}
+		if next.Internal() && len(next.Domains) > 0 {
+			return fmt.Errorf("service %s is internal, it has no route for its domains", next.Name)
+		}
+		if lo.Contains(next.Domains, "") {
+			return fmt.Errorf("invalid domain of %s: domain is empty", next.Name)
+		}
+
+		for key := range next.Variables {
+			if !varNameRegex.MatchString(key) {
+				return fmt.Errorf("invalid variable of %s: %s", next.Name, key)
+			}
+			if key == "PORT" {
+				return errors.New("PORT is set to the port of the service, set port in .sarabi.yml instead")
+			}
+		}
+	}
+	return nil
+}
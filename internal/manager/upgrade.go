@@ -0,0 +1,294 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"go.uber.org/zap"
+	databasecomponent "sarabi/internal/components/database"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/integrations/docker"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"strings"
+	"time"
+)
+
+// upgrade holds what is needed to move one storage engine of an environment to another version
+type upgrade struct {
+	*databaseSpec
+	from       databasecomponent.Provider
+	to         databasecomponent.Provider
+	identifier string
+}
+
+// UpgradeDatabase moves a storage engine of an environment to another version.
+// the data is copied with the engine's own dump and restore tools into a container running the new version,
+// on its own volume. the new version only takes over the volume, variables and port of the old one once both
+// copies hold the same data. the previous data is kept in a safety volume and put back if the new version fails
+func (m *manager) UpgradeDatabase(ctx context.Context, applicationID uuid.UUID, params types.UpgradeDatabaseParams, identifier string) error {
+	if _, err := m.appService.FindCurrentlyActiveDeploymentsEnv(ctx, applicationID, types.InstanceTypeDatabase, params.Environment); err != nil {
+		return errorpkg.Wrap(err, "no database running in environment: "+params.Environment)
+	}
+
+	u, err := m.prepareUpgrade(ctx, applicationID, params, identifier)
+	if err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Taking a backup before upgrading...")
+	if err := m.backupService.SafetyBackup(ctx, applicationID, params.Environment, types.BackupTriggerUpgrade); err != nil {
+		return errorpkg.Wrap(err, "backup before upgrade failed")
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Pulling "+u.to.Image())
+	if err := m.dockerClient.PullImage(ctx, u.to.Image()); err != nil {
+		return err
+	}
+
+	volume := u.deployment.VolumeName(u.engine)
+	upgradeVolume := volume + "-upgrade"
+	if err := m.dockerClient.CreateVolume(ctx, upgradeVolume); err != nil {
+		return err
+	}
+	defer func() {
+		if err := m.dockerClient.RemoveVolume(context.Background(), upgradeVolume); err != nil {
+			logger.Warn("failed to remove upgrade volume",
+				zap.String("volume", upgradeVolume),
+				zap.Error(err))
+		}
+	}()
+
+	containerName := u.from.ContainerName(u.deployment)
+	upgradeContainer := containerName + "-upgrade"
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Provisioning "+u.to.Image())
+	if err := m.startDatabase(ctx, u.databaseSpec, u.to, upgradeContainer, upgradeVolume, false); err != nil {
+		return errorpkg.Wrap(err, "failed to provision new version")
+	}
+	defer func() {
+		_ = m.dockerClient.StopAndRemoveContainer(context.Background(), docker.StopContainerParams{
+			ContainerName: upgradeContainer,
+		})
+	}()
+
+	if err := m.waitDatabaseReady(ctx, upgradeContainer, u.to); err != nil {
+		return err
+	}
+
+	// nothing may write to the old version while its data is copied over
+	restartBackends := m.stopBackends(ctx, applicationID, params.Environment)
+	defer restartBackends()
+
+	m.eventBus.Broadcast(identifier, eventbus.Info, "Copying data to "+u.to.Image())
+	if err := m.databaseExec(ctx, upgradeContainer, u.to.ImportCommand(containerName)); err != nil {
+		return errorpkg.Wrap(err, "failed to copy data to new version")
+	}
+
+	checksum, err := m.verifyCopy(ctx, u, containerName, upgradeContainer)
+	if err != nil {
+		return err
+	}
+
+	safetyVolume, err := m.swapDatabase(ctx, u, upgradeContainer, upgradeVolume, checksum)
+	if err != nil {
+		return err
+	}
+
+	if err := m.appService.UpdateEngineVersion(ctx, applicationID, params.Environment, u.engine, u.to.Version()); err != nil {
+		return errorpkg.Wrap(err, "upgrade completed but failed to record the new version")
+	}
+
+	if u.engine == types.StorageEnginePostgres {
+		if err := m.backupService.ResumePITR(ctx, applicationID, params.Environment); err != nil {
+			m.eventBus.Broadcast(identifier, eventbus.Error, "Failed to resume point-in-time recovery: "+err.Error())
+		}
+	}
+	// the replicas can not stream from another version, they are copied from the new one
+	if err := m.rebuildReplicas(ctx, applicationID, params.Environment, u.engine, identifier); err != nil {
+		m.eventBus.Broadcast(identifier, eventbus.Error, "Failed to rebuild read replicas: "+err.Error())
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete,
+		fmt.Sprintf("Upgraded %s to %s, the previous data is kept in volume %s", u.engine, u.to.Version(), safetyVolume))
+	return nil
+}
+
+func (m *manager) prepareUpgrade(ctx context.Context, applicationID uuid.UUID, params types.UpgradeDatabaseParams, identifier string) (*upgrade, error) {
+	app, err := m.appService.Get(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	se, err := storageEngineOf(app, params.StorageEngine)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Version == "" {
+		return nil, errors.New("version is required")
+	}
+	if _, _, err := types.ParseStorageEngine(se.String() + ":" + params.Version); err != nil {
+		return nil, err
+	}
+
+	current := app.EngineVersion(se, params.Environment)
+	if current == params.Version {
+		return nil, fmt.Errorf("%s is already running version %s", se, current)
+	}
+	if types.MajorVersion(params.Version) < types.MajorVersion(current) {
+		return nil, fmt.Errorf("downgrading %s from %s to %s is not supported", se, current, params.Version)
+	}
+
+	spec, err := m.databaseSpec(ctx, app, params.Environment, se)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upgrade{
+		databaseSpec: spec,
+		from:         spec.provider,
+		to:           databasecomponent.NewProvider(se, params.Version),
+		identifier:   identifier,
+	}, nil
+}
+
+// verifyCopy compares the data held by both versions and returns its checksum
+func (m *manager) verifyCopy(ctx context.Context, u *upgrade, containerName, upgradeContainer string) (string, error) {
+	m.eventBus.Broadcast(u.identifier, eventbus.Info, "Verifying copied data...")
+	expected, err := m.databaseOutput(ctx, containerName, u.from.ChecksumCommand())
+	if err != nil {
+		return "", errorpkg.Wrap(err, "failed to summarize current data")
+	}
+
+	actual, err := m.databaseOutput(ctx, upgradeContainer, u.to.ChecksumCommand())
+	if err != nil {
+		return "", errorpkg.Wrap(err, "failed to summarize copied data")
+	}
+
+	if expected != actual {
+		return "", fmt.Errorf("copied data does not match, expected:\n%s\ngot:\n%s", expected, actual)
+	}
+	return expected, nil
+}
+
+// swapDatabase moves the upgraded data into the database volume and replaces the old container with one running
+// the new version. it returns the safety volume holding the previous data
+func (m *manager) swapDatabase(ctx context.Context, u *upgrade, upgradeContainer, upgradeVolume, checksum string) (string, error) {
+	containerName := u.from.ContainerName(u.deployment)
+	volume := u.deployment.VolumeName(u.engine)
+	safetyVolume := fmt.Sprintf("%s-pre-upgrade-%s", volume, time.Now().Format("20060102150405"))
+
+	m.eventBus.Broadcast(u.identifier, eventbus.Info, "Switching over to "+u.to.Image())
+	if err := m.dockerClient.StopContainer(ctx, upgradeContainer); err != nil {
+		return "", err
+	}
+
+	if err := m.dockerClient.StopContainer(ctx, containerName); err != nil {
+		return "", err
+	}
+
+	if err := m.dockerClient.CreateVolume(ctx, safetyVolume); err != nil {
+		_ = m.dockerClient.RestartContainer(ctx, containerName)
+		return "", err
+	}
+
+	if err := m.copyVolume(ctx, u.to.Image(), volume, safetyVolume); err != nil {
+		_ = m.dockerClient.RestartContainer(ctx, containerName)
+		return "", errorpkg.Wrap(err, "failed to copy current data into safety volume")
+	}
+
+	if err := m.dockerClient.StopAndRemoveContainer(ctx, docker.StopContainerParams{ContainerName: containerName}); err != nil {
+		_ = m.dockerClient.RestartContainer(ctx, containerName)
+		return "", err
+	}
+
+	rollback := func(cause error) (string, error) {
+		logger.Error("database upgrade failed, putting back previous version",
+			zap.String("container", containerName),
+			zap.Error(cause))
+		m.eventBus.Broadcast(u.identifier, eventbus.Error, "Upgrade failed, putting back "+u.from.Image())
+
+		_ = m.dockerClient.StopAndRemoveContainer(context.Background(), docker.StopContainerParams{ContainerName: containerName})
+		if err := m.copyVolume(context.Background(), u.to.Image(), safetyVolume, volume); err != nil {
+			return "", fmt.Errorf("%w: rollback failed, previous data is kept in volume %s: %s", cause, safetyVolume, err.Error())
+		}
+		if err := m.startDatabase(context.Background(), u.databaseSpec, u.from, containerName, volume, true); err != nil {
+			return "", fmt.Errorf("%w: rollback failed, previous data is kept in volume %s: %s", cause, safetyVolume, err.Error())
+		}
+		return "", cause
+	}
+
+	if err := m.copyVolume(ctx, u.to.Image(), upgradeVolume, volume); err != nil {
+		return rollback(errorpkg.Wrap(err, "failed to move upgraded data into the database volume"))
+	}
+
+	if err := m.startDatabase(ctx, u.databaseSpec, u.to, containerName, volume, true); err != nil {
+		return rollback(err)
+	}
+
+	if err := m.waitDatabaseReady(ctx, containerName, u.to); err != nil {
+		return rollback(err)
+	}
+
+	actual, err := m.databaseOutput(ctx, containerName, u.to.ChecksumCommand())
+	if err != nil {
+		return rollback(err)
+	}
+	if actual != checksum {
+		return rollback(errors.New("data changed while switching over to the new version"))
+	}
+	return safetyVolume, nil
+}
+
+// stopBackends stops the web and worker containers of the backend and the named services of an environment
+// and returns a function starting them again
+func (m *manager) stopBackends(ctx context.Context, applicationID uuid.UUID, environment string) func() {
+	backends, err := m.activeBackends(ctx, applicationID, environment)
+	if err != nil {
+		return func() {}
+	}
+
+	stopped := make([]string, 0)
+	for _, backend := range backends {
+		for _, name := range backend.ProcessContainerNames() {
+			if err := m.dockerClient.StopContainer(ctx, name); err != nil {
+				logger.Warn("failed to stop backend instance", zap.String("container", name), zap.Error(err))
+				continue
+			}
+			stopped = append(stopped, name)
+		}
+	}
+
+	return func() {
+		for _, name := range stopped {
+			if err := m.dockerClient.RestartContainer(context.Background(), name); err != nil {
+				logger.Error("failed to start backend instance", zap.String("container", name), zap.Error(err))
+			}
+		}
+	}
+}
+
+// copyVolume replaces the content of a volume with another, the ownership of the data directory is kept
+// so the engine can still write to it
+func (m *manager) copyVolume(ctx context.Context, image, from, to string) error {
+	result, err := m.dockerClient.RunContainer(ctx, docker.StartContainerParams{
+		Image: image,
+		Cmd: []string{"sh", "-c", `set -e
+find /to -mindepth 1 -delete
+cp -a /from/. /to/
+chown --reference=/from /to
+chmod --reference=/from /to`},
+		Mounts: map[string]string{from: "/from", to: "/to"},
+		User:   "root",
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.ExitCode != 0 {
+		return fmt.Errorf("exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
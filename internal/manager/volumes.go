@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errorpkg "github.com/pkg/errors"
+	"go.uber.org/zap"
+	"path"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"time"
+)
+
+// AddVolume declares a volume mounted into every container of the backend of an environment, the backend is
+// deployed again with it when it runs. its data is kept across deployments, scaling and rollbacks
+func (m *manager) AddVolume(ctx context.Context, applicationID uuid.UUID, params types.AddVolumeParams, identifier string) error {
+	if err := validateVolume(params); err != nil {
+		return err
+	}
+
+	volumes, err := m.appService.Volumes(ctx, applicationID, params.Environment)
+	if err != nil {
+		return err
+	}
+	for _, next := range volumes {
+		if next.Name == params.Name {
+			return fmt.Errorf("%s already has a volume named %s", params.Environment, params.Name)
+		}
+		if next.Path == path.Clean(params.Path) {
+			return fmt.Errorf("volume %s is already mounted at %s", next.Name, next.Path)
+		}
+	}
+
+	volume := &types.Volume{
+		ID:            uuid.New(),
+		ApplicationID: applicationID,
+		Environment:   params.Environment,
+		Name:          params.Name,
+		Path:          path.Clean(params.Path),
+		CreatedAt:     time.Now(),
+	}
+	if err := m.dockerClient.CreateVolume(ctx, volume.DockerVolume()); err != nil {
+		return errorpkg.Wrap(err, "failed to create volume")
+	}
+	if err := m.appService.SaveVolume(ctx, volume); err != nil {
+		return err
+	}
+
+	if err := m.redeployBackends(ctx, applicationID, params.Environment, identifier,
+		fmt.Sprintf("Deploying %s with %s mounted at %s", params.Environment, volume.Name, volume.Path)); err != nil {
+		return err
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete, fmt.Sprintf("%s is mounted at %s in %s", volume.Name, volume.Path, params.Environment))
+	return nil
+}
+
+// RemoveVolume removes a volume from the backend of an environment, the backend is deployed again without it.
+// the data of the volume is deleted unless it is kept
+func (m *manager) RemoveVolume(ctx context.Context, applicationID uuid.UUID, params types.RemoveVolumeParams, identifier string) error {
+	volume, err := m.appService.Volume(ctx, applicationID, params.Environment, params.Name)
+	if err != nil {
+		return err
+	}
+	if volume == nil {
+		return fmt.Errorf("%s has no volume named %s", params.Environment, params.Name)
+	}
+
+	if err := m.appService.RemoveVolume(ctx, volume.ID); err != nil {
+		return err
+	}
+
+	if err := m.redeployBackends(ctx, applicationID, params.Environment, identifier,
+		fmt.Sprintf("Deploying %s without %s", params.Environment, volume.Name)); err != nil {
+		return err
+	}
+
+	if !params.KeepData {
+		// the containers of the previous deployment are removed by now, nothing uses the volume anymore
+		if err := m.dockerClient.RemoveVolume(ctx, volume.DockerVolume()); err != nil {
+			logger.Warn("failed to remove volume",
+				zap.String("volume", volume.DockerVolume()),
+				zap.Error(err))
+		}
+	}
+
+	m.eventBus.Broadcast(identifier, eventbus.Complete, fmt.Sprintf("Removed %s from %s", volume.Name, params.Environment))
+	return nil
+}
+
+// removeVolumes deletes the volumes of an environment with their data, the ones of every environment when it is empty
+func (m *manager) removeVolumes(ctx context.Context, applicationID uuid.UUID, environment string) error {
+	volumes, err := m.appService.Volumes(ctx, applicationID, environment)
+	if err != nil {
+		return err
+	}
+	for _, next := range volumes {
+		if err := m.appService.RemoveVolume(ctx, next.ID); err != nil {
+			return err
+		}
+		if err := m.dockerClient.RemoveVolume(ctx, next.DockerVolume()); err != nil {
+			logger.Warn("failed to remove volume",
+				zap.String("volume", next.DockerVolume()),
+				zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// ListVolumes returns the volumes of an environment with how much they hold, the ones of every environment
+// when it is empty
+func (m *manager) ListVolumes(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Volume, error) {
+	volumes, err := m.appService.Volumes(ctx, applicationID, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes, err := m.dockerClient.VolumeSizes(ctx)
+	if err != nil {
+		logger.Warn("failed to read volume sizes", zap.Error(err))
+	}
+	for _, next := range volumes {
+		next.Size = -1
+		if size, ok := sizes[next.DockerVolume()]; ok {
+			next.Size = size
+		}
+	}
+	return volumes, nil
+}
+
+func validateVolume(params types.AddVolumeParams) error {
+	if params.Environment == "" {
+		return errors.New("environment is required")
+	}
+	if !nameRegex.MatchString(params.Name) {
+		return fmt.Errorf("invalid volume name: %s, it must start with a letter and contain lower case letters, numbers and hyphens", params.Name)
+	}
+	if !path.IsAbs(params.Path) || path.Clean(params.Path) == "/" {
+		return fmt.Errorf("invalid path: %s, it must be an absolute path in the container other than /", params.Path)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"math/big"
 	"net"
+	"net/url"
 	"sarabi/internal/types"
 	"strconv"
 	"strings"
@@ -142,6 +143,8 @@ func FormatURI(dbType, username, password, host, port, dbName string, sslMode st
 		uri = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?tls=%s", username, password, host, port, dbName, sslMode)
 	case "redis":
 		uri = fmt.Sprintf("redis://%s:%s@%s:%s", username, password, host, port)
+	case "amqp":
+		uri = fmt.Sprintf("amqp://%s:%s@%s:%s", username, password, host, port)
 	case "mongodb", "mongo":
 		uri = fmt.Sprintf("mongodb://%s:%s@%s:%s/%s?ssl=%s", username, password, host, port, dbName, sslMode)
 	default:
@@ -151,6 +154,22 @@ func FormatURI(dbType, username, password, host, port, dbName string, sslMode st
 	return uri
 }
 
+// ParseURI parses the connection URL of a database, its scheme must be one of schemes and it must name a host
+func ParseURI(raw string, schemes ...string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if !StrContains(u.Scheme, schemes) {
+		return nil, fmt.Errorf("unsupported scheme %q, expected %s", u.Scheme, strings.Join(schemes, " or "))
+	}
+	if u.Host == "" {
+		return nil, errors.New("the URL must name a host")
+	}
+	return u, nil
+}
+
 func StrPtr(s string) *string {
 	return &s
 }
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
 	"sarabi/internal/database"
 	"sarabi/internal/types"
 	"strings"
@@ -25,16 +27,48 @@ type (
 		FindDeploymentsByIdentifier(ctx context.Context, identifier string) ([]*types.Deployment, error)
 		FindDeploymentsByApplication(ctx context.Context, applicationID uuid.UUID) ([]*types.Deployment, error)
 		UpdateDeploymentStatus(ctx context.Context, deploymentID uuid.UUID, status types.DeploymentStatus) error
+		UpdateEngineVersion(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine, version string) error
+		AddStorageEngine(ctx context.Context, applicationID uuid.UUID, se types.StorageEngine, version string) (*types.Application, error)
+		RemoveStorageEngine(ctx context.Context, applicationID uuid.UUID, se types.StorageEngine, keepVersions bool) (*types.Application, error)
+		DatabaseConfig(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (types.ConfigSettings, error)
+		UpdateDatabaseConfig(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine, overrides types.ConfigSettings) error
+		DatabaseReplicas(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (int, error)
+		UpdateDatabaseReplicas(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine, replicas int) error
+		ReplicatedDatabases(ctx context.Context) ([]*types.DatabaseConfig, error)
+		ExternalDatabase(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (*types.ExternalDatabase, error)
+		ExternalDatabases(ctx context.Context, applicationID uuid.UUID) ([]*types.ExternalDatabase, error)
+		LinkDatabase(ctx context.Context, external *types.ExternalDatabase) error
+		UnlinkDatabase(ctx context.Context, id uuid.UUID) error
+		Addon(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Addon, error)
+		Addons(ctx context.Context, applicationID uuid.UUID) ([]*types.Addon, error)
+		SaveAddon(ctx context.Context, addon *types.Addon) error
+		RemoveAddon(ctx context.Context, id uuid.UUID) error
+		Volume(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Volume, error)
+		Volumes(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Volume, error)
+		SaveVolume(ctx context.Context, volume *types.Volume) error
+		RemoveVolume(ctx context.Context, id uuid.UUID) error
+		InternalAccess(ctx context.Context, applicationID, fromApplicationID uuid.UUID) (*types.InternalAccess, error)
+		InternalAccesses(ctx context.Context, applicationID uuid.UUID) ([]*types.InternalAccess, error)
+		SaveInternalAccess(ctx context.Context, access *types.InternalAccess) error
+		RemoveInternalAccess(ctx context.Context, id uuid.UUID) error
 	}
 )
 
 type applicationService struct {
-	applicationRepository database.ApplicationRepository
-	deploymentRepository  database.DeploymentRepository
+	applicationRepository    database.ApplicationRepository
+	deploymentRepository     database.DeploymentRepository
+	databaseConfigRepository database.DatabaseConfigRepository
+	externalRepository       database.ExternalDatabaseRepository
+	addonRepository          database.AddonRepository
+	volumeRepository         database.VolumeRepository
+	internalAccessRepository database.InternalAccessRepository
 }
 
-func NewApplicationService(repo database.ApplicationRepository, dr database.DeploymentRepository) ApplicationService {
-	return &applicationService{applicationRepository: repo, deploymentRepository: dr}
+func NewApplicationService(repo database.ApplicationRepository, dr database.DeploymentRepository,
+	dcr database.DatabaseConfigRepository, er database.ExternalDatabaseRepository, ar database.AddonRepository,
+	vr database.VolumeRepository, iar database.InternalAccessRepository) ApplicationService {
+	return &applicationService{applicationRepository: repo, deploymentRepository: dr, databaseConfigRepository: dcr,
+		externalRepository: er, addonRepository: ar, volumeRepository: vr, internalAccessRepository: iar}
 }
 
 func (a *applicationService) Create(ctx context.Context, params types.CreateApplicationParams) (*types.Application, error) {
@@ -43,8 +77,18 @@ func (a *applicationService) Create(ctx context.Context, params types.CreateAppl
 	}
 
 	engine := make(types.StorageEngines, 0)
-	for _, se := range params.StorageEngine {
-		engine = append(engine, types.StorageEngine(se))
+	versions := make(map[types.StorageEngine]string)
+	for _, next := range params.StorageEngine {
+		se, version, err := types.ParseStorageEngine(next)
+		if err != nil {
+			return nil, err
+		}
+
+		if version == "" {
+			version = types.DefaultEngineVersions[se]
+		}
+		engine = append(engine, se)
+		versions[se] = version
 	}
 
 	app := &types.Application{
@@ -52,6 +96,7 @@ func (a *applicationService) Create(ctx context.Context, params types.CreateAppl
 		Name:           params.Name,
 		Domain:         params.Domain,
 		StorageEngines: engine,
+		EngineVersions: types.EngineVersions{Default: versions},
 		Frontend:       params.Frontend,
 		Backend:        params.Backend,
 		CreatedAt:      time.Now(),
@@ -71,6 +116,244 @@ func (a *applicationService) Get(ctx context.Context, applicationID uuid.UUID) (
 	return a.applicationRepository.FindByID(ctx, applicationID)
 }
 
+func (a *applicationService) UpdateEngineVersion(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine, version string) error {
+	app, err := a.applicationRepository.FindByID(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	app.SetEngineVersion(se, environment, version)
+	return a.applicationRepository.Save(ctx, app)
+}
+
+// AddStorageEngine adds a storage engine to an application, pinned to version or to the version
+// it ran before when it was removed with its data kept
+func (a *applicationService) AddStorageEngine(ctx context.Context, applicationID uuid.UUID, se types.StorageEngine, version string) (*types.Application, error) {
+	app, err := a.applicationRepository.FindByID(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if lo.Contains(app.StorageEngines, se) {
+		return nil, fmt.Errorf("%s already has storage engine %s", app.Name, se)
+	}
+
+	if app.EngineVersions.Default == nil {
+		app.EngineVersions.Default = make(map[types.StorageEngine]string)
+	}
+	if version != "" {
+		app.EngineVersions.Default[se] = version
+	} else if app.EngineVersions.Default[se] == "" {
+		app.EngineVersions.Default[se] = types.DefaultEngineVersions[se]
+	}
+
+	app.StorageEngines = append(app.StorageEngines, se)
+	if err := a.applicationRepository.Save(ctx, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// RemoveStorageEngine removes a storage engine from an application. the versions it ran are kept when
+// keepVersions is set, its data is left behind and must be read by the same versions when it is added back
+func (a *applicationService) RemoveStorageEngine(ctx context.Context, applicationID uuid.UUID, se types.StorageEngine, keepVersions bool) (*types.Application, error) {
+	app, err := a.applicationRepository.FindByID(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !lo.Contains(app.StorageEngines, se) {
+		return nil, fmt.Errorf("%s has no storage engine %s", app.Name, se)
+	}
+
+	app.StorageEngines = lo.Without(app.StorageEngines, se)
+	if !keepVersions {
+		delete(app.EngineVersions.Default, se)
+		for _, versions := range app.EngineVersions.Environments {
+			delete(versions, se)
+		}
+	}
+
+	if err := a.applicationRepository.Save(ctx, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// DatabaseConfig returns the settings a user set on a storage engine of an environment
+func (a *applicationService) DatabaseConfig(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (types.ConfigSettings, error) {
+	config, err := a.databaseConfigRepository.Find(ctx, applicationID, environment, se)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.ConfigSettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config.Overrides, nil
+}
+
+func (a *applicationService) UpdateDatabaseConfig(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine, overrides types.ConfigSettings) error {
+	config, err := a.databaseConfigRepository.Find(ctx, applicationID, environment, se)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		config = &types.DatabaseConfig{
+			ID:            uuid.New(),
+			ApplicationID: applicationID,
+			Environment:   environment,
+			StorageEngine: se,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	config.Overrides = overrides
+	config.UpdatedAt = time.Now()
+	return a.databaseConfigRepository.Save(ctx, config)
+}
+
+// DatabaseReplicas returns how many read replicas a storage engine of an environment has
+func (a *applicationService) DatabaseReplicas(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (int, error) {
+	config, err := a.databaseConfigRepository.Find(ctx, applicationID, environment, se)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return config.Replicas, nil
+}
+
+func (a *applicationService) UpdateDatabaseReplicas(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine, replicas int) error {
+	config, err := a.databaseConfigRepository.Find(ctx, applicationID, environment, se)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		config = &types.DatabaseConfig{
+			ID:            uuid.New(),
+			ApplicationID: applicationID,
+			Environment:   environment,
+			StorageEngine: se,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	config.Replicas = replicas
+	config.UpdatedAt = time.Now()
+	return a.databaseConfigRepository.Save(ctx, config)
+}
+
+// ReplicatedDatabases returns the settings of every storage engine that has read replicas
+func (a *applicationService) ReplicatedDatabases(ctx context.Context) ([]*types.DatabaseConfig, error) {
+	return a.databaseConfigRepository.FindReplicated(ctx)
+}
+
+// ExternalDatabase returns the database run outside sarabi a storage engine of an environment is linked to,
+// nil when sarabi runs the engine itself
+func (a *applicationService) ExternalDatabase(ctx context.Context, applicationID uuid.UUID, environment string, se types.StorageEngine) (*types.ExternalDatabase, error) {
+	external, err := a.externalRepository.Find(ctx, applicationID, environment, se)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return external, nil
+}
+
+func (a *applicationService) ExternalDatabases(ctx context.Context, applicationID uuid.UUID) ([]*types.ExternalDatabase, error) {
+	return a.externalRepository.FindAll(ctx, applicationID)
+}
+
+func (a *applicationService) LinkDatabase(ctx context.Context, external *types.ExternalDatabase) error {
+	return a.externalRepository.Save(ctx, external)
+}
+
+func (a *applicationService) UnlinkDatabase(ctx context.Context, id uuid.UUID) error {
+	return a.externalRepository.Delete(ctx, id)
+}
+
+// Addon returns an add-on of an environment by name, nil when the environment has none with that name
+func (a *applicationService) Addon(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Addon, error) {
+	addon, err := a.addonRepository.Find(ctx, applicationID, environment, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return addon, nil
+}
+
+func (a *applicationService) Addons(ctx context.Context, applicationID uuid.UUID) ([]*types.Addon, error) {
+	return a.addonRepository.FindAll(ctx, applicationID)
+}
+
+func (a *applicationService) SaveAddon(ctx context.Context, addon *types.Addon) error {
+	return a.addonRepository.Save(ctx, addon)
+}
+
+func (a *applicationService) RemoveAddon(ctx context.Context, id uuid.UUID) error {
+	return a.addonRepository.Delete(ctx, id)
+}
+
+// Volume returns a volume of an environment by name, nil when the environment has none with that name
+func (a *applicationService) Volume(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Volume, error) {
+	volume, err := a.volumeRepository.Find(ctx, applicationID, environment, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// Volumes returns the volumes of an environment, the ones of every environment when it is empty
+func (a *applicationService) Volumes(ctx context.Context, applicationID uuid.UUID, environment string) ([]*types.Volume, error) {
+	volumes, err := a.volumeRepository.FindAll(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if environment == "" {
+		return volumes, nil
+	}
+	return lo.Filter(volumes, func(item *types.Volume, index int) bool {
+		return item.Environment == environment
+	}), nil
+}
+
+func (a *applicationService) SaveVolume(ctx context.Context, volume *types.Volume) error {
+	return a.volumeRepository.Save(ctx, volume)
+}
+
+func (a *applicationService) RemoveVolume(ctx context.Context, id uuid.UUID) error {
+	return a.volumeRepository.Delete(ctx, id)
+}
+
+// InternalAccess returns the access of an application to another, nil when it is not allowed
+func (a *applicationService) InternalAccess(ctx context.Context, applicationID, fromApplicationID uuid.UUID) (*types.InternalAccess, error) {
+	access, err := a.internalAccessRepository.Find(ctx, applicationID, fromApplicationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return access, nil
+}
+
+// InternalAccesses returns the accesses to an application and the ones from it
+func (a *applicationService) InternalAccesses(ctx context.Context, applicationID uuid.UUID) ([]*types.InternalAccess, error) {
+	return a.internalAccessRepository.FindAll(ctx, applicationID)
+}
+
+func (a *applicationService) SaveInternalAccess(ctx context.Context, access *types.InternalAccess) error {
+	return a.internalAccessRepository.Save(ctx, access)
+}
+
+func (a *applicationService) RemoveInternalAccess(ctx context.Context, id uuid.UUID) error {
+	return a.internalAccessRepository.Delete(ctx, id)
+}
+
 func (a *applicationService) GetDeployment(ctx context.Context, deploymentID uuid.UUID) (*types.Deployment, error) {
 	return a.deploymentRepository.FindByID(ctx, deploymentID)
 }
@@ -91,6 +374,8 @@ func (a *applicationService) FindCurrentlyActiveDeployments(ctx context.Context,
 	return actives, nil
 }
 
+// FindCurrentlyActiveDeploymentsEnv returns the active deployment of an instance type in an environment,
+// the named services are not returned for the backend
 func (a *applicationService) FindCurrentlyActiveDeploymentsEnv(ctx context.Context, applicationID uuid.UUID, instanceType types.InstanceType, environment string) (*types.Deployment, error) {
 	actives, err := a.FindCurrentlyActiveDeployments(ctx, applicationID, instanceType)
 	if err != nil {
@@ -98,7 +383,7 @@ func (a *applicationService) FindCurrentlyActiveDeploymentsEnv(ctx context.Conte
 	}
 
 	for _, next := range actives {
-		if strings.ToLower(environment) == strings.ToLower(next.Environment) {
+		if strings.ToLower(environment) == strings.ToLower(next.Environment) && next.Service == "" {
 			return next, nil
 		}
 	}
@@ -124,6 +409,9 @@ func (a *applicationService) CreateDeployment(ctx context.Context, param types.C
 		Port:          param.Port,
 		InstanceType:  param.InstanceType,
 		Identifier:    param.Identifier,
+		Processes:     param.Processes,
+		Service:       param.Service,
+		Settings:      param.Settings,
 	}
 
 	err := a.deploymentRepository.Save(ctx, deployment)
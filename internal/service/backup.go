@@ -11,16 +11,13 @@ import (
 	"github.com/robfig/cron/v3"
 	"github.com/samber/lo"
 	"go.uber.org/zap"
-	"os"
-	"os/signal"
 	"sarabi/internal/backup"
 	"sarabi/internal/database"
+	"sarabi/internal/eventbus"
 	"sarabi/internal/integrations/docker"
-	"sarabi/internal/storage"
 	"sarabi/internal/types"
 	"sarabi/logger"
 	"strings"
-	"syscall"
 	"time"
 )
 
@@ -30,6 +27,14 @@ type (
 		CreateBackupSettings(ctx context.Context, applicationID uuid.UUID, environment string, cronExpression string, updateRunner bool) error
 		Download(ctx context.Context, backupID uuid.UUID) (*types.File, error)
 		ListBackups(ctx context.Context, applicationID uuid.UUID) ([]*types.Backup, error)
+		RunNow(ctx context.Context, applicationID uuid.UUID, environment, identifier string) ([]*types.Backup, error)
+		SafetyBackup(ctx context.Context, applicationID uuid.UUID, environment string, trigger types.BackupTrigger) error
+		RemoveBackupSettings(ctx context.Context, applicationID uuid.UUID, environment string) error
+		DeleteBackups(ctx context.Context, applicationID uuid.UUID, environment string) error
+		EnablePITR(ctx context.Context, applicationID uuid.UUID, environment, cronExpression string) error
+		DisablePITR(ctx context.Context, applicationID uuid.UUID, environment string) error
+		Restore(ctx context.Context, applicationID uuid.UUID, environment string, target time.Time, identifier string) error
+		ResumePITR(ctx context.Context, applicationID uuid.UUID, environment string) error
 	}
 
 	backupService struct {
@@ -38,18 +43,27 @@ type (
 		secretService            SecretService
 		backupSettingsRepository database.BackupSettingsRepository
 		backupRepository         database.BackupRepository
+		pitrSettingsRepository   database.PITRSettingsRepository
+		walSegmentRepository     database.WALSegmentRepository
+		backupCopyRepository     database.BackupCopyRepository
+		eb                       eventbus.Bus
 		scheduler                gocron.Scheduler
 		started                  bool
+		// ctx lives as long as the server, the scheduled jobs run with it and never with the context of a request
+		ctx context.Context
 	}
 )
 
-func NewBackupService(dc docker.Docker, service ApplicationService,
-	ss SecretService, backupSettings database.BackupSettingsRepository, repository database.BackupRepository) (BackupService, error) {
-	scheduler, err := gocron.NewScheduler(
+// NewScheduler is the scheduler the backups and the jobs of the applications run on
+func NewScheduler() (gocron.Scheduler, error) {
+	return gocron.NewScheduler(
 		gocron.WithLimitConcurrentJobs(10, gocron.LimitModeWait))
-	if err != nil {
-		return nil, err
-	}
+}
+
+func NewBackupService(ctx context.Context, dc docker.Docker, service ApplicationService,
+	ss SecretService, backupSettings database.BackupSettingsRepository, repository database.BackupRepository,
+	pitrSettings database.PITRSettingsRepository, walSegments database.WALSegmentRepository,
+	copies database.BackupCopyRepository, eb eventbus.Bus, scheduler gocron.Scheduler) (BackupService, error) {
 	return &backupService{
 		dockerClient:             dc,
 		applicationService:       service,
@@ -57,105 +71,205 @@ func NewBackupService(dc docker.Docker, service ApplicationService,
 		backupSettingsRepository: backupSettings,
 		scheduler:                scheduler,
 		backupRepository:         repository,
+		pitrSettingsRepository:   pitrSettings,
+		walSegmentRepository:     walSegments,
+		backupCopyRepository:     copies,
+		eb:                       eb,
+		ctx:                      ctx,
 	}, nil
 }
 
 func (b backupService) Run(ctx context.Context) error {
+	// no backup runs before the server starts, the attempts left running were interrupted by the previous shutdown
+	if err := b.backupRepository.FailRunning(ctx, "interrupted by a restart of the server"); err != nil {
+		return err
+	}
+
 	all, err := b.backupSettingsRepository.FindAll(ctx)
 	if err != nil {
 		return err
 	}
 
 	for _, bc := range all {
-		if err := b.runScheduler(ctx, bc); err != nil {
+		if err := b.runScheduler(bc); err != nil {
+			return err
+		}
+	}
+
+	pitrSettings, err := b.pitrSettingsRepository.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, next := range pitrSettings {
+		if !next.Enabled {
+			continue
+		}
+
+		if err := b.schedulePITR(next); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (b backupService) run(ctx context.Context, settings *types.BackupSettings) error {
-	application, err := b.applicationService.Get(ctx, settings.ApplicationID)
+func (b backupService) RunNow(ctx context.Context, applicationID uuid.UUID, environment, identifier string) ([]*types.Backup, error) {
+	b.notify(identifier, eventbus.Info, "Starting backup: "+environment)
+	result, err := b.run(ctx, applicationID, environment, types.BackupTriggerManual, identifier)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	data, _ := json.Marshal(result)
+	b.eb.BroadcastWithData(identifier, eventbus.Complete, "Backup completed", data)
+	return result, nil
+}
+
+func (b backupService) run(
+	ctx context.Context,
+	applicationID uuid.UUID,
+	environment string,
+	trigger types.BackupTrigger,
+	identifier string) ([]*types.Backup, error) {
+	application, err := b.applicationService.Get(ctx, applicationID)
+	if err != nil {
+		return nil, err
 	}
 
-	allAppVars, err := b.secretService.FindAll(ctx, settings.ApplicationID)
+	allAppVars, err := b.secretService.FindAll(ctx, applicationID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	dbVars := lo.Filter(allAppVars, func(item *types.Secret, index int) bool {
 		return types.InstanceType(item.InstanceType) == types.InstanceTypeDatabase &&
-			item.Environment == settings.Environment
+			item.Environment == environment
 	})
-	storageCred, err := b.findStorageCredential(ctx, application)
+	destinations, err := b.destinations(ctx, application.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	param := backup.Params{
-		Environment:       settings.Environment,
-		DatabaseVars:      dbVars,
-		StorageCredential: storageCred,
-		Application:       application,
+		Environment:  environment,
+		DatabaseVars: dbVars,
+		Destinations: destinations,
+		Application:  application,
 	}
 
+	result := make([]*types.Backup, 0, len(application.StorageEngines))
 	for _, se := range application.StorageEngines {
-		var bk backup.Executor
-		switch se {
-		case types.StorageEnginePostgres:
-			bk = backup.NewPostgres(b.dockerClient)
-		case types.StorageEngineMysql:
-			bk = backup.NewMysql(b.dockerClient)
-		case types.StorageEngineMongo:
-			bk = backup.NewMongo(b.dockerClient)
-		case types.StorageEngineRedis:
-			bk = backup.NewRedis(b.dockerClient)
-		default:
-			return nil
+		bk, err := backup.NewExecutor(se, b.dockerClient)
+		if err != nil {
+			continue
 		}
-		result, err := bk.Execute(ctx, param)
+
+		params := param
+		external, err := b.applicationService.ExternalDatabase(ctx, application.ID, environment, se)
 		if err != nil {
-			logger.Error("backup returned error",
-				zap.Error(err),
-				zap.String("application", application.Name),
-				zap.Any("storage_engine", se))
-		} else {
-			logger.Info("backup completed",
-				zap.String("application", application.Name),
-				zap.String("environment", settings.Environment),
-				zap.String("engine", string(se)),
-				zap.String("ts", time.Now().String()))
-
-			newBackup := &types.Backup{
-				ID:            uuid.New(),
-				ApplicationID: application.ID,
-				Environment:   settings.Environment,
-				CreatedAt:     time.Now(),
-				StorageEngine: se,
-				Location:      result.Location,
-				StorageType:   string(result.StorageType),
-				Size:          result.Size,
-			}
-			if err := b.backupRepository.Save(ctx, newBackup); err != nil {
-				logger.Error("failed to save backup", zap.Error(err))
-			}
+			return result, err
 		}
+		if external != nil {
+			params.Sidecar = external.Image
+		}
+
+		record := &types.Backup{
+			ApplicationID: application.ID,
+			Environment:   environment,
+			StorageEngine: se,
+			Trigger:       trigger,
+			Kind:          types.BackupKindDump,
+		}
+		b.execute(ctx, bk, params, record, identifier)
+		result = append(result, record)
 	}
 
-	return nil
+	// the volumes of the backend are backed up with the databases of the environment, as tar snapshots
+	volumes, err := b.applicationService.Volumes(ctx, application.ID, environment)
+	if err != nil {
+		return result, err
+	}
+	for _, next := range volumes {
+		params := param
+		params.Volume = next
+		record := &types.Backup{
+			ApplicationID: application.ID,
+			Environment:   environment,
+			Volume:        next.Name,
+			Trigger:       trigger,
+			Kind:          types.BackupKindVolume,
+		}
+		b.execute(ctx, backup.NewVolume(b.dockerClient), params, record, identifier)
+		result = append(result, record)
+	}
+
+	return result, nil
+}
+
+// execute runs an executor and records the outcome of the attempt in record
+func (b backupService) execute(ctx context.Context, bk backup.Executor, params backup.Params, record *types.Backup, identifier string) {
+	// the attempt is saved before it runs so that a crash mid-way still leaves a trace of it
+	record.ID = uuid.New()
+	record.CreatedAt = time.Now()
+	record.Status = types.BackupStatusRunning
+	if err := b.backupRepository.Save(ctx, record); err != nil {
+		logger.Error("failed to save backup", zap.Error(err))
+	}
+
+	b.notify(identifier, eventbus.Info, "Backing up "+record.Source()+"...")
+	executed, err := bk.Execute(ctx, params)
+	record.Duration = time.Since(record.CreatedAt)
+	if err != nil {
+		logger.Error("backup returned error",
+			zap.Error(err),
+			zap.String("application", params.Application.Name),
+			zap.String("source", record.Source()))
+
+		record.Status = types.BackupStatusFailed
+		record.Error = err.Error()
+		b.notify(identifier, eventbus.Error, fmt.Sprintf("Backup failed: %s: %s", record.Source(), err.Error()))
+	} else {
+		logger.Info("backup completed",
+			zap.String("application", params.Application.Name),
+			zap.String("environment", params.Environment),
+			zap.String("source", record.Source()),
+			zap.String("ts", time.Now().String()))
+
+		record.Status = types.BackupStatusSucceeded
+		record.Location = executed.Location
+		record.Size = executed.Size
+		record.StorageType, record.Error = b.saveCopies(ctx, record.ID, executed.Location, executed.Copies)
+		b.notify(identifier, eventbus.Success, "Backup completed: "+record.Source())
+	}
+
+	// the outcome is saved even when ctx ran out, otherwise the attempt would stay running forever
+	if err := b.backupRepository.Save(context.WithoutCancel(ctx), record); err != nil {
+		logger.Error("failed to save backup", zap.Error(err))
+	}
 }
 
 func (b backupService) runBG(ctx context.Context, settings *types.BackupSettings) error {
 	go func() {
-		if err := b.run(ctx, settings); err != nil {
+		if _, err := b.run(ctx, settings.ApplicationID, settings.Environment, types.BackupTriggerSchedule, ""); err != nil {
 			logger.Info("run failed", zap.Error(err))
 		}
 	}()
 	return nil
 }
 
-func (b backupService) runScheduler(ctx context.Context, bc *types.BackupSettings) error {
+// notify sends backup progress to a client following a manual backup.
+// scheduled runs have no identifier as nobody is listening for them
+func (b backupService) notify(identifier string, evType eventbus.Type, message string) {
+	if identifier == "" {
+		return
+	}
+	b.eb.Broadcast(identifier, evType, message)
+}
+
+func (b backupService) runScheduler(bc *types.BackupSettings) error {
 	job, err := b.scheduler.NewJob(
 		gocron.CronJob(bc.CronExpression, false),
-		gocron.NewTask(b.runBG, ctx, bc),
+		gocron.NewTask(b.runBG, b.ctx, bc),
 		gocron.WithIdentifier(bc.ID))
 	if err != nil {
 		return err
@@ -206,7 +320,7 @@ func (b backupService) CreateBackupSettings(
 		return err
 	}
 
-	return b.runScheduler(ctx, backupSettings)
+	return b.runScheduler(backupSettings)
 }
 
 func (b backupService) updateBackupSettings(ctx context.Context, settings *types.BackupSettings, expression string) error {
@@ -214,12 +328,10 @@ func (b backupService) updateBackupSettings(ctx context.Context, settings *types
 		return err
 	}
 
-	ctx, _ = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-
 	err := b.scheduler.RemoveJob(settings.ID)
 	if errors.Is(err, gocron.ErrJobNotFound) {
 		settings.CronExpression = expression
-		return b.runScheduler(ctx, settings)
+		return b.runScheduler(settings)
 	}
 
 	if err != nil {
@@ -227,7 +339,7 @@ func (b backupService) updateBackupSettings(ctx context.Context, settings *types
 	}
 
 	settings.CronExpression = expression
-	return b.runScheduler(ctx, settings)
+	return b.runScheduler(settings)
 }
 
 func (b backupService) Download(ctx context.Context, backupID uuid.UUID) (*types.File, error) {
@@ -236,49 +348,138 @@ func (b backupService) Download(ctx context.Context, backupID uuid.UUID) (*types
 		return nil, err
 	}
 
-	cred, crederr := b.findStorageCredential(ctx, bk.Application)
-	var st storage.Storage
-	switch storage.Type(bk.StorageType) {
-	case storage.TypeFS:
-		st = storage.NewFileStorage()
-	case storage.TypeS3:
-		if cred == nil {
-			return nil, errors2.Wrap(crederr, "failed to find object storage credential")
+	if !bk.Succeeded() {
+		return nil, fmt.Errorf("backup %s has no file to download: status=%s", bk.ID, bk.Status)
+	}
+
+	copies, err := b.copiesOf(ctx, bk.ID, bk.StorageType, bk.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.open(ctx, bk.ApplicationID, copies)
+}
+
+// SafetyBackup backs up the databases of an environment before they are destroyed or replaced,
+// it fails unless every one of them was backed up
+func (b backupService) SafetyBackup(ctx context.Context, applicationID uuid.UUID, environment string, trigger types.BackupTrigger) error {
+	result, err := b.run(ctx, applicationID, environment, trigger, "")
+	if err != nil {
+		return err
+	}
+
+	for _, next := range result {
+		if !next.Succeeded() {
+			return fmt.Errorf("%s backup of %s failed: %s", trigger, next.Source(), next.Error)
 		}
-		st, err = storage.NewObjectStorage(*cred)
-		if err != nil {
-			return nil, err
+	}
+	return nil
+}
+
+// RemoveBackupSettings stops the scheduled backups of an environment and deletes its settings.
+// an empty environment removes the settings of all environments of the application
+func (b backupService) RemoveBackupSettings(ctx context.Context, applicationID uuid.UUID, environment string) error {
+	settings, err := b.backupSettingsRepository.FindByApplicationID(ctx, applicationID)
+	if err != nil {
+		return errors2.Wrap(err, "failed to fetch backup settings")
+	}
+
+	for _, next := range settings {
+		if environment != "" && !strings.EqualFold(next.Environment, environment) {
+			continue
 		}
-	default:
-		return nil, errors.New("unknown storage type")
+
+		if err := b.removeSettings(ctx, next); err != nil {
+			return err
+		}
+	}
+
+	pitrSettings, err := b.pitrSettingsRepository.FindAll(ctx)
+	if err != nil {
+		return errors2.Wrap(err, "failed to fetch PITR settings")
 	}
 
-	return st.Get(ctx, bk.Location)
+	for _, next := range pitrSettings {
+		if next.ApplicationID != applicationID ||
+			(environment != "" && !strings.EqualFold(next.Environment, environment)) {
+			continue
+		}
+
+		if err := b.removePITRSettings(ctx, applicationID, next.Environment); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (b backupService) ListBackups(ctx context.Context, applicationID uuid.UUID) ([]*types.Backup, error) {
-	return b.backupRepository.FindByApplicationID(ctx, applicationID)
+func (b backupService) removeSettings(ctx context.Context, settings *types.BackupSettings) error {
+	err := b.scheduler.RemoveJob(settings.ID)
+	if err != nil && !errors.Is(err, gocron.ErrJobNotFound) {
+		return err
+	}
+
+	logger.Info("backup job removed",
+		zap.Any("application_id", settings.ApplicationID),
+		zap.String("environment", settings.Environment))
+	return b.backupSettingsRepository.Delete(ctx, settings.ID)
 }
 
-func (b backupService) findStorageCredential(ctx context.Context, application *types.Application) (*types.StorageCredentials, error) {
-	credentials, err := b.secretService.FindApplicationServerConfigs(ctx, application.ID)
+// DeleteBackups removes the backup files and records of an environment.
+// an empty environment deletes the backups of all environments of the application
+func (b backupService) DeleteBackups(ctx context.Context, applicationID uuid.UUID, environment string) error {
+	backups, err := b.backupRepository.FindByApplicationID(ctx, applicationID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	objectStorageConfig := lo.Filter(credentials, func(item *types.ServerConfig, index int) bool {
-		return item.Name == types.ServerConfigObjectStorage
-	})
-	if len(objectStorageConfig) == 0 {
-		return nil, errors.New("no object storage configured")
+	for _, next := range backups {
+		if environment != "" && !strings.EqualFold(next.Environment, environment) {
+			continue
+		}
+
+		if err := b.deleteCopies(ctx, applicationID, next.ID, next.StorageType, next.Location); err != nil {
+			return err
+		}
+
+		if err := b.backupRepository.Delete(ctx, next.ID); err != nil {
+			return err
+		}
+	}
+
+	segments, err := b.walSegmentRepository.FindByApplicationID(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	for _, next := range segments {
+		if environment != "" && !strings.EqualFold(next.Environment, environment) {
+			continue
+		}
+
+		if err := b.deleteCopies(ctx, applicationID, next.ID, next.StorageType, next.Location); err != nil {
+			return err
+		}
+
+		if err := b.walSegmentRepository.Delete(ctx, next.ID); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	value := objectStorageConfig[0]
-	cred := &types.StorageCredentials{}
-	if err := json.Unmarshal([]byte(value.Value), cred); err != nil {
+func (b backupService) ListBackups(ctx context.Context, applicationID uuid.UUID) ([]*types.Backup, error) {
+	backups, err := b.backupRepository.FindByApplicationID(ctx, applicationID)
+	if err != nil {
 		return nil, err
 	}
-	return cred, nil
+
+	for _, next := range backups {
+		next.Copies, err = b.backupCopyRepository.FindByBackupID(ctx, next.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return backups, nil
 }
 
 func (b backupService) parseExpression(cronExpression string) error {
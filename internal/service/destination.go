@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	errors2 "github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"sarabi/internal/storage"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"sort"
+	"strings"
+	"time"
+)
+
+const destinationPingTimeout = 5 * time.Second
+
+// destinations returns where the backups of an application are replicated to:
+// the local file system for fast restores plus every storage configured for the application
+func (b backupService) destinations(ctx context.Context, applicationID uuid.UUID) ([]storage.Destination, error) {
+	configs, err := b.secretService.FindApplicationServerConfigs(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []storage.Destination{storage.LocalDestination()}
+	for _, next := range configs {
+		if _, ok := storage.LookupConfig(next.Name); !ok {
+			continue
+		}
+
+		destination, err := configDestination(next)
+		if err != nil {
+			logger.Warn("skipping invalid backup destination",
+				zap.Any("id", next.ID),
+				zap.Error(err))
+			continue
+		}
+		result = append(result, destination)
+	}
+	return result, nil
+}
+
+func configDestination(config *types.ServerConfig) (storage.Destination, error) {
+	driver, ok := storage.LookupConfig(config.Name)
+	if !ok {
+		return storage.Destination{}, errors.New("no storage driver for config: " + config.Name)
+	}
+
+	st, err := driver.Open([]byte(config.Value))
+	if err != nil {
+		return storage.Destination{}, err
+	}
+
+	return storage.Destination{
+		ID:      config.ID,
+		Name:    fmt.Sprintf("%s(%s)", driver.Type, config.Provider),
+		Type:    driver.Type,
+		Storage: st,
+	}, nil
+}
+
+// saveCopies records the outcome of a replication. it returns the storage type of the first successful copy
+// and a summary of the destinations that failed
+func (b backupService) saveCopies(ctx context.Context, ownerID uuid.UUID, location string, copies []storage.Copy) (string, string) {
+	storageType := ""
+	failures := make([]string, 0)
+	for _, next := range copies {
+		record := &types.BackupCopy{
+			ID:            uuid.New(),
+			BackupID:      ownerID,
+			DestinationID: next.Destination.ID,
+			Destination:   next.Destination.Name,
+			StorageType:   next.Destination.Type.String(),
+			Location:      location,
+			Status:        types.BackupStatusSucceeded,
+			CreatedAt:     time.Now(),
+		}
+		if next.Error != nil {
+			record.Status = types.BackupStatusFailed
+			record.Error = next.Error.Error()
+			failures = append(failures, next.Destination.Name+": "+next.Error.Error())
+		} else if storageType == "" {
+			storageType = record.StorageType
+		}
+
+		if err := b.backupCopyRepository.Save(ctx, record); err != nil {
+			logger.Error("failed to save backup copy", zap.Error(err))
+		}
+	}
+	return storageType, strings.Join(failures, "; ")
+}
+
+// copiesOf returns the copies of a backup file. backups taken before replication existed
+// have no copy recorded, their single location is returned instead
+func (b backupService) copiesOf(ctx context.Context, ownerID uuid.UUID, storageType, location string) ([]*types.BackupCopy, error) {
+	copies, err := b.backupCopyRepository.FindByBackupID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(copies) > 0 || location == "" {
+		return copies, nil
+	}
+
+	return []*types.BackupCopy{{
+		BackupID:    ownerID,
+		StorageType: storageType,
+		Location:    location,
+		Status:      types.BackupStatusSucceeded,
+	}}, nil
+}
+
+func (b backupService) storageOf(ctx context.Context, applicationID uuid.UUID, c *types.BackupCopy) (storage.Storage, error) {
+	if storage.Type(c.StorageType) == storage.TypeFS {
+		return storage.NewFileStorage(), nil
+	}
+
+	configs, err := b.secretService.FindApplicationServerConfigs(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	config, found := lo.Find(configs, func(item *types.ServerConfig) bool {
+		// copies made before replication existed don't know their destination, they went to the only object storage
+		if c.DestinationID == uuid.Nil {
+			return item.Name == types.ServerConfigObjectStorage
+		}
+		return item.ID == c.DestinationID
+	})
+	if !found {
+		return nil, errors.New("backup destination no longer configured: " + c.Destination)
+	}
+
+	destination, err := configDestination(config)
+	if err != nil {
+		return nil, errors2.Wrap(err, "invalid storage credential")
+	}
+	return destination.Storage, nil
+}
+
+// open returns the backup file from the fastest healthy copy, see openFastest
+func (b backupService) open(ctx context.Context, applicationID uuid.UUID, copies []*types.BackupCopy) (*types.File, error) {
+	stored := make([]storedCopy, 0, len(copies))
+	for _, next := range copies {
+		if next.Status == types.BackupStatusFailed {
+			continue
+		}
+
+		st, err := b.storageOf(ctx, applicationID, next)
+		if err != nil {
+			logger.Warn("skipping backup copy", zap.String("destination", next.Destination), zap.Error(err))
+			continue
+		}
+		stored = append(stored, storedCopy{copy: next, storage: st})
+	}
+	return openFastest(ctx, stored)
+}
+
+// storedCopy is a copy of a backup file with the storage it was saved in
+type storedCopy struct {
+	copy    *types.BackupCopy
+	storage storage.Storage
+}
+
+// openFastest reads a backup file from the copy whose storage answers a ping the fastest.
+// unreachable storages are skipped, the next fastest copy is read when reading one fails
+func openFastest(ctx context.Context, copies []storedCopy) (*types.File, error) {
+	type candidate struct {
+		storedCopy
+		latency time.Duration
+	}
+
+	candidates := make([]candidate, 0, len(copies))
+	for _, next := range copies {
+		pingCtx, cancel := context.WithTimeout(ctx, destinationPingTimeout)
+		start := time.Now()
+		err := next.storage.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			logger.Warn("backup destination unhealthy", zap.String("destination", next.copy.Destination), zap.Error(err))
+			continue
+		}
+		candidates = append(candidates, candidate{storedCopy: next, latency: time.Since(start)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].latency < candidates[j].latency
+	})
+
+	var lastErr error = errors.New("no healthy copy of the backup is available")
+	for _, next := range candidates {
+		file, err := next.storage.Get(ctx, next.copy.Location)
+		if err == nil {
+			return file, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// deleteCopies removes a backup file from every destination it was replicated to
+func (b backupService) deleteCopies(ctx context.Context, applicationID, ownerID uuid.UUID, storageType, location string) error {
+	copies, err := b.copiesOf(ctx, ownerID, storageType, location)
+	if err != nil {
+		return err
+	}
+
+	for _, next := range copies {
+		if next.Status != types.BackupStatusFailed {
+			st, err := b.storageOf(ctx, applicationID, next)
+			if err != nil {
+				return err
+			}
+			if err := st.Delete(ctx, next.Location); err != nil {
+				return errors2.Wrap(err, "failed to delete backup file: "+next.Location)
+			}
+		}
+
+		if next.ID != uuid.Nil {
+			if err := b.backupCopyRepository.Delete(ctx, next.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
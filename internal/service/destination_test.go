@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+// latencyStorage answers a ping after latency and serves content, errors fail the calls they are set for
+type latencyStorage struct {
+	latency time.Duration
+	pingErr error
+	getErr  error
+	content string
+}
+
+func (s latencyStorage) Save(context.Context, string, types.File) error {
+	return nil
+}
+
+func (s latencyStorage) Get(context.Context, string) (*types.File, error) {
+	if s.getErr != nil {
+		return nil, s.getErr
+	}
+	return &types.File{Content: io.NopCloser(strings.NewReader(s.content))}, nil
+}
+
+func (s latencyStorage) Ping(context.Context) error {
+	time.Sleep(s.latency)
+	return s.pingErr
+}
+
+func (s latencyStorage) Delete(context.Context, string) error {
+	return nil
+}
+
+func TestOpenFastest(t *testing.T) {
+	require.NoError(t, logger.InitLogger("test"))
+	stored := func(name string, st latencyStorage) storedCopy {
+		return storedCopy{copy: &types.BackupCopy{Destination: name, Location: "backups/app-prod/postgres.sql"}, storage: st}
+	}
+	slow := stored("slow", latencyStorage{latency: 50 * time.Millisecond, content: "slow"})
+	fast := stored("fast", latencyStorage{content: "fast"})
+	down := stored("down", latencyStorage{pingErr: errors.New("connection refused"), content: "down"})
+	broken := stored("broken", latencyStorage{getErr: errors.New("object not found")})
+
+	tests := []struct {
+		name        string
+		copies      []storedCopy
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "fastest copy",
+			copies:   []storedCopy{slow, fast},
+			expected: "fast",
+		},
+		{
+			name:     "unreachable copy is skipped",
+			copies:   []storedCopy{down, slow},
+			expected: "slow",
+		},
+		{
+			name:     "next copy when reading fails",
+			copies:   []storedCopy{slow, broken},
+			expected: "slow",
+		},
+		{
+			name:        "every copy fails to read",
+			copies:      []storedCopy{broken},
+			expectedErr: "object not found",
+		},
+		{
+			name:        "no healthy copy",
+			copies:      []storedCopy{down},
+			expectedErr: "no healthy copy of the backup is available",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := openFastest(context.Background(), tt.copies)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			content, _ := io.ReadAll(file.Content)
+			assert.Equal(t, tt.expected, string(content))
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"sarabi/internal/database"
+	"sarabi/internal/types"
+	"sarabi/logger"
+)
+
+// jobRunsKept is how many runs of a job are kept in its history
+const jobRunsKept = 100
+
+type (
+	JobService interface {
+		Save(ctx context.Context, job *types.Job) error
+		Job(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Job, error)
+		Jobs(ctx context.Context, applicationID uuid.UUID) ([]*types.Job, error)
+		List(ctx context.Context) ([]*types.Job, error)
+		Remove(ctx context.Context, job *types.Job) error
+		Schedule(ctx context.Context, job *types.Job, task func(ctx context.Context, job *types.Job)) error
+		ScheduleTask(ctx context.Context, id uuid.UUID, expression string, task func(ctx context.Context)) error
+		SaveRun(ctx context.Context, run *types.JobRun) error
+		Runs(ctx context.Context, jobID uuid.UUID, limit int) ([]*types.JobRun, error)
+	}
+
+	jobService struct {
+		jobRepository    database.JobRepository
+		jobRunRepository database.JobRunRepository
+		scheduler        gocron.Scheduler
+	}
+)
+
+// NewJobService schedules the jobs on the scheduler of the backups
+func NewJobService(jr database.JobRepository, jrr database.JobRunRepository, scheduler gocron.Scheduler) JobService {
+	return &jobService{
+		jobRepository:    jr,
+		jobRunRepository: jrr,
+		scheduler:        scheduler,
+	}
+}
+
+func (j *jobService) Save(ctx context.Context, job *types.Job) error {
+	return j.jobRepository.Save(ctx, job)
+}
+
+// Job returns the job of an environment, nil when there is none
+func (j *jobService) Job(ctx context.Context, applicationID uuid.UUID, environment, name string) (*types.Job, error) {
+	job, err := j.jobRepository.Find(ctx, applicationID, environment, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Jobs returns the jobs of an application with the time they are due next
+func (j *jobService) Jobs(ctx context.Context, applicationID uuid.UUID) ([]*types.Job, error) {
+	jobs, err := j.jobRepository.FindAll(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduled := make(map[uuid.UUID]gocron.Job)
+	for _, next := range j.scheduler.Jobs() {
+		scheduled[next.ID()] = next
+	}
+	for _, job := range jobs {
+		if next, ok := scheduled[job.ID]; ok {
+			if nextRun, err := next.NextRun(); err == nil {
+				job.NextRun = &nextRun
+			}
+		}
+	}
+	return jobs, nil
+}
+
+func (j *jobService) List(ctx context.Context) ([]*types.Job, error) {
+	return j.jobRepository.List(ctx)
+}
+
+// Remove stops scheduling a job and deletes it with its history, a run in progress is left to complete
+func (j *jobService) Remove(ctx context.Context, job *types.Job) error {
+	if err := j.scheduler.RemoveJob(job.ID); err != nil && !errors.Is(err, gocron.ErrJobNotFound) {
+		return err
+	}
+	if err := j.jobRunRepository.DeleteAll(ctx, job.ID); err != nil {
+		return err
+	}
+	return j.jobRepository.Delete(ctx, job.ID)
+}
+
+// Schedule runs task every time the job is due, the previous schedule of the job is replaced
+func (j *jobService) Schedule(ctx context.Context, job *types.Job, task func(ctx context.Context, job *types.Job)) error {
+	if err := j.scheduler.RemoveJob(job.ID); err != nil && !errors.Is(err, gocron.ErrJobNotFound) {
+		return err
+	}
+
+	_, err := j.scheduler.NewJob(
+		gocron.CronJob(job.Schedule, false),
+		gocron.NewTask(task, ctx, job),
+		gocron.WithIdentifier(job.ID))
+	if err != nil {
+		return err
+	}
+
+	logger.Info("job queued",
+		zap.String("name", job.Name),
+		zap.String("expression", job.Schedule),
+		zap.String("environment", job.Environment))
+	j.scheduler.Start()
+	return nil
+}
+
+// ScheduleTask runs a task of the server every time expression is due, the previous schedule of id is replaced.
+// a run is skipped while the previous one has not returned
+func (j *jobService) ScheduleTask(ctx context.Context, id uuid.UUID, expression string, task func(ctx context.Context)) error {
+	if err := j.scheduler.RemoveJob(id); err != nil && !errors.Is(err, gocron.ErrJobNotFound) {
+		return err
+	}
+
+	_, err := j.scheduler.NewJob(
+		gocron.CronJob(expression, false),
+		gocron.NewTask(task, ctx),
+		gocron.WithIdentifier(id),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule))
+	if err != nil {
+		return err
+	}
+	j.scheduler.Start()
+	return nil
+}
+
+// SaveRun records a run of a job, the oldest runs are deleted once it has more than jobRunsKept
+func (j *jobService) SaveRun(ctx context.Context, run *types.JobRun) error {
+	if err := j.jobRunRepository.Save(ctx, run); err != nil {
+		return err
+	}
+	return j.jobRunRepository.Prune(ctx, run.JobID, jobRunsKept)
+}
+
+func (j *jobService) Runs(ctx context.Context, jobID uuid.UUID, limit int) ([]*types.JobRun, error) {
+	return j.jobRunRepository.FindAll(ctx, jobID, limit)
+}
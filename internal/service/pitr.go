@@ -0,0 +1,476 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	errors2 "github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"sarabi/internal/backup"
+	"sarabi/internal/eventbus"
+	"sarabi/internal/storage"
+	"sarabi/internal/types"
+	"sarabi/logger"
+	"time"
+)
+
+const (
+	defaultBaseBackupInterval = "0 0 * * *"
+	walShippingInterval       = time.Minute
+	// baseBackupsKept is how many base backups an environment keeps, the WAL archived before the oldest of them is dropped
+	baseBackupsKept = 7
+)
+
+// EnablePITR turns on WAL archiving for the postgres database of an environment and takes the first base backup.
+// calling it on an environment that already has PITR enabled only updates the base backup schedule
+func (b backupService) EnablePITR(ctx context.Context, applicationID uuid.UUID, environment, cronExpression string) error {
+	if cronExpression == "" {
+		cronExpression = defaultBaseBackupInterval
+	}
+	if err := b.parseExpression(cronExpression); err != nil {
+		return err
+	}
+
+	existing, err := b.pitrSettingsRepository.Find(ctx, applicationID, environment)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if err == nil && existing.Enabled {
+		existing.BaseBackupCronExpression = cronExpression
+		if err := b.pitrSettingsRepository.Save(ctx, existing); err != nil {
+			return err
+		}
+		b.unschedulePITR(existing)
+		return b.schedulePITR(existing)
+	}
+
+	params, err := b.pitrParams(ctx, applicationID, environment)
+	if err != nil {
+		return err
+	}
+
+	if err := backup.NewPostgresPITR(b.dockerClient).Enable(ctx, params); err != nil {
+		return err
+	}
+
+	settings := &types.PITRSettings{
+		ID:                       uuid.New(),
+		ApplicationID:            applicationID,
+		Environment:              environment,
+		Enabled:                  true,
+		BaseBackupCronExpression: cronExpression,
+		CreatedAt:                time.Now(),
+	}
+	if existing != nil && existing.ID != uuid.Nil {
+		settings.ID = existing.ID
+		settings.CreatedAt = existing.CreatedAt
+	}
+	if err := b.pitrSettingsRepository.Save(ctx, settings); err != nil {
+		return err
+	}
+
+	// WAL is only useful on top of a base backup taken after archiving started
+	if _, err := b.baseBackup(ctx, settings, types.BackupTriggerManual); err != nil {
+		return err
+	}
+	return b.schedulePITR(settings)
+}
+
+func (b backupService) DisablePITR(ctx context.Context, applicationID uuid.UUID, environment string) error {
+	settings, err := b.pitrSettingsRepository.Find(ctx, applicationID, environment)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	params, err := b.pitrParams(ctx, applicationID, environment)
+	if err != nil {
+		return err
+	}
+
+	b.unschedulePITR(settings)
+	// ship what is left so the archive stays usable up to this point
+	b.shipWAL(ctx, settings)
+	if err := backup.NewPostgresPITR(b.dockerClient).Disable(ctx, params); err != nil {
+		return err
+	}
+	return b.pitrSettingsRepository.Delete(ctx, settings.ID)
+}
+
+// ResumePITR turns WAL archiving back on after the postgres data of an environment was replaced, e.g by a
+// major upgrade. a new base backup is taken since the previous ones can't be replayed on the new data
+func (b backupService) ResumePITR(ctx context.Context, applicationID uuid.UUID, environment string) error {
+	settings, err := b.pitrSettingsRepository.Find(ctx, applicationID, environment)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !settings.Enabled {
+		return nil
+	}
+
+	params, err := b.pitrParams(ctx, applicationID, environment)
+	if err != nil {
+		return err
+	}
+
+	if err := backup.NewPostgresPITR(b.dockerClient).Enable(ctx, params); err != nil {
+		return err
+	}
+
+	_, err = b.baseBackup(ctx, settings, types.BackupTriggerManual)
+	return err
+}
+
+// Restore recovers the postgres database of an environment to the given point in time
+// from the latest base backup that completed before it and the WAL archived since
+func (b backupService) Restore(ctx context.Context, applicationID uuid.UUID, environment string, target time.Time, identifier string) error {
+	if target.After(time.Now()) {
+		return errors.New("cannot restore to a point in the future")
+	}
+
+	settings, err := b.pitrSettingsRepository.Find(ctx, applicationID, environment)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("point-in-time recovery is not enabled for environment: " + environment)
+	}
+	if err != nil {
+		return err
+	}
+
+	params, err := b.pitrParams(ctx, applicationID, environment)
+	if err != nil {
+		return err
+	}
+
+	backups, err := b.backupRepository.FindByApplicationID(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+
+	base, err := restoreBase(backups, environment, target)
+	if err != nil {
+		return err
+	}
+
+	b.notify(identifier, eventbus.Info, "Shipping pending WAL...")
+	b.shipWAL(ctx, settings)
+
+	segments, err := b.walSegmentRepository.FindSince(ctx, applicationID, environment, base.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := checkWALReaches(segments, target); err != nil {
+		return err
+	}
+
+	stagingDir := filepath.Join(storage.BackupTempDir, uuid.NewString())
+	defer func() {
+		_ = os.RemoveAll(stagingDir)
+	}()
+
+	b.notify(identifier, eventbus.Info, fmt.Sprintf("Fetching base backup of %s and %d WAL segment(s)...", base.CreatedAt.Format(time.DateTime), len(segments)))
+	if err := b.stage(ctx, applicationID, base.ID, base.StorageType, base.Location, stagingDir, "base.tar.gz"); err != nil {
+		return err
+	}
+	for _, next := range segments {
+		if err := b.stage(ctx, applicationID, next.ID, next.StorageType, next.Location, stagingDir, filepath.Join("wal", next.Name)); err != nil {
+			return err
+		}
+	}
+
+	b.unschedulePITR(settings)
+	defer func() {
+		if err := b.schedulePITR(settings); err != nil {
+			logger.Error("failed to reschedule PITR jobs", zap.Error(err))
+		}
+	}()
+
+	b.notify(identifier, eventbus.Info, "Replaying WAL up to "+target.Format(time.DateTime)+", the database is unavailable until this completes...")
+	result, err := backup.NewPostgresPITR(b.dockerClient).Restore(ctx, backup.RestoreParams{
+		Environment:  environment,
+		DatabaseVars: params.DatabaseVars,
+		Application:  params.Application,
+		StagingDir:   stagingDir,
+		TargetTime:   target,
+	})
+	if err != nil {
+		return err
+	}
+
+	b.notify(identifier, eventbus.Info, "Previous data is kept in volume: "+result.SafetyVolume)
+	// the restored database runs on a new timeline, older base backups can't be used past this point
+	if _, err := b.baseBackup(ctx, settings, types.BackupTriggerManual); err != nil {
+		b.notify(identifier, eventbus.Error, "Failed to take a base backup after restore: "+err.Error())
+	}
+
+	b.eb.Broadcast(identifier, eventbus.Complete, "Restored to "+target.Format(time.DateTime))
+	return nil
+}
+
+// restoreBase returns the latest base backup of an environment that completed before target, backups are ordered newest first
+func restoreBase(backups []*types.Backup, environment string, target time.Time) (*types.Backup, error) {
+	base, found := lo.Find(backups, func(item *types.Backup) bool {
+		return item.Environment == environment && item.IsBase() && item.Succeeded() && !item.FinishedAt().After(target)
+	})
+	if !found {
+		return nil, fmt.Errorf("no base backup completed before %s", target.Format(time.DateTime))
+	}
+	return base, nil
+}
+
+// checkWALReaches makes sure the segments shipped since the base backup, ordered oldest first, replay up to target
+func checkWALReaches(segments []*types.WALSegment, target time.Time) error {
+	if len(segments) == 0 || segments[len(segments)-1].ArchivedAt.Before(target) {
+		return fmt.Errorf("the archived WAL does not reach %s yet, try again in a minute", target.Format(time.DateTime))
+	}
+	return nil
+}
+
+func (b backupService) stage(ctx context.Context, applicationID, ownerID uuid.UUID, storageType, location, dir, name string) error {
+	copies, err := b.copiesOf(ctx, ownerID, storageType, location)
+	if err != nil {
+		return err
+	}
+
+	file, err := b.open(ctx, applicationID, copies)
+	if err != nil {
+		return errors2.Wrap(err, "failed to fetch "+location)
+	}
+	defer file.Content.Close()
+
+	return backup.StageFile(dir, name, file)
+}
+
+func (b backupService) baseBackup(ctx context.Context, settings *types.PITRSettings, trigger types.BackupTrigger) (*types.Backup, error) {
+	params, err := b.pitrParams(ctx, settings.ApplicationID, settings.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &types.Backup{
+		ID:            uuid.New(),
+		ApplicationID: settings.ApplicationID,
+		Environment:   settings.Environment,
+		CreatedAt:     time.Now(),
+		StorageEngine: types.StorageEnginePostgres,
+		Status:        types.BackupStatusRunning,
+		Trigger:       trigger,
+		Kind:          types.BackupKindBase,
+	}
+	if err := b.backupRepository.Save(ctx, record); err != nil {
+		logger.Error("failed to save backup", zap.Error(err))
+	}
+
+	executed, err := backup.NewPostgresPITR(b.dockerClient).BaseBackup(ctx, params)
+	record.Duration = time.Since(record.CreatedAt)
+	if err != nil {
+		record.Status = types.BackupStatusFailed
+		record.Error = err.Error()
+	} else {
+		record.Status = types.BackupStatusSucceeded
+		record.Location = executed.Location
+		record.Size = executed.Size
+		record.StorageType, record.Error = b.saveCopies(ctx, record.ID, executed.Location, executed.Copies)
+	}
+
+	if err := b.backupRepository.Save(ctx, record); err != nil {
+		logger.Error("failed to save backup", zap.Error(err))
+	}
+
+	if err == nil {
+		if err := b.prunePITR(ctx, settings); err != nil {
+			logger.Warn("failed to prune PITR backups", zap.Error(err))
+		}
+	}
+	return record, err
+}
+
+// prunePITR drops the base backups of an environment past the last baseBackupsKept and the WAL segments
+// archived before the oldest base backup kept, no restore can start from them anymore
+func (b backupService) prunePITR(ctx context.Context, settings *types.PITRSettings) error {
+	backups, err := b.backupRepository.FindByApplicationID(ctx, settings.ApplicationID)
+	if err != nil {
+		return err
+	}
+
+	segments, err := b.walSegmentRepository.FindByApplicationID(ctx, settings.ApplicationID)
+	if err != nil {
+		return err
+	}
+
+	expiredBackups, expiredSegments := expiredPITR(backups, segments, settings.Environment, baseBackupsKept)
+	for _, next := range expiredBackups {
+		if err := b.deleteCopies(ctx, settings.ApplicationID, next.ID, next.StorageType, next.Location); err != nil {
+			return err
+		}
+		if err := b.backupRepository.Delete(ctx, next.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, next := range expiredSegments {
+		if err := b.deleteCopies(ctx, settings.ApplicationID, next.ID, next.StorageType, next.Location); err != nil {
+			return err
+		}
+		if err := b.walSegmentRepository.Delete(ctx, next.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiredPITR returns the base backups of an environment older than the last keep that succeeded, and the WAL
+// segments archived before the oldest of those. backups are ordered newest first. nothing expires until a base backup succeeded
+func expiredPITR(backups []*types.Backup, segments []*types.WALSegment, environment string, keep int) ([]*types.Backup, []*types.WALSegment) {
+	bases := lo.Filter(backups, func(item *types.Backup, index int) bool {
+		return item.Environment == environment && item.IsBase()
+	})
+	kept := lo.Filter(bases, func(item *types.Backup, index int) bool {
+		return item.Succeeded()
+	})
+	if len(kept) == 0 {
+		return nil, nil
+	}
+
+	oldest := kept[min(keep, len(kept))-1]
+	expiredBackups := lo.Filter(bases, func(item *types.Backup, index int) bool {
+		return item.CreatedAt.Before(oldest.CreatedAt)
+	})
+	expiredSegments := lo.Filter(segments, func(item *types.WALSegment, index int) bool {
+		return item.Environment == environment && item.ArchivedAt.Before(oldest.CreatedAt)
+	})
+	return expiredBackups, expiredSegments
+}
+
+func (b backupService) shipWAL(ctx context.Context, settings *types.PITRSettings) {
+	params, err := b.pitrParams(ctx, settings.ApplicationID, settings.Environment)
+	if err != nil {
+		logger.Error("failed to ship WAL", zap.Error(err))
+		return
+	}
+
+	// segments shipped before an error are still recorded
+	shipped, err := backup.NewPostgresPITR(b.dockerClient).ShipWAL(ctx, params)
+	if err != nil {
+		logger.Error("failed to ship WAL",
+			zap.String("application", params.Application.Name),
+			zap.String("environment", settings.Environment),
+			zap.Error(err))
+	}
+
+	for _, next := range shipped {
+		segment := &types.WALSegment{
+			ID:            uuid.New(),
+			ApplicationID: settings.ApplicationID,
+			Environment:   settings.Environment,
+			Name:          next.Name,
+			Location:      next.Location,
+			Size:          next.Size,
+			ArchivedAt:    time.Now(),
+		}
+		segment.StorageType, _ = b.saveCopies(ctx, segment.ID, next.Location, next.Copies)
+		if err := b.walSegmentRepository.Save(ctx, segment); err != nil {
+			logger.Error("failed to save WAL segment", zap.Error(err))
+		}
+	}
+}
+
+func (b backupService) pitrParams(ctx context.Context, applicationID uuid.UUID, environment string) (backup.Params, error) {
+	application, err := b.applicationService.Get(ctx, applicationID)
+	if err != nil {
+		return backup.Params{}, err
+	}
+
+	if !lo.Contains(application.StorageEngines, types.StorageEnginePostgres) {
+		return backup.Params{}, errors.New("point-in-time recovery is only supported for postgres")
+	}
+
+	allAppVars, err := b.secretService.FindAll(ctx, applicationID)
+	if err != nil {
+		return backup.Params{}, err
+	}
+
+	dbVars := lo.Filter(allAppVars, func(item *types.Secret, index int) bool {
+		return types.InstanceType(item.InstanceType) == types.InstanceTypeDatabase &&
+			item.Environment == environment
+	})
+	destinations, err := b.destinations(ctx, applicationID)
+	if err != nil {
+		return backup.Params{}, err
+	}
+
+	return backup.Params{
+		Environment:  environment,
+		DatabaseVars: dbVars,
+		Destinations: destinations,
+		Application:  application,
+	}, nil
+}
+
+// schedulePITR queues the base backup job under the settings ID and the WAL shipping job under an ID derived from it,
+// both run with the context of the service since they outlive the request enabling PITR
+func (b backupService) schedulePITR(settings *types.PITRSettings) error {
+	_, err := b.scheduler.NewJob(
+		gocron.CronJob(settings.BaseBackupCronExpression, false),
+		gocron.NewTask(func() {
+			if _, err := b.baseBackup(b.ctx, settings, types.BackupTriggerSchedule); err != nil {
+				logger.Info("base backup failed", zap.Error(err))
+			}
+		}),
+		gocron.WithIdentifier(settings.ID))
+	if err != nil {
+		return err
+	}
+
+	_, err = b.scheduler.NewJob(
+		gocron.DurationJob(walShippingInterval),
+		gocron.NewTask(b.shipWAL, b.ctx, settings),
+		gocron.WithIdentifier(walJobID(settings)),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule))
+	if err != nil {
+		return err
+	}
+
+	logger.Info("PITR jobs queued",
+		zap.String("expression", settings.BaseBackupCronExpression),
+		zap.String("environment", settings.Environment))
+	b.scheduler.Start()
+	return nil
+}
+
+func (b backupService) unschedulePITR(settings *types.PITRSettings) {
+	for _, id := range []uuid.UUID{settings.ID, walJobID(settings)} {
+		if err := b.scheduler.RemoveJob(id); err != nil && !errors.Is(err, gocron.ErrJobNotFound) {
+			logger.Warn("failed to remove PITR job", zap.Error(err))
+		}
+	}
+}
+
+func (b backupService) removePITRSettings(ctx context.Context, applicationID uuid.UUID, environment string) error {
+	settings, err := b.pitrSettingsRepository.Find(ctx, applicationID, environment)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	b.unschedulePITR(settings)
+	return b.pitrSettingsRepository.Delete(ctx, settings.ID)
+}
+
+func walJobID(settings *types.PITRSettings) uuid.UUID {
+	return uuid.NewSHA1(settings.ID, []byte("wal"))
+}
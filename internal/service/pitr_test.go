@@ -0,0 +1,139 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sarabi/internal/types"
+	"testing"
+	"time"
+)
+
+func TestRestoreBase(t *testing.T) {
+	now := time.Date(2026, 10, 1, 14, 0, 0, 0, time.UTC)
+	base := func(env string, createdAt time.Time, duration time.Duration, status types.BackupStatus) *types.Backup {
+		return &types.Backup{Environment: env, CreatedAt: createdAt, Duration: duration, Status: status, Kind: types.BackupKindBase}
+	}
+
+	// newest first, as the repository returns them
+	backups := []*types.Backup{
+		base("prod", now.Add(-10*time.Minute), 20*time.Minute, types.BackupStatusSucceeded),
+		base("staging", now.Add(-time.Hour), time.Minute, types.BackupStatusSucceeded),
+		{Environment: "prod", CreatedAt: now.Add(-time.Hour), Status: types.BackupStatusSucceeded},
+		base("prod", now.Add(-2*time.Hour), time.Minute, types.BackupStatusFailed),
+		base("prod", now.Add(-3*time.Hour), time.Minute, types.BackupStatusSucceeded),
+		base("prod", now.Add(-24*time.Hour), time.Minute, types.BackupStatusSucceeded),
+	}
+
+	tests := []struct {
+		name        string
+		environment string
+		target      time.Time
+		expected    *types.Backup
+		expectedErr string
+	}{
+		{
+			name:        "latest completed base backup",
+			environment: "prod",
+			target:      now.Add(30 * time.Minute),
+			expected:    backups[0],
+		},
+		{
+			name:        "base backup still running at the target",
+			environment: "prod",
+			target:      now,
+			expected:    backups[4],
+		},
+		{
+			name:        "other environment",
+			environment: "staging",
+			target:      now,
+			expected:    backups[1],
+		},
+		{
+			name:        "older base backup",
+			environment: "prod",
+			target:      now.Add(-4 * time.Hour),
+			expected:    backups[5],
+		},
+		{
+			name:        "target before the first base backup",
+			environment: "prod",
+			target:      now.Add(-48 * time.Hour),
+			expectedErr: "no base backup completed before 2026-09-29 14:00:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := restoreBase(backups, tt.environment, tt.target)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Same(t, tt.expected, result)
+		})
+	}
+}
+
+func TestCheckWALReaches(t *testing.T) {
+	target := time.Date(2026, 10, 1, 14, 32, 0, 0, time.UTC)
+	segments := []*types.WALSegment{
+		{Name: "000000010000000000000001", ArchivedAt: target.Add(-time.Hour)},
+		{Name: "000000010000000000000002", ArchivedAt: target.Add(time.Minute)},
+	}
+
+	assert.NoError(t, checkWALReaches(segments, target))
+	assert.NoError(t, checkWALReaches(segments, target.Add(time.Minute)))
+	assert.EqualError(t, checkWALReaches(segments, target.Add(2*time.Minute)),
+		"the archived WAL does not reach 2026-10-01 14:34:00 yet, try again in a minute")
+	assert.EqualError(t, checkWALReaches(nil, target),
+		"the archived WAL does not reach 2026-10-01 14:32:00 yet, try again in a minute")
+}
+
+func TestExpiredPITR(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	base := func(env string, age time.Duration, status types.BackupStatus) *types.Backup {
+		return &types.Backup{Environment: env, CreatedAt: now.Add(-age), Status: status, Kind: types.BackupKindBase}
+	}
+	segment := func(env string, age time.Duration) *types.WALSegment {
+		return &types.WALSegment{Environment: env, ArchivedAt: now.Add(-age)}
+	}
+
+	backups := []*types.Backup{
+		base("prod", 0, types.BackupStatusSucceeded),
+		base("prod", day, types.BackupStatusFailed),
+		base("prod", 2*day, types.BackupStatusSucceeded),
+		{Environment: "prod", CreatedAt: now.Add(-3 * day), Status: types.BackupStatusSucceeded, Kind: types.BackupKindDump},
+		base("prod", 3*day, types.BackupStatusFailed),
+		base("prod", 4*day, types.BackupStatusSucceeded),
+		base("staging", 5*day, types.BackupStatusSucceeded),
+	}
+	segments := []*types.WALSegment{
+		segment("prod", time.Hour),
+		segment("prod", 2*day-time.Hour),
+		segment("prod", 2*day+time.Hour),
+		segment("prod", 5*day),
+		segment("staging", 6*day),
+	}
+
+	// the second newest successful base backup is the oldest kept, what is older goes
+	expiredBackups, expiredSegments := expiredPITR(backups, segments, "prod", 2)
+	assert.Equal(t, []*types.Backup{backups[4], backups[5]}, expiredBackups)
+	assert.Equal(t, []*types.WALSegment{segments[2], segments[3]}, expiredSegments)
+
+	// fewer base backups than kept
+	expiredBackups, expiredSegments = expiredPITR(backups, segments, "prod", 7)
+	assert.Empty(t, expiredBackups)
+	assert.Equal(t, []*types.WALSegment{segments[3]}, expiredSegments)
+
+	// the WAL archived since the last base backup is all a restore has until one succeeds
+	expiredBackups, expiredSegments = expiredPITR([]*types.Backup{base("prod", 0, types.BackupStatusFailed)}, segments, "prod", 2)
+	assert.Empty(t, expiredBackups)
+	assert.Empty(t, expiredSegments)
+
+	expiredBackups, expiredSegments = expiredPITR(backups, segments, "staging", 1)
+	assert.Empty(t, expiredBackups)
+	assert.Equal(t, []*types.WALSegment{segments[4]}, expiredSegments)
+}
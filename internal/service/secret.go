@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/samber/lo"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"sarabi/internal/database"
@@ -25,7 +24,7 @@ type SecretService interface {
 	CreateServerConfig(ctx context.Context, params types.CreateServerConfigParams) (*types.ServerConfigResponse, error)
 	FindApplicationServerConfigs(ctx context.Context, applicationID uuid.UUID) ([]*types.ServerConfig, error)
 	DeleteDeploymentSecrets(ctx context.Context, deploymentID uuid.UUID) error
-	FindStorageCredentials(ctx context.Context, applicationID uuid.UUID) (*types.StorageCredentials, error)
+	Delete(ctx context.Context, applicationID uuid.UUID, environment string, instanceType types.InstanceType, keys ...string) error
 }
 
 type secretService struct {
@@ -214,25 +213,22 @@ func (s *secretService) DeleteDeploymentSecrets(ctx context.Context, deploymentI
 	return err
 }
 
-func (s *secretService) FindStorageCredentials(ctx context.Context, applicationID uuid.UUID) (*types.StorageCredentials, error) {
-	credentials, err := s.FindApplicationServerConfigs(ctx, applicationID)
-	if err != nil {
-		return nil, err
-	}
-
-	objectStorageConfig := lo.Filter(credentials, func(item *types.ServerConfig, index int) bool {
-		return item.Name == types.ServerConfigObjectStorage
-	})
-	if len(objectStorageConfig) == 0 {
-		return nil, errors.New("no object storage configured")
-	}
+// Delete removes the secrets of an environment named after one of keys
+func (s *secretService) Delete(ctx context.Context, applicationID uuid.UUID, environment string, instanceType types.InstanceType, keys ...string) error {
+	for _, key := range keys {
+		sc, err := s.repository.FindBy(ctx, applicationID, key, environment, string(instanceType))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
 
-	value := objectStorageConfig[0]
-	cred := &types.StorageCredentials{}
-	if err := json.Unmarshal([]byte(value.Value), cred); err != nil {
-		return nil, err
+		if err := s.repository.Delete(ctx, sc.ID); err != nil {
+			return err
+		}
 	}
-	return cred, nil
+	return nil
 }
 
 func FindSecret(name string, secrets []*types.Secret) (*types.Secret, error) {
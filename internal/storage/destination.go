@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"sarabi/internal/types"
+)
+
+const LocalDestinationName = "local"
+
+type (
+	// Destination is one of the places a backup is replicated to
+	Destination struct {
+		// ID is the ID of the ServerConfig holding the destination credentials, uuid.Nil for the local file system
+		ID      uuid.UUID
+		Name    string
+		Type    Type
+		Storage Storage
+	}
+
+	// Copy is the outcome of saving a file into a destination
+	Copy struct {
+		Destination Destination
+		Error       error
+	}
+)
+
+func LocalDestination() Destination {
+	return Destination{
+		ID:      uuid.Nil,
+		Name:    LocalDestinationName,
+		Type:    TypeFS,
+		Storage: NewFileStorage(),
+	}
+}
+
+// Replicate saves file into every destination. a failing destination does not stop the others,
+// an error is only returned when no copy could be made
+func Replicate(ctx context.Context, destinations []Destination, location string, file types.File) ([]Copy, error) {
+	copies := make([]Copy, 0, len(destinations))
+	succeeded := 0
+	for _, next := range destinations {
+		if seeker, ok := file.Content.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return copies, err
+			}
+		}
+
+		err := next.Storage.Save(ctx, location, file)
+		if err == nil {
+			succeeded++
+		}
+		copies = append(copies, Copy{Destination: next, Error: err})
+	}
+
+	if succeeded == 0 {
+		if len(copies) == 0 {
+			return copies, fmt.Errorf("no backup destination configured")
+		}
+		return copies, fmt.Errorf("failed to save file in storage: %w", copies[0].Error)
+	}
+	return copies, nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"sarabi/internal/types"
+	"testing"
+)
+
+// unreachableStorage fails every call, like a destination that is down
+type unreachableStorage struct{}
+
+func (unreachableStorage) Save(context.Context, string, types.File) error {
+	return errors.New("connection refused")
+}
+
+func (unreachableStorage) Get(context.Context, string) (*types.File, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (unreachableStorage) Ping(context.Context) error {
+	return errors.New("connection refused")
+}
+
+func (unreachableStorage) Delete(context.Context, string) error {
+	return errors.New("connection refused")
+}
+
+func TestReplicate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "postgres.sql")
+	require.NoError(t, os.WriteFile(path, []byte("dump"), 0600))
+	open := func() types.File {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = f.Close() })
+		return types.File{Content: f}
+	}
+
+	mirror := func(name string) (Destination, string) {
+		root := t.TempDir()
+		st, err := NewMirrorStorage(root)
+		require.NoError(t, err)
+		return Destination{Name: name, Type: TypeMirror, Storage: st}, root
+	}
+	first, firstRoot := mirror("first")
+	second, secondRoot := mirror("second")
+	down := Destination{Name: "down", Type: TypeS3, Storage: unreachableStorage{}}
+	location := BackupDir + "/app-prod/postgres.sql"
+
+	// the file is read again from the start for every destination
+	copies, err := Replicate(context.Background(), []Destination{first, down, second}, location, open())
+	assert.NoError(t, err)
+	assert.Len(t, copies, 3)
+	assert.NoError(t, copies[0].Error)
+	assert.EqualError(t, copies[1].Error, "connection refused")
+	assert.Equal(t, "down", copies[1].Destination.Name)
+	assert.NoError(t, copies[2].Error)
+	for _, root := range []string{firstRoot, secondRoot} {
+		content, err := os.ReadFile(filepath.Join(root, "app-prod", "postgres.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, "dump", string(content))
+	}
+
+	copies, err = Replicate(context.Background(), []Destination{down}, location, open())
+	assert.EqualError(t, err, "failed to save file in storage: connection refused")
+	assert.Len(t, copies, 1)
+
+	_, err = Replicate(context.Background(), nil, location, types.File{Content: io.NopCloser(nil)})
+	assert.EqualError(t, err, "no backup destination configured")
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// Field describes one credential a driver expects
+	Field struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Required    bool   `json:"required"`
+		// Secret fields are never shown back to the user
+		Secret bool `json:"secret"`
+	}
+
+	// Driver builds a Storage from the credentials kept in a ServerConfig
+	Driver struct {
+		Type Type `json:"type"`
+		// ConfigName is the ServerConfig name the driver credentials are stored under
+		ConfigName string                                    `json:"config_name"`
+		Schema     []Field                                   `json:"schema"`
+		New        func(credentials []byte) (Storage, error) `json:"-"`
+	}
+
+	// LogStore is implemented by storages Loki can keep its chunks in
+	LogStore interface {
+		LokiStore() LokiStore
+	}
+
+	LokiStore struct {
+		// ObjectStore is the Loki object store name, s3 or filesystem
+		ObjectStore string
+		S3URI       string
+		// Directory is a host directory mounted into the Loki container for filesystem stores
+		Directory string
+		Insecure  bool
+		PathStyle bool
+		// CACert is a PEM bundle Loki should trust when talking to the object store
+		CACert string
+	}
+)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[Type]Driver)
+)
+
+// Register makes a storage driver available. drivers register themselves from their init function
+func Register(d Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if _, ok := drivers[d.Type]; ok {
+		panic("storage driver registered twice: " + d.Type.String())
+	}
+	drivers[d.Type] = d
+}
+
+func Drivers() []Driver {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	result := make([]Driver, 0, len(drivers))
+	for _, next := range drivers {
+		result = append(result, next)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Type < result[j].Type
+	})
+	return result
+}
+
+func Lookup(t Type) (Driver, error) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	for k, v := range drivers {
+		if strings.EqualFold(string(k), string(t)) {
+			return v, nil
+		}
+	}
+	return Driver{}, fmt.Errorf("unknown storage driver: %s", t)
+}
+
+// LookupConfig returns the driver whose credentials are stored under a ServerConfig name
+func LookupConfig(configName string) (Driver, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	for _, v := range drivers {
+		if v.ConfigName == configName {
+			return v, true
+		}
+	}
+	return Driver{}, false
+}
+
+// Open validates credentials against the driver schema and builds the storage
+func (d Driver) Open(credentials []byte) (Storage, error) {
+	values := make(map[string]any)
+	if err := json.Unmarshal(credentials, &values); err != nil {
+		return nil, fmt.Errorf("invalid %s credentials: %w", d.Type, err)
+	}
+
+	for _, next := range d.Schema {
+		if !next.Required {
+			continue
+		}
+		if v, ok := values[next.Name]; !ok || v == nil || v == "" {
+			return nil, fmt.Errorf("%s: %s is required", d.Type, next.Name)
+		}
+	}
+	return d.New(credentials)
+}
+
+// relativeKey turns a backup location into a path relative to the backup directory,
+// used by the storages that are rooted somewhere else
+func relativeKey(location string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(location, BackupDir), "/")
+}
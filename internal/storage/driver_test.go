@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sarabi/internal/types"
+	"strings"
+	"testing"
+)
+
+func TestDriverOpen(t *testing.T) {
+	tests := []struct {
+		name        string
+		storageType Type
+		credentials string
+		expectedErr string
+	}{
+		{
+			name:        "missing required field",
+			storageType: TypeS3,
+			credentials: `{"endpoint": "localhost:9000", "access_key_id": "key"}`,
+			expectedErr: "S3: secret_key is required",
+		},
+		{
+			name:        "unknown addressing",
+			storageType: TypeS3,
+			credentials: `{"endpoint": "localhost:9000", "access_key_id": "key", "secret_key": "secret", "addressing": "dns"}`,
+			expectedErr: "unknown addressing: dns",
+		},
+		{
+			name:        "ca cert without tls",
+			storageType: TypeS3,
+			credentials: `{"endpoint": "localhost:9000", "access_key_id": "key", "secret_key": "secret", "ca_cert": "pem"}`,
+			expectedErr: "ca_cert requires secure to be on",
+		},
+		{
+			name:        "logs in the backup bucket",
+			storageType: TypeS3,
+			credentials: `{"endpoint": "localhost:9000", "access_key_id": "key", "secret_key": "secret", "bucket": "sarabi-logs"}`,
+			expectedErr: "log_bucket sarabi-logs is the backup bucket, logs and backups must be kept apart",
+		},
+		{
+			name:        "relative mirror path",
+			storageType: TypeMirror,
+			credentials: `{"path": "backups"}`,
+			expectedErr: "mirror path must be absolute: backups",
+		},
+		{
+			name:        "unsupported webdav scheme",
+			storageType: TypeWebDAV,
+			credentials: `{"url": "ftp://example.com/backups"}`,
+			expectedErr: "unsupported webdav url: ftp://example.com/backups",
+		},
+		{
+			name:        "valid mirror",
+			storageType: TypeMirror,
+			credentials: `{"path": "/mnt/backups"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			driver, err := Lookup(test.storageType)
+			assert.NoError(t, err)
+
+			_, err = driver.Open([]byte(test.credentials))
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestMirrorStorage(t *testing.T) {
+	root := t.TempDir()
+	st, err := NewMirrorStorage(root)
+	assert.NoError(t, err)
+	assert.NoError(t, st.Ping(context.Background()))
+
+	location := BackupDir + "/app-prod/postgres.sql"
+	err = st.Save(context.Background(), location, types.File{
+		Content: io.NopCloser(strings.NewReader("dump")),
+	})
+	assert.NoError(t, err)
+
+	_, err = os.Stat(root + "/app-prod/postgres.sql")
+	assert.NoError(t, err)
+
+	file, err := st.Get(context.Background(), location)
+	assert.NoError(t, err)
+	content, _ := io.ReadAll(file.Content)
+	_ = file.Content.Close()
+	assert.Equal(t, "dump", string(content))
+
+	assert.NoError(t, st.Delete(context.Background(), location))
+}
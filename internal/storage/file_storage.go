@@ -71,3 +71,11 @@ func (f fileStorage) Get(ctx context.Context, location string) (*types.File, err
 func (f fileStorage) Ping(ctx context.Context) error {
 	return nil
 }
+
+func (f fileStorage) Delete(ctx context.Context, location string) error {
+	err := os.Remove(location)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
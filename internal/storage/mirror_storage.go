@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sarabi/internal/types"
+)
+
+// mirrorStorage keeps backups in a directory mounted on the host, such as an NFS share
+type mirrorStorage struct {
+	root  string
+	local Storage
+}
+
+type mirrorCredentials struct {
+	Path string `json:"path"`
+}
+
+func init() {
+	Register(Driver{
+		Type:       TypeMirror,
+		ConfigName: "mirror_storage",
+		Schema: []Field{
+			{Name: "path", Description: "Absolute path of a mounted directory, e.g an NFS share", Required: true},
+		},
+		New: func(credentials []byte) (Storage, error) {
+			cred := mirrorCredentials{}
+			if err := json.Unmarshal(credentials, &cred); err != nil {
+				return nil, err
+			}
+			return NewMirrorStorage(cred.Path)
+		},
+	})
+}
+
+func NewMirrorStorage(root string) (Storage, error) {
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("mirror path must be absolute: %s", root)
+	}
+	return &mirrorStorage{root: root, local: NewFileStorage()}, nil
+}
+
+func (m mirrorStorage) path(location string) string {
+	return filepath.Join(m.root, relativeKey(location))
+}
+
+func (m mirrorStorage) Save(ctx context.Context, location string, file types.File) error {
+	return m.local.Save(ctx, m.path(location), file)
+}
+
+func (m mirrorStorage) Get(ctx context.Context, location string) (*types.File, error) {
+	return m.local.Get(ctx, m.path(location))
+}
+
+// Ping makes sure the directory is mounted and writable
+func (m mirrorStorage) Ping(ctx context.Context) error {
+	stat, err := os.Stat(m.root)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", m.root)
+	}
+
+	fi, err := os.CreateTemp(m.root, ".sarabi-ping-")
+	if err != nil {
+		return err
+	}
+	_ = fi.Close()
+	return os.Remove(fi.Name())
+}
+
+func (m mirrorStorage) Delete(ctx context.Context, location string) error {
+	return m.local.Delete(ctx, m.path(location))
+}
+
+func (m mirrorStorage) LokiStore() LokiStore {
+	return LokiStore{ObjectStore: "filesystem", Directory: filepath.Join(m.root, "loki")}
+}
@@ -2,42 +2,129 @@ package storage
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"path"
 	types "sarabi/internal/types"
+	"strings"
 )
 
 const (
-	backupBucket = "backups"
+	defaultBackupBucket = "backups"
 )
 
 type objectStorage struct {
 	client *minio.Client
 	region string
+	bucket string
+	prefix string
+	cred   types.StorageCredentials
 }
 
-func NewObjectStorage(cred types.StorageCredentials) (Storage, error) {
-	mn, err := minio.New(cred.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cred.AccessKeyID, cred.SecretKey, ""),
-		Secure: false,
-		Region: cred.Region,
+func init() {
+	Register(Driver{
+		Type:       TypeS3,
+		ConfigName: types.ServerConfigObjectStorage,
+		Schema: []Field{
+			{Name: "endpoint", Description: "S3 compatible endpoint, host[:port]", Required: true},
+			{Name: "access_key_id", Description: "Access key ID", Required: true},
+			{Name: "secret_key", Description: "Secret access key", Required: true, Secret: true},
+			{Name: "region", Description: "Bucket region"},
+			{Name: "secure", Description: "Connect over TLS"},
+			{Name: "ca_cert", Description: "PEM encoded CA bundle trusted on top of the system roots"},
+			{Name: "addressing", Description: "Bucket addressing, path(default) or virtual-host"},
+			{Name: "bucket", Description: "Bucket backups are stored in, defaults to backups"},
+			{Name: "prefix", Description: "Prefix prepended to every backup object key"},
+			{Name: "log_bucket", Description: "Bucket Loki keeps logs in, defaults to sarabi-logs. It must differ from the backup bucket"},
+		},
+		New: func(credentials []byte) (Storage, error) {
+			cred := types.StorageCredentials{}
+			if err := json.Unmarshal(credentials, &cred); err != nil {
+				return nil, err
+			}
+			return NewObjectStorage(cred)
+		},
 	})
+}
+
+func NewObjectStorage(cred types.StorageCredentials) (Storage, error) {
+	opts := &minio.Options{
+		Creds:        credentials.NewStaticV4(cred.AccessKeyID, cred.SecretKey, ""),
+		Secure:       cred.Secure,
+		Region:       cred.Region,
+		BucketLookup: minio.BucketLookupPath,
+	}
+
+	switch cred.Addressing {
+	case "", types.AddressingPath:
+	case types.AddressingVirtualHost:
+		opts.BucketLookup = minio.BucketLookupDNS
+	default:
+		return nil, fmt.Errorf("unknown addressing: %s", cred.Addressing)
+	}
+
+	if cred.CACert != "" {
+		if !cred.Secure {
+			return nil, errors.New("ca_cert requires secure to be on")
+		}
+
+		transport, err := minio.DefaultTransport(true)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(cred.CACert)) {
+			return nil, errors.New("ca_cert contains no valid certificate")
+		}
+		transport.TLSClientConfig.RootCAs = pool
+		opts.Transport = transport
+	}
+
+	mn, err := minio.New(cred.Endpoint, opts)
 	if err != nil {
 		return nil, err
 	}
+
+	bucket := cred.Bucket
+	if bucket == "" {
+		bucket = defaultBackupBucket
+	}
+	if cred.LogBucketName() == bucket {
+		return nil, fmt.Errorf("log_bucket %s is the backup bucket, logs and backups must be kept apart", bucket)
+	}
 	return &objectStorage{
 		region: cred.Region,
 		client: mn,
+		bucket: bucket,
+		prefix: strings.Trim(cred.Prefix, "/"),
+		cred:   cred,
 	}, nil
 }
 
+// key returns the object key of a backup location.
+// without a prefix the location is used as is, so backups saved before prefixes existed are still found
+func (s objectStorage) key(location string) string {
+	if s.prefix == "" {
+		return location
+	}
+	return path.Join(s.prefix, relativeKey(location))
+}
+
 func (s objectStorage) Save(ctx context.Context, location string, file types.File) error {
 	if err := s.makeBucket(ctx); err != nil {
 		return err
 	}
 
 	// TODO: implement chunk writer
-	_, err := s.client.PutObject(ctx, backupBucket, location, file.Content, file.Stat.Size, minio.PutObjectOptions{
+	_, err := s.client.PutObject(ctx, s.bucket, s.key(location), file.Content, file.Stat.Size, minio.PutObjectOptions{
 		ContentType: file.GetContentType(),
 	})
 	if err != nil {
@@ -47,7 +134,7 @@ func (s objectStorage) Save(ctx context.Context, location string, file types.Fil
 }
 
 func (s objectStorage) Get(ctx context.Context, location string) (*types.File, error) {
-	r, err := s.client.GetObject(ctx, backupBucket, location, minio.GetObjectOptions{})
+	r, err := s.client.GetObject(ctx, s.bucket, s.key(location), minio.GetObjectOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +151,7 @@ func (s objectStorage) Get(ctx context.Context, location string) (*types.File, e
 }
 
 func (s objectStorage) makeBucket(ctx context.Context) error {
-	exists, err := s.client.BucketExists(ctx, backupBucket)
+	exists, err := s.client.BucketExists(ctx, s.bucket)
 	if err != nil {
 		return err
 	}
@@ -73,15 +160,27 @@ func (s objectStorage) makeBucket(ctx context.Context) error {
 		return nil
 	}
 
-	return s.client.MakeBucket(ctx, backupBucket, minio.MakeBucketOptions{
+	return s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{
 		Region: s.region,
 	})
 }
 
 func (s objectStorage) Ping(ctx context.Context) error {
-	_, err := s.client.ListBuckets(ctx)
-	if err != nil {
-		return err
+	// checking the bucket rather than listing all buckets works with keys scoped to a single bucket
+	_, err := s.client.BucketExists(ctx, s.bucket)
+	return err
+}
+
+func (s objectStorage) Delete(ctx context.Context, location string) error {
+	return s.client.RemoveObject(ctx, s.bucket, s.key(location), minio.RemoveObjectOptions{})
+}
+
+func (s objectStorage) LokiStore() LokiStore {
+	return LokiStore{
+		ObjectStore: "s3",
+		S3URI:       s.cred.URI(),
+		Insecure:    !s.cred.Secure,
+		PathStyle:   !s.cred.VirtualHostStyle(),
+		CACert:      s.cred.CACert,
 	}
-	return nil
 }
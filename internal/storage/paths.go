@@ -6,6 +6,7 @@ const (
 	Path              = "/var/sarabi/data"
 	DBDir             = Path + "/floki.db"
 	AppLogDir         = Path + "/logs"
+	ImportDir         = Path + "/imports"
 	ServerCrtFilePath = Path + "/certs/server.crt"
 	ServerKeyFilePath = Path + "/certs/server.key"
 )
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sarabi/internal/types"
+	"time"
+)
+
+type sftpStorage struct {
+	cred   sftpCredentials
+	config *ssh.ClientConfig
+}
+
+type sftpCredentials struct {
+	Host       string `json:"host"`
+	Port       string `json:"port"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	PrivateKey string `json:"private_key"`
+	// HostKey is the server public key in authorized_keys format
+	HostKey   string `json:"host_key"`
+	Directory string `json:"directory"`
+}
+
+// sftpSession closes the ssh connection along with the file it was opened for
+type sftpSession struct {
+	io.ReadCloser
+	conn *ssh.Client
+	sc   *sftp.Client
+}
+
+func (s sftpSession) Close() error {
+	err := s.ReadCloser.Close()
+	_ = s.sc.Close()
+	_ = s.conn.Close()
+	return err
+}
+
+func init() {
+	Register(Driver{
+		Type:       TypeSFTP,
+		ConfigName: "sftp_storage",
+		Schema: []Field{
+			{Name: "host", Description: "SFTP server host", Required: true},
+			{Name: "port", Description: "SFTP server port, defaults to 22"},
+			{Name: "username", Description: "SSH username", Required: true},
+			{Name: "password", Description: "SSH password, when not using a private key", Secret: true},
+			{Name: "private_key", Description: "PEM encoded SSH private key", Secret: true},
+			{Name: "host_key", Description: "Server public key in authorized_keys format", Required: true},
+			{Name: "directory", Description: "Remote directory backups are stored in", Required: true},
+		},
+		New: func(credentials []byte) (Storage, error) {
+			cred := sftpCredentials{}
+			if err := json.Unmarshal(credentials, &cred); err != nil {
+				return nil, err
+			}
+			return newSFTPStorage(cred)
+		},
+	})
+}
+
+func newSFTPStorage(cred sftpCredentials) (Storage, error) {
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cred.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key: %w", err)
+	}
+
+	auth := make([]ssh.AuthMethod, 0)
+	if cred.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(cred.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cred.Password != "" {
+		auth = append(auth, ssh.Password(cred.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("sftp: password or private_key is required")
+	}
+
+	if cred.Port == "" {
+		cred.Port = "22"
+	}
+	return &sftpStorage{
+		cred: cred,
+		config: &ssh.ClientConfig{
+			User:            cred.Username,
+			Auth:            auth,
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+			Timeout:         30 * time.Second,
+		},
+	}, nil
+}
+
+func (s sftpStorage) connect() (*ssh.Client, *sftp.Client, error) {
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(s.cred.Host, s.cred.Port), s.config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	return conn, sc, nil
+}
+
+func (s sftpStorage) path(location string) string {
+	return path.Join(s.cred.Directory, relativeKey(location))
+}
+
+func (s sftpStorage) Save(ctx context.Context, location string, file types.File) error {
+	conn, sc, err := s.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer sc.Close()
+
+	remote := s.path(location)
+	if err := sc.MkdirAll(path.Dir(remote)); err != nil {
+		return err
+	}
+
+	fi, err := sc.Create(remote)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	_, err = fi.ReadFrom(file.Content)
+	return err
+}
+
+func (s sftpStorage) Get(ctx context.Context, location string) (*types.File, error) {
+	conn, sc, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	remote := s.path(location)
+	fi, err := sc.Open(remote)
+	if err != nil {
+		_ = sc.Close()
+		_ = conn.Close()
+		return nil, err
+	}
+
+	stat, err := fi.Stat()
+	if err != nil {
+		_ = fi.Close()
+		_ = sc.Close()
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &types.File{
+		Content: sftpSession{ReadCloser: fi, conn: conn, sc: sc},
+		Stat:    types.FileStat{Size: stat.Size(), Name: stat.Name()},
+	}, nil
+}
+
+func (s sftpStorage) Ping(ctx context.Context) error {
+	conn, sc, err := s.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer sc.Close()
+
+	if err := sc.MkdirAll(s.cred.Directory); err != nil {
+		return err
+	}
+
+	_, err = sc.Stat(s.cred.Directory)
+	return err
+}
+
+func (s sftpStorage) Delete(ctx context.Context, location string) error {
+	conn, sc, err := s.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer sc.Close()
+
+	err = sc.Remove(s.path(location))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -12,12 +12,16 @@ type (
 		Save(ctx context.Context, location string, f types.File) error
 		Get(ctx context.Context, location string) (*types.File, error)
 		Ping(ctx context.Context) error
+		Delete(ctx context.Context, location string) error
 	}
 )
 
 const (
-	TypeFS Type = "File"
-	TypeS3 Type = "S3"
+	TypeFS     Type = "File"
+	TypeS3     Type = "S3"
+	TypeSFTP   Type = "SFTP"
+	TypeWebDAV Type = "WebDAV"
+	TypeMirror Type = "Mirror"
 
 	bufferSize int = 4 * 1024 * 1024 // 4MB
 )
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sarabi/internal/types"
+	"strings"
+	"time"
+)
+
+type webdavStorage struct {
+	baseURL  *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+type webdavCredentials struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func init() {
+	Register(Driver{
+		Type:       TypeWebDAV,
+		ConfigName: "webdav_storage",
+		Schema: []Field{
+			{Name: "url", Description: "URL of the WebDAV collection backups are stored in", Required: true},
+			{Name: "username", Description: "Basic auth username"},
+			{Name: "password", Description: "Basic auth password", Secret: true},
+		},
+		New: func(credentials []byte) (Storage, error) {
+			cred := webdavCredentials{}
+			if err := json.Unmarshal(credentials, &cred); err != nil {
+				return nil, err
+			}
+			return NewWebDAVStorage(cred.URL, cred.Username, cred.Password)
+		},
+	})
+}
+
+func NewWebDAVStorage(rawURL, username, password string) (Storage, error) {
+	u, err := url.Parse(strings.TrimSuffix(rawURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported webdav url: %s", rawURL)
+	}
+	return &webdavStorage{
+		baseURL:  u,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Minute},
+	}, nil
+}
+
+func (w webdavStorage) url(key string) string {
+	u := *w.baseURL
+	u.Path = path.Join(u.Path, key)
+	return u.String()
+}
+
+func (w webdavStorage) do(ctx context.Context, method, key string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, w.url(key), body)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		req.ContentLength = size
+	}
+	if method == "PROPFIND" {
+		req.Header.Set("Depth", "0")
+	}
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	return w.client.Do(req)
+}
+
+func (w webdavStorage) Save(ctx context.Context, location string, file types.File) error {
+	key := relativeKey(location)
+	if err := w.mkcol(ctx, path.Dir(key)); err != nil {
+		return err
+	}
+
+	resp, err := w.do(ctx, http.MethodPut, key, file.Content, file.Stat.Size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkWebDAVResponse(resp, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+}
+
+// mkcol creates the collections leading to dir, existing ones are answered with 405
+func (w webdavStorage) mkcol(ctx context.Context, dir string) error {
+	current := ""
+	for _, next := range strings.Split(dir, "/") {
+		if next == "" || next == "." {
+			continue
+		}
+		current = path.Join(current, next)
+		resp, err := w.do(ctx, "MKCOL", current+"/", nil, 0)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if err := checkWebDAVResponse(resp, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w webdavStorage) Get(ctx context.Context, location string) (*types.File, error) {
+	key := relativeKey(location)
+	resp, err := w.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWebDAVResponse(resp, http.StatusOK); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+
+	return &types.File{
+		Content: resp.Body,
+		Stat:    types.FileStat{Size: resp.ContentLength, Name: path.Base(key)},
+	}, nil
+}
+
+func (w webdavStorage) Ping(ctx context.Context) error {
+	resp, err := w.do(ctx, "PROPFIND", "", nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkWebDAVResponse(resp, http.StatusOK, http.StatusMultiStatus)
+}
+
+func (w webdavStorage) Delete(ctx context.Context, location string) error {
+	resp, err := w.do(ctx, http.MethodDelete, relativeKey(location), nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkWebDAVResponse(resp, http.StatusOK, http.StatusNoContent, http.StatusNotFound)
+}
+
+func checkWebDAVResponse(resp *http.Response, expected ...int) error {
+	for _, next := range expected {
+		if resp.StatusCode == next {
+			return nil
+		}
+	}
+	return fmt.Errorf("webdav %s %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status)
+}
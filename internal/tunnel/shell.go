@@ -0,0 +1,129 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/websocket"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Size is the size of the terminal a shell is attached to, the client sends it as a text message
+// when the session starts and whenever its terminal is resized
+type Size struct {
+	Rows uint `json:"rows"`
+	Cols uint `json:"cols"`
+}
+
+// inputCloser is a command whose input can be ended without detaching from its output
+type inputCloser interface {
+	CloseInput() error
+}
+
+// Attach connects a websocket to a command: binary messages are written to its input,
+// its output is sent back as binary messages and text messages resize its terminal.
+// it returns once the output of the command ends. the input is ended when the client goes away,
+// what the command prints after that is still read to the end
+func Attach(ws *websocket.Conn, input io.WriteCloser, output io.Reader, resize func(Size) error) error {
+	go func() {
+		defer closeInput(input)
+		for {
+			messageType, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			switch messageType {
+			case websocket.BinaryMessage:
+				if _, err := input.Write(data); err != nil {
+					return
+				}
+			case websocket.TextMessage:
+				var size Size
+				if err := json.Unmarshal(data, &size); err == nil {
+					_ = resize(size)
+				}
+			}
+		}
+	}()
+
+	_, err := io.Copy(&stream{ws: ws}, output)
+	if err == nil || isClosed(err) {
+		return nil
+	}
+	return err
+}
+
+func closeInput(input io.WriteCloser) {
+	if ic, ok := input.(inputCloser); ok {
+		_ = ic.CloseInput()
+		return
+	}
+	_ = input.Close()
+}
+
+// Exit ends a shell session, the exit code of the command is the reason of the close message
+func Exit(ws *websocket.Conn, code int) {
+	_ = ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, strconv.Itoa(code)), time.Now().Add(writeTimeout))
+	_ = ws.Close()
+}
+
+// ExitCode returns the exit code of the command a shell session ran, from the error
+// its websocket was closed with
+func ExitCode(err error) (int, bool) {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseNormalClosure {
+		return 0, false
+	}
+
+	code, err := strconv.Atoi(closeErr.Text)
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// Shell is the client end of a shell session
+type Shell struct {
+	ws     *websocket.Conn
+	output *stream
+	mu     sync.Mutex
+}
+
+func NewShell(ws *websocket.Conn) *Shell {
+	return &Shell{ws: ws, output: &stream{ws: ws}}
+}
+
+// Read reads the output of the command, it returns the close error of the session once the command exits
+func (s *Shell) Read(p []byte) (int, error) {
+	return s.output.Read(p)
+}
+
+// Write sends p to the input of the command
+func (s *Shell) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Resize changes the size of the terminal of the command
+func (s *Shell) Resize(size Size) error {
+	data, err := json.Marshal(size)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+func (s *Shell) Close() error {
+	return s.ws.Close()
+}
@@ -0,0 +1,120 @@
+package tunnel
+
+import (
+	"bytes"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoCommand prints back its input and exits once it reads quit
+type echoCommand struct {
+	output *io.PipeWriter
+}
+
+func (c *echoCommand) Write(p []byte) (int, error) {
+	if bytes.Equal(p, []byte("quit")) {
+		return len(p), c.output.Close()
+	}
+	return c.output.Write(p)
+}
+
+func (c *echoCommand) Close() error {
+	return c.output.Close()
+}
+
+func TestAttach(t *testing.T) {
+	sizes := make(chan Size, 1)
+	upgrader := websocket.Upgrader{ReadBufferSize: BufferSize, WriteBufferSize: BufferSize}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		pr, pw := io.Pipe()
+		_ = Attach(ws, &echoCommand{output: pw}, pr, func(size Size) error {
+			sizes <- size
+			return nil
+		})
+		Exit(ws, 3)
+	}))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	shell := NewShell(ws)
+	defer shell.Close()
+
+	require.NoError(t, shell.Resize(Size{Rows: 40, Cols: 120}))
+	assert.Equal(t, Size{Rows: 40, Cols: 120}, <-sizes)
+
+	_, err = shell.Write([]byte("select 1;"))
+	require.NoError(t, err)
+	received := make([]byte, len("select 1;"))
+	_, err = io.ReadFull(shell, received)
+	require.NoError(t, err)
+	assert.Equal(t, "select 1;", string(received))
+
+	_, err = shell.Write([]byte("quit"))
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, shell)
+	code, ok := ExitCode(err)
+	assert.True(t, ok)
+	assert.Equal(t, 3, code)
+}
+
+// batchCommand prints its input back only once its input ends
+type batchCommand struct {
+	input  bytes.Buffer
+	output *io.PipeWriter
+	closed chan bool
+}
+
+func (c *batchCommand) Write(p []byte) (int, error) {
+	return c.input.Write(p)
+}
+
+func (c *batchCommand) CloseInput() error {
+	_, err := c.output.Write(c.input.Bytes())
+	c.closed <- false
+	return c.output.CloseWithError(err)
+}
+
+func (c *batchCommand) Close() error {
+	c.closed <- true
+	return c.output.Close()
+}
+
+func TestAttachEndsInput(t *testing.T) {
+	command := &batchCommand{closed: make(chan bool, 1)}
+	upgrader := websocket.Upgrader{ReadBufferSize: BufferSize, WriteBufferSize: BufferSize}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		pr, pw := io.Pipe()
+		command.output = pw
+		_ = Attach(ws, command, pr, func(Size) error { return nil })
+		_ = ws.Close()
+	}))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	shell := NewShell(ws)
+
+	_, err = shell.Write([]byte("select 1;"))
+	require.NoError(t, err)
+	require.NoError(t, shell.Close())
+
+	// the output written after the input ended is read, the write would block otherwise
+	assert.False(t, <-command.closed)
+}
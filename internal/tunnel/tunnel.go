@@ -0,0 +1,114 @@
+package tunnel
+
+import (
+	"errors"
+	"github.com/gorilla/websocket"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// BufferSize is the size of the read and write buffers of both ends of a tunnel
+	BufferSize = 32 * 1024
+
+	// pingInterval keeps idle tunnels open through proxies closing quiet connections
+	pingInterval = 30 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// Pipe copies the bytes of a TCP connection over a websocket and back, as binary messages.
+// it returns once either side closes, both sides are closed by then
+func Pipe(ws *websocket.Conn, conn net.Conn) error {
+	var (
+		once     sync.Once
+		firstErr error
+		done     = make(chan struct{})
+	)
+	closeAll := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(done)
+			_ = ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeTimeout))
+			_ = ws.Close()
+			_ = conn.Close()
+		})
+	}
+
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+					closeAll(err)
+					return
+				}
+			}
+		}
+	}()
+
+	stream := &stream{ws: ws}
+	go func() {
+		_, err := io.Copy(conn, stream)
+		closeAll(err)
+	}()
+
+	_, err := io.Copy(stream, conn)
+	closeAll(err)
+	<-done
+
+	if firstErr == nil || isClosed(firstErr) {
+		return nil
+	}
+	return firstErr
+}
+
+func isClosed(err error) bool {
+	return errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.EOF) ||
+		websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
+}
+
+// stream reads and writes the binary messages of a websocket as a stream of bytes
+type stream struct {
+	ws     *websocket.Conn
+	reader io.Reader
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	for {
+		if s.reader == nil {
+			messageType, reader, err := s.ws.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			if messageType != websocket.BinaryMessage {
+				continue
+			}
+			s.reader = reader
+		}
+
+		n, err := s.reader.Read(p)
+		if errors.Is(err, io.EOF) {
+			s.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	if err := s.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
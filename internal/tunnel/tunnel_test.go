@@ -0,0 +1,63 @@
+package tunnel
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPipe(t *testing.T) {
+	// the upstream echoes back whatever it receives, like a database answering a query
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer upstream.Close()
+	go func() {
+		conn, err := upstream.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	upgrader := websocket.Upgrader{ReadBufferSize: BufferSize, WriteBufferSize: BufferSize}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := net.Dial("tcp", upstream.Addr().String())
+		if err != nil {
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = Pipe(ws, conn)
+	}))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+
+	local, remote := net.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		result <- Pipe(ws, remote)
+	}()
+
+	message := []byte(strings.Repeat("select 1;", 10000))
+	go func() {
+		_, _ = local.Write(message)
+	}()
+
+	received := make([]byte, len(message))
+	_, err = io.ReadFull(local, received)
+	require.NoError(t, err)
+	assert.Equal(t, message, received)
+
+	require.NoError(t, local.Close())
+	assert.NoError(t, <-result)
+}
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+type (
+	// InternalAccess allows the backends of an application to reach the backends of another at their internal hosts,
+	// the backends of the two are connected to a network nothing else is connected to. the access is mutual on the
+	// network: only the application reached is resolved by its internal hosts, but its backends can still reach the
+	// containers of the allowed application by their container names
+	InternalAccess struct {
+		ID uuid.UUID `gorm:"primaryKey" json:"id"`
+		// ApplicationID is the application reached
+		ApplicationID uuid.UUID `gorm:"index" json:"application_id"`
+		// FromApplicationID is the application allowed to reach it
+		FromApplicationID uuid.UUID `gorm:"index" json:"from_application_id"`
+		CreatedAt         time.Time `json:"created_at"`
+	}
+
+	InternalAccessParams struct {
+		// Application is the name of the application allowed
+		Application string `json:"application"`
+	}
+
+	InternalAccessResponse struct {
+		// Hosts are the internal addresses of the active backends of the application
+		Hosts []string `json:"hosts"`
+		// AllowedFrom are the applications allowed to reach the application
+		AllowedFrom []string `json:"allowed_from"`
+		// Reaches are the applications the application is allowed to reach
+		Reaches []string `json:"reaches"`
+	}
+)
+
+// SelfAccess is the access of an application to its own backends, it is always allowed
+func SelfAccess(applicationID uuid.UUID) *InternalAccess {
+	return &InternalAccess{ApplicationID: applicationID, FromApplicationID: applicationID}
+}
+
+// NetworkName is the network the backends of the two applications are connected to, both ways
+func (a *InternalAccess) NetworkName() string {
+	return fmt.Sprintf("internal-%s-%s",
+		strings.ReplaceAll(a.ApplicationID.String(), "-", ""),
+		strings.ReplaceAll(a.FromApplicationID.String(), "-", ""))
+}
+
+// InternalHost is the host the web containers of the deployment are resolved by on the internal networks
+// of its application e.g api.prod.billing.internal, the backend is named backend
+func (a *Deployment) InternalHost() string {
+	name := a.Service
+	if name == "" {
+		name = string(InstanceTypeBackend)
+	}
+	return fmt.Sprintf("%s.%s.%s.internal", name, a.Environment, a.Application.Name)
+}
+
+// InternalAddress is the host and port the deployment is reached at on the internal networks
+func (a *Deployment) InternalAddress() string {
+	return fmt.Sprintf("%s:%s", a.InternalHost(), a.Port)
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+type (
+	// Addon is a service run from any image next to the application in an environment, e.g a search engine.
+	// the backends reach it at Name on the network of the environment
+	Addon struct {
+		ID            uuid.UUID `gorm:"primaryKey" json:"id"`
+		ApplicationID uuid.UUID `json:"application_id"`
+		Environment   string    `json:"environment"`
+		Name          string    `json:"name"`
+		Image         string    `json:"image"`
+		Port          string    `json:"port"`
+		// Volume is the path in the container kept in a volume, nothing is kept when it is empty
+		Volume string `json:"volume"`
+		// Vars are set on the container as they are, the backends do not get them
+		Vars ConfigSettings `json:"vars"`
+		// Secrets are the names of the variables generated for the add-on, the backends get them too
+		Secrets AddonSecrets `json:"secrets"`
+		// Public routes <name>-<env>.<domain> to the add-on through the proxy
+		Public    bool      `json:"public"`
+		Status    string    `json:"status" gorm:"-"`
+		URL       string    `json:"url,omitempty" gorm:"-"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	AddonSecrets []string
+
+	AddAddonParams struct {
+		Environment string         `json:"environment"`
+		Name        string         `json:"name"`
+		Image       string         `json:"image"`
+		Port        string         `json:"port"`
+		Volume      string         `json:"volume"`
+		Vars        ConfigSettings `json:"vars"`
+		Secrets     []string       `json:"secrets"`
+		Public      bool           `json:"public"`
+	}
+
+	RemoveAddonParams struct {
+		Environment string `json:"environment"`
+		Name        string `json:"name"`
+		// KeepData leaves the volume of the add-on in place so that adding it again brings the data back
+		KeepData bool `json:"keep_data"`
+	}
+)
+
+// AddonContainerName is the container of an add-on in the deployment environment
+func (a *Deployment) AddonContainerName(name string) string {
+	return fmt.Sprintf("addon-%s-%s-%s", name, a.Application.Name, a.Environment)
+}
+
+// AddonVolumeName is the docker volume holding the data of an add-on in the deployment environment
+func (a *Deployment) AddonVolumeName(name string) string {
+	return fmt.Sprintf("%s-%s-addon-%s", a.ApplicationID, a.Environment, name)
+}
+
+// AddonAccessURL is where a public add-on is reached from outside the server
+func (a *Deployment) AddonAccessURL(name string) string {
+	return fmt.Sprintf("%s-%s.%s", name, a.Environment, a.Application.Domain)
+}
+
+// AddonVarPrefix is the prefix of the variables the backends get to reach an add-on, e.g MEILISEARCH_
+func AddonVarPrefix(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+func (s AddonSecrets) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *AddonSecrets) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan AddonSecrets: type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, s)
+}
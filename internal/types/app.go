@@ -10,6 +10,8 @@ import (
 	"github.com/shirou/gopsutil/v4/mem"
 	"gorm.io/gorm"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -25,6 +27,7 @@ type (
 		Name           string               `json:"name"`
 		Domain         string               `json:"domain"`
 		StorageEngines StorageEngines       `json:"storage_engines"`
+		EngineVersions EngineVersions       `json:"engine_versions"`
 		Frontend       string               `json:"frontend"`
 		Backend        string               `json:"backend"`
 		Resources      ResourcesAllocations `json:"resources"`
@@ -40,11 +43,17 @@ type (
 		Status        string       `json:"status"`
 		Instances     int          `json:"instances"`
 		Name          string       `json:"name" gorm:"-"`
+		Health        string       `json:"health,omitempty" gorm:"-"`
 		Port          string       `json:"port"`
 		InstanceType  InstanceType `json:"instance_type"`
 		Identifier    string       `json:"identifier"`
-		Application   Application  `gorm:"foreignKey:ApplicationID" json:"-"`
-		CreatedAt     time.Time    `json:"created_at"`
+		// Processes are the process types of a backend, only web runs when there are none
+		Processes Processes `json:"processes"`
+		// Service is the name of a named service of the application, it is empty for the backend
+		Service     string          `json:"service"`
+		Settings    ServiceSettings `json:"settings"`
+		Application Application     `gorm:"foreignKey:ApplicationID" json:"-"`
+		CreatedAt   time.Time       `json:"created_at"`
 	}
 
 	NetworkAccess struct {
@@ -70,6 +79,14 @@ type (
 
 	ResourcesAllocations map[StorageEngine]ResourceAllocationConfig
 
+	// EngineVersions records the version of every storage engine of an application.
+	// new environments run the version chosen when the application was created, an environment
+	// keeps its own version once it has been upgraded
+	EngineVersions struct {
+		Default      map[StorageEngine]string            `json:"default"`
+		Environments map[string]map[StorageEngine]string `json:"environments"`
+	}
+
 	DeploymentStatus string
 	InstanceType     string
 )
@@ -83,6 +100,12 @@ type (
 		Backend       string   `json:"backend"`
 	}
 
+	UpgradeDatabaseParams struct {
+		Environment   string        `json:"environment"`
+		StorageEngine StorageEngine `json:"storage_engine"`
+		Version       string        `json:"version"`
+	}
+
 	DeployParams struct {
 		ApplicationID uuid.UUID
 		Frontend      io.Reader
@@ -90,15 +113,22 @@ type (
 		Instances     int
 		Environment   string
 		Identifier    string
+		Processes     Processes
+		Services      []ServiceParams
+		// Visibility is the visibility of the backend
+		Visibility Visibility
 	}
 
 	CreateDeploymentParams struct {
 		ApplicationID uuid.UUID `json:"application_id"`
 		Environment   string    `json:"environment"`
 		Instances     int
-		Port          string       `json:"-"`
-		InstanceType  InstanceType `json:"instance_type"` // frontend, backend, database, proxy
-		Identifier    string       `json:"identifier"`
+		Port          string          `json:"-"`
+		InstanceType  InstanceType    `json:"instance_type"` // frontend, backend, database, proxy
+		Identifier    string          `json:"identifier"`
+		Processes     Processes       `json:"processes"`
+		Service       string          `json:"service"`
+		Settings      ServiceSettings `json:"settings"`
 	}
 
 	ContainerIdentity struct {
@@ -117,14 +147,58 @@ const (
 	StorageEngineMysql    StorageEngine = "mysql"
 	StorageEngineMongo    StorageEngine = "mongo"
 	StorageEngineRedis    StorageEngine = "redis"
+	StorageEngineRabbitMQ StorageEngine = "rabbitmq"
+	StorageEngineMinio    StorageEngine = "minio"
 )
 
+// DefaultEngineVersions are used when no version is chosen for a storage engine
+var DefaultEngineVersions = map[StorageEngine]string{
+	StorageEnginePostgres: "17",
+	StorageEngineMysql:    "9.1.0",
+	StorageEngineMongo:    "8.0.3",
+	StorageEngineRedis:    "7.4",
+	StorageEngineRabbitMQ: "4.0",
+	StorageEngineMinio:    "2024.12.18",
+}
+
+var engineVersionRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// ParseStorageEngine reads a storage engine in the form engine[:version], e.g postgres:16.
+// the version is empty when none is given
+func ParseStorageEngine(value string) (StorageEngine, string, error) {
+	name, version, _ := strings.Cut(strings.TrimSpace(value), ":")
+	se := StorageEngine(strings.ToLower(name))
+	if _, ok := DefaultEngineVersions[se]; !ok {
+		return "", "", fmt.Errorf("unsupported storage engine: %s", name)
+	}
+
+	if version != "" && !engineVersionRegex.MatchString(version) {
+		return "", "", fmt.Errorf("invalid %s version: %s", se, version)
+	}
+	return se, version, nil
+}
+
+// MajorVersion is the leading number of a version, 0 when it doesn't start with one
+func MajorVersion(version string) int {
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
 const (
 	DeploymentStatusActive  DeploymentStatus = "ACTIVE"
 	DeploymentStatusCreated DeploymentStatus = "CREATED"
 	DeploymentStatusStopped DeploymentStatus = "STOPPED"
 )
 
+const (
+	DatabaseHealthHealthy   = "healthy"
+	DatabaseHealthUnhealthy = "unhealthy"
+)
+
 func (s StorageEngine) Value() (driver.Value, error) {
 	return string(s), nil
 }
@@ -155,6 +229,23 @@ func (s *StorageEngines) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, s)
 }
 
+func (s EngineVersions) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *EngineVersions) Scan(value interface{}) error {
+	if value == nil {
+		// applications created before versions were recorded
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan EngineVersions: type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
 func (s ResourcesAllocations) Value() (driver.Value, error) {
 	return json.Marshal(s)
 }
@@ -172,6 +263,10 @@ const (
 	InstanceTypeBackend  InstanceType = "backend"
 	InstanceTypeProxy    InstanceType = "proxy"
 	InstanceTypeDatabase InstanceType = "database"
+	InstanceTypeAddon    InstanceType = "addon"
+	// InstanceTypeKeptDatabase holds the variables of a storage engine removed with its data kept,
+	// they are not deployed with the backends
+	InstanceTypeKeptDatabase InstanceType = "kept-database"
 )
 
 func (a *Deployment) ImageName() string {
@@ -186,6 +281,21 @@ func (a *Deployment) DatabaseMountVolume() string {
 	return fmt.Sprintf("/var/sarabi/data/storage/%s-%s/data", strings.ReplaceAll(a.ApplicationID.String(), "-", ""), a.Environment)
 }
 
+// VolumeName is the docker volume holding the data of a storage engine in the deployment environment
+func (a *Deployment) VolumeName(se StorageEngine) string {
+	return fmt.Sprintf("%s-%s-%s", a.ApplicationID, a.Environment, se.String())
+}
+
+// ArchiveVolumeName is the volume a storage engine of the environment archives its log to
+func (a *Deployment) ArchiveVolumeName(se StorageEngine) string {
+	return a.VolumeName(se) + "-archive"
+}
+
+// DatabaseConfigPath is where the configuration files of the storage engines of the environment are kept on the host
+func (a *Deployment) DatabaseConfigPath(name string) string {
+	return fmt.Sprintf("%s/databases/%s/%s/%s", sarabiDataPath, a.ApplicationID, a.Environment, name)
+}
+
 func (a *Deployment) ContainerName(instanceId int) string {
 	return fmt.Sprintf("%s-%s-%d", strings.ReplaceAll(a.ID.String(), "-", ""), a.Environment, instanceId)
 }
@@ -210,6 +320,30 @@ func (a *Deployment) LogFilename() string {
 	return fmt.Sprintf("%s-%s-%s.log", a.Application.Name, a.InstanceType, a.Environment)
 }
 
+// EngineVersion returns the version of a storage engine running in an environment
+func (a *Application) EngineVersion(se StorageEngine, environment string) string {
+	if version := a.EngineVersions.Environments[environment][se]; version != "" {
+		return version
+	}
+
+	if version := a.EngineVersions.Default[se]; version != "" {
+		return version
+	}
+	return DefaultEngineVersions[se]
+}
+
+// SetEngineVersion records the version of a storage engine running in an environment
+func (a *Application) SetEngineVersion(se StorageEngine, environment, version string) {
+	if a.EngineVersions.Environments == nil {
+		a.EngineVersions.Environments = make(map[string]map[StorageEngine]string)
+	}
+
+	if a.EngineVersions.Environments[environment] == nil {
+		a.EngineVersions.Environments[environment] = make(map[StorageEngine]string)
+	}
+	a.EngineVersions.Environments[environment][se] = version
+}
+
 func (a *Application) ResourcesAllocation(se StorageEngine) (ResourceAllocation, error) {
 	defaultAlloc, err := hostResources()
 	if err != nil {
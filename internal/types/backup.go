@@ -5,6 +5,32 @@ import (
 	"time"
 )
 
+type (
+	BackupStatus  string
+	BackupTrigger string
+	BackupKind    string
+)
+
+const (
+	BackupStatusRunning   BackupStatus = "RUNNING"
+	BackupStatusSucceeded BackupStatus = "SUCCEEDED"
+	BackupStatusFailed    BackupStatus = "FAILED"
+
+	BackupTriggerSchedule BackupTrigger = "schedule"
+	BackupTriggerManual   BackupTrigger = "manual"
+	BackupTriggerDestroy  BackupTrigger = "destroy"
+	BackupTriggerUpgrade  BackupTrigger = "upgrade"
+	BackupTriggerImport   BackupTrigger = "import"
+	BackupTriggerClone    BackupTrigger = "clone"
+
+	// BackupKindDump is a logical dump produced by the engine dump tool(pg_dump, mysqldump...)
+	BackupKindDump BackupKind = "dump"
+	// BackupKindBase is a physical copy of a postgres data directory, the starting point of a point-in-time recovery
+	BackupKindBase BackupKind = "base"
+	// BackupKindVolume is a tar snapshot of a volume of the backend
+	BackupKindVolume BackupKind = "volume"
+)
+
 type (
 	BackupSettings struct {
 		ID             uuid.UUID `gorm:"primaryKey"`
@@ -15,6 +41,44 @@ type (
 		DeletedAt      time.Time
 	}
 
+	// BackupCopy tracks the copy of a backup file in one of the application backup destinations.
+	// BackupID is the ID of the Backup or WALSegment the copy belongs to
+	BackupCopy struct {
+		ID            uuid.UUID    `gorm:"primaryKey" json:"id"`
+		BackupID      uuid.UUID    `json:"backup_id"`
+		DestinationID uuid.UUID    `json:"destination_id"`
+		Destination   string       `json:"destination"`
+		StorageType   string       `json:"storage_type"`
+		Location      string       `json:"location"`
+		Status        BackupStatus `json:"status"`
+		Error         string       `json:"error"`
+		CreatedAt     time.Time    `json:"created_at"`
+	}
+
+	// PITRSettings holds the point-in-time recovery configuration of a postgres database in an environment
+	PITRSettings struct {
+		ID                       uuid.UUID `gorm:"primaryKey"`
+		ApplicationID            uuid.UUID
+		Environment              string
+		Enabled                  bool
+		BaseBackupCronExpression string
+		CreatedAt                time.Time
+	}
+
+	// WALSegment is a postgres write-ahead log segment shipped to the backup storage
+	WALSegment struct {
+		ID            uuid.UUID `gorm:"primaryKey"`
+		ApplicationID uuid.UUID
+		Environment   string
+		Name          string
+		Location      string
+		StorageType   string
+		Size          int64
+		ArchivedAt    time.Time
+	}
+
+	// Backup is a record of a single backup attempt for one storage engine or volume.
+	// Failed attempts are kept too, Location is empty and Error holds the reason.
 	Backup struct {
 		ID            uuid.UUID     `json:"id" gorm:"primaryKey"`
 		ApplicationID uuid.UUID     `json:"application_id"`
@@ -24,7 +88,37 @@ type (
 		Location      string        `json:"location"`
 		StorageType   string        `json:"storage_type"`
 		Size          int64         `json:"size"`
+		Status        BackupStatus  `json:"status"`
+		Trigger       BackupTrigger `json:"trigger"`
+		Error         string        `json:"error"`
+		Duration      time.Duration `json:"duration"`
+		Kind          BackupKind    `json:"kind"`
+		// Volume is the name of the volume a volume snapshot was taken of
+		Volume string        `json:"volume,omitempty"`
+		Copies []*BackupCopy `json:"copies" gorm:"-"`
 
 		Application *Application `gorm:"foreignKey:ApplicationID"`
 	}
 )
+
+func (b *Backup) Succeeded() bool {
+	// records created before run tracking existed have no status, they were only saved on success
+	return b.Status == BackupStatusSucceeded || b.Status == ""
+}
+
+// Source is what was backed up, the storage engine of a dump or the volume of a snapshot
+func (b *Backup) Source() string {
+	if b.Kind == BackupKindVolume {
+		return "volume " + b.Volume
+	}
+	return b.StorageEngine.String()
+}
+
+func (b *Backup) IsBase() bool {
+	return b.Kind == BackupKindBase
+}
+
+// FinishedAt is the time at which the backup run ended
+func (b *Backup) FinishedAt() time.Time {
+	return b.CreatedAt.Add(b.Duration)
+}
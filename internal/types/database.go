@@ -0,0 +1,275 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type (
+	// DatabaseConfig holds the settings a user set on a storage engine of an environment,
+	// they override the ones tuned from the resources allocated to the engine
+	DatabaseConfig struct {
+		ID            uuid.UUID      `gorm:"primaryKey" json:"id"`
+		ApplicationID uuid.UUID      `json:"application_id"`
+		Environment   string         `json:"environment"`
+		StorageEngine StorageEngine  `json:"storage_engine"`
+		Overrides     ConfigSettings `json:"overrides"`
+		// Replicas is how many read replicas stream from the engine
+		Replicas  int       `json:"replicas"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	ConfigSettings map[string]string
+
+	// DatabaseConfigFile is the configuration file of a storage engine
+	DatabaseConfigFile struct {
+		// Name of the file on the host
+		Name string
+		// Path the file is mounted at in the container
+		Path    string
+		Content []byte
+		// Cmd starts the engine with the file, it is empty when the image loads Path on its own
+		Cmd []string
+		// Envs are added to the variables of the container, for the engines told where Path is by one
+		Envs []string
+	}
+
+	UpdateDatabaseConfigParams struct {
+		Environment   string         `json:"environment"`
+		StorageEngine StorageEngine  `json:"storage_engine"`
+		Settings      ConfigSettings `json:"settings"`
+	}
+
+	// RotationPlan is how a storage engine moves to new credentials. Grant makes the new credentials work
+	// alongside the current ones, Revoke runs once nothing uses the current ones anymore
+	RotationPlan struct {
+		PreviousUser string
+		User         string
+		Vars         []CreateSecretParams
+		Grant        []string
+		Revoke       []string
+		// Restart is set when the container must be created again with the new variables,
+		// the engine or the tools run in its container read the credentials from them
+		Restart bool
+	}
+
+	// CredentialRotation is the audit record of a rotation of the credentials of a storage engine
+	CredentialRotation struct {
+		ID            uuid.UUID                `gorm:"primaryKey" json:"id"`
+		ApplicationID uuid.UUID                `json:"application_id"`
+		Environment   string                   `json:"environment"`
+		StorageEngine StorageEngine            `json:"storage_engine"`
+		PreviousUser  string                   `json:"previous_user"`
+		User          string                   `json:"user"`
+		Status        CredentialRotationStatus `json:"status"`
+		Error         string                   `json:"error"`
+		CreatedAt     time.Time                `json:"created_at"`
+		CompletedAt   *time.Time               `json:"completed_at"`
+	}
+
+	CredentialRotationStatus string
+
+	// ReplicaContainer is how the container of a read replica differs from the one of its primary
+	ReplicaContainer struct {
+		Cmd []string
+		// Envs are the variables the replica needs on top of the ones of the engine
+		Envs []string
+		// User runs the replica, it copies the data directory of the primary itself
+		User string
+	}
+
+	ScaleReplicasParams struct {
+		Environment   string        `json:"environment"`
+		StorageEngine StorageEngine `json:"storage_engine"`
+		Replicas      int           `json:"replicas"`
+	}
+
+	// ReplicaStatus is the state of a read replica as seen by the primary it streams from
+	ReplicaStatus struct {
+		Name      string `json:"name"`
+		Container string `json:"container"`
+		// Status is the status of the container of the replica
+		Status string `json:"status"`
+		// State is the state of its replication connection e.g streaming, empty when it is not connected
+		State      string  `json:"state"`
+		LagSeconds float64 `json:"lag_seconds"`
+		LagBytes   int64   `json:"lag_bytes"`
+	}
+
+	RotateCredentialsParams struct {
+		Environment   string
+		StorageEngine StorageEngine
+	}
+
+	DatabaseTunnelParams struct {
+		Environment   string
+		StorageEngine StorageEngine
+	}
+
+	DatabaseShellParams struct {
+		Environment   string
+		StorageEngine StorageEngine
+		// Command is run instead of an interactive session when it is set
+		Command string
+	}
+
+	AddStorageEngineParams struct {
+		// StorageEngine is the engine to add, optionally pinned to a version: engine[:version]
+		StorageEngine string
+	}
+
+	RemoveStorageEngineParams struct {
+		StorageEngine StorageEngine
+		// KeepData leaves the volumes of the engine in place so that adding it again brings the data back,
+		// with the credentials it was initialised with
+		KeepData bool
+	}
+
+	// ExternalDatabase is a storage engine of an environment run outside sarabi, e.g a managed database.
+	// its connection URL and credentials are kept encrypted in the variables of the environment
+	ExternalDatabase struct {
+		ID            uuid.UUID     `gorm:"primaryKey" json:"id"`
+		ApplicationID uuid.UUID     `json:"application_id"`
+		Environment   string        `json:"environment"`
+		StorageEngine StorageEngine `json:"storage_engine"`
+		// Host is where the database is reached, without the credentials
+		Host string `json:"host"`
+		// Image holds the client tools run against the database, e.g to back it up
+		Image     string    `json:"image"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	LinkDatabaseParams struct {
+		Environment string `json:"environment"`
+		// StorageEngine is the engine of the database, optionally with the version it runs: engine[:version].
+		// the client tools of that version are used against it, they must not be older than the server
+		StorageEngine string `json:"storage_engine"`
+		URL           string `json:"url"`
+	}
+
+	UnlinkDatabaseParams struct {
+		Environment   string
+		StorageEngine StorageEngine
+	}
+
+	// DatabaseImport is a dump uploaded in chunks to be loaded into a storage engine
+	DatabaseImport struct {
+		ID uuid.UUID `json:"id"`
+		// Size is how much of the dump has been uploaded so far
+		Size int64 `json:"size"`
+	}
+
+	ImportDatabaseParams struct {
+		ImportID      uuid.UUID
+		Environment   string
+		StorageEngine StorageEngine
+		// Wipe deletes the data of the engine before the dump is loaded, a backup is taken first
+		Wipe bool
+	}
+
+	// DumpFormat is the format of a dump made by the native tools of an engine
+	DumpFormat string
+
+	CloneDatabaseParams struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+		// StorageEngine is the engine to clone, all the engines of the application are cloned when it is empty
+		StorageEngine StorageEngine       `json:"storage_engine"`
+		Rules         []AnonymisationRule `json:"rules"`
+	}
+
+	// AnonymisationRule replaces the values of a column with ones that can not be traced back to them
+	AnonymisationRule struct {
+		// StorageEngine can be left out when the application has only one
+		StorageEngine StorageEngine `json:"storage_engine" yaml:"engine"`
+		// Table holds the column, the collection for mongo. it can be qualified with its schema for postgres
+		Table string `json:"table" yaml:"table"`
+		// Column is a dotted path to a field for mongo
+		Column   string                `json:"column" yaml:"column"`
+		Strategy AnonymisationStrategy `json:"strategy" yaml:"strategy"`
+		// Fake is the kind of value the fake strategy puts in the column, text when it is empty
+		Fake FakeKind `json:"fake,omitempty" yaml:"fake,omitempty"`
+	}
+
+	AnonymisationStrategy string
+
+	FakeKind string
+)
+
+const (
+	// AnonymisationHash replaces a value with a salted hash of it, equal values stay equal within a clone
+	AnonymisationHash AnonymisationStrategy = "hash"
+	AnonymisationNull AnonymisationStrategy = "null"
+	// AnonymisationFake replaces a value with a made up one of the same kind, derived from its hash
+	AnonymisationFake AnonymisationStrategy = "fake"
+
+	FakeEmail FakeKind = "email"
+	FakeName  FakeKind = "name"
+	FakePhone FakeKind = "phone"
+	FakeText  FakeKind = "text"
+)
+
+const (
+	DumpFormatSQL            DumpFormat = "sql"
+	DumpFormatPostgresCustom DumpFormat = "pg_custom"
+	DumpFormatMysqldump      DumpFormat = "mysqldump"
+	DumpFormatMongoArchive   DumpFormat = "mongo_archive"
+	// DumpFormatMongoDirectory is the output directory of mongodump in a tar archive
+	DumpFormatMongoDirectory DumpFormat = "mongo_directory"
+	DumpFormatRedisRDB       DumpFormat = "redis_rdb"
+)
+
+const (
+	CredentialRotationStarted   CredentialRotationStatus = "started"
+	CredentialRotationCompleted CredentialRotationStatus = "completed"
+	CredentialRotationFailed    CredentialRotationStatus = "failed"
+)
+
+func (r AnonymisationRule) Validate() error {
+	if r.Table == "" || r.Column == "" {
+		return errors.New("anonymisation rules need a table and a column")
+	}
+
+	switch r.Strategy {
+	case AnonymisationHash, AnonymisationNull:
+		if r.Fake != "" {
+			return fmt.Errorf("%s.%s: fake is only used by the fake strategy", r.Table, r.Column)
+		}
+	case AnonymisationFake:
+		switch r.Fake {
+		case "", FakeEmail, FakeName, FakePhone, FakeText:
+		default:
+			return fmt.Errorf("%s.%s: unknown fake value %q, expected one of email, name, phone or text", r.Table, r.Column, r.Fake)
+		}
+	default:
+		return fmt.Errorf("%s.%s: unknown strategy %q, expected one of hash, null or fake", r.Table, r.Column, r.Strategy)
+	}
+	return nil
+}
+
+// FakeKind returns the kind of value the fake strategy puts in the column
+func (r AnonymisationRule) FakeKind() FakeKind {
+	if r.Fake == "" {
+		return FakeText
+	}
+	return r.Fake
+}
+
+func (s ConfigSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *ConfigSettings) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan ConfigSettings: type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// ExternalDatabaseStatus is the status of a linked storage engine, sarabi runs no container for it
+const ExternalDatabaseStatus = "external"
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type (
+	// Job runs a command in a container of the image of the active backend of an environment on a cron schedule,
+	// the container has the variables of the backend and is removed once the command exited
+	Job struct {
+		ID            uuid.UUID  `gorm:"primaryKey" json:"id"`
+		ApplicationID uuid.UUID  `json:"application_id"`
+		Environment   string     `json:"environment"`
+		Name          string     `json:"name"`
+		Schedule      string     `json:"schedule"`
+		Command       JobCommand `json:"command"`
+		// Timeout is how many seconds a run may take before its container is removed
+		Timeout           int               `json:"timeout"`
+		ConcurrencyPolicy ConcurrencyPolicy `json:"concurrency_policy"`
+		NextRun           *time.Time        `json:"next_run,omitempty" gorm:"-"`
+		CreatedAt         time.Time         `json:"created_at"`
+	}
+
+	// JobRun is a run of a job, Output has what the command wrote to stdout followed by stderr
+	JobRun struct {
+		ID         uuid.UUID `gorm:"primaryKey" json:"id"`
+		JobID      uuid.UUID `gorm:"index" json:"job_id"`
+		Status     JobStatus `json:"status"`
+		ExitCode   int64     `json:"exit_code"`
+		Output     string    `json:"output"`
+		StartedAt  time.Time `json:"started_at"`
+		FinishedAt time.Time `json:"finished_at"`
+	}
+
+	// JobCommand is the command and arguments of a job, it is run as it is without a shell
+	JobCommand []string
+
+	// ConcurrencyPolicy is what happens when a job is due while its previous run has not exited
+	ConcurrencyPolicy string
+
+	JobStatus string
+
+	AddJobParams struct {
+		Environment       string            `json:"environment"`
+		Name              string            `json:"name"`
+		Schedule          string            `json:"schedule"`
+		Command           []string          `json:"command"`
+		Timeout           int               `json:"timeout"`
+		ConcurrencyPolicy ConcurrencyPolicy `json:"concurrency_policy"`
+	}
+)
+
+const (
+	// ConcurrencyAllow starts the new run next to the previous one
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencyForbid skips the new run
+	ConcurrencyForbid ConcurrencyPolicy = "forbid"
+	// ConcurrencyReplace stops the previous run and starts the new one
+	ConcurrencyReplace ConcurrencyPolicy = "replace"
+)
+
+const (
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusTimedOut  JobStatus = "timed out"
+	JobStatusSkipped   JobStatus = "skipped"
+	JobStatusReplaced  JobStatus = "replaced"
+)
+
+// Duration is how long the run took, until now while it is running
+func (r *JobRun) Duration() time.Duration {
+	if r.FinishedAt.IsZero() {
+		return time.Since(r.StartedAt)
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// JobContainerName is the container of a run of a job in the deployment environment
+func (a *Deployment) JobContainerName(name string, started time.Time) string {
+	return fmt.Sprintf("job-%s-%s-%s-%d", name, a.Application.Name, a.Environment, started.Unix())
+}
+
+func (c JobCommand) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+func (c *JobCommand) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan JobCommand: type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, c)
+}
@@ -0,0 +1,107 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type (
+	ProcessType string
+
+	// Process is a process type of a backend, every type runs its own command from the image of the deployment
+	// with the same variables
+	Process struct {
+		// Command is run by sh in the container, the command of the image is run when it is empty
+		Command string `json:"command"`
+		// Replicas is how many containers of a worker are running, the web replicas are the instances of the deployment
+		Replicas int `json:"replicas"`
+		// Schedule is the cron expression a cron process runs on
+		Schedule string `json:"schedule,omitempty"`
+	}
+
+	Processes map[ProcessType]Process
+)
+
+const (
+	// ProcessWeb serves the requests routed through the proxy
+	ProcessWeb ProcessType = "web"
+	// ProcessWorker runs in the background, nothing is routed to it
+	ProcessWorker ProcessType = "worker"
+	// ProcessCron runs to completion every time its schedule is due, as the job named cron of the environment
+	ProcessCron ProcessType = "cron"
+)
+
+// ProcessTypes are the process types a backend can have
+var ProcessTypes = []ProcessType{ProcessWeb, ProcessWorker, ProcessCron}
+
+// ParseProcessType reads a process type, web when it is empty
+func ParseProcessType(value string) (ProcessType, error) {
+	if value == "" {
+		return ProcessWeb, nil
+	}
+
+	for _, next := range ProcessTypes {
+		if string(next) == strings.ToLower(value) {
+			return next, nil
+		}
+	}
+	return "", fmt.Errorf("unknown process type: %s, it must be one of web, worker or cron", value)
+}
+
+// ProcessContainerName is the container of a replica of a worker or of a run of a cron process,
+// the web replicas keep the names from ContainerName
+func (a *Deployment) ProcessContainerName(process ProcessType, instanceId int) string {
+	return fmt.Sprintf("%s-%s-%s-%d", strings.ReplaceAll(a.ID.String(), "-", ""), a.Environment, process, instanceId)
+}
+
+// ProcessContainerNames are the containers of the web and worker replicas of the deployment
+func (a *Deployment) ProcessContainerNames() []string {
+	names := make([]string, 0, a.Instances)
+	for idx := 0; idx < a.Instances; idx++ {
+		names = append(names, a.ContainerName(idx))
+	}
+	for idx := 0; idx < a.ProcessReplicas(ProcessWorker); idx++ {
+		names = append(names, a.ProcessContainerName(ProcessWorker, idx))
+	}
+	return names
+}
+
+// ProcessReplicas is how many containers of a process type run with the deployment, a cron process has none
+func (a *Deployment) ProcessReplicas(process ProcessType) int {
+	switch process {
+	case ProcessWeb:
+		return a.Instances
+	case ProcessWorker:
+		return a.Processes[ProcessWorker].Replicas
+	}
+	return 0
+}
+
+// Cmd is the command of the containers of a process type, nil when the command of the image is used
+func (p Processes) Cmd(process ProcessType) []string {
+	command := p[process].Command
+	if command == "" {
+		return nil
+	}
+	return []string{"sh", "-c", command}
+}
+
+func (p Processes) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *Processes) Scan(value interface{}) error {
+	if value == nil {
+		// deployments created before process types only run web
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan Processes: type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, p)
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"time"
@@ -8,6 +9,9 @@ import (
 
 const (
 	ServerConfigObjectStorage = "object_storage"
+
+	AddressingPath        = "path"
+	AddressingVirtualHost = "virtual-host"
 )
 
 type (
@@ -41,9 +45,11 @@ type (
 	}
 
 	AddCredentialsParams struct {
-		ApplicationID uuid.UUID          `json:"application_id"`
-		Provider      string             `json:"provider"`
-		Value         StorageCredentials `json:"value"`
+		ApplicationID uuid.UUID `json:"application_id"`
+		Provider      string    `json:"provider"`
+		// Type is the storage driver the credentials are for, S3 when empty
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
 	}
 
 	StorageCredentials struct {
@@ -51,6 +57,18 @@ type (
 		SecretKey   string `json:"secret_key"`
 		Endpoint    string `json:"endpoint"`
 		Region      string `json:"region"`
+		// Secure turns on TLS
+		Secure bool `json:"secure"`
+		// CACert is a PEM bundle trusted on top of the system roots, for endpoints with a private CA
+		CACert string `json:"ca_cert"`
+		// Addressing is either path(the default) or virtual-host
+		Addressing string `json:"addressing"`
+		Bucket     string `json:"bucket"`
+		// Prefix is prepended to every object key
+		Prefix string `json:"prefix"`
+		// LogBucket is the bucket Loki keeps logs in. it is never the backup bucket, pruning old backups
+		// must not delete logs
+		LogBucket string `json:"log_bucket"`
 	}
 
 	AddCredentialsResponse struct {
@@ -82,6 +100,19 @@ func (s *Secret) Env() string {
 	return fmt.Sprintf("%s=%s", s.Name, s.Value)
 }
 
+// URI is the Loki s3 storage url, the logs go to the log bucket
 func (s *StorageCredentials) URI() string {
-	return fmt.Sprintf("s3://%s:%s@%s/sarabi-logs", s.AccessKeyID, s.SecretKey, s.Endpoint)
+	return fmt.Sprintf("s3://%s:%s@%s/%s", s.AccessKeyID, s.SecretKey, s.Endpoint, s.LogBucketName())
+}
+
+// LogBucketName returns the configured log bucket or sarabi-logs
+func (s *StorageCredentials) LogBucketName() string {
+	if s.LogBucket == "" {
+		return "sarabi-logs"
+	}
+	return s.LogBucket
+}
+
+func (s *StorageCredentials) VirtualHostStyle() bool {
+	return s.Addressing == AddressingVirtualHost
 }
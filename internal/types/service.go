@@ -0,0 +1,92 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type (
+	// ServiceSettings are the settings of a named service from .sarabi.yml, a named service is a backend
+	// built from its own directory next to the other backends of the environment
+	ServiceSettings struct {
+		// Dockerfile is the path of the Dockerfile in the directory of the service, Dockerfile when it is empty
+		Dockerfile string `json:"dockerfile,omitempty"`
+		// Domains are routed to the service next to its access URL
+		Domains []string `json:"domains,omitempty"`
+		// Variables are only set on the containers of the service, they take precedence over the variables of the environment
+		Variables map[string]string `json:"variables,omitempty"`
+		// Visibility is public when it is empty, it is also the visibility of the backend
+		Visibility Visibility `json:"visibility,omitempty"`
+	}
+
+	// Visibility is whether a backend is routed through the proxy or only reached by other applications on the server
+	Visibility string
+
+	ServiceParams struct {
+		Name string `json:"name"`
+		// Port is the port the service listens on, one is allocated when it is empty
+		Port     string `json:"port"`
+		Replicas int    `json:"replicas"`
+		ServiceSettings
+		Artifact io.Reader `json:"-"`
+	}
+)
+
+const (
+	VisibilityPublic Visibility = "public"
+	// VisibilityInternal backends have no route on the proxy, they are only reached at their internal hosts
+	VisibilityInternal Visibility = "internal"
+)
+
+// Internal is set when nothing is routed to the backend through the proxy
+func (s ServiceSettings) Internal() bool {
+	return s.Visibility == VisibilityInternal
+}
+
+// ServiceAccessURL is the address the backend of the deployment is routed at, a named service has its own
+func (a *Deployment) ServiceAccessURL() string {
+	if a.Service == "" {
+		return a.AccessURL(InstanceTypeBackend)
+	}
+	return fmt.Sprintf("%s-%s.%s", a.Service, a.Environment, a.Application.Domain)
+}
+
+// ServiceEnvs are the variables the containers of a named service get on top of the variables of the environment
+func (a *Deployment) ServiceEnvs() []string {
+	if a.Service == "" {
+		return nil
+	}
+
+	keys := make([]string, 0, len(a.Settings.Variables))
+	for key := range a.Settings.Variables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	envs := []string{"PORT=" + a.Port}
+	for _, key := range keys {
+		envs = append(envs, key+"="+a.Settings.Variables[key])
+	}
+	return envs
+}
+
+func (s ServiceSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *ServiceSettings) Scan(value interface{}) error {
+	if value == nil {
+		// deployments created before named services
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan ServiceSettings: type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, s)
+}
@@ -19,6 +19,23 @@ type (
 		Environment  string       `json:"environment"`
 	}
 
+	UpdatePITRParams struct {
+		Environment string `json:"environment"`
+		Enabled     bool   `json:"enabled"`
+		// BaseBackupCronExpression defaults to a daily base backup when empty
+		BaseBackupCronExpression string `json:"base_backup_cron_expression"`
+	}
+
+	DestroyParams struct {
+		Environment string `json:"environment"`
+		// FinalBackup takes one last backup of the environment databases before they are removed
+		FinalBackup bool `json:"final_backup"`
+		// DeleteBackups removes existing backup files and records of the destroyed environment(s)
+		DeleteBackups bool `json:"delete_backups"`
+		// KeepVolumes leaves the volumes of the backends in place, deploying the environment again mounts them
+		KeepVolumes bool `json:"keep_volumes"`
+	}
+
 	DeployResponse struct {
 		Identifier string    `json:"identifier"`
 		AccessURL  AccessURL `json:"access_url"`
@@ -27,5 +44,7 @@ type (
 	AccessURL struct {
 		Frontend []string `json:"frontend"`
 		Backend  []string `json:"backend"`
+		// Services are the addresses of the named services deployed, by name
+		Services map[string][]string `json:"services,omitempty"`
 	}
 )
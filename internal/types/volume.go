@@ -0,0 +1,41 @@
+package types
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type (
+	// Volume is a named volume mounted into every container of the backend of an environment,
+	// its data outlives the containers e.g user uploads or a sqlite file
+	Volume struct {
+		ID            uuid.UUID `gorm:"primaryKey" json:"id"`
+		ApplicationID uuid.UUID `json:"application_id"`
+		Environment   string    `json:"environment"`
+		Name          string    `json:"name"`
+		// Path is where the volume is mounted in the backend containers
+		Path string `json:"path"`
+		// Size is how much the volume holds in bytes, -1 when docker did not compute it
+		Size      int64     `json:"size" gorm:"-"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	AddVolumeParams struct {
+		Environment string `json:"environment"`
+		Name        string `json:"name"`
+		Path        string `json:"path"`
+	}
+
+	RemoveVolumeParams struct {
+		Environment string `json:"environment"`
+		Name        string `json:"name"`
+		// KeepData leaves the docker volume in place so that adding the volume again brings the data back
+		KeepData bool `json:"keep_data"`
+	}
+)
+
+// DockerVolume is the docker volume holding the data of the volume
+func (v *Volume) DockerVolume() string {
+	return fmt.Sprintf("%s-%s-volume-%s", v.ApplicationID, v.Environment, v.Name)
+}